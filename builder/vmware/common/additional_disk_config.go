@@ -0,0 +1,329 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type AdditionalDiskConfig
+
+package common
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/vmware/packer-plugin-vmware/builder/vmware/common/devices"
+	"github.com/vmware/packer-plugin-vmware/builder/vmware/common/vmdk"
+)
+
+// allowedDiskAdapterTypes is the list of allowed adapter types of a disk. The
+// SCSI controller types imply the `scsi` bus.
+var allowedDiskAdapterTypes = []string{
+	"ide",
+	"sata",
+	"nvme",
+	"scsi",
+	"lsilogic",
+	"lsisas1068",
+	"buslogic",
+	"pvscsi",
+}
+
+// allowedDiskModes is the list of allowed modes of a disk.
+var allowedDiskModes = []string{
+	"persistent",
+	"independent-persistent",
+	"independent-nonpersistent",
+}
+
+// The source modes of a disk with a source path.
+const (
+	DiskSourceModeCopy      = "copy"
+	DiskSourceModeReference = "reference"
+
+	// diskModeIndependentNonpersistent is the mode of a referenced disk.
+	diskModeIndependentNonpersistent = "independent-nonpersistent"
+)
+
+// allowedDiskSourceModes is the list of allowed source modes of a disk.
+var allowedDiskSourceModes = []string{
+	DiskSourceModeCopy,
+	DiskSourceModeReference,
+}
+
+// AdditionalDiskConfig defines an additional virtual disk of the virtual
+// machine. The disk is created in the output directory, or attached from an
+// existing virtual disk, and attached to the first free unit of the
+// controller.
+//
+// HCL Example:
+//
+// ```hcl
+//
+//	disk {
+//	  size = 20480
+//	}
+//
+//	disk {
+//	  size            = 102400
+//	  name            = "data"
+//	  adapter_type    = "nvme"
+//	  ssd             = true
+//	  mode            = "independent-persistent"
+//	  skip_compaction = true
+//	}
+//
+//	disk {
+//	  source_path = "/var/cache/packages.vmdk"
+//	  source_mode = "reference"
+//	  detach      = true
+//	}
+//
+// ```
+//
+// JSON Example:
+//
+// ```json
+//
+//	"disk": [
+//	  {
+//	    "size": 20480
+//	  },
+//	  {
+//	    "size": 102400,
+//	    "name": "data",
+//	    "adapter_type": "nvme",
+//	    "ssd": true,
+//	    "mode": "independent-persistent",
+//	    "skip_compaction": true
+//	  },
+//	  {
+//	    "source_path": "/var/cache/packages.vmdk",
+//	    "source_mode": "reference",
+//	    "detach": true
+//	  }
+//	]
+//
+// ```
+type AdditionalDiskConfig struct {
+	// The size of the disk in MB. Required, unless `source_path` is set.
+	Size uint `mapstructure:"size" required:"true"`
+	// The path to an existing virtual disk to attach instead of creating an
+	// empty disk. The disk is validated before the build and must not be
+	// used by another virtual machine during the build.
+	SourcePath string `mapstructure:"source_path" required:"false"`
+	// How the disk at `source_path` is attached. Allowed values are:
+	//
+	// - `copy` - The disk is copied to the output directory and attached like
+	//   a created disk. Delta disks cannot be copied.
+	// - `reference` - The disk is attached in place, in the
+	//   `independent-nonpersistent` mode, so the changes to the disk are
+	//   discarded when the virtual machine is powered off. The disk is not
+	//   compacted or exported, so `detach` must be `true`.
+	//
+	// Defaults to `copy`.
+	SourceMode string `mapstructure:"source_mode" required:"false"`
+	// Detach the disk from the virtual machine at the end of the build,
+	// before the virtual machine is exported. A copied disk is removed from
+	// the output directory. Required for a disk with the `reference` source
+	// mode, so the artifact does not refer to a path on the host. Defaults to
+	// `false`.
+	Detach bool `mapstructure:"detach" required:"false"`
+	// The filename of the disk _without_ the `.vmdk` extension. Defaults to
+	// `<vmdk_name>-<n>`, where `<n>` is the position of the disk in the list,
+	// starting at `1` and skipping `7`, as for `disk_additional_size`; for
+	// example, the seventh disk is named `disk-8`.
+	Name string `mapstructure:"name" required:"false"`
+	// The adapter type of the disk. Allowed values are `ide`, `sata`, `nvme`,
+	// `scsi`, `lsilogic`, `lsisas1068`, `buslogic`, and `pvscsi`. The SCSI
+	// controller types attach the disk to a `scsi` controller of that type.
+	// Defaults to the value of `disk_adapter_type` for the `vmware-iso`
+	// builder, and to the adapter type of the disks of the source virtual
+	// machine for the `vmware-vmx` builder.
+	AdapterType string `mapstructure:"adapter_type" required:"false"`
+	// The number of the controller of the adapter type to which the disk is
+	// attached. The controller is added if it is not present. Defaults to `0`.
+	Controller int `mapstructure:"controller" required:"false"`
+	// The type of virtual disk to create. Refer to `disk_type_id` for the
+	// available options. Defaults to the value of `disk_type_id`.
+	DiskTypeId string `mapstructure:"disk_type_id" required:"false"`
+	// Present the disk to the guest as a solid-state drive. Defaults to
+	// `false`.
+	SSD bool `mapstructure:"ssd" required:"false"`
+	// The mode of the disk. Allowed values are `persistent`,
+	// `independent-persistent`, and `independent-nonpersistent`. Independent
+	// disks are not included in snapshots. Defaults to `persistent`.
+	Mode string `mapstructure:"mode" required:"false"`
+	// Skip the compaction of the disk at the end of the build. Defaults to
+	// `false`.
+	SkipCompaction bool `mapstructure:"skip_compaction" required:"false"`
+}
+
+// AdditionalDiskNumber returns the number of the additional disk at the index,
+// which is used in the default name of the disk. The numbers start at 1 and
+// skip 7, matching the names of the disks created by earlier versions of the
+// plugin.
+func AdditionalDiskNumber(index int) int {
+	if index+1 >= 7 {
+		return index + 2
+	}
+	return index + 1
+}
+
+// Prepare validates and sets default values for the disk at the index. The
+// name defaults to the name of the primary disk and the position of the disk.
+func (c *AdditionalDiskConfig) Prepare(index int, diskName string) []error {
+	var errs []error
+
+	if c.SourcePath == "" {
+		if c.Size == 0 {
+			errs = append(errs, fmt.Errorf("'size' is required"))
+		}
+		if c.SourceMode != "" {
+			errs = append(errs, fmt.Errorf("'source_mode' requires 'source_path'"))
+		}
+	} else {
+		errs = append(errs, c.prepareSource()...)
+	}
+
+	c.Name = strings.TrimSuffix(c.Name, ".vmdk")
+	if c.Name == "" {
+		c.Name = fmt.Sprintf("%s-%d", diskName, AdditionalDiskNumber(index))
+	} else if strings.ContainsAny(c.Name, `/\`) {
+		errs = append(errs, fmt.Errorf("invalid 'name' specified: %s; must be a filename", c.Name))
+	}
+
+	c.AdapterType = strings.ToLower(c.AdapterType)
+	if c.AdapterType != "" && !slices.Contains(allowedDiskAdapterTypes, c.AdapterType) {
+		errs = append(errs, fmt.Errorf("invalid 'adapter_type' specified: %s; must be one of %s", c.AdapterType, strings.Join(allowedDiskAdapterTypes, ", ")))
+	}
+
+	if c.Controller < 0 {
+		errs = append(errs, fmt.Errorf("invalid 'controller' specified (controller < 0): %d", c.Controller))
+	} else if c.AdapterType != "" {
+		if bus := DiskBus(c.AdapterType); c.Controller >= bus.MaxControllers() {
+			errs = append(errs, fmt.Errorf("invalid 'controller' specified: %d; %s supports up to %d controllers", c.Controller, bus, bus.MaxControllers()))
+		}
+	}
+
+	c.Mode = strings.ToLower(c.Mode)
+	if c.Mode != "" && !slices.Contains(allowedDiskModes, c.Mode) {
+		errs = append(errs, fmt.Errorf("invalid 'mode' specified: %s; must be one of %s", c.Mode, strings.Join(allowedDiskModes, ", ")))
+	}
+
+	if c.Reference() {
+		if c.Mode == "" {
+			c.Mode = diskModeIndependentNonpersistent
+		} else if c.Mode != diskModeIndependentNonpersistent {
+			errs = append(errs, fmt.Errorf("invalid 'mode' specified: %s; a referenced disk must be %s", c.Mode, diskModeIndependentNonpersistent))
+		}
+		if !c.Detach {
+			errs = append(errs, fmt.Errorf("'source_mode' %s requires 'detach' to be true; a referenced disk is not exported", DiskSourceModeReference))
+		}
+	}
+
+	return errs
+}
+
+// prepareSource validates the source of a disk that is attached from an
+// existing virtual disk. The path is made absolute, so a referenced disk is
+// found from the output directory.
+func (c *AdditionalDiskConfig) prepareSource() []error {
+	var errs []error
+
+	if c.Size != 0 {
+		errs = append(errs, fmt.Errorf("'size' and 'source_path' cannot be used together"))
+	}
+	if c.DiskTypeId != "" {
+		errs = append(errs, fmt.Errorf("'disk_type_id' and 'source_path' cannot be used together"))
+	}
+
+	c.SourceMode = strings.ToLower(c.SourceMode)
+	if c.SourceMode == "" {
+		c.SourceMode = DiskSourceModeCopy
+	} else if !slices.Contains(allowedDiskSourceModes, c.SourceMode) {
+		errs = append(errs, fmt.Errorf("invalid 'source_mode' specified: %s; must be one of %s", c.SourceMode, strings.Join(allowedDiskSourceModes, ", ")))
+	}
+
+	path, err := filepath.Abs(c.SourcePath)
+	if err != nil {
+		return append(errs, fmt.Errorf("invalid 'source_path' specified: %s", err))
+	}
+	c.SourcePath = path
+
+	info, err := vmdk.Stat(c.SourcePath)
+	if err != nil {
+		return append(errs, fmt.Errorf("invalid 'source_path' specified: %s", err))
+	}
+	if c.SourceMode == DiskSourceModeCopy && info.Parent != nil {
+		errs = append(errs, fmt.Errorf("invalid 'source_path' specified: %s is a delta disk and cannot be copied; set 'source_mode' to %s", c.SourcePath, DiskSourceModeReference))
+	}
+
+	return errs
+}
+
+// Reference reports whether the disk is attached in place from the source
+// path, rather than created or copied in the output directory.
+func (c *AdditionalDiskConfig) Reference() bool {
+	return c.SourcePath != "" && c.SourceMode == DiskSourceModeReference
+}
+
+// FileName returns the filename of the disk.
+func (c *AdditionalDiskConfig) FileName() string {
+	return c.Name + ".vmdk"
+}
+
+// VMXFileName returns the filename of the disk in the .vmx file, which is the
+// source path of a referenced disk.
+func (c *AdditionalDiskConfig) VMXFileName() string {
+	if c.Reference() {
+		return c.SourcePath
+	}
+	return c.FileName()
+}
+
+// VMXData returns the .vmx data for the disk attached at the slot.
+func (c *AdditionalDiskConfig) VMXData(slot devices.Slot) map[string]string {
+	vmxData := make(map[string]string)
+	devices.Disk{Slot: slot, Present: true, FileName: c.VMXFileName(), Mode: c.Mode}.Encode(vmxData)
+	vmxData[slot.String()+".redo"] = ""
+	if c.SSD {
+		vmxData[slot.String()+".virtualssd"] = "1"
+	}
+	return vmxData
+}
+
+// DiskBus returns the type of the storage controller for the disk adapter
+// type. The SCSI controller types, such as `lsilogic`, return the `scsi` type.
+func DiskBus(adapterType string) devices.Bus {
+	if bus, err := devices.ParseBus(adapterType); err == nil {
+		return bus
+	}
+	return devices.BusSCSI
+}
+
+// EnableDiskController adds the storage controller of the slot to the .vmx
+// document, if the controller is not present. The virtual device of a SCSI
+// controller is set from the disk adapter type, defaulting to `lsilogic`. IDE
+// controllers are always present.
+func EnableDiskController(vmx *VMXDocument, slot devices.Slot, adapterType string) {
+	if slot.Bus == devices.BusIDE {
+		return
+	}
+	if c, ok := devices.Decode(vmx.Map()).Controller(slot.Bus, slot.Controller); ok && c.Present {
+		return
+	}
+
+	controller := devices.Controller{Bus: slot.Bus, Number: slot.Controller, Present: true}
+	if slot.Bus == devices.BusSCSI {
+		controller.VirtualDev = strings.ToLower(adapterType)
+		if controller.VirtualDev == "" || controller.VirtualDev == string(devices.BusSCSI) {
+			controller.VirtualDev = defaultDiskAdapterType
+		}
+	}
+
+	vmxData := make(map[string]string)
+	controller.Encode(vmxData)
+	vmx.SetAll(vmxData)
+}
@@ -0,0 +1,51 @@
+// Code generated by "packer-sdc mapstructure-to-hcl2"; DO NOT EDIT.
+
+package common
+
+import (
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// FlatAdditionalDiskConfig is an auto-generated flat version of AdditionalDiskConfig.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatAdditionalDiskConfig struct {
+	Size           *uint   `mapstructure:"size" required:"true" cty:"size" hcl:"size"`
+	SourcePath     *string `mapstructure:"source_path" required:"false" cty:"source_path" hcl:"source_path"`
+	SourceMode     *string `mapstructure:"source_mode" required:"false" cty:"source_mode" hcl:"source_mode"`
+	Detach         *bool   `mapstructure:"detach" required:"false" cty:"detach" hcl:"detach"`
+	Name           *string `mapstructure:"name" required:"false" cty:"name" hcl:"name"`
+	AdapterType    *string `mapstructure:"adapter_type" required:"false" cty:"adapter_type" hcl:"adapter_type"`
+	Controller     *int    `mapstructure:"controller" required:"false" cty:"controller" hcl:"controller"`
+	DiskTypeId     *string `mapstructure:"disk_type_id" required:"false" cty:"disk_type_id" hcl:"disk_type_id"`
+	SSD            *bool   `mapstructure:"ssd" required:"false" cty:"ssd" hcl:"ssd"`
+	Mode           *string `mapstructure:"mode" required:"false" cty:"mode" hcl:"mode"`
+	SkipCompaction *bool   `mapstructure:"skip_compaction" required:"false" cty:"skip_compaction" hcl:"skip_compaction"`
+}
+
+// FlatMapstructure returns a new FlatAdditionalDiskConfig.
+// FlatAdditionalDiskConfig is an auto-generated flat version of AdditionalDiskConfig.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*AdditionalDiskConfig) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatAdditionalDiskConfig)
+}
+
+// HCL2Spec returns the hcl spec of a AdditionalDiskConfig.
+// This spec is used by HCL to read the fields of AdditionalDiskConfig.
+// The decoded values from this spec will then be applied to a FlatAdditionalDiskConfig.
+func (*FlatAdditionalDiskConfig) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"size":            &hcldec.AttrSpec{Name: "size", Type: cty.Number, Required: false},
+		"source_path":     &hcldec.AttrSpec{Name: "source_path", Type: cty.String, Required: false},
+		"source_mode":     &hcldec.AttrSpec{Name: "source_mode", Type: cty.String, Required: false},
+		"detach":          &hcldec.AttrSpec{Name: "detach", Type: cty.Bool, Required: false},
+		"name":            &hcldec.AttrSpec{Name: "name", Type: cty.String, Required: false},
+		"adapter_type":    &hcldec.AttrSpec{Name: "adapter_type", Type: cty.String, Required: false},
+		"controller":      &hcldec.AttrSpec{Name: "controller", Type: cty.Number, Required: false},
+		"disk_type_id":    &hcldec.AttrSpec{Name: "disk_type_id", Type: cty.String, Required: false},
+		"ssd":             &hcldec.AttrSpec{Name: "ssd", Type: cty.Bool, Required: false},
+		"mode":            &hcldec.AttrSpec{Name: "mode", Type: cty.String, Required: false},
+		"skip_compaction": &hcldec.AttrSpec{Name: "skip_compaction", Type: cty.Bool, Required: false},
+	}
+	return s
+}
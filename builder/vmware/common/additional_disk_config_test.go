@@ -0,0 +1,140 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vmware/packer-plugin-vmware/builder/vmware/common/devices"
+	"github.com/vmware/packer-plugin-vmware/builder/vmware/common/vmdk"
+)
+
+func TestAdditionalDiskConfigPrepare(t *testing.T) {
+	c := &AdditionalDiskConfig{Size: 1024}
+	if errs := c.Prepare(1, "disk"); len(errs) > 0 {
+		t.Fatalf("bad: %v", errs)
+	}
+	assert.Equal(t, "disk-2", c.Name)
+	assert.Equal(t, "disk-2.vmdk", c.FileName())
+
+	c = &AdditionalDiskConfig{Size: 1024, Name: "data.vmdk", AdapterType: "PVSCSI", Controller: 3, Mode: "Independent-Persistent"}
+	if errs := c.Prepare(0, "disk"); len(errs) > 0 {
+		t.Fatalf("bad: %v", errs)
+	}
+	assert.Equal(t, "data", c.Name)
+	assert.Equal(t, "pvscsi", c.AdapterType)
+	assert.Equal(t, "independent-persistent", c.Mode)
+
+	c = &AdditionalDiskConfig{Name: "../data", AdapterType: "floppy", Controller: -1, Mode: "undoable"}
+	if errs := c.Prepare(0, "disk"); len(errs) != 5 {
+		t.Fatalf("expected 5 errors, got: %v", errs)
+	}
+
+	c = &AdditionalDiskConfig{Size: 1024, AdapterType: "ide", Controller: 2}
+	if errs := c.Prepare(0, "disk"); len(errs) != 1 {
+		t.Fatalf("expected an error for the controller, got: %v", errs)
+	}
+}
+
+func TestAdditionalDiskConfigPrepare_source(t *testing.T) {
+	source := filepath.Join(t.TempDir(), "source.vmdk")
+	if err := vmdk.Create(source, 1024*1024, vmdk.DiskTypeMonolithicSparse, "lsilogic"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	c := &AdditionalDiskConfig{SourcePath: source}
+	if errs := c.Prepare(0, "disk"); len(errs) > 0 {
+		t.Fatalf("bad: %v", errs)
+	}
+	assert.Equal(t, DiskSourceModeCopy, c.SourceMode)
+	assert.False(t, c.Reference())
+	assert.Equal(t, "disk-1.vmdk", c.VMXFileName())
+
+	c = &AdditionalDiskConfig{SourcePath: source, SourceMode: "Reference", Detach: true}
+	if errs := c.Prepare(0, "disk"); len(errs) > 0 {
+		t.Fatalf("bad: %v", errs)
+	}
+	assert.True(t, c.Reference())
+	assert.Equal(t, "independent-nonpersistent", c.Mode)
+	assert.Equal(t, source, c.VMXFileName())
+
+	c = &AdditionalDiskConfig{SourcePath: source, SourceMode: "reference", Mode: "persistent", Detach: true}
+	if errs := c.Prepare(0, "disk"); len(errs) != 1 {
+		t.Fatalf("expected an error for the mode, got: %v", errs)
+	}
+
+	c = &AdditionalDiskConfig{SourcePath: source, SourceMode: "reference"}
+	if errs := c.Prepare(0, "disk"); len(errs) != 1 {
+		t.Fatalf("expected an error for detach, got: %v", errs)
+	}
+
+	c = &AdditionalDiskConfig{SourcePath: source, Size: 1024, DiskTypeId: "0", SourceMode: "link"}
+	if errs := c.Prepare(0, "disk"); len(errs) != 3 {
+		t.Fatalf("expected 3 errors, got: %v", errs)
+	}
+
+	c = &AdditionalDiskConfig{Size: 1024, SourceMode: "copy"}
+	if errs := c.Prepare(0, "disk"); len(errs) != 1 {
+		t.Fatalf("expected an error for the source mode, got: %v", errs)
+	}
+
+	c = &AdditionalDiskConfig{SourcePath: filepath.Join(t.TempDir(), "missing.vmdk")}
+	if errs := c.Prepare(0, "disk"); len(errs) != 1 {
+		t.Fatalf("expected an error for the source path, got: %v", errs)
+	}
+}
+
+func TestAdditionalDiskConfigVMXData(t *testing.T) {
+	c := &AdditionalDiskConfig{Size: 1024, Name: "data", SSD: true, Mode: "independent-persistent"}
+	slot := devices.Slot{Bus: devices.BusNVMe, Controller: 1, Unit: 2}
+
+	assert.Equal(t, map[string]string{
+		"nvme1:2.present":    "TRUE",
+		"nvme1:2.filename":   "data.vmdk",
+		"nvme1:2.mode":       "independent-persistent",
+		"nvme1:2.redo":       "",
+		"nvme1:2.virtualssd": "1",
+	}, c.VMXData(slot))
+}
+
+func TestDiskBus(t *testing.T) {
+	tests := map[string]devices.Bus{
+		"ide":      devices.BusIDE,
+		"SATA":     devices.BusSATA,
+		"nvme":     devices.BusNVMe,
+		"scsi":     devices.BusSCSI,
+		"lsilogic": devices.BusSCSI,
+		"pvscsi":   devices.BusSCSI,
+	}
+
+	for adapterType, expected := range tests {
+		assert.Equal(t, expected, DiskBus(adapterType), adapterType)
+	}
+}
+
+func TestEnableDiskController(t *testing.T) {
+	vmx := newTestVMXDocument(map[string]string{
+		"scsi0.present":    "FALSE",
+		"scsi0.virtualdev": "lsilogic",
+		"sata0.present":    "TRUE",
+	})
+
+	EnableDiskController(vmx, devices.Slot{Bus: devices.BusSCSI, Unit: 1}, "pvscsi")
+	EnableDiskController(vmx, devices.Slot{Bus: devices.BusSCSI, Controller: 1}, "scsi")
+	EnableDiskController(vmx, devices.Slot{Bus: devices.BusSATA, Unit: 1}, "sata")
+	EnableDiskController(vmx, devices.Slot{Bus: devices.BusNVMe}, "nvme")
+	EnableDiskController(vmx, devices.Slot{Bus: devices.BusIDE, Controller: 1}, "ide")
+
+	assert.Equal(t, map[string]string{
+		"scsi0.present":    "TRUE",
+		"scsi0.virtualdev": "pvscsi",
+		"scsi1.present":    "TRUE",
+		"scsi1.virtualdev": "lsilogic",
+		"sata0.present":    "TRUE",
+		"nvme0.present":    "TRUE",
+	}, vmx.Map())
+}
@@ -6,7 +6,9 @@ package common
 
 import (
 	"fmt"
+	"slices"
 	"strconv"
+	"strings"
 
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
@@ -63,7 +65,13 @@ func (a *artifact) Destroy() error {
 }
 
 // NewArtifact creates a new artifact from the build results and configuration.
-func NewArtifact(format string, vmName string, skipExport bool, state multistep.StateBag) (packersdk.Artifact, error) {
+// The files of each export format are available from the artifact state using
+// the format name prefixed with "format.", such as "format.ova". The names of
+// the snapshots from the root of the snapshot tree to the snapshot the virtual
+// machine was cloned from are available as "snapshot_chain". The checksums of
+// the files, keyed by the path of the file and the checksum type, are
+// available as "checksums".
+func NewArtifact(formats []string, vmName string, skipExport bool, state multistep.StateBag) (packersdk.Artifact, error) {
 	dir := state.Get("dir").(OutputDir)
 
 	files, err := dir.ListFiles()
@@ -71,10 +79,49 @@ func NewArtifact(format string, vmName string, skipExport bool, state multistep.
 		return nil, err
 	}
 
+	exportedFiles, _ := state.Get("exported_files").(map[string][]string)
+
+	stateData := map[string]interface{}{"generated_data": state.Get("generated_data")}
+	if chain, ok := state.GetOk("snapshot_chain"); ok {
+		stateData["snapshot_chain"] = chain
+	}
+	if checksums, ok := state.GetOk("checksums"); ok {
+		stateData["checksums"] = checksums
+	}
+
+	// The files that are not exported to a format are the virtual machine
+	// files.
+	var vmFiles []string
+	for _, file := range files {
+		exported := false
+		for _, formatFiles := range exportedFiles {
+			if slices.Contains(formatFiles, file) {
+				exported = true
+				break
+			}
+		}
+		if !exported {
+			vmFiles = append(vmFiles, file)
+		}
+	}
+
+	for _, format := range formats {
+		if format == ExportFormatVmx {
+			stateData[artifactStateFormatPrefix+format] = vmFiles
+		} else if !skipExport {
+			stateData[artifactStateFormatPrefix+format] = exportedFiles[format]
+		}
+	}
+
+	// The Vagrant box is the only file of the artifact for the vagrant format.
+	if slices.Equal(formats, []string{exportFormatVagrant}) && !skipExport {
+		files = exportedFiles[exportFormatVagrant]
+	}
+
 	builderId := builderId
 
 	config := make(map[string]string)
-	config[artifactConfFormat] = format
+	config[artifactConfFormat] = strings.Join(formats, ",")
 	config[artifactConfSkipExport] = strconv.FormatBool(skipExport)
 
 	return &artifact{
@@ -83,6 +130,6 @@ func NewArtifact(format string, vmName string, skipExport bool, state multistep.
 		dir:       dir,
 		f:         files,
 		config:    config,
-		StateData: map[string]interface{}{"generated_data": state.Get("generated_data")},
+		StateData: stateData,
 	}, nil
 }
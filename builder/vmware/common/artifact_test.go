@@ -5,11 +5,103 @@
 package common
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestLocalArtifact_impl(t *testing.T) {
 	var _ packersdk.Artifact = new(artifact)
 }
+
+func TestNewArtifact_vagrant(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"vm.vmx", "vm.vmdk", "vm.box"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0644); err != nil { //nolint:gosec
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	state := new(multistep.BasicStateBag)
+	state.Put("dir", &LocalOutputDir{dir: dir})
+	state.Put("exported_files", map[string][]string{
+		"vagrant": {filepath.Join(dir, "vm.box")},
+	})
+
+	a, err := NewArtifact([]string{"vagrant"}, "vm", false, state)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if files := a.Files(); len(files) != 1 || files[0] != filepath.Join(dir, "vm.box") {
+		t.Fatalf("expected only the box, got: %v", files)
+	}
+
+	a, err = NewArtifact([]string{"vmx"}, "vm", true, state)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if files := a.Files(); len(files) != 3 {
+		t.Fatalf("expected all files, got: %v", files)
+	}
+}
+
+func TestNewArtifact_formats(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "ova"), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	for _, name := range []string{"vm.vmx", "vm.vmdk", filepath.Join("ova", "vm.ova")} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0644); err != nil { //nolint:gosec
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	state := new(multistep.BasicStateBag)
+	state.Put("dir", &LocalOutputDir{dir: dir})
+	state.Put("exported_files", map[string][]string{
+		"ova": {filepath.Join(dir, "ova", "vm.ova")},
+	})
+
+	a, err := NewArtifact([]string{"vmx", "ova"}, "vm", false, state)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	assert.Len(t, a.Files(), 3)
+	assert.Equal(t, []string{filepath.Join(dir, "ova", "vm.ova")}, a.State("format.ova"))
+	assert.Equal(t, []string{filepath.Join(dir, "vm.vmdk"), filepath.Join(dir, "vm.vmx")}, a.State("format.vmx"))
+	assert.Equal(t, "vmx,ova", a.State(artifactConfFormat))
+}
+
+func TestNewArtifact_snapshotChain(t *testing.T) {
+	state := new(multistep.BasicStateBag)
+	state.Put("dir", &LocalOutputDir{dir: t.TempDir()})
+	state.Put("snapshot_chain", []string{"base", "patched"})
+
+	a, err := NewArtifact([]string{"vmx"}, "vm", true, state)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	assert.Equal(t, []string{"base", "patched"}, a.State("snapshot_chain"))
+}
+
+func TestNewArtifact_checksums(t *testing.T) {
+	checksums := map[string]map[string]string{
+		"vm.vmx": {"sha256": "abc"},
+	}
+	state := new(multistep.BasicStateBag)
+	state.Put("dir", &LocalOutputDir{dir: t.TempDir()})
+	state.Put("checksums", checksums)
+
+	a, err := NewArtifact([]string{"vmx"}, "vm", true, state)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	assert.Equal(t, checksums, a.State("checksums"))
+}
@@ -6,81 +6,48 @@ package common
 
 import (
 	"fmt"
-	"regexp"
-	"strconv"
 	"strings"
+
+	"github.com/vmware/packer-plugin-vmware/builder/vmware/common/devices"
 )
 
 // FindNextAvailableCDROMSlot locates the next available CD-ROM device slot for
 // the specified adapter type.
-func FindNextAvailableCDROMSlot(vmxData map[string]string, adapterType string) (string, error) {
+func FindNextAvailableCDROMSlot(vmx *VMXDocument, adapterType string) (string, error) {
 	if adapterType == "" {
 		return "", fmt.Errorf("adapter type cannot be empty")
 	}
 
-	adapterType = strings.ToLower(adapterType)
-
-	validAdapters := []string{"ide", "sata", "scsi"}
-	isValid := false
-	for _, valid := range validAdapters {
-		if adapterType == valid {
-			isValid = true
-			break
-		}
-	}
-	if !isValid {
-		return "", fmt.Errorf("invalid adapter type: %s; must be one of %v", adapterType, validAdapters)
+	bus, err := cdromBus(adapterType)
+	if err != nil {
+		return "", err
 	}
 
-	devicePattern := regexp.MustCompile(fmt.Sprintf(`^%s(\d+):(\d+)\.present$`, adapterType))
-	usedSlots := make(map[string]map[int]bool) // bus -> slot -> used
-
-	for key := range vmxData {
-		if matches := devicePattern.FindStringSubmatch(key); matches != nil {
-			bus := matches[1]
-			slot, err := strconv.Atoi(matches[2])
-			if err != nil {
-				continue
-			}
-
-			if usedSlots[bus] == nil {
-				usedSlots[bus] = make(map[int]bool)
-			}
-			usedSlots[bus][slot] = true
-		}
+	slot, err := devices.NewAllocator(devices.Decode(vmx.Map())).Allocate(bus)
+	if err != nil {
+		return "", fmt.Errorf("no available CD-ROM slots found for adapter type %s", bus)
 	}
 
-	// Find the next available slot.
-	for busNum := 0; busNum < 4; busNum++ { // VMware supports up to 4 buses for most adapter types
-		busStr := strconv.Itoa(busNum)
-		busSlots := usedSlots[busStr]
-
-		// Check slots 0-15 (or 0-6,8-15 for SCSI to skip reserved slot 7).
-		maxSlots := 16
-		if adapterType == "ide" {
-			maxSlots = 2 // IDE typically supports only 2 devices per bus.
-		}
-
-		for slot := 0; slot < maxSlots; slot++ {
-			// Skip reserved slot 7 for SCSI adapters.
-			if adapterType == "scsi" && slot == 7 {
-				continue
-			}
+	return slot.String(), nil
+}
 
-			if busSlots == nil || !busSlots[slot] {
-				return fmt.Sprintf("%s%d:%d", adapterType, busNum, slot), nil
-			}
+// cdromBus returns the controller type of a CD-ROM adapter type.
+func cdromBus(adapterType string) (devices.Bus, error) {
+	validAdapters := []devices.Bus{devices.BusIDE, devices.BusSATA, devices.BusSCSI}
+	bus := devices.Bus(strings.ToLower(adapterType))
+	for _, valid := range validAdapters {
+		if bus == valid {
+			return bus, nil
 		}
 	}
-
-	return "", fmt.Errorf("no available CD-ROM slots found for adapter type %s", adapterType)
+	return "", fmt.Errorf("invalid adapter type: %s; must be one of %v", adapterType, validAdapters)
 }
 
-// AttachCDROMDevice adds CD-ROM device entries to VMX data for the specified
-// device path and ISO file.
-func AttachCDROMDevice(vmxData map[string]string, devicePath, isoPath, adapterType string) error {
-	if vmxData == nil {
-		return fmt.Errorf("vmxData cannot be nil")
+// AttachCDROMDevice adds CD-ROM device entries to the .vmx document for the
+// specified device path and ISO file.
+func AttachCDROMDevice(vmx *VMXDocument, devicePath, isoPath, adapterType string) error {
+	if vmx == nil {
+		return fmt.Errorf("vmx cannot be nil")
 	}
 	if devicePath == "" {
 		return fmt.Errorf("devicePath cannot be empty")
@@ -92,62 +59,53 @@ func AttachCDROMDevice(vmxData map[string]string, devicePath, isoPath, adapterTy
 		return fmt.Errorf("adapterType cannot be empty")
 	}
 
-	adapterType = strings.ToLower(adapterType)
-	devicePattern := regexp.MustCompile(`^(ide|sata|scsi)(\d+):(\d+)$`)
-	matches := devicePattern.FindStringSubmatch(devicePath)
-	if matches == nil {
-		return fmt.Errorf("invalid device path format: %s; expected format like 'ide0:1'", devicePath)
+	bus, err := cdromBus(adapterType)
+	if err != nil {
+		return err
 	}
 
-	pathAdapterType := matches[1]
-	busNum := matches[2]
+	slot, err := devices.ParseSlot(devicePath)
+	if err != nil || slot.Bus == devices.BusNVMe {
+		return fmt.Errorf("invalid device path format: %s; expected format like 'ide0:1'", devicePath)
+	}
 
-	if pathAdapterType != adapterType {
-		return fmt.Errorf("device path adapter type %s does not match specified adapter type %s", pathAdapterType, adapterType)
+	if slot.Bus != bus {
+		return fmt.Errorf("device path adapter type %s does not match specified adapter type %s", slot.Bus, bus)
 	}
 
 	presentKey := fmt.Sprintf("%s.present", devicePath)
-	if existing, exists := vmxData[presentKey]; exists && strings.ToLower(existing) == "true" {
+	if existing, exists := vmx.Get(presentKey); exists && strings.ToLower(existing) == "true" {
 		return fmt.Errorf("device %s is already in use", devicePath)
 	}
 
-	adapterKey := fmt.Sprintf("%s%s.present", adapterType, busNum)
-	vmxData[adapterKey] = "TRUE"
-
-	vmxData[fmt.Sprintf("%s.present", devicePath)] = "TRUE"
-	vmxData[fmt.Sprintf("%s.filename", devicePath)] = isoPath
-	vmxData[fmt.Sprintf("%s.devicetype", devicePath)] = "cdrom-image"
+	vmxData := make(map[string]string)
+	devices.Controller{Bus: slot.Bus, Number: slot.Controller, Present: true}.Encode(vmxData)
+	devices.CDROM{
+		Slot:       slot,
+		Present:    true,
+		FileName:   isoPath,
+		DeviceType: "cdrom-image",
+	}.Encode(vmxData)
+	vmx.SetAll(vmxData)
 
 	return nil
 }
 
-// DetachCDROMDevice removes CD-ROM device entries from VMX data for the
-// specified device path.
-func DetachCDROMDevice(vmxData map[string]string, devicePath string) error {
-	if vmxData == nil {
-		return fmt.Errorf("vmxData cannot be nil")
+// DetachCDROMDevice removes CD-ROM device entries from the .vmx document for
+// the specified device path.
+func DetachCDROMDevice(vmx *VMXDocument, devicePath string) error {
+	if vmx == nil {
+		return fmt.Errorf("vmx cannot be nil")
 	}
 	if devicePath == "" {
 		return fmt.Errorf("devicePath cannot be empty")
 	}
 
-	devicePattern := regexp.MustCompile(`^(ide|sata|scsi)(\d+):(\d+)$`)
-	if !devicePattern.MatchString(devicePath) {
+	if slot, err := devices.ParseSlot(devicePath); err != nil || slot.Bus == devices.BusNVMe {
 		return fmt.Errorf("invalid device path format: %s; expected format like 'ide0:1'", devicePath)
 	}
 
-	devicePrefix := devicePath + "."
-	keysToDelete := make([]string, 0)
-
-	for key := range vmxData {
-		if strings.HasPrefix(key, devicePrefix) {
-			keysToDelete = append(keysToDelete, key)
-		}
-	}
-
-	for _, key := range keysToDelete {
-		delete(vmxData, key)
-	}
+	vmx.DeleteDevice(devicePath)
 
 	return nil
 }
@@ -107,7 +107,7 @@ func TestFindNextAvailableCDROMSlot(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result, err := FindNextAvailableCDROMSlot(tt.vmxData, tt.adapterType)
+			result, err := FindNextAvailableCDROMSlot(newTestVMXDocument(tt.vmxData), tt.adapterType)
 
 			if tt.expectError {
 				if err == nil {
@@ -256,7 +256,8 @@ func TestAttachCDROMDevice(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := AttachCDROMDevice(tt.vmxData, tt.devicePath, tt.isoPath, tt.adapterType)
+			vmx := newTestVMXDocument(tt.vmxData)
+			err := AttachCDROMDevice(vmx, tt.devicePath, tt.isoPath, tt.adapterType)
 
 			if tt.expectError {
 				if err == nil {
@@ -271,8 +272,9 @@ func TestAttachCDROMDevice(t *testing.T) {
 			}
 
 			// Check that expected keys are present with correct values
+			vmxData := vmx.Map()
 			for key, expectedValue := range tt.expectedKeys {
-				if actualValue, exists := tt.vmxData[key]; !exists {
+				if actualValue, exists := vmxData[key]; !exists {
 					t.Errorf("expected key %s not found in vmxData", key)
 				} else if actualValue != expectedValue {
 					t.Errorf("key %s: expected %s, got %s", key, expectedValue, actualValue)
@@ -348,7 +350,8 @@ func TestDetachCDROMDevice(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := DetachCDROMDevice(tt.vmxData, tt.devicePath)
+			vmx := newTestVMXDocument(tt.vmxData)
+			err := DetachCDROMDevice(vmx, tt.devicePath)
 
 			if tt.expectError {
 				if err == nil {
@@ -363,12 +366,13 @@ func TestDetachCDROMDevice(t *testing.T) {
 			}
 
 			// Check that only expected keys remain
-			if len(tt.vmxData) != len(tt.expectedRemain) {
-				t.Errorf("expected %d keys to remain, got %d", len(tt.expectedRemain), len(tt.vmxData))
+			vmxData := vmx.Map()
+			if len(vmxData) != len(tt.expectedRemain) {
+				t.Errorf("expected %d keys to remain, got %d", len(tt.expectedRemain), len(vmxData))
 			}
 
 			for key, expectedValue := range tt.expectedRemain {
-				if actualValue, exists := tt.vmxData[key]; !exists {
+				if actualValue, exists := vmxData[key]; !exists {
 					t.Errorf("expected key %s not found in vmxData", key)
 				} else if actualValue != expectedValue {
 					t.Errorf("key %s: expected %s, got %s", key, expectedValue, actualValue)
@@ -377,7 +381,7 @@ func TestDetachCDROMDevice(t *testing.T) {
 
 			// Check that device-specific keys were removed
 			devicePrefix := tt.devicePath + "."
-			for key := range tt.vmxData {
+			for key := range vmxData {
 				if len(key) > len(devicePrefix) && key[:len(devicePrefix)] == devicePrefix {
 					t.Errorf("device key %s should have been removed", key)
 				}
@@ -388,11 +392,11 @@ func TestDetachCDROMDevice(t *testing.T) {
 
 // TestCDROMUtilsIntegration tests the utilities working together
 func TestCDROMUtilsIntegration(t *testing.T) {
-	vmxData := map[string]string{
+	vmx := newTestVMXDocument(map[string]string{
 		"ide0:0.present": "TRUE", // existing installation ISO
-	}
+	})
 
-	devicePath, err := FindNextAvailableCDROMSlot(vmxData, "ide")
+	devicePath, err := FindNextAvailableCDROMSlot(vmx, "ide")
 	if err != nil {
 		t.Fatalf("FindNextAvailableCDROMSlot failed: %v", err)
 	}
@@ -403,7 +407,7 @@ func TestCDROMUtilsIntegration(t *testing.T) {
 	}
 
 	isoPath := "/path/to/vmware-tools.iso"
-	err = AttachCDROMDevice(vmxData, devicePath, isoPath, "ide")
+	err = AttachCDROMDevice(vmx, devicePath, isoPath, "ide")
 	if err != nil {
 		t.Fatalf("AttachCDROMDevice failed: %v", err)
 	}
@@ -415,6 +419,7 @@ func TestCDROMUtilsIntegration(t *testing.T) {
 		"ide0:1.devicetype": "cdrom-image",
 	}
 
+	vmxData := vmx.Map()
 	for key, expectedValue := range expectedKeys {
 		if actualValue, exists := vmxData[key]; !exists {
 			t.Errorf("expected key %s not found after attachment", key)
@@ -423,7 +428,7 @@ func TestCDROMUtilsIntegration(t *testing.T) {
 		}
 	}
 
-	err = DetachCDROMDevice(vmxData, devicePath)
+	err = DetachCDROMDevice(vmx, devicePath)
 	if err != nil {
 		t.Fatalf("DetachCDROMDevice failed: %v", err)
 	}
@@ -433,6 +438,7 @@ func TestCDROMUtilsIntegration(t *testing.T) {
 		"ide0:0.present": "TRUE",
 	}
 
+	vmxData = vmx.Map()
 	if len(vmxData) != len(expectedRemaining) {
 		t.Errorf("expected %d keys after detachment, got %d", len(expectedRemaining), len(vmxData))
 	}
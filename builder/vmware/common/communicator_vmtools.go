@@ -0,0 +1,300 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// GuestCredentials are the credentials of the guest operating system account
+// used for guest operations.
+type GuestCredentials struct {
+	Username string
+	Password string
+}
+
+// VMToolsCommunicator is a communicator that runs commands and transfers files
+// using the VMware Tools guest operations of vmrun. The guest operating system
+// does not require network connectivity.
+//
+// Commands are run by copying a script to the guest and running it with the
+// shell of the guest operating system. The output of the command is redirected
+// to files in the guest, which are copied to the host after the command exits.
+type VMToolsCommunicator struct {
+	driver      Driver
+	vmxPath     string
+	credentials GuestCredentials
+	windows     bool
+}
+
+var _ packersdk.Communicator = new(VMToolsCommunicator)
+
+// NewVMToolsCommunicator returns a communicator for the guest operating system
+// of the virtual machine. If windows is true, the commands are run with the
+// Windows command interpreter; otherwise, they are run with /bin/sh.
+func NewVMToolsCommunicator(driver Driver, vmxPath string, credentials GuestCredentials, windows bool) *VMToolsCommunicator {
+	return &VMToolsCommunicator{
+		driver:      driver,
+		vmxPath:     vmxPath,
+		credentials: credentials,
+		windows:     windows,
+	}
+}
+
+// Start copies the command to the guest as a script and runs it in the
+// background. The exit status and output of the command are set on the
+// remote command once it exits.
+func (c *VMToolsCommunicator) Start(ctx context.Context, cmd *packersdk.RemoteCmd) error {
+	if cmd.Stdin != nil {
+		log.Printf("[WARN] The vmtools communicator does not support stdin; ignoring.")
+	}
+
+	id := fmt.Sprintf("packer-vmtools-%016x", rand.Uint64())
+	stdoutPath := c.guestTempPath(id + ".out")
+	stderrPath := c.guestTempPath(id + ".err")
+
+	log.Printf("[INFO] Starting remote command: %s", cmd.Command)
+
+	// A Windows command is written to a batch file of its own, which is called
+	// by the script, so that the output of every line of the command is
+	// redirected.
+	var script, scriptPath string
+	var paths []string
+	if c.windows {
+		commandPath := c.guestTempPath(id + "-command.cmd")
+		command := fmt.Sprintf("@echo off\r\n%s\r\n", cmd.Command)
+		if err := c.Upload(commandPath, strings.NewReader(command), nil); err != nil {
+			return fmt.Errorf("error uploading command script: %s", err)
+		}
+		paths = append(paths, commandPath)
+
+		scriptPath = c.guestTempPath(id + ".cmd")
+		script = fmt.Sprintf("@call \"%s\" > \"%s\" 2> \"%s\"\r\n@exit /b %%ERRORLEVEL%%\r\n", commandPath, stdoutPath, stderrPath)
+	} else {
+		scriptPath = c.guestTempPath(id + ".sh")
+		script = fmt.Sprintf("exec > '%s' 2> '%s'\n%s\n", stdoutPath, stderrPath, cmd.Command)
+	}
+
+	if err := c.Upload(scriptPath, strings.NewReader(script), nil); err != nil {
+		c.remove(paths...)
+		return fmt.Errorf("error uploading command script: %s", err)
+	}
+	paths = append(paths, scriptPath, stdoutPath, stderrPath)
+
+	go func() {
+		exitStatus, err := c.run(scriptPath, stdoutPath, stderrPath, paths, cmd)
+		if err != nil {
+			log.Printf("[ERROR] Remote command failed: %s", err)
+			exitStatus = packersdk.CmdDisconnect
+		}
+		log.Printf("[INFO] Remote command exited with: %d", exitStatus)
+		cmd.SetExited(exitStatus)
+	}()
+
+	return nil
+}
+
+// run runs the script in the guest, copies the output of the command to the
+// remote command, and removes the files at the paths from the guest.
+func (c *VMToolsCommunicator) run(scriptPath string, stdoutPath string, stderrPath string, paths []string, cmd *packersdk.RemoteCmd) (int, error) {
+	defer c.remove(paths...)
+
+	var exitStatus int
+	var err error
+	if c.windows {
+		exitStatus, err = c.driver.RunProgramInGuest(c.vmxPath, c.credentials, guestOpsWindowsShell, "/c", scriptPath)
+	} else {
+		exitStatus, err = c.driver.RunProgramInGuest(c.vmxPath, c.credentials, guestOpsLinuxShell, scriptPath)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	for guestPath, w := range map[string]io.Writer{stdoutPath: cmd.Stdout, stderrPath: cmd.Stderr} {
+		if w == nil {
+			continue
+		}
+		if err := c.Download(guestPath, w); err != nil {
+			return 0, fmt.Errorf("error downloading command output: %s", err)
+		}
+	}
+
+	return exitStatus, nil
+}
+
+// remove removes the files from the guest. Errors are logged, but otherwise
+// ignored.
+func (c *VMToolsCommunicator) remove(paths ...string) {
+	if len(paths) == 0 {
+		return
+	}
+
+	var err error
+	if c.windows {
+		_, err = c.driver.RunProgramInGuest(c.vmxPath, c.credentials, guestOpsWindowsShell, append([]string{"/c", "del", "/q"}, paths...)...)
+	} else {
+		_, err = c.driver.RunProgramInGuest(c.vmxPath, c.credentials, "/bin/rm", append([]string{"-f"}, paths...)...)
+	}
+	if err != nil {
+		log.Printf("[WARN] Error removing files from guest: %s", err)
+	}
+}
+
+// Upload copies the contents of the reader to the path in the guest.
+func (c *VMToolsCommunicator) Upload(dst string, r io.Reader, _ *os.FileInfo) error {
+	f, err := os.CreateTemp("", "packer-vmtools")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if _, err := io.Copy(f, r); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	log.Printf("[INFO] Uploading file to guest: %s", dst)
+	return c.driver.CopyFileFromHostToGuest(c.vmxPath, c.credentials, f.Name(), dst)
+}
+
+// UploadDir copies the contents of the directory to the path in the guest. If
+// the source does not end with a path separator, the directory itself is
+// created in the destination.
+func (c *VMToolsCommunicator) UploadDir(dst string, src string, exclude []string) error {
+	if !strings.HasSuffix(src, "/") && !strings.HasSuffix(src, string(os.PathSeparator)) {
+		dst = c.guestJoin(dst, filepath.Base(src))
+	}
+
+	return filepath.Walk(src, func(hostPath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, hostPath)
+		if err != nil {
+			return err
+		}
+		for _, pattern := range exclude {
+			if matched, _ := filepath.Match(pattern, rel); matched {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
+
+		guestPath := dst
+		if rel != "." {
+			guestPath = c.guestJoin(dst, filepath.ToSlash(rel))
+		}
+
+		if info.IsDir() {
+			return c.mkdir(guestPath)
+		}
+
+		f, err := os.Open(hostPath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		return c.Upload(guestPath, f, &info)
+	})
+}
+
+// mkdir creates the directory in the guest, if it does not exist.
+func (c *VMToolsCommunicator) mkdir(dir string) error {
+	exists, err := c.driver.DirectoryExistsInGuest(c.vmxPath, c.credentials, dir)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	var exitStatus int
+	if c.windows {
+		exitStatus, err = c.driver.RunProgramInGuest(c.vmxPath, c.credentials, guestOpsWindowsShell, "/c", "mkdir", dir)
+	} else {
+		exitStatus, err = c.driver.RunProgramInGuest(c.vmxPath, c.credentials, "/bin/mkdir", "-p", dir)
+	}
+	if err != nil {
+		return err
+	}
+	if exitStatus != 0 {
+		return fmt.Errorf("error creating directory %s in guest: exit status %d", dir, exitStatus)
+	}
+
+	return nil
+}
+
+// Download copies the file at the path in the guest to the writer.
+func (c *VMToolsCommunicator) Download(src string, w io.Writer) error {
+	f, err := os.CreateTemp("", "packer-vmtools")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	log.Printf("[INFO] Downloading file from guest: %s", src)
+	if err := c.driver.CopyFileFromGuestToHost(c.vmxPath, c.credentials, src, f.Name()); err != nil {
+		return err
+	}
+
+	f, err = os.Open(f.Name())
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// DownloadDir is not supported by the communicator.
+func (c *VMToolsCommunicator) DownloadDir(string, string, []string) error {
+	return errors.New("downloading directories is not supported by the vmtools communicator")
+}
+
+// guestTempPath returns the path of the file in the temporary directory of
+// the guest.
+func (c *VMToolsCommunicator) guestTempPath(name string) string {
+	if c.windows {
+		return c.guestJoin(guestOpsWindowsTempDir, name)
+	}
+	return c.guestJoin(guestOpsLinuxTempDir, name)
+}
+
+// guestJoin joins the elements of a path in the guest, using the path
+// separator of the guest operating system.
+func (c *VMToolsCommunicator) guestJoin(elem ...string) string {
+	if c.windows {
+		for i := range elem {
+			elem[i] = strings.ReplaceAll(elem[i], "/", "\\")
+			if i < len(elem)-1 {
+				elem[i] = strings.TrimRight(elem[i], "\\")
+			}
+		}
+		return strings.Join(elem, "\\")
+	}
+	return path.Join(elem...)
+}
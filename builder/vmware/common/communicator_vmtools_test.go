@@ -0,0 +1,199 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/stretchr/testify/assert"
+)
+
+// testGuestScript simulates running a command script in the guest by writing
+// the output files referenced by the script.
+func testGuestScript(stdout string, stderr string, exitStatus int) func(map[string][]byte, string, []string) int {
+	return func(files map[string][]byte, program string, args []string) int {
+		if program != guestOpsLinuxShell {
+			return 0
+		}
+		script := string(files[args[0]])
+		var outPath, errPath string
+		for _, field := range strings.Fields(strings.SplitN(script, "\n", 2)[0]) {
+			field = strings.Trim(field, "'")
+			switch {
+			case strings.HasSuffix(field, ".out"):
+				outPath = field
+			case strings.HasSuffix(field, ".err"):
+				errPath = field
+			}
+		}
+		files[outPath] = []byte(stdout)
+		files[errPath] = []byte(stderr)
+		return exitStatus
+	}
+}
+
+func TestVMToolsCommunicator_impl(t *testing.T) {
+	var _ packersdk.Communicator = new(VMToolsCommunicator)
+}
+
+func TestVMToolsCommunicator_Start(t *testing.T) {
+	driver := new(DriverMock)
+	driver.RunProgramInGuestFunc = testGuestScript("hello\n", "warning\n", 3)
+	credentials := GuestCredentials{Username: "packer", Password: "secret"}
+	comm := NewVMToolsCommunicator(driver, "foo.vmx", credentials, false)
+
+	var stdout, stderr bytes.Buffer
+	cmd := &packersdk.RemoteCmd{
+		Command: "echo hello",
+		Stdout:  &stdout,
+		Stderr:  &stderr,
+	}
+	if err := comm.Start(context.Background(), cmd); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	assert.Equal(t, 3, cmd.Wait())
+	assert.Equal(t, "hello\n", stdout.String())
+	assert.Equal(t, "warning\n", stderr.String())
+
+	driver.Lock()
+	defer driver.Unlock()
+	assert.Equal(t, credentials, driver.GuestCredentials)
+
+	// The script and output files are removed from the guest.
+	assert.Equal(t, "/bin/rm", driver.RunProgramInGuestProgram)
+	assert.Len(t, driver.RunProgramInGuestArgs, 4)
+	for _, path := range driver.RunProgramInGuestArgs[1:] {
+		assert.True(t, strings.HasPrefix(path, guestOpsLinuxTempDir+"/packer-vmtools-"), path)
+	}
+}
+
+func TestVMToolsCommunicator_StartWindows(t *testing.T) {
+	driver := new(DriverMock)
+	comm := NewVMToolsCommunicator(driver, "foo.vmx", GuestCredentials{Username: "Administrator"}, true)
+
+	var script, command string
+	var removed []string
+	driver.RunProgramInGuestFunc = func(files map[string][]byte, program string, args []string) int {
+		assert.Equal(t, guestOpsWindowsShell, program)
+		if len(args) == 2 {
+			base := strings.TrimSuffix(args[1], ".cmd")
+			script = string(files[args[1]])
+			command = string(files[base+"-command.cmd"])
+			files[base+".out"] = []byte("ok")
+			files[base+".err"] = nil
+		} else {
+			removed = args[3:]
+		}
+		return 0
+	}
+
+	var stdout bytes.Buffer
+	cmd := &packersdk.RemoteCmd{Command: "whoami\r\nhostname", Stdout: &stdout}
+	if err := comm.Start(context.Background(), cmd); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	assert.Equal(t, 0, cmd.Wait())
+	assert.Equal(t, "ok", stdout.String())
+
+	// The command is called by the script, so that the output of every line
+	// of the command is redirected.
+	assert.Equal(t, "@echo off\r\nwhoami\r\nhostname\r\n", command)
+	assert.Contains(t, script, "@call \"C:\\Windows\\Temp\\packer-vmtools-")
+	assert.Contains(t, script, "-command.cmd\" > \"C:\\Windows\\Temp\\packer-vmtools-")
+	assert.Contains(t, script, "@exit /b %ERRORLEVEL%")
+
+	driver.Lock()
+	defer driver.Unlock()
+	assert.Len(t, removed, 4)
+}
+
+func TestVMToolsCommunicator_StartError(t *testing.T) {
+	driver := new(DriverMock)
+	driver.CopyFileFromHostToGuestErr = os.ErrPermission
+	comm := NewVMToolsCommunicator(driver, "foo.vmx", GuestCredentials{}, false)
+
+	if err := comm.Start(context.Background(), &packersdk.RemoteCmd{Command: "true"}); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestVMToolsCommunicator_UploadDownload(t *testing.T) {
+	driver := new(DriverMock)
+	comm := NewVMToolsCommunicator(driver, "foo.vmx", GuestCredentials{}, false)
+
+	if err := comm.Upload("/tmp/file.txt", strings.NewReader("contents"), nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assert.Equal(t, []byte("contents"), driver.GuestFiles["/tmp/file.txt"])
+
+	var buf bytes.Buffer
+	if err := comm.Download("/tmp/file.txt", &buf); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assert.Equal(t, "contents", buf.String())
+
+	if err := comm.Download("/tmp/missing.txt", &buf); err == nil {
+		t.Fatal("should error")
+	}
+	if err := comm.DownloadDir("/tmp", t.TempDir(), nil); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestVMToolsCommunicator_UploadDir(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "files")
+	for name, contents := range map[string]string{
+		"a.txt":         "a",
+		"sub/b.txt":     "b",
+		"sub/skip.tmp":  "skip",
+		"other/c.txt":   "c",
+		"other/d/e.txt": "e",
+	} {
+		path := filepath.Join(src, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0644); err != nil { //nolint:gosec
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	driver := new(DriverMock)
+	driver.GuestDirectories = []string{"/opt/files"}
+	var created []string
+	driver.RunProgramInGuestFunc = func(_ map[string][]byte, program string, args []string) int {
+		if program == "/bin/mkdir" {
+			created = append(created, args[1])
+		}
+		return 0
+	}
+	comm := NewVMToolsCommunicator(driver, "foo.vmx", GuestCredentials{}, false)
+
+	if err := comm.UploadDir("/opt", src, []string{"sub/*.tmp", "other"}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	assert.Equal(t, []string{"/opt/files/sub"}, created)
+	assert.Equal(t, map[string][]byte{
+		"/opt/files/a.txt":     []byte("a"),
+		"/opt/files/sub/b.txt": []byte("b"),
+	}, driver.GuestFiles)
+}
+
+func TestVMToolsCommunicator_guestJoin(t *testing.T) {
+	linux := NewVMToolsCommunicator(new(DriverMock), "foo.vmx", GuestCredentials{}, false)
+	assert.Equal(t, "/opt/files/a.txt", linux.guestJoin("/opt/", "files/a.txt"))
+
+	windows := NewVMToolsCommunicator(new(DriverMock), "foo.vmx", GuestCredentials{}, true)
+	assert.Equal(t, "C:\\opt\\files\\a.txt", windows.guestJoin("C:\\opt\\", "files/a.txt"))
+}
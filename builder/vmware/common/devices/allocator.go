@@ -0,0 +1,63 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+package devices
+
+import (
+	"fmt"
+)
+
+// Allocator allocates the slots of storage controllers for new devices. A slot
+// that is used by a device, or that was allocated or reserved, is not
+// allocated again.
+type Allocator struct {
+	used map[Slot]bool
+}
+
+// NewAllocator returns an allocator for the slots that are not used by the
+// devices.
+func NewAllocator(d *Devices) *Allocator {
+	a := &Allocator{used: make(map[Slot]bool)}
+	for _, s := range d.slots {
+		a.used[s] = true
+	}
+	return a
+}
+
+// Allocate allocates the first free slot of the controllers of the type.
+func (a *Allocator) Allocate(bus Bus) (Slot, error) {
+	for controller := 0; controller < bus.MaxControllers(); controller++ {
+		if s, err := a.AllocateFrom(bus, controller, 0); err == nil {
+			return s, nil
+		}
+	}
+	return Slot{}, fmt.Errorf("no available slots found for adapter type %s", bus)
+}
+
+// AllocateFrom allocates the first free slot of the controller, starting at
+// the unit.
+func (a *Allocator) AllocateFrom(bus Bus, controller int, unit int) (Slot, error) {
+	for ; unit < bus.MaxUnits(); unit++ {
+		s := Slot{Bus: bus, Controller: controller, Unit: unit}
+		if s.Validate() != nil || a.used[s] {
+			continue
+		}
+		a.used[s] = true
+		return s, nil
+	}
+	return Slot{}, fmt.Errorf("no available slots found on controller %s%d", bus, controller)
+}
+
+// Reserve reserves the slot, so that it is not allocated. An error is
+// returned if the slot is not valid or already used.
+func (a *Allocator) Reserve(s Slot) error {
+	if err := s.Validate(); err != nil {
+		return err
+	}
+	if a.used[s] {
+		return fmt.Errorf("%s: slot is already in use", s)
+	}
+	a.used[s] = true
+	return nil
+}
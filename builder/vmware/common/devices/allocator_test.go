@@ -0,0 +1,92 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+package devices
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestAllocator_allocate(t *testing.T) {
+	vmxData := map[string]string{
+		"ide0:0.present": "TRUE",
+		"ide0:1.present": "TRUE",
+		"ide1:0.present": "FALSE",
+	}
+	for unit := 0; unit < 7; unit++ {
+		vmxData[fmt.Sprintf("scsi0:%d.present", unit)] = "TRUE"
+	}
+
+	a := NewAllocator(Decode(vmxData))
+
+	cases := []struct {
+		bus      Bus
+		expected string
+	}{
+		{BusIDE, "ide1:1"},
+		{BusSCSI, "scsi0:8"},
+		{BusSCSI, "scsi0:9"},
+		{BusSATA, "sata0:0"},
+		{BusNVMe, "nvme0:0"},
+	}
+	for _, tc := range cases {
+		slot, err := a.Allocate(tc.bus)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if slot.String() != tc.expected {
+			t.Errorf("expected %s, got %s", tc.expected, slot)
+		}
+	}
+
+	if _, err := a.Allocate(BusIDE); err == nil {
+		t.Error("should error when all the IDE slots are used")
+	}
+}
+
+func TestAllocator_allocateFrom(t *testing.T) {
+	a := NewAllocator(Decode(map[string]string{
+		"scsi0:0.present": "TRUE",
+		"scsi0:8.present": "TRUE",
+	}))
+
+	for _, expected := range []string{"scsi0:6", "scsi0:9", "scsi0:10"} {
+		slot, err := a.AllocateFrom(BusSCSI, 0, 6)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if slot.String() != expected {
+			t.Errorf("expected %s, got %s", expected, slot)
+		}
+	}
+
+	if _, err := a.AllocateFrom(BusSCSI, 0, 16); err == nil {
+		t.Error("should error when no units are left")
+	}
+}
+
+func TestAllocator_reserve(t *testing.T) {
+	a := NewAllocator(Decode(map[string]string{
+		"sata0:0.present": "TRUE",
+	}))
+
+	if err := a.Reserve(Slot{Bus: BusSATA, Unit: 0}); err == nil {
+		t.Error("should error for a used slot")
+	}
+	if err := a.Reserve(Slot{Bus: BusSCSI, Unit: 7}); err == nil {
+		t.Error("should error for a reserved slot")
+	}
+	if err := a.Reserve(Slot{Bus: BusSATA, Unit: 1}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	slot, err := a.Allocate(BusSATA)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if slot.String() != "sata0:2" {
+		t.Errorf("expected sata0:2, got %s", slot)
+	}
+}
@@ -0,0 +1,609 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package devices decodes the virtual devices of a virtual machine from the
+// data of a .vmx file into typed controllers and devices, allocates slots for
+// new devices, and encodes devices back to the data.
+package devices
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Bus is the type of a storage controller.
+type Bus string
+
+const (
+	BusIDE  Bus = "ide"
+	BusSATA Bus = "sata"
+	BusSCSI Bus = "scsi"
+	BusNVMe Bus = "nvme"
+)
+
+// Buses is the list of storage controller types, in the order in which they
+// are reported.
+var Buses = []Bus{BusIDE, BusSATA, BusSCSI, BusNVMe}
+
+// scsiReservedUnit is the unit of a SCSI controller reserved for the
+// controller itself.
+const scsiReservedUnit = 7
+
+var (
+	// controllerRe matches the key of a storage controller; for example,
+	// `scsi0.present`.
+	controllerRe = regexp.MustCompile(`^(ide|sata|scsi|nvme)(\d+)\.(.+)$`)
+	// slotRe matches the key of a device attached to a storage controller; for
+	// example, `scsi0:1.present`.
+	slotRe = regexp.MustCompile(`^(ide|sata|scsi|nvme)(\d+):(\d+)\.(.+)$`)
+	// indexedRe matches the key of a device that is not attached to a storage
+	// controller; for example, `ethernet0.present`.
+	indexedRe = regexp.MustCompile(`^(ethernet|serial|parallel|floppy)(\d+)\.(.+)$`)
+	// slotNameRe matches a slot; for example, `scsi0:1`.
+	slotNameRe = regexp.MustCompile(`^(ide|sata|scsi|nvme)(\d+):(\d+)$`)
+)
+
+// ParseBus parses the type of a storage controller, case-insensitively.
+func ParseBus(s string) (Bus, error) {
+	bus := Bus(strings.ToLower(s))
+	for _, b := range Buses {
+		if bus == b {
+			return bus, nil
+		}
+	}
+	return "", fmt.Errorf("invalid adapter type: %s; must be one of %v", s, Buses)
+}
+
+// MaxControllers returns the maximum number of controllers of the type.
+func (b Bus) MaxControllers() int {
+	if b == BusIDE {
+		return 2
+	}
+	return 4
+}
+
+// MaxUnits returns the maximum number of units of a controller of the type,
+// including any reserved unit.
+func (b Bus) MaxUnits() int {
+	switch b {
+	case BusIDE:
+		return 2
+	case BusSATA:
+		return 30
+	case BusNVMe:
+		return 15
+	default:
+		return 16
+	}
+}
+
+// Reserved reports whether the unit of a controller of the type is reserved
+// and cannot be used by a device.
+func (b Bus) Reserved(unit int) bool {
+	return b == BusSCSI && unit == scsiReservedUnit
+}
+
+// Slot is the location of a device attached to a storage controller.
+type Slot struct {
+	Bus        Bus
+	Controller int
+	Unit       int
+}
+
+// ParseSlot parses a slot in the `<bus><controller>:<unit>` format; for
+// example, `scsi0:1`.
+func ParseSlot(s string) (Slot, error) {
+	matches := slotNameRe.FindStringSubmatch(strings.ToLower(s))
+	if matches == nil {
+		return Slot{}, fmt.Errorf("invalid device path format: %s; expected format like 'ide0:1'", s)
+	}
+
+	controller, err := strconv.Atoi(matches[2])
+	if err != nil {
+		return Slot{}, fmt.Errorf("invalid controller number: %s", s)
+	}
+	unit, err := strconv.Atoi(matches[3])
+	if err != nil {
+		return Slot{}, fmt.Errorf("invalid unit number: %s", s)
+	}
+	return Slot{Bus: Bus(matches[1]), Controller: controller, Unit: unit}, nil
+}
+
+// String returns the slot in the `<bus><controller>:<unit>` format.
+func (s Slot) String() string {
+	return fmt.Sprintf("%s%d:%d", s.Bus, s.Controller, s.Unit)
+}
+
+// ControllerName returns the name of the controller of the slot; for example,
+// `scsi0`.
+func (s Slot) ControllerName() string {
+	return fmt.Sprintf("%s%d", s.Bus, s.Controller)
+}
+
+// Validate checks that the controller and the unit of the slot are within
+// the limits of the controller type and that the unit is not reserved.
+func (s Slot) Validate() error {
+	if s.Controller < 0 || s.Controller >= s.Bus.MaxControllers() {
+		return fmt.Errorf("%s: %s supports up to %d controllers", s, s.Bus, s.Bus.MaxControllers())
+	}
+	if s.Unit < 0 || s.Unit >= s.Bus.MaxUnits() {
+		return fmt.Errorf("%s: %s supports up to %d units per controller", s, s.Bus, s.Bus.MaxUnits())
+	}
+	if s.Bus.Reserved(s.Unit) {
+		return fmt.Errorf("%s: unit %d is reserved for the %s controller", s, s.Unit, s.Bus)
+	}
+	return nil
+}
+
+// less reports whether the slot is ordered before the other slot.
+func (s Slot) less(o Slot) bool {
+	if s.Bus != o.Bus {
+		return busIndex(s.Bus) < busIndex(o.Bus)
+	}
+	if s.Controller != o.Controller {
+		return s.Controller < o.Controller
+	}
+	return s.Unit < o.Unit
+}
+
+// busIndex returns the index of the controller type in Buses.
+func busIndex(b Bus) int {
+	for i, bus := range Buses {
+		if b == bus {
+			return i
+		}
+	}
+	return len(Buses)
+}
+
+// Controller is a storage controller.
+type Controller struct {
+	Bus    Bus
+	Number int
+	// Whether the controller is present. A controller that is not present
+	// explicitly can not have devices attached.
+	Present bool
+	// Whether the data defines whether the controller is present. IDE
+	// controllers are present implicitly.
+	Defined bool
+	// The virtual device of the controller; for example, `lsilogic` or
+	// `pvscsi`.
+	VirtualDev string
+}
+
+// Name returns the name of the controller; for example, `scsi0`.
+func (c Controller) Name() string {
+	return fmt.Sprintf("%s%d", c.Bus, c.Number)
+}
+
+// Encode sets the data of the controller.
+func (c Controller) Encode(vmxData map[string]string) {
+	vmxData[c.Name()+".present"] = encodeBool(c.Present)
+	if c.VirtualDev != "" {
+		vmxData[c.Name()+".virtualdev"] = c.VirtualDev
+	}
+}
+
+// Disk is a virtual disk attached to a storage controller.
+type Disk struct {
+	Slot
+	Present bool
+	// The path to the disk, relative to the directory of the .vmx file.
+	FileName string
+	// The disk mode; for example, `persistent` or `independent-persistent`.
+	Mode string
+}
+
+// Name returns the name of the device; for example, `scsi0:0`.
+func (d Disk) Name() string {
+	return d.Slot.String()
+}
+
+// Encode sets the data of the disk.
+func (d Disk) Encode(vmxData map[string]string) {
+	vmxData[d.Name()+".present"] = encodeBool(d.Present)
+	if d.FileName != "" {
+		vmxData[d.Name()+".filename"] = d.FileName
+	}
+	if d.Mode != "" {
+		vmxData[d.Name()+".mode"] = d.Mode
+	}
+}
+
+// CDROM is a CD-ROM device attached to a storage controller.
+type CDROM struct {
+	Slot
+	Present bool
+	// The path to the ISO file, or the name of the host device.
+	FileName string
+	// The device type; for example, `cdrom-image` or `cdrom-raw`.
+	DeviceType string
+}
+
+// Name returns the name of the device; for example, `ide1:0`.
+func (c CDROM) Name() string {
+	return c.Slot.String()
+}
+
+// Encode sets the data of the CD-ROM device.
+func (c CDROM) Encode(vmxData map[string]string) {
+	vmxData[c.Name()+".present"] = encodeBool(c.Present)
+	if c.FileName != "" {
+		vmxData[c.Name()+".filename"] = c.FileName
+	}
+	if c.DeviceType != "" {
+		vmxData[c.Name()+".devicetype"] = c.DeviceType
+	}
+}
+
+// Floppy is a floppy device.
+type Floppy struct {
+	Number   int
+	Present  bool
+	FileType string
+	FileName string
+}
+
+// Name returns the name of the device; for example, `floppy0`.
+func (f Floppy) Name() string {
+	return fmt.Sprintf("floppy%d", f.Number)
+}
+
+// NIC is a network adapter.
+type NIC struct {
+	Number  int
+	Present bool
+	// The connection type; for example, `nat` or `custom`.
+	ConnectionType string
+	// The virtual device; for example, `e1000e` or `vmxnet3`.
+	VirtualDev string
+	// The virtual network device of a custom connection; for example,
+	// `vmnet8`.
+	VNet string
+	// The address type; for example, `generated` or `static`.
+	AddressType string
+	// The static MAC address.
+	Address string
+}
+
+// Name returns the name of the device; for example, `ethernet0`.
+func (n NIC) Name() string {
+	return fmt.Sprintf("ethernet%d", n.Number)
+}
+
+// PortKind is the type of a serial or parallel port.
+type PortKind string
+
+const (
+	PortSerial   PortKind = "serial"
+	PortParallel PortKind = "parallel"
+)
+
+// Port is a serial or parallel port.
+type Port struct {
+	Kind     PortKind
+	Number   int
+	Present  bool
+	FileType string
+	FileName string
+}
+
+// Name returns the name of the device; for example, `serial0`.
+func (p Port) Name() string {
+	return fmt.Sprintf("%s%d", p.Kind, p.Number)
+}
+
+// Devices are the virtual devices of a virtual machine.
+type Devices struct {
+	Controllers   []Controller
+	Disks         []Disk
+	CDROMs        []CDROM
+	Floppies      []Floppy
+	NICs          []NIC
+	SerialPorts   []Port
+	ParallelPorts []Port
+
+	// The slots of the storage controllers that are used by a device,
+	// including devices that are not disks or CD-ROM devices.
+	slots []Slot
+}
+
+// Decode decodes the devices from the data of a .vmx file. Keys are matched
+// case-insensitively.
+func Decode(vmxData map[string]string) *Devices {
+	controllers := make(map[Slot]map[string]string)
+	slots := make(map[Slot]map[string]string)
+	indexed := make(map[indexedDevice]map[string]string)
+
+	for key, value := range vmxData {
+		key = strings.ToLower(key)
+		if matches := slotRe.FindStringSubmatch(key); matches != nil {
+			controller, err1 := strconv.Atoi(matches[2])
+			unit, err2 := strconv.Atoi(matches[3])
+			if err1 != nil || err2 != nil {
+				continue
+			}
+			slot := Slot{Bus: Bus(matches[1]), Controller: controller, Unit: unit}
+			addAttribute(slots, slot, matches[4], value)
+		} else if matches := controllerRe.FindStringSubmatch(key); matches != nil {
+			number, err := strconv.Atoi(matches[2])
+			if err != nil {
+				continue
+			}
+			addAttribute(controllers, Slot{Bus: Bus(matches[1]), Controller: number}, matches[3], value)
+		} else if matches := indexedRe.FindStringSubmatch(key); matches != nil {
+			number, err := strconv.Atoi(matches[2])
+			if err != nil {
+				continue
+			}
+			addAttribute(indexed, indexedDevice{kind: matches[1], number: number}, matches[3], value)
+		}
+	}
+
+	d := &Devices{}
+
+	for slot, attrs := range controllers {
+		present, defined := attrs["present"]
+		d.Controllers = append(d.Controllers, Controller{
+			Bus:        slot.Bus,
+			Number:     slot.Controller,
+			Present:    decodeBool(present),
+			Defined:    defined,
+			VirtualDev: attrs["virtualdev"],
+		})
+	}
+	sort.Slice(d.Controllers, func(i, j int) bool {
+		a, b := d.Controllers[i], d.Controllers[j]
+		return Slot{Bus: a.Bus, Controller: a.Number}.less(Slot{Bus: b.Bus, Controller: b.Number})
+	})
+
+	for slot, attrs := range slots {
+		d.slots = append(d.slots, slot)
+
+		deviceType := strings.ToLower(attrs["devicetype"])
+		present := decodeBool(attrs["present"])
+		switch {
+		case strings.HasPrefix(deviceType, "cdrom-") || deviceType == "atapi-cdrom":
+			d.CDROMs = append(d.CDROMs, CDROM{
+				Slot:       slot,
+				Present:    present,
+				FileName:   attrs["filename"],
+				DeviceType: attrs["devicetype"],
+			})
+		case deviceType == "" || deviceType == "disk" || strings.HasSuffix(deviceType, "-harddisk"):
+			if attrs["filename"] == "" && deviceType == "" {
+				continue
+			}
+			d.Disks = append(d.Disks, Disk{
+				Slot:     slot,
+				Present:  present,
+				FileName: attrs["filename"],
+				Mode:     attrs["mode"],
+			})
+		}
+	}
+	sortSlots(d.slots)
+	sort.Slice(d.Disks, func(i, j int) bool { return d.Disks[i].Slot.less(d.Disks[j].Slot) })
+	sort.Slice(d.CDROMs, func(i, j int) bool { return d.CDROMs[i].Slot.less(d.CDROMs[j].Slot) })
+
+	for device, attrs := range indexed {
+		number := device.number
+		present := decodeBool(attrs["present"])
+		switch device.kind {
+		case "ethernet":
+			d.NICs = append(d.NICs, NIC{
+				Number:         number,
+				Present:        present,
+				ConnectionType: attrs["connectiontype"],
+				VirtualDev:     attrs["virtualdev"],
+				VNet:           attrs["vnet"],
+				AddressType:    attrs["addresstype"],
+				Address:        attrs["address"],
+			})
+		case "floppy":
+			d.Floppies = append(d.Floppies, Floppy{
+				Number:   number,
+				Present:  present,
+				FileType: attrs["filetype"],
+				FileName: attrs["filename"],
+			})
+		case "serial", "parallel":
+			port := Port{
+				Kind:     PortKind(device.kind),
+				Number:   number,
+				Present:  present,
+				FileType: attrs["filetype"],
+				FileName: attrs["filename"],
+			}
+			if port.Kind == PortSerial {
+				d.SerialPorts = append(d.SerialPorts, port)
+			} else {
+				d.ParallelPorts = append(d.ParallelPorts, port)
+			}
+		}
+	}
+	sort.Slice(d.NICs, func(i, j int) bool { return d.NICs[i].Number < d.NICs[j].Number })
+	sort.Slice(d.Floppies, func(i, j int) bool { return d.Floppies[i].Number < d.Floppies[j].Number })
+	sort.Slice(d.SerialPorts, func(i, j int) bool { return d.SerialPorts[i].Number < d.SerialPorts[j].Number })
+	sort.Slice(d.ParallelPorts, func(i, j int) bool { return d.ParallelPorts[i].Number < d.ParallelPorts[j].Number })
+
+	return d
+}
+
+// Slots returns the slots of the storage controllers that are used by a
+// device, in order.
+func (d *Devices) Slots() []Slot {
+	return append([]Slot(nil), d.slots...)
+}
+
+// Controller returns the controller with the name, if it is defined.
+func (d *Devices) Controller(bus Bus, number int) (Controller, bool) {
+	for _, c := range d.Controllers {
+		if c.Bus == bus && c.Number == number {
+			return c, true
+		}
+	}
+	return Controller{}, false
+}
+
+// BootDisk returns the disk from which the virtual machine boots, if any.
+// Disks that are not present are skipped. The boot disk is the first disk in
+// the `bios.hddOrder` list, such as `scsi0:0,sata0:0`, if set; otherwise, it is
+// the disk at unit 0 of controller 0, in the order of Buses if several buses
+// have such a disk, or else the first disk.
+func (d *Devices) BootDisk(hddOrder string) (Disk, bool) {
+	var present []Disk
+	for _, disk := range d.Disks {
+		if disk.Present {
+			present = append(present, disk)
+		}
+	}
+
+	for _, name := range strings.Split(hddOrder, ",") {
+		slot, err := ParseSlot(strings.TrimSpace(name))
+		if err != nil {
+			continue
+		}
+		for _, disk := range present {
+			if disk.Slot == slot {
+				return disk, true
+			}
+		}
+	}
+
+	for _, disk := range present {
+		if disk.Slot.Controller == 0 && disk.Slot.Unit == 0 {
+			return disk, true
+		}
+	}
+
+	if len(present) > 0 {
+		return present[0], true
+	}
+	return Disk{}, false
+}
+
+// LastUnit returns the highest unit of the controller that is used by a
+// device, or -1 if no unit is used.
+func (d *Devices) LastUnit(bus Bus, controller int) int {
+	last := -1
+	for _, s := range d.slots {
+		if s.Bus == bus && s.Controller == controller && s.Unit > last {
+			last = s.Unit
+		}
+	}
+	return last
+}
+
+// Validate checks the devices for conflicts that prevent the virtual machine
+// from powering on: slots that are out of range or reserved, devices that are
+// attached to a controller that is not present, disks that are attached more
+// than once, and network adapters with the same static MAC address.
+func (d *Devices) Validate() error {
+	var errs []error
+
+	for _, c := range d.Controllers {
+		if c.Number >= c.Bus.MaxControllers() {
+			errs = append(errs, fmt.Errorf("%s: %s supports up to %d controllers", c.Name(), c.Bus, c.Bus.MaxControllers()))
+		}
+	}
+
+	present := make(map[Slot]bool)
+	for _, disk := range d.Disks {
+		present[disk.Slot] = disk.Present
+	}
+	for _, cdrom := range d.CDROMs {
+		present[cdrom.Slot] = cdrom.Present
+	}
+
+	for _, s := range d.slots {
+		if !present[s] {
+			continue
+		}
+		if err := s.Validate(); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if c, ok := d.Controller(s.Bus, s.Controller); ok && c.Defined && !c.Present {
+			errs = append(errs, fmt.Errorf("%s: controller %s is not present", s, c.Name()))
+		}
+	}
+
+	disks := make(map[string]string)
+	for _, disk := range d.Disks {
+		if !disk.Present || disk.FileName == "" {
+			continue
+		}
+		if other, ok := disks[disk.FileName]; ok {
+			errs = append(errs, fmt.Errorf("%s: disk %s is also attached to %s", disk.Name(), disk.FileName, other))
+			continue
+		}
+		disks[disk.FileName] = disk.Name()
+	}
+
+	addresses := make(map[string]string)
+	for _, nic := range d.NICs {
+		if !nic.Present || !strings.EqualFold(nic.AddressType, "static") || nic.Address == "" {
+			continue
+		}
+		address := strings.ToLower(nic.Address)
+		if other, ok := addresses[address]; ok {
+			errs = append(errs, fmt.Errorf("%s: MAC address %s is also used by %s", nic.Name(), nic.Address, other))
+			continue
+		}
+		addresses[address] = nic.Name()
+	}
+
+	return errors.Join(errs...)
+}
+
+// Remove removes all the data of the device or controller with the name; for
+// example, `floppy0` or `ide1:0`.
+func Remove(vmxData map[string]string, name string) {
+	prefix := strings.ToLower(name) + "."
+	for key := range vmxData {
+		if strings.HasPrefix(strings.ToLower(key), prefix) {
+			delete(vmxData, key)
+		}
+	}
+}
+
+// indexedDevice identifies a device that is not attached to a storage
+// controller.
+type indexedDevice struct {
+	kind   string
+	number int
+}
+
+// addAttribute adds the attribute of the device with the name.
+func addAttribute[K comparable](devices map[K]map[string]string, name K, attr string, value string) {
+	attrs, ok := devices[name]
+	if !ok {
+		attrs = make(map[string]string)
+		devices[name] = attrs
+	}
+	attrs[attr] = value
+}
+
+// sortSlots sorts the slots in order.
+func sortSlots(slots []Slot) {
+	sort.Slice(slots, func(i, j int) bool { return slots[i].less(slots[j]) })
+}
+
+// decodeBool decodes a boolean value of the data.
+func decodeBool(value string) bool {
+	return strings.EqualFold(value, "TRUE")
+}
+
+// encodeBool encodes a boolean value of the data.
+func encodeBool(value bool) string {
+	if value {
+		return "TRUE"
+	}
+	return "FALSE"
+}
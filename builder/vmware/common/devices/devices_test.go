@@ -0,0 +1,285 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+package devices
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var testVMXData = map[string]string{
+	"scsi0.present":            "TRUE",
+	"scsi0.virtualdev":         "lsilogic",
+	"scsi0:0.present":          "TRUE",
+	"scsi0:0.filename":         "disk.vmdk",
+	"scsi0:1.present":          "TRUE",
+	"scsi0:1.fileName":         "disk-1.vmdk",
+	"scsi0:1.mode":             "independent-persistent",
+	"sata0.present":            "FALSE",
+	"ide1:0.present":           "TRUE",
+	"ide1:0.filename":          "/path/to/os.iso",
+	"ide1:0.devicetype":        "cdrom-image",
+	"floppy0.present":          "TRUE",
+	"floppy0.filetype":         "file",
+	"floppy0.filename":         "floppy.img",
+	"ethernet0.present":        "TRUE",
+	"ethernet0.connectiontype": "nat",
+	"ethernet0.virtualdev":     "e1000e",
+	"ethernet1.present":        "TRUE",
+	"ethernet1.connectiontype": "custom",
+	"ethernet1.vnet":           "vmnet2",
+	"serial0.present":          "FALSE",
+	"parallel0.present":        "FALSE",
+	"displayname":              "vm",
+}
+
+func TestDecode(t *testing.T) {
+	d := Decode(testVMXData)
+
+	expectedControllers := []Controller{
+		{Bus: BusSATA, Number: 0, Present: false, Defined: true},
+		{Bus: BusSCSI, Number: 0, Present: true, Defined: true, VirtualDev: "lsilogic"},
+	}
+	if !reflect.DeepEqual(d.Controllers, expectedControllers) {
+		t.Errorf("invalid controllers: %#v", d.Controllers)
+	}
+
+	expectedDisks := []Disk{
+		{Slot: Slot{Bus: BusSCSI, Controller: 0, Unit: 0}, Present: true, FileName: "disk.vmdk"},
+		{Slot: Slot{Bus: BusSCSI, Controller: 0, Unit: 1}, Present: true, FileName: "disk-1.vmdk", Mode: "independent-persistent"},
+	}
+	if !reflect.DeepEqual(d.Disks, expectedDisks) {
+		t.Errorf("invalid disks: %#v", d.Disks)
+	}
+
+	expectedCDROMs := []CDROM{
+		{Slot: Slot{Bus: BusIDE, Controller: 1, Unit: 0}, Present: true, FileName: "/path/to/os.iso", DeviceType: "cdrom-image"},
+	}
+	if !reflect.DeepEqual(d.CDROMs, expectedCDROMs) {
+		t.Errorf("invalid CD-ROM devices: %#v", d.CDROMs)
+	}
+
+	expectedFloppies := []Floppy{{Number: 0, Present: true, FileType: "file", FileName: "floppy.img"}}
+	if !reflect.DeepEqual(d.Floppies, expectedFloppies) {
+		t.Errorf("invalid floppy devices: %#v", d.Floppies)
+	}
+
+	expectedNICs := []NIC{
+		{Number: 0, Present: true, ConnectionType: "nat", VirtualDev: "e1000e"},
+		{Number: 1, Present: true, ConnectionType: "custom", VNet: "vmnet2"},
+	}
+	if !reflect.DeepEqual(d.NICs, expectedNICs) {
+		t.Errorf("invalid network adapters: %#v", d.NICs)
+	}
+
+	if len(d.SerialPorts) != 1 || d.SerialPorts[0].Name() != "serial0" || d.SerialPorts[0].Present {
+		t.Errorf("invalid serial ports: %#v", d.SerialPorts)
+	}
+	if len(d.ParallelPorts) != 1 || d.ParallelPorts[0].Name() != "parallel0" {
+		t.Errorf("invalid parallel ports: %#v", d.ParallelPorts)
+	}
+
+	expectedSlots := []Slot{
+		{Bus: BusIDE, Controller: 1, Unit: 0},
+		{Bus: BusSCSI, Controller: 0, Unit: 0},
+		{Bus: BusSCSI, Controller: 0, Unit: 1},
+	}
+	if !reflect.DeepEqual(d.Slots(), expectedSlots) {
+		t.Errorf("invalid slots: %v", d.Slots())
+	}
+
+	if last := d.LastUnit(BusSCSI, 0); last != 1 {
+		t.Errorf("invalid last unit: %d", last)
+	}
+	if last := d.LastUnit(BusSATA, 0); last != -1 {
+		t.Errorf("invalid last unit: %d", last)
+	}
+}
+
+func TestParseSlot(t *testing.T) {
+	slot, err := ParseSlot("SCSI0:8")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if slot != (Slot{Bus: BusSCSI, Controller: 0, Unit: 8}) {
+		t.Errorf("invalid slot: %#v", slot)
+	}
+	if slot.String() != "scsi0:8" || slot.ControllerName() != "scsi0" {
+		t.Errorf("invalid slot names: %s, %s", slot.String(), slot.ControllerName())
+	}
+
+	for _, s := range []string{"", "scsi0", "floppy0:0", "scsi0:1.present", "usb0:0"} {
+		if _, err := ParseSlot(s); err == nil {
+			t.Errorf("should error for %q", s)
+		}
+	}
+}
+
+func TestSlotValidate(t *testing.T) {
+	cases := []struct {
+		slot  Slot
+		valid bool
+	}{
+		{Slot{Bus: BusSCSI, Controller: 0, Unit: 6}, true},
+		{Slot{Bus: BusSCSI, Controller: 0, Unit: 7}, false},
+		{Slot{Bus: BusSCSI, Controller: 3, Unit: 15}, true},
+		{Slot{Bus: BusSCSI, Controller: 0, Unit: 16}, false},
+		{Slot{Bus: BusSCSI, Controller: 4, Unit: 0}, false},
+		{Slot{Bus: BusIDE, Controller: 1, Unit: 1}, true},
+		{Slot{Bus: BusIDE, Controller: 0, Unit: 2}, false},
+		{Slot{Bus: BusIDE, Controller: 2, Unit: 0}, false},
+		{Slot{Bus: BusSATA, Controller: 0, Unit: 29}, true},
+		{Slot{Bus: BusSATA, Controller: 0, Unit: 30}, false},
+		{Slot{Bus: BusNVMe, Controller: 0, Unit: 7}, true},
+		{Slot{Bus: BusNVMe, Controller: 0, Unit: 15}, false},
+	}
+
+	for _, tc := range cases {
+		if err := tc.slot.Validate(); (err == nil) != tc.valid {
+			t.Errorf("invalid validation of %s: %v", tc.slot, err)
+		}
+	}
+}
+
+func TestBootDisk(t *testing.T) {
+	vmxData := map[string]string{
+		"ide0:1.present":   "TRUE",
+		"ide0:1.filename":  "ide.vmdk",
+		"sata0:0.present":  "FALSE",
+		"sata0:0.filename": "sata.vmdk",
+		"scsi0:0.present":  "TRUE",
+		"scsi0:0.filename": "scsi.vmdk",
+		"nvme0:0.present":  "TRUE",
+		"nvme0:0.filename": "nvme.vmdk",
+	}
+	d := Decode(vmxData)
+
+	cases := map[string]string{
+		"":                  "scsi.vmdk",
+		"nvme0:0":           "nvme.vmdk",
+		"sata0:0, NVMe0:0":  "nvme.vmdk",
+		"invalid,ide0:1":    "ide.vmdk",
+		"scsi1:0,sata0:0":   "scsi.vmdk",
+		"scsi0:0,nvme0:0,,": "scsi.vmdk",
+	}
+	for hddOrder, expected := range cases {
+		disk, ok := d.BootDisk(hddOrder)
+		if !ok || disk.FileName != expected {
+			t.Errorf("%q: expected %s, got %#v", hddOrder, expected, disk)
+		}
+	}
+
+	// The first disk is the boot disk if no disk is at unit 0 of controller 0.
+	delete(vmxData, "scsi0:0.present")
+	delete(vmxData, "nvme0:0.present")
+	if disk, ok := Decode(vmxData).BootDisk(""); !ok || disk.FileName != "ide.vmdk" {
+		t.Errorf("expected ide.vmdk, got %#v", disk)
+	}
+
+	delete(vmxData, "ide0:1.present")
+	if disk, ok := Decode(vmxData).BootDisk(""); ok {
+		t.Errorf("disks that are not present should not be the boot disk: %#v", disk)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := Decode(testVMXData).Validate(); err != nil {
+		t.Fatalf("should not error: %s", err)
+	}
+
+	conflicts := map[string]string{
+		"scsi0:7.present":          "TRUE",
+		"scsi0:7.filename":         "disk-7.vmdk",
+		"ide0:2.present":           "TRUE",
+		"ide0:2.filename":          "disk-2.vmdk",
+		"sata0:0.present":          "TRUE",
+		"sata0:0.filename":         "disk-3.vmdk",
+		"scsi0:2.present":          "TRUE",
+		"scsi0:2.filename":         "disk.vmdk",
+		"ethernet0.addresstype":    "static",
+		"ethernet0.address":        "00:50:56:00:00:01",
+		"ethernet1.addresstype":    "static",
+		"ethernet1.address":        "00:50:56:00:00:01",
+		"scsi0:9.present":          "FALSE",
+		"scsi0:9.filename":         "disk.vmdk",
+		"ethernet0.connectiontype": "nat",
+	}
+	vmxData := make(map[string]string)
+	for k, v := range testVMXData {
+		vmxData[k] = v
+	}
+	for k, v := range conflicts {
+		vmxData[k] = v
+	}
+
+	err := Decode(vmxData).Validate()
+	if err == nil {
+		t.Fatal("should error")
+	}
+
+	expected := []string{
+		"scsi0:7: unit 7 is reserved",
+		"ide0:2: ide supports up to 2 units",
+		"sata0:0: controller sata0 is not present",
+		"scsi0:2: disk disk.vmdk is also attached to scsi0:0",
+		"ethernet1: MAC address 00:50:56:00:00:01 is also used by ethernet0",
+	}
+	for _, e := range expected {
+		if !strings.Contains(err.Error(), e) {
+			t.Errorf("error should contain %q: %s", e, err)
+		}
+	}
+	if strings.Contains(err.Error(), "scsi0:9") {
+		t.Errorf("devices that are not present should not conflict: %s", err)
+	}
+}
+
+func TestEncode(t *testing.T) {
+	vmxData := map[string]string{
+		"scsi0.virtualdev": "pvscsi",
+	}
+
+	Controller{Bus: BusSCSI, Number: 0, Present: true}.Encode(vmxData)
+	Disk{Slot: Slot{Bus: BusSCSI, Unit: 1}, Present: true, FileName: "disk-1.vmdk"}.Encode(vmxData)
+	CDROM{Slot: Slot{Bus: BusSATA, Unit: 0}, Present: true, FileName: "tools.iso", DeviceType: "cdrom-image"}.Encode(vmxData)
+
+	expected := map[string]string{
+		"scsi0.present":      "TRUE",
+		"scsi0.virtualdev":   "pvscsi",
+		"scsi0:1.present":    "TRUE",
+		"scsi0:1.filename":   "disk-1.vmdk",
+		"sata0:0.present":    "TRUE",
+		"sata0:0.filename":   "tools.iso",
+		"sata0:0.devicetype": "cdrom-image",
+	}
+	if !reflect.DeepEqual(vmxData, expected) {
+		t.Errorf("invalid data: %#v", vmxData)
+	}
+
+	decoded := Decode(vmxData)
+	if len(decoded.Disks) != 1 || len(decoded.CDROMs) != 1 {
+		t.Errorf("encoded devices should decode: %#v", decoded)
+	}
+}
+
+func TestRemove(t *testing.T) {
+	vmxData := map[string]string{
+		"ide1:0.present":  "TRUE",
+		"IDE1:0.fileName": "os.iso",
+		"ide1:1.present":  "TRUE",
+		"ide1.present":    "TRUE",
+	}
+
+	Remove(vmxData, "ide1:0")
+
+	expected := map[string]string{
+		"ide1:1.present": "TRUE",
+		"ide1.present":   "TRUE",
+	}
+	if !reflect.DeepEqual(vmxData, expected) {
+		t.Errorf("invalid data: %#v", vmxData)
+	}
+}
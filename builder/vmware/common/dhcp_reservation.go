@@ -0,0 +1,308 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// configFileLockTimeout is the maximum amount of time to wait for another
+	// build to release the lock on a host networking configuration file.
+	configFileLockTimeout = 30 * time.Second
+	// configFileLockInterval is the interval at which the lock is retried.
+	configFileLockInterval = 100 * time.Millisecond
+)
+
+// DhcpReservation is a fixed IP address reserved for a MAC address in the DHCP
+// configuration of a virtual network.
+type DhcpReservation struct {
+	// The name of the host declaration of the reservation.
+	Name string
+	// The path to the DHCP configuration file.
+	ConfPath string
+	// The virtual network device; for example, `vmnet8`.
+	Device string
+	// The reserved MAC address.
+	HardwareAddress net.HardwareAddr
+	// The reserved IP address.
+	Address net.IP
+}
+
+// dhcpReservationName returns the name of the host declaration for the MAC
+// address.
+func dhcpReservationName(hwaddr net.HardwareAddr) string {
+	return DefaultNamePrefix + "-" + strings.ReplaceAll(hwaddr.String(), ":", "")
+}
+
+// AddDhcpReservation reserves a free IP address of the subnet of the device for
+// the MAC address by appending a host declaration to the DHCP configuration
+// file at confPath. The DHCP service must be restarted for the reservation to
+// take effect.
+func AddDhcpReservation(confPath string, device string, hwaddr net.HardwareAddr) (*DhcpReservation, error) {
+	unlock, err := lockConfigFile(confPath)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	config, err := ReadDhcpConfig(confPath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading DHCP configuration: %s", err)
+	}
+
+	r := &DhcpReservation{
+		Name:            dhcpReservationName(hwaddr),
+		ConfPath:        confPath,
+		Device:          device,
+		HardwareAddress: hwaddr,
+	}
+
+	if _, err := config.HostByName(r.Name); err == nil {
+		return nil, fmt.Errorf("a DHCP reservation for %s already exists in %s", hwaddr, confPath)
+	}
+
+	r.Address, err = freeDhcpAddress(config, device)
+	if err != nil {
+		return nil, err
+	}
+
+	contents, err := os.ReadFile(confPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var b strings.Builder
+	b.Write(contents)
+	if len(contents) > 0 && !strings.HasSuffix(string(contents), "\n") {
+		b.WriteString("\n")
+	}
+	b.WriteString(configBlock(r.Name, r.declaration()))
+
+	log.Printf("[INFO] Reserving %s for %s in %s", r.Address, hwaddr, confPath)
+	if err := writeFileAtomic(confPath, []byte(b.String())); err != nil {
+		return nil, fmt.Errorf("error writing DHCP configuration: %s", err)
+	}
+
+	return r, nil
+}
+
+// Remove removes the host declaration of the reservation from the DHCP
+// configuration file. The DHCP service must be restarted for the removal to
+// take effect.
+func (r *DhcpReservation) Remove() error {
+	unlock, err := lockConfigFile(r.ConfPath)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	contents, err := os.ReadFile(r.ConfPath)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[INFO] Removing reservation of %s for %s from %s", r.Address, r.HardwareAddress, r.ConfPath)
+	if err := writeFileAtomic(r.ConfPath, []byte(removeConfigBlock(string(contents), r.Name))); err != nil {
+		return fmt.Errorf("error writing DHCP configuration: %s", err)
+	}
+	return nil
+}
+
+// declaration returns the host declaration of the reservation in the DHCP
+// configuration file format.
+func (r *DhcpReservation) declaration() string {
+	decl := pDeclaration{
+		id: pDeclarationHost{name: r.Name},
+		parameters: []pParameter{
+			pParameterHardware{class: "ethernet", address: r.HardwareAddress},
+			pParameterAddress4{r.Address.String()},
+		},
+	}
+	return decl.text("")
+}
+
+// freeDhcpAddress returns the first IP address of the subnet of the device that
+// is not in a dynamic range, not the address of the host or a router, and not
+// reserved by a host declaration.
+func freeDhcpAddress(config DhcpConfiguration, device string) (net.IP, error) {
+	host, err := config.HostByName(device)
+	if err != nil {
+		return nil, fmt.Errorf("unable to find the host declaration of %s: %s", device, err)
+	}
+
+	hostAddress, err := host.IP4()
+	if err != nil {
+		return nil, fmt.Errorf("unable to find the host address of %s: %s", device, err)
+	}
+
+	subnet, err := config.SubnetByAddress(hostAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	id, ok := subnet.id[0].(pDeclarationSubnet4)
+	if !ok {
+		return nil, fmt.Errorf("subnet of %s is not an IPv4 subnet", device)
+	}
+	network := id.IP.To4()
+	if network == nil {
+		return nil, fmt.Errorf("subnet of %s is not an IPv4 subnet", device)
+	}
+
+	used := map[string]bool{hostAddress.String(): true}
+	for _, router := range strings.Split(subnet.options["routers"], ",") {
+		if addr := net.ParseIP(strings.TrimSpace(router)); addr != nil {
+			used[addr.String()] = true
+		}
+	}
+	for _, decl := range config {
+		for _, p := range decl.address {
+			if addrs, ok := p.(pParameterAddress4); ok {
+				for _, addr := range addrs {
+					if ip := net.ParseIP(addr); ip != nil {
+						used[ip.String()] = true
+					}
+				}
+			}
+		}
+	}
+
+	var ranges []pParameterRange4
+	for _, p := range subnet.address {
+		if r, ok := p.(pParameterRange4); ok {
+			ranges = append(ranges, r)
+		}
+	}
+
+	ones, bits := id.Mask.Size()
+	first := binary.BigEndian.Uint32(network.Mask(id.Mask))
+	last := first | (1<<(bits-ones) - 1)
+	for n := first + 1; n < last; n++ {
+		addr := make(net.IP, net.IPv4len)
+		binary.BigEndian.PutUint32(addr, n)
+
+		if used[addr.String()] || inDhcpRanges(addr, ranges) {
+			continue
+		}
+		return addr, nil
+	}
+
+	return nil, fmt.Errorf("no free IP address found in subnet %s of %s", id.String(), device)
+}
+
+// inDhcpRanges reports whether the IP address is in one of the dynamic ranges.
+func inDhcpRanges(addr net.IP, ranges []pParameterRange4) bool {
+	n := binary.BigEndian.Uint32(addr.To4())
+	for _, r := range ranges {
+		minAddr, maxAddr := r.min.To4(), r.max.To4()
+		if minAddr == nil || maxAddr == nil {
+			continue
+		}
+		if n >= binary.BigEndian.Uint32(minAddr) && n <= binary.BigEndian.Uint32(maxAddr) {
+			return true
+		}
+	}
+	return false
+}
+
+// configBlock returns the contents enclosed by comments marking the beginning
+// and the end of the block with the name, so that the block can be removed
+// from a configuration file by removeConfigBlock.
+func configBlock(name string, contents string) string {
+	return fmt.Sprintf("# BEGIN %s\n%s# END %s\n", name, contents, name)
+}
+
+// removeConfigBlock removes the block with the name from the contents of a
+// configuration file.
+func removeConfigBlock(contents string, name string) string {
+	begin, end := "# BEGIN "+name, "# END "+name
+	var lines []string
+	var inBlock bool
+	for _, line := range strings.SplitAfter(contents, "\n") {
+		switch strings.TrimSpace(line) {
+		case begin:
+			inBlock = true
+			continue
+		case end:
+			inBlock = false
+			continue
+		}
+		if !inBlock {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "")
+}
+
+// lockConfigFile acquires an exclusive lock on a host networking configuration
+// file, such as the DHCP configuration file, so that concurrent builds do not
+// overwrite the changes of each other. It returns a function that releases the
+// lock.
+//
+// The lock file records the process ID of its owner. A lock left behind by a
+// process that is no longer running, such as a killed build, is broken. A lock
+// file without a process ID is broken once it is older than the lock timeout.
+// Otherwise, the lock file can be removed by hand if no build is running.
+func lockConfigFile(confPath string) (func(), error) {
+	lockPath := confPath + ".packer-lock"
+	deadline := time.Now().Add(configFileLockTimeout)
+	for {
+		f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644) //nolint:gosec
+		if err == nil {
+			_, err = f.WriteString(strconv.Itoa(os.Getpid()))
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				_ = os.Remove(lockPath)
+				return nil, fmt.Errorf("error locking %s: %s", confPath, err)
+			}
+			return func() {
+				if err := os.Remove(lockPath); err != nil {
+					log.Printf("[WARN] Failed to remove configuration lock file: %s", err)
+				}
+			}, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("error locking %s: %s", confPath, err)
+		}
+		if configLockStale(lockPath) {
+			log.Printf("[WARN] Breaking stale configuration lock: %s", lockPath)
+			if err := os.Remove(lockPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return nil, fmt.Errorf("error removing stale configuration lock %s: %s", lockPath, err)
+			}
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for configuration lock: %s; remove it if no other build is running", lockPath)
+		}
+		time.Sleep(configFileLockInterval)
+	}
+}
+
+// configLockStale reports whether a configuration lock file was left behind by
+// a process that is no longer running.
+func configLockStale(lockPath string) bool {
+	data, err := os.ReadFile(lockPath)
+	if err != nil {
+		return false
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 {
+		// The owner may not have written its process ID yet.
+		info, err := os.Stat(lockPath)
+		return err == nil && time.Since(info.ModTime()) > configFileLockTimeout
+	}
+	return !processRunning(pid)
+}
@@ -0,0 +1,181 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testDhcpConfig(t *testing.T, contents string) string {
+	confPath := filepath.Join(t.TempDir(), "dhcpd.conf")
+	if err := os.WriteFile(confPath, []byte(contents), 0644); err != nil { //nolint:gosec
+		t.Fatalf("err: %s", err)
+	}
+	return confPath
+}
+
+func TestAddDhcpReservation(t *testing.T) {
+	original, err := os.ReadFile(filepath.Join("testdata", "dhcpd-example.conf"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	confPath := testDhcpConfig(t, string(original))
+
+	hwaddr, _ := net.ParseMAC("00:50:56:00:00:02")
+	r, err := AddDhcpReservation(confPath, "vmnet8", hwaddr)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The host and router addresses are skipped.
+	assert.Equal(t, "172.33.33.3", r.Address.String())
+	assert.Equal(t, "packer-005056000002", r.Name)
+
+	config, err := ReadDhcpConfig(confPath)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	host, err := config.HostByName(r.Name)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	addr, err := host.IP4()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assert.Equal(t, "172.33.33.3", addr.String())
+	hw, err := host.Hardware()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assert.Equal(t, hwaddr, hw)
+
+	if _, err := AddDhcpReservation(confPath, "vmnet8", hwaddr); err == nil {
+		t.Fatal("should error for an existing reservation")
+	}
+
+	other, _ := net.ParseMAC("00:50:56:00:00:03")
+	r2, err := AddDhcpReservation(confPath, "vmnet8", other)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assert.Equal(t, "172.33.33.4", r2.Address.String())
+
+	if err := r.Remove(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := r2.Remove(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	contents, err := os.ReadFile(confPath)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assert.Equal(t, string(original), string(contents), "reservations should be removed")
+
+	_, err = os.Stat(confPath + ".packer-lock")
+	assert.True(t, os.IsNotExist(err), "lock file should be removed")
+}
+
+func TestAddDhcpReservation_exhausted(t *testing.T) {
+	confPath := testDhcpConfig(t, `subnet 10.0.0.0 netmask 255.255.255.248 {
+	range 10.0.0.4 10.0.0.6;
+}
+host vmnet2 {
+	hardware ethernet 00:50:56:c0:00:02;
+	fixed-address 10.0.0.1;
+}`)
+
+	var addrs []string
+	for _, mac := range []string{"00:50:56:00:00:01", "00:50:56:00:00:02"} {
+		hwaddr, _ := net.ParseMAC(mac)
+		r, err := AddDhcpReservation(confPath, "vmnet2", hwaddr)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		addrs = append(addrs, r.Address.String())
+	}
+	assert.Equal(t, []string{"10.0.0.2", "10.0.0.3"}, addrs)
+
+	hwaddr, _ := net.ParseMAC("00:50:56:00:00:03")
+	if _, err := AddDhcpReservation(confPath, "vmnet2", hwaddr); err == nil {
+		t.Fatal("should error when no address is free")
+	}
+}
+
+func TestAddDhcpReservation_unknownDevice(t *testing.T) {
+	confPath := testDhcpConfig(t, `subnet 10.0.0.0 netmask 255.255.255.0 {
+}`)
+
+	hwaddr, _ := net.ParseMAC("00:50:56:00:00:01")
+	if _, err := AddDhcpReservation(confPath, "vmnet2", hwaddr); err == nil {
+		t.Fatal("should error without a host declaration for the device")
+	}
+}
+
+func TestLockConfigFile_stale(t *testing.T) {
+	confPath := testDhcpConfig(t, "")
+	lockPath := confPath + ".packer-lock"
+
+	// A process ID beyond the maximum on supported hosts is never running.
+	if err := os.WriteFile(lockPath, []byte("2147483647"), 0644); err != nil { //nolint:gosec
+		t.Fatalf("err: %s", err)
+	}
+
+	unlock, err := lockConfigFile(confPath)
+	if err != nil {
+		t.Fatalf("should break a stale lock: %s", err)
+	}
+
+	contents, err := os.ReadFile(lockPath)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assert.Equal(t, strconv.Itoa(os.Getpid()), string(contents), "lock file should record the process ID")
+
+	unlock()
+	_, err = os.Stat(lockPath)
+	assert.True(t, os.IsNotExist(err), "lock file should be removed")
+}
+
+func TestLockConfigFile_staleWithoutProcessID(t *testing.T) {
+	confPath := testDhcpConfig(t, "")
+	lockPath := confPath + ".packer-lock"
+
+	if err := os.WriteFile(lockPath, nil, 0644); err != nil { //nolint:gosec
+		t.Fatalf("err: %s", err)
+	}
+	old := time.Now().Add(-2 * configFileLockTimeout)
+	if err := os.Chtimes(lockPath, old, old); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	unlock, err := lockConfigFile(confPath)
+	if err != nil {
+		t.Fatalf("should break an old lock without a process ID: %s", err)
+	}
+	unlock()
+}
+
+func TestConfigLockStale_running(t *testing.T) {
+	lockPath := filepath.Join(t.TempDir(), "dhcpd.conf.packer-lock")
+	if err := os.WriteFile(lockPath, []byte(strconv.Itoa(os.Getpid())), 0644); err != nil { //nolint:gosec
+		t.Fatalf("err: %s", err)
+	}
+	assert.False(t, configLockStale(lockPath), "lock of a running process should not be stale")
+
+	if err := os.WriteFile(lockPath, nil, 0644); err != nil { //nolint:gosec
+		t.Fatalf("err: %s", err)
+	}
+	assert.False(t, configLockStale(lockPath), "new lock without a process ID should not be stale")
+}
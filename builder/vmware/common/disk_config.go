@@ -7,13 +7,23 @@
 package common
 
 import (
+	"fmt"
+
 	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
 )
 
 type DiskConfig struct {
 	// The size(s) of additional virtual hard disks in MB. If not specified,
 	// the virtual machine will contain only a primary hard disk.
+	//
+	// ~> **Note:** This option is deprecated. Use `disk` blocks instead; each
+	// size is equivalent to a `disk` block with the `size` set.
 	AdditionalDiskSize []uint `mapstructure:"disk_additional_size" required:"false"`
+	// Additional virtual disks to create and attach to the virtual machine.
+	// Each disk sets its own size, name, adapter type, controller, disk type,
+	// and mode. Refer to the [additional disk configuration](#additional-disk-configuration)
+	// for more information.
+	AdditionalDisks []AdditionalDiskConfig `mapstructure:"disk" required:"false"`
 	// The adapter type for additional virtual disk(s). Available options
 	//  are `ide`, `sata`, `nvme`, or `scsi`.
 	//
@@ -58,5 +68,34 @@ func (c *DiskConfig) Prepare(ctx *interpolate.Context) []error {
 		c.DiskAdapterType = defaultDiskAdapterType
 	}
 
+	if len(c.AdditionalDiskSize) > 0 {
+		if len(c.AdditionalDisks) > 0 {
+			errs = append(errs, fmt.Errorf("'disk_additional_size' and 'disk' cannot be used together"))
+		} else {
+			for _, size := range c.AdditionalDiskSize {
+				c.AdditionalDisks = append(c.AdditionalDisks, AdditionalDiskConfig{Size: size})
+			}
+			c.AdditionalDiskSize = nil
+		}
+	}
+
+	names := map[string]int{c.DiskName: -1}
+	for i := range c.AdditionalDisks {
+		for _, err := range c.AdditionalDisks[i].Prepare(i, c.DiskName) {
+			errs = append(errs, fmt.Errorf("disk[%d]: %s", i, err))
+		}
+
+		name := c.AdditionalDisks[i].Name
+		if other, ok := names[name]; ok {
+			if other < 0 {
+				errs = append(errs, fmt.Errorf("disk[%d]: 'name' %s is used by the primary disk", i, name))
+			} else {
+				errs = append(errs, fmt.Errorf("disk[%d]: 'name' %s is also used by disk[%d]", i, name, other))
+			}
+			continue
+		}
+		names[name] = i
+	}
+
 	return errs
 }
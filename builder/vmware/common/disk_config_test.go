@@ -0,0 +1,68 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiskConfigPrepare(t *testing.T) {
+	c := new(DiskConfig)
+	if errs := c.Prepare(nil); len(errs) > 0 {
+		t.Fatalf("bad: %v", errs)
+	}
+	assert.Equal(t, defaultDiskName, c.DiskName)
+	assert.Equal(t, defaultDiskAdapterType, c.DiskAdapterType)
+	assert.Empty(t, c.AdditionalDisks)
+}
+
+func TestDiskConfigPrepare_additionalDiskSize(t *testing.T) {
+	c := &DiskConfig{AdditionalDiskSize: []uint{1024, 2048}}
+	if errs := c.Prepare(nil); len(errs) > 0 {
+		t.Fatalf("bad: %v", errs)
+	}
+	assert.Nil(t, c.AdditionalDiskSize)
+	assert.Equal(t, []AdditionalDiskConfig{
+		{Size: 1024, Name: "disk-1"},
+		{Size: 2048, Name: "disk-2"},
+	}, c.AdditionalDisks)
+
+	// The disk numbers skip 7, as in earlier versions of the plugin.
+	c = &DiskConfig{AdditionalDiskSize: []uint{1, 2, 3, 4, 5, 6, 7, 8}}
+	if errs := c.Prepare(nil); len(errs) > 0 {
+		t.Fatalf("bad: %v", errs)
+	}
+	var names []string
+	for _, disk := range c.AdditionalDisks {
+		names = append(names, disk.Name)
+	}
+	assert.Equal(t, []string{"disk-1", "disk-2", "disk-3", "disk-4", "disk-5", "disk-6", "disk-8", "disk-9"}, names)
+
+	c = &DiskConfig{
+		AdditionalDiskSize: []uint{1024},
+		AdditionalDisks:    []AdditionalDiskConfig{{Size: 1024}},
+	}
+	if errs := c.Prepare(nil); len(errs) != 1 {
+		t.Fatalf("expected an error, got: %v", errs)
+	}
+}
+
+func TestDiskConfigPrepare_duplicateNames(t *testing.T) {
+	c := &DiskConfig{
+		AdditionalDisks: []AdditionalDiskConfig{
+			{Size: 1024, Name: "data"},
+			{Size: 1024, Name: "data"},
+			{Size: 1024, Name: "disk"},
+		},
+	}
+	errs := c.Prepare(nil)
+	if len(errs) != 2 {
+		t.Fatalf("expected 2 errors, got: %v", errs)
+	}
+	assert.EqualError(t, errs[0], "disk[1]: 'name' data is also used by disk[0]")
+	assert.EqualError(t, errs[1], "disk[2]: 'name' disk is used by the primary disk")
+}
@@ -0,0 +1,74 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package diskimage writes the contents of a virtual disk as a QCOW2, raw, or
+// VHDX disk image.
+package diskimage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// The disk image formats.
+const (
+	FormatQcow2 = "qcow2"
+	FormatRaw   = "raw"
+	FormatVHDX  = "vhdx"
+)
+
+// Formats is the list of the disk image formats.
+var Formats = []string{FormatQcow2, FormatRaw, FormatVHDX}
+
+// Progress is called with the number of bytes of the source that have been
+// written to the disk image.
+type Progress func(written int64)
+
+// Write writes the contents of the source with the capacity in bytes to the
+// file as a disk image in the format. Ranges of the source that contain only
+// zeros are not allocated in the disk image. The progress, if any, is called
+// as the source is written.
+func Write(f *os.File, format string, src io.ReaderAt, capacity int64, progress Progress) error {
+	if capacity <= 0 {
+		return errors.New("capacity must be greater than zero")
+	}
+	if progress == nil {
+		progress = func(int64) {}
+	}
+
+	switch format {
+	case FormatQcow2:
+		return writeQcow2(f, src, capacity, progress)
+	case FormatRaw:
+		return writeRaw(f, src, capacity, progress)
+	case FormatVHDX:
+		return writeVHDX(f, src, capacity, progress)
+	default:
+		return fmt.Errorf("unsupported disk image format: %s", format)
+	}
+}
+
+// readChunk reads the chunk of the source at the offset into the buffer,
+// which is filled with zeros past the capacity. It reports whether the chunk
+// contains only zeros.
+func readChunk(src io.ReaderAt, buf []byte, offset int64, capacity int64) (bool, error) {
+	n := int(min(int64(len(buf)), capacity-offset))
+	if read, err := src.ReadAt(buf[:n], offset); err != nil && !(err == io.EOF && read == n) {
+		return false, fmt.Errorf("error reading source at offset %d: %s", offset, err)
+	}
+	clear(buf[n:])
+	return isZero(buf), nil
+}
+
+// isZero reports whether the buffer contains only zeros.
+func isZero(buf []byte) bool {
+	for _, b := range buf {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
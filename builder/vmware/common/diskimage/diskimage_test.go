@@ -0,0 +1,181 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+package diskimage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testImage returns an image with random data in some ranges and zeros in
+// others. The size is not a multiple of the block size of any format.
+func testImage() []byte {
+	image := make([]byte, 70*1024*1024+3*512)
+	r := rand.New(rand.NewPCG(1, 2))
+	for _, start := range []int{0, 5 * 1024 * 1024, 40*1024*1024 + 100, len(image) - 4096} {
+		for j := start; j < start+4096; j++ {
+			image[j] = byte(r.UintN(255) + 1)
+		}
+	}
+	return image
+}
+
+// testWrite writes the image in the format and returns the contents of the
+// file.
+func testWrite(t *testing.T, format string, image []byte) []byte {
+	path := filepath.Join(t.TempDir(), "disk."+format)
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer f.Close()
+
+	var written int64
+	progress := func(n int64) {
+		assert.GreaterOrEqual(t, n, written)
+		written = n
+	}
+	if err := Write(f, format, bytes.NewReader(image), int64(len(image)), progress); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assert.Equal(t, int64(len(image)), written)
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	return contents
+}
+
+func TestWrite_raw(t *testing.T) {
+	image := testImage()
+	assert.Equal(t, image, testWrite(t, FormatRaw, image))
+}
+
+func TestWrite_qcow2(t *testing.T) {
+	image := testImage()
+	contents := testWrite(t, FormatQcow2, image)
+
+	var h qcow2Header
+	if err := binary.Read(bytes.NewReader(contents), binary.BigEndian, &h); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assert.Equal(t, qcow2Magic, h.Magic)
+	assert.Equal(t, uint32(3), h.Version)
+	assert.Equal(t, uint64(len(image)), h.Size)
+	assert.Equal(t, uint32(qcow2HeaderLength), h.HeaderLength)
+	assert.Zero(t, len(contents)%qcow2ClusterSize)
+
+	// Read the image through the L1 and L2 tables.
+	read := make([]byte, len(image))
+	allocated := 0
+	for i := uint64(0); i < uint64(h.L1Size); i++ {
+		l1 := binary.BigEndian.Uint64(contents[h.L1TableOffset+i*8:])
+		if l1 == 0 {
+			continue
+		}
+		assert.NotZero(t, l1&qcow2OflagCopied)
+		l2Offset := l1 &^ qcow2OflagCopied
+		for j := uint64(0); j < qcow2L2Entries; j++ {
+			l2 := binary.BigEndian.Uint64(contents[l2Offset+j*8:])
+			if l2 == 0 {
+				continue
+			}
+			allocated++
+			offset := (i*qcow2L2Entries + j) * qcow2ClusterSize
+			cluster := l2 &^ qcow2OflagCopied
+			copy(read[offset:], contents[cluster:cluster+qcow2ClusterSize])
+		}
+	}
+	assert.Equal(t, image, read)
+	assert.Equal(t, 5, allocated)
+
+	// Every cluster of the image has a refcount of one.
+	clusters := len(contents) / qcow2ClusterSize
+	for i := 0; i < clusters; i++ {
+		block := binary.BigEndian.Uint64(contents[h.RefcountTableOffset+uint64(i/qcow2RefcountEntries)*8:])
+		refcount := binary.BigEndian.Uint16(contents[block+uint64(i%qcow2RefcountEntries)*2:])
+		assert.Equal(t, uint16(1), refcount, "cluster %d", i)
+	}
+}
+
+func TestWrite_vhdx(t *testing.T) {
+	image := testImage()
+	contents := testWrite(t, FormatVHDX, image)
+
+	assert.Equal(t, "vhdxfile", string(contents[:8]))
+
+	for _, offset := range []int{vhdxHeader1Offset, vhdxHeader2Offset} {
+		header := bytes.Clone(contents[offset : offset+vhdxHeaderSize])
+		assert.Equal(t, "head", string(header[:4]))
+		checksum := binary.LittleEndian.Uint32(header[4:])
+		binary.LittleEndian.PutUint32(header[4:], 0)
+		assert.Equal(t, crc32.Checksum(header, crc32c), checksum)
+	}
+
+	regions := bytes.Clone(contents[vhdxRegionTable1Offset : vhdxRegionTable1Offset+vhdxRegionTableSize])
+	assert.Equal(t, "regi", string(regions[:4]))
+	checksum := binary.LittleEndian.Uint32(regions[4:])
+	binary.LittleEndian.PutUint32(regions[4:], 0)
+	assert.Equal(t, crc32.Checksum(regions, crc32c), checksum)
+	assert.Equal(t, contents[vhdxRegionTable1Offset:vhdxRegionTable1Offset+vhdxRegionTableSize],
+		contents[vhdxRegionTable2Offset:vhdxRegionTable2Offset+vhdxRegionTableSize])
+
+	// Read the metadata items.
+	metadata := contents[vhdxMetadataOffset : vhdxMetadataOffset+vhdxMetadataLength]
+	assert.Equal(t, "metadata", string(metadata[:8]))
+	items := make(map[[16]byte][]byte)
+	for i := 0; i < int(binary.LittleEndian.Uint16(metadata[10:])); i++ {
+		entry := metadata[32+i*32:]
+		var guid [16]byte
+		copy(guid[:], entry)
+		offset := binary.LittleEndian.Uint32(entry[16:])
+		length := binary.LittleEndian.Uint32(entry[20:])
+		items[guid] = metadata[offset : offset+length]
+	}
+	assert.Equal(t, uint32(vhdxBlockSize), binary.LittleEndian.Uint32(items[vhdxFileParametersGUID]))
+	assert.Equal(t, uint64(len(image)), binary.LittleEndian.Uint64(items[vhdxVirtualDiskSizeGUID]))
+	assert.Equal(t, uint32(512), binary.LittleEndian.Uint32(items[vhdxLogicalSectorSizeGUID]))
+
+	// Read the image through the BAT.
+	read := make([]byte, len(image))
+	blocks := (len(image) + vhdxBlockSize - 1) / vhdxBlockSize
+	allocated := 0
+	for i := 0; i < blocks; i++ {
+		entry := binary.LittleEndian.Uint64(contents[vhdxBATOffset+(i+i/vhdxChunkRatio)*8:])
+		if entry&7 != vhdxPayloadBlockFullyPresent {
+			assert.Zero(t, entry)
+			continue
+		}
+		allocated++
+		offset := (entry >> 20) * vhdxMB
+		copy(read[i*vhdxBlockSize:], contents[offset:offset+vhdxBlockSize])
+	}
+	assert.Equal(t, image, read)
+	assert.Equal(t, 3, allocated)
+}
+
+func TestWrite_unsupported(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "disk.img"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer f.Close()
+
+	assert.Error(t, Write(f, "vhd", bytes.NewReader(nil), 1024, nil))
+	assert.Error(t, Write(f, FormatRaw, bytes.NewReader(nil), 0, nil))
+}
+
+func TestVHDXGUID(t *testing.T) {
+	guid := vhdxGUID("2DC27766-F623-4200-9D64-115E9BFD4A08")
+	assert.Equal(t, []byte{0x66, 0x77, 0xc2, 0x2d, 0x23, 0xf6, 0x00, 0x42, 0x9d, 0x64, 0x11, 0x5e, 0x9b, 0xfd, 0x4a, 0x08}, guid[:])
+}
@@ -0,0 +1,200 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+package diskimage
+
+import (
+	"encoding/binary"
+	"io"
+	"os"
+)
+
+const (
+	// qcow2Magic is the magic number of a QCOW2 image ("QFI\xfb").
+	qcow2Magic uint32 = 0x514649fb
+	// qcow2Version is the version of the QCOW2 images that are written.
+	qcow2Version = 3
+	// qcow2HeaderLength is the length of the version 3 header.
+	qcow2HeaderLength = 104
+
+	qcow2ClusterBits = 16
+	qcow2ClusterSize = 1 << qcow2ClusterBits
+	// qcow2RefcountOrder is the order of the width of a refcount in bits.
+	qcow2RefcountOrder = 4
+	// qcow2L2Entries is the number of entries in an L2 table.
+	qcow2L2Entries = qcow2ClusterSize / 8
+	// qcow2RefcountEntries is the number of entries in a refcount block.
+	qcow2RefcountEntries = qcow2ClusterSize * 8 / (1 << qcow2RefcountOrder)
+
+	// qcow2OflagCopied indicates that the refcount of the cluster of an L1 or
+	// L2 entry is exactly one.
+	qcow2OflagCopied uint64 = 1 << 63
+)
+
+// qcow2Header is the header of a version 3 QCOW2 image.
+type qcow2Header struct {
+	Magic                 uint32
+	Version               uint32
+	BackingFileOffset     uint64
+	BackingFileSize       uint32
+	ClusterBits           uint32
+	Size                  uint64
+	CryptMethod           uint32
+	L1Size                uint32
+	L1TableOffset         uint64
+	RefcountTableOffset   uint64
+	RefcountTableClusters uint32
+	NbSnapshots           uint32
+	SnapshotsOffset       uint64
+	IncompatibleFeatures  uint64
+	CompatibleFeatures    uint64
+	AutoclearFeatures     uint64
+	RefcountOrder         uint32
+	HeaderLength          uint32
+}
+
+// writeQcow2 writes the source as a QCOW2 image. The header is followed by the
+// allocated data clusters, in order, and the metadata is written after the
+// data, so the source is read once.
+func writeQcow2(f *os.File, src io.ReaderAt, capacity int64, progress Progress) error {
+	numClusters := (capacity + qcow2ClusterSize - 1) / qcow2ClusterSize
+	l1Size := (numClusters + qcow2L2Entries - 1) / qcow2L2Entries
+
+	// The data clusters follow the header cluster.
+	l2 := make([]uint64, l1Size*qcow2L2Entries)
+	next := int64(1)
+
+	buf := make([]byte, qcow2ClusterSize)
+	for i := int64(0); i < numClusters; i++ {
+		offset := i * qcow2ClusterSize
+		zero, err := readChunk(src, buf, offset, capacity)
+		if err != nil {
+			return err
+		}
+		if !zero {
+			if _, err := f.WriteAt(buf, next*qcow2ClusterSize); err != nil {
+				return err
+			}
+			l2[i] = uint64(next*qcow2ClusterSize) | qcow2OflagCopied
+			next++
+		}
+		progress(min(offset+qcow2ClusterSize, capacity))
+	}
+
+	// The L2 tables that have an allocated entry follow the data clusters,
+	// followed by the L1 table.
+	l1 := make([]uint64, l1Size)
+	for i := range l1 {
+		table := l2[int64(i)*qcow2L2Entries : int64(i+1)*qcow2L2Entries]
+		if isZeroTable(table) {
+			continue
+		}
+		if err := writeTable(f, next*qcow2ClusterSize, table); err != nil {
+			return err
+		}
+		l1[i] = uint64(next*qcow2ClusterSize) | qcow2OflagCopied
+		next++
+	}
+
+	l1Offset := next * qcow2ClusterSize
+	if err := writeTable(f, l1Offset, l1); err != nil {
+		return err
+	}
+	next += (l1Size*8 + qcow2ClusterSize - 1) / qcow2ClusterSize
+
+	// The refcount blocks and the refcount table follow the L1 table. Every
+	// cluster of the image is used once, including the clusters of the
+	// refcount blocks and the refcount table.
+	var blocks, tableClusters int64
+	for {
+		total := next + blocks + tableClusters
+		b := (total + qcow2RefcountEntries - 1) / qcow2RefcountEntries
+		t := (b*8 + qcow2ClusterSize - 1) / qcow2ClusterSize
+		if b == blocks && t == tableClusters {
+			break
+		}
+		blocks, tableClusters = b, t
+	}
+	total := next + blocks + tableClusters
+
+	refcountTable := make([]uint64, tableClusters*qcow2ClusterSize/8)
+	block := make([]byte, qcow2ClusterSize)
+	for i := int64(0); i < blocks; i++ {
+		clear(block)
+		for j := int64(0); j < qcow2RefcountEntries && i*qcow2RefcountEntries+j < total; j++ {
+			binary.BigEndian.PutUint16(block[j*2:], 1)
+		}
+		blockOffset := (next + i) * qcow2ClusterSize
+		if _, err := f.WriteAt(block, blockOffset); err != nil {
+			return err
+		}
+		refcountTable[i] = uint64(blockOffset)
+	}
+
+	refcountTableOffset := (next + blocks) * qcow2ClusterSize
+	if err := writeTable(f, refcountTableOffset, refcountTable); err != nil {
+		return err
+	}
+
+	header := qcow2Header{
+		Magic:                 qcow2Magic,
+		Version:               qcow2Version,
+		ClusterBits:           qcow2ClusterBits,
+		Size:                  uint64(capacity),
+		L1Size:                uint32(l1Size),
+		L1TableOffset:         uint64(l1Offset),
+		RefcountTableOffset:   uint64(refcountTableOffset),
+		RefcountTableClusters: uint32(tableClusters),
+		RefcountOrder:         qcow2RefcountOrder,
+		HeaderLength:          qcow2HeaderLength,
+	}
+
+	// The header cluster includes the end of the header extensions, which is
+	// zero.
+	headerBuf := make([]byte, qcow2ClusterSize)
+	w := &bufWriter{buf: headerBuf}
+	if err := binary.Write(w, binary.BigEndian, &header); err != nil {
+		return err
+	}
+	if _, err := f.WriteAt(headerBuf, 0); err != nil {
+		return err
+	}
+
+	return f.Truncate(total * qcow2ClusterSize)
+}
+
+// isZeroTable reports whether the table has no allocated entries.
+func isZeroTable(table []uint64) bool {
+	for _, e := range table {
+		if e != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// writeTable writes the big-endian table at the offset.
+func writeTable(f *os.File, offset int64, table []uint64) error {
+	buf := make([]byte, len(table)*8)
+	for i, e := range table {
+		binary.BigEndian.PutUint64(buf[i*8:], e)
+	}
+	_, err := f.WriteAt(buf, offset)
+	return err
+}
+
+// bufWriter writes to the beginning of a buffer.
+type bufWriter struct {
+	buf []byte
+	n   int
+}
+
+func (w *bufWriter) Write(p []byte) (int, error) {
+	n := copy(w.buf[w.n:], p)
+	w.n += n
+	if n < len(p) {
+		return n, io.ErrShortWrite
+	}
+	return n, nil
+}
@@ -0,0 +1,36 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+package diskimage
+
+import (
+	"io"
+	"os"
+)
+
+// rawChunkSize is the size of the chunks in which the source is copied to a
+// raw disk image.
+const rawChunkSize = 1024 * 1024
+
+// writeRaw writes the source as a raw disk image. Chunks that contain only
+// zeros are not written, so the file is sparse on file systems that support
+// sparse files.
+func writeRaw(f *os.File, src io.ReaderAt, capacity int64, progress Progress) error {
+	buf := make([]byte, rawChunkSize)
+	for offset := int64(0); offset < capacity; offset += rawChunkSize {
+		zero, err := readChunk(src, buf, offset, capacity)
+		if err != nil {
+			return err
+		}
+		if !zero {
+			n := min(int64(len(buf)), capacity-offset)
+			if _, err := f.WriteAt(buf[:n], offset); err != nil {
+				return err
+			}
+		}
+		progress(min(offset+rawChunkSize, capacity))
+	}
+
+	return f.Truncate(capacity)
+}
@@ -0,0 +1,258 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+package diskimage
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"hash/crc32"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf16"
+)
+
+const (
+	vhdxMB = 1024 * 1024
+
+	// The offsets of the structures of the header section.
+	vhdxHeader1Offset      = 64 * 1024
+	vhdxHeader2Offset      = 128 * 1024
+	vhdxRegionTable1Offset = 192 * 1024
+	vhdxRegionTable2Offset = 256 * 1024
+
+	vhdxHeaderSize      = 4 * 1024
+	vhdxRegionTableSize = 64 * 1024
+
+	// The offsets and sizes of the log and the metadata region. The BAT
+	// follows the metadata region.
+	vhdxLogOffset      = 1 * vhdxMB
+	vhdxLogLength      = 1 * vhdxMB
+	vhdxMetadataOffset = 2 * vhdxMB
+	vhdxMetadataLength = 1 * vhdxMB
+	vhdxBATOffset      = 3 * vhdxMB
+
+	// vhdxMetadataItemsOffset is the offset of the metadata items in the
+	// metadata region.
+	vhdxMetadataItemsOffset = 64 * 1024
+
+	vhdxBlockSize          = 32 * vhdxMB
+	vhdxLogicalSectorSize  = 512
+	vhdxPhysicalSectorSize = 4096
+	// vhdxChunkRatio is the number of payload blocks described by a sector
+	// bitmap block.
+	vhdxChunkRatio = (1 << 23) * vhdxLogicalSectorSize / vhdxBlockSize
+
+	// vhdxPayloadBlockFullyPresent is the state of a BAT entry of an
+	// allocated payload block.
+	vhdxPayloadBlockFullyPresent = 6
+
+	// The flags of a metadata entry.
+	vhdxMetadataIsVirtualDisk = 1 << 1
+	vhdxMetadataIsRequired    = 1 << 2
+
+	vhdxCreator = "packer-plugin-vmware"
+)
+
+// The GUIDs of the regions and the metadata items.
+var (
+	vhdxBATGUID                = vhdxGUID("2DC27766-F623-4200-9D64-115E9BFD4A08")
+	vhdxMetadataGUID           = vhdxGUID("8B7CA206-4790-4B9A-B8FE-575F050F886E")
+	vhdxFileParametersGUID     = vhdxGUID("CAA16737-FA36-4D43-B3B6-33F0AA44E76B")
+	vhdxVirtualDiskSizeGUID    = vhdxGUID("2FA54224-CD1B-4876-B211-5DBED83BF4B8")
+	vhdxPage83DataGUID         = vhdxGUID("BECA12AB-B2E6-4523-93EF-C309E000C746")
+	vhdxLogicalSectorSizeGUID  = vhdxGUID("8141BF1D-A96F-4709-BA47-F233A8FAAB5F")
+	vhdxPhysicalSectorSizeGUID = vhdxGUID("CDA348C7-445D-4471-9CC9-E9885251C556")
+)
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+// writeVHDX writes the source as a dynamic VHDX image. The payload blocks
+// that contain only zeros are not allocated. The allocated payload blocks
+// follow the BAT, in order, and the BAT is written after the payload blocks,
+// so the source is read once.
+func writeVHDX(f *os.File, src io.ReaderAt, capacity int64, progress Progress) error {
+	// The capacity of a VHDX image is a multiple of the logical sector size.
+	capacity = (capacity + vhdxLogicalSectorSize - 1) / vhdxLogicalSectorSize * vhdxLogicalSectorSize
+
+	payloadBlocks := (capacity + vhdxBlockSize - 1) / vhdxBlockSize
+	batEntries := payloadBlocks + (payloadBlocks-1)/vhdxChunkRatio
+	batLength := (batEntries*8 + vhdxMB - 1) / vhdxMB * vhdxMB
+
+	if err := writeVHDXHeaders(f, batLength); err != nil {
+		return err
+	}
+	if err := writeVHDXMetadata(f, capacity); err != nil {
+		return err
+	}
+
+	// The sector bitmap entries of the BAT are not present, because the image
+	// does not have a parent.
+	bat := make([]byte, batLength)
+	next := int64(vhdxBATOffset) + batLength
+
+	buf := make([]byte, vhdxBlockSize)
+	for i := int64(0); i < payloadBlocks; i++ {
+		offset := i * vhdxBlockSize
+		zero, err := readChunk(src, buf, offset, capacity)
+		if err != nil {
+			return err
+		}
+		if !zero {
+			if _, err := f.WriteAt(buf, next); err != nil {
+				return err
+			}
+			entry := uint64(vhdxPayloadBlockFullyPresent) | uint64(next/vhdxMB)<<20
+			binary.LittleEndian.PutUint64(bat[(i+i/vhdxChunkRatio)*8:], entry)
+			next += vhdxBlockSize
+		}
+		progress(min(offset+vhdxBlockSize, capacity))
+	}
+
+	if _, err := f.WriteAt(bat, vhdxBATOffset); err != nil {
+		return err
+	}
+
+	return f.Truncate(next)
+}
+
+// writeVHDXHeaders writes the file type identifier, the headers, the region
+// tables, and the empty log.
+func writeVHDXHeaders(f *os.File, batLength int64) error {
+	identifier := make([]byte, vhdxHeader1Offset)
+	copy(identifier, "vhdxfile")
+	for i, c := range utf16.Encode([]rune(vhdxCreator)) {
+		binary.LittleEndian.PutUint16(identifier[8+i*2:], c)
+	}
+	if _, err := f.WriteAt(identifier, 0); err != nil {
+		return err
+	}
+
+	fileWriteGUID, err := randomGUID()
+	if err != nil {
+		return err
+	}
+	dataWriteGUID, err := randomGUID()
+	if err != nil {
+		return err
+	}
+
+	// The headers are identical, except for the sequence number. The log GUID
+	// is zero, because the log is empty.
+	for i, offset := range []int64{vhdxHeader1Offset, vhdxHeader2Offset} {
+		header := make([]byte, vhdxHeaderSize)
+		copy(header, "head")
+		binary.LittleEndian.PutUint64(header[8:], uint64(i+1))
+		copy(header[16:], fileWriteGUID[:])
+		copy(header[32:], dataWriteGUID[:])
+		binary.LittleEndian.PutUint16(header[64:], 0)
+		binary.LittleEndian.PutUint16(header[66:], 1)
+		binary.LittleEndian.PutUint32(header[68:], vhdxLogLength)
+		binary.LittleEndian.PutUint64(header[72:], vhdxLogOffset)
+		binary.LittleEndian.PutUint32(header[4:], crc32.Checksum(header, crc32c))
+		if _, err := f.WriteAt(header, offset); err != nil {
+			return err
+		}
+	}
+
+	regions := make([]byte, vhdxRegionTableSize)
+	copy(regions, "regi")
+	binary.LittleEndian.PutUint32(regions[8:], 2)
+	for i, region := range []struct {
+		guid   [16]byte
+		offset int64
+		length int64
+	}{
+		{vhdxBATGUID, vhdxBATOffset, batLength},
+		{vhdxMetadataGUID, vhdxMetadataOffset, vhdxMetadataLength},
+	} {
+		entry := regions[16+i*32:]
+		copy(entry, region.guid[:])
+		binary.LittleEndian.PutUint64(entry[16:], uint64(region.offset))
+		binary.LittleEndian.PutUint32(entry[24:], uint32(region.length))
+		binary.LittleEndian.PutUint32(entry[28:], 1)
+	}
+	binary.LittleEndian.PutUint32(regions[4:], crc32.Checksum(regions, crc32c))
+	for _, offset := range []int64{vhdxRegionTable1Offset, vhdxRegionTable2Offset} {
+		if _, err := f.WriteAt(regions, offset); err != nil {
+			return err
+		}
+	}
+
+	_, err = f.WriteAt(make([]byte, vhdxLogLength), vhdxLogOffset)
+	return err
+}
+
+// writeVHDXMetadata writes the metadata region of an image with the capacity.
+func writeVHDXMetadata(f *os.File, capacity int64) error {
+	page83, err := randomGUID()
+	if err != nil {
+		return err
+	}
+
+	fileParameters := make([]byte, 8)
+	binary.LittleEndian.PutUint32(fileParameters, vhdxBlockSize)
+	virtualDiskSize := binary.LittleEndian.AppendUint64(nil, uint64(capacity))
+	logicalSectorSize := binary.LittleEndian.AppendUint32(nil, vhdxLogicalSectorSize)
+	physicalSectorSize := binary.LittleEndian.AppendUint32(nil, vhdxPhysicalSectorSize)
+
+	items := []struct {
+		guid  [16]byte
+		flags uint32
+		data  []byte
+	}{
+		{vhdxFileParametersGUID, vhdxMetadataIsRequired, fileParameters},
+		{vhdxVirtualDiskSizeGUID, vhdxMetadataIsVirtualDisk | vhdxMetadataIsRequired, virtualDiskSize},
+		{vhdxPage83DataGUID, vhdxMetadataIsVirtualDisk | vhdxMetadataIsRequired, page83[:]},
+		{vhdxLogicalSectorSizeGUID, vhdxMetadataIsVirtualDisk | vhdxMetadataIsRequired, logicalSectorSize},
+		{vhdxPhysicalSectorSizeGUID, vhdxMetadataIsVirtualDisk | vhdxMetadataIsRequired, physicalSectorSize},
+	}
+
+	metadata := make([]byte, vhdxMetadataLength)
+	copy(metadata, "metadata")
+	binary.LittleEndian.PutUint16(metadata[10:], uint16(len(items)))
+
+	offset := vhdxMetadataItemsOffset
+	for i, item := range items {
+		entry := metadata[32+i*32:]
+		copy(entry, item.guid[:])
+		binary.LittleEndian.PutUint32(entry[16:], uint32(offset))
+		binary.LittleEndian.PutUint32(entry[20:], uint32(len(item.data)))
+		binary.LittleEndian.PutUint32(entry[24:], item.flags)
+		copy(metadata[offset:], item.data)
+		offset += len(item.data)
+	}
+
+	_, err = f.WriteAt(metadata, vhdxMetadataOffset)
+	return err
+}
+
+// vhdxGUID returns the on-disk representation of the GUID. The first three
+// fields of a GUID are stored in little-endian byte order.
+func vhdxGUID(s string) [16]byte {
+	b, err := hex.DecodeString(strings.ReplaceAll(s, "-", ""))
+	if err != nil || len(b) != 16 {
+		panic("invalid GUID: " + s)
+	}
+
+	var guid [16]byte
+	binary.LittleEndian.PutUint32(guid[0:], binary.BigEndian.Uint32(b[0:]))
+	binary.LittleEndian.PutUint16(guid[4:], binary.BigEndian.Uint16(b[4:]))
+	binary.LittleEndian.PutUint16(guid[6:], binary.BigEndian.Uint16(b[6:]))
+	copy(guid[8:], b[8:])
+	return guid
+}
+
+// randomGUID returns a random version 4 GUID.
+func randomGUID() ([16]byte, error) {
+	var guid [16]byte
+	if _, err := rand.Read(guid[:]); err != nil {
+		return guid, err
+	}
+	guid[7] = guid[7]&0x0f | 0x40
+	guid[8] = guid[8]&0x3f | 0x80
+	return guid, nil
+}
@@ -15,11 +15,13 @@ import (
 	"os/exec"
 	"regexp"
 	"runtime"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/hashicorp/go-version"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/vmware/packer-plugin-vmware/builder/vmware/common/diskimage"
 )
 
 const (
@@ -29,6 +31,9 @@ const (
 	// Artifact configuration keys.
 	artifactConfFormat     = "artifact.conf.format"
 	artifactConfSkipExport = "artifact.conf.skip_export"
+	// artifactStateFormatPrefix is the prefix of the artifact state keys for
+	// the files of each export format.
+	artifactStateFormatPrefix = "format."
 
 	// VMware Fusion.
 	fusionProductName     = "VMware Fusion"
@@ -85,11 +90,21 @@ const (
 
 	// Application binary names.
 	appOvfTool      = "ovftool"
+	appQemuImg      = "qemu-img"
 	appVdiskManager = "vmware-vdiskmanager"
 	appVmrun        = "vmrun"
 	appVmware       = "vmware"
 	appVmx          = "vmware-vmx"
 
+	// Network service binary names.
+	appVmnetCli         = "vmnet-cli"
+	appVmwareNetworking = "vmware-networking"
+
+	// dhcpVmnetService is the name of the DHCP service on Windows.
+	dhcpVmnetService = "VMnetDHCP"
+	// natVmnetService is the name of the NAT service on Windows.
+	natVmnetService = "VMware NAT Service"
+
 	// Version regular expressions.
 	productVersionRegex = `(?i)VMware [a-z0-9-]+ (\d+\.\d+\.\d+)`
 	ovfToolVersionRegex = `\d+\.\d+\.\d+`
@@ -142,6 +157,37 @@ const (
 	exportFormatOva = "ova"
 	// ExportFormatVmx defines the export format as "vmx" for Virtual Machine eXchange.
 	ExportFormatVmx = "vmx"
+	// exportFormatVagrant defines the export format as "vagrant" for a Vagrant box.
+	exportFormatVagrant = "vagrant"
+
+	// ExportEngineOvfTool defines the export engine as VMware OVF Tool.
+	ExportEngineOvfTool = "ovftool"
+	// ExportEngineNative defines the export engine as the built-in OVF exporter.
+	ExportEngineNative = "native"
+
+	// DiskConversionEngineNative defines the disk conversion engine as the
+	// built-in disk image writer.
+	DiskConversionEngineNative = "native"
+	// DiskConversionEngineQemuImg defines the disk conversion engine as QEMU
+	// disk image utility.
+	DiskConversionEngineQemuImg = "qemu-img"
+
+	// Checksum types of the checksum files of the artifact.
+	checksumTypeMd5    = "md5"
+	checksumTypeSha1   = "sha1"
+	checksumTypeSha256 = "sha256"
+	checksumTypeSha512 = "sha512"
+
+	// CommunicatorVMTools defines the communicator type that uses VMware Tools guest operations.
+	CommunicatorVMTools = "vmtools"
+
+	// Guest operations settings.
+	guestOpsDefaultTimeout = 5 * time.Minute
+	guestOpsPollInterval   = 5 * time.Second
+	guestOpsLinuxShell     = "/bin/sh"
+	guestOpsLinuxTempDir   = "/tmp"
+	guestOpsWindowsShell   = "C:\\Windows\\System32\\cmd.exe"
+	guestOpsWindowsTempDir = "C:\\Windows\\Temp"
 
 	// Tools mode constants
 	toolsModeUpload  = "upload"
@@ -189,7 +235,31 @@ var (
 var allowedExportFormats = []string{
 	ExportFormatOvf,
 	exportFormatOva,
+	exportFormatVagrant,
 	ExportFormatVmx,
+	diskimage.FormatQcow2,
+	diskimage.FormatRaw,
+	diskimage.FormatVHDX,
+}
+
+// The allowed export engines for a virtual machine.
+var allowedExportEngines = []string{
+	ExportEngineOvfTool,
+	ExportEngineNative,
+}
+
+// The allowed disk conversion engines for a virtual machine.
+var allowedDiskConversionEngines = []string{
+	DiskConversionEngineNative,
+	DiskConversionEngineQemuImg,
+}
+
+// The allowed checksum types for the checksum files of the artifact.
+var allowedChecksumTypes = []string{
+	checksumTypeMd5,
+	checksumTypeSha1,
+	checksumTypeSha256,
+	checksumTypeSha512,
 }
 
 // The allowed firmware types for a virtual machine.
@@ -262,6 +332,9 @@ var skipCleanFileExtensions = []string{
 // The product version.
 var productVersion = regexp.MustCompile(productVersionRegex)
 
+// The exit code of a guest program reported by vmrun.
+var guestProgramExitCode = regexp.MustCompile(`non-zero exit code: (-?\d+)`)
+
 // The VMware OVF Tool version.
 var ovfToolVersion = regexp.MustCompile(ovfToolVersionRegex)
 
@@ -271,16 +344,46 @@ type Driver interface {
 	// Clone duplicates the source virtual machine to the destination, using the specified clone type and snapshot.
 	Clone(dst string, src string, cloneType bool, snapshot string) error
 
-	// CompactDisk compacts the specified virtual disk to reclaim unused space on the virtual machine.
-	CompactDisk(string) error
+	// CompactDisk compacts the specified virtual disk to reclaim unused space on the virtual machine, reporting the
+	// progress of the compaction. It returns ErrCompactDiskSkipped if the virtual disk manager is not available.
+	CompactDisk(string, DiskProgress) error
 
-	// CreateDisk creates a virtual disk with specified path, size, adapter type, and disk type.
-	CreateDisk(string, string, string, string) error
+	// CreateDisk creates a virtual disk with specified path, size, adapter type, and disk type, reporting the progress
+	// of the creation.
+	CreateDisk(string, string, string, string, DiskProgress) error
+
+	// ExpandDisk expands the specified virtual disk to the specified size.
+	ExpandDisk(string, string) error
 
 	// CreateSnapshot creates a snapshot of the virtual machine specified by its path and assigns it the given snapshot
 	// name.
 	CreateSnapshot(string, string) error
 
+	// ListSnapshots returns the names of the snapshots of the virtual machine specified by its path.
+	ListSnapshots(string) ([]string, error)
+
+	// RevertToSnapshot reverts the virtual machine specified by its path to the snapshot with the given name.
+	RevertToSnapshot(string, string) error
+
+	// DeleteSnapshot deletes the snapshot with the given name from the virtual machine specified by its path.
+	DeleteSnapshot(string, string) error
+
+	// RunProgramInGuest runs a program with arguments in the guest operating system of the virtual machine specified
+	// by its path, using the given guest credentials, and returns the exit code of the program.
+	RunProgramInGuest(string, GuestCredentials, string, ...string) (int, error)
+
+	// CopyFileFromHostToGuest copies a file from the host to the guest operating system of the virtual machine
+	// specified by its path, using the given guest credentials.
+	CopyFileFromHostToGuest(string, GuestCredentials, string, string) error
+
+	// CopyFileFromGuestToHost copies a file from the guest operating system of the virtual machine specified by its
+	// path to the host, using the given guest credentials.
+	CopyFileFromGuestToHost(string, GuestCredentials, string, string) error
+
+	// DirectoryExistsInGuest checks if a directory exists in the guest operating system of the virtual machine
+	// specified by its path, using the given guest credentials.
+	DirectoryExistsInGuest(string, GuestCredentials, string) (bool, error)
+
 	// IsRunning checks if the specified virtual machine is currently running.
 	IsRunning(string) (bool, error)
 
@@ -368,12 +471,64 @@ func NewDriver(dconfig *DriverConfig, config *SSHConfig, vmName string) (Driver,
 	return nil, fmt.Errorf("driver initialization failed. fix at least one driver to continue:\n%s", errs)
 }
 
+// guestOpsArgs returns the vmrun arguments for a guest operation on the
+// virtual machine, authenticated with the guest credentials.
+func guestOpsArgs(hostType string, vmxPath string, credentials GuestCredentials, operation string, args ...string) []string {
+	return append([]string{
+		"-T", hostType,
+		"-gu", credentials.Username,
+		"-gp", credentials.Password,
+		operation, vmxPath,
+	}, args...)
+}
+
+// runGuestProgram runs the vmrun command for a guest program and returns the
+// exit code of the guest program. A non-zero exit code of the guest program
+// is not an error.
+func runGuestProgram(cmd *exec.Cmd) (int, error) {
+	_, _, err := runAndLog(cmd)
+	if err == nil {
+		return 0, nil
+	}
+
+	if matches := guestProgramExitCode.FindStringSubmatch(err.Error()); matches != nil {
+		code, convErr := strconv.Atoi(matches[1])
+		if convErr == nil {
+			return code, nil
+		}
+	}
+
+	return 0, err
+}
+
+// parseDirectoryExists parses the output of `vmrun directoryExistsInGuest`.
+func parseDirectoryExists(stdout string) bool {
+	return strings.Contains(stdout, "The directory exists")
+}
+
+// redactArgs returns the command arguments with the guest password redacted.
+func redactArgs(args []string) []string {
+	redacted := make([]string, len(args))
+	copy(redacted, args)
+	for i := 0; i < len(redacted)-1; i++ {
+		if redacted[i] == "-gp" {
+			redacted[i+1] = "<sensitive>"
+		}
+	}
+	return redacted
+}
+
 // runAndLog executes the given command, logs its execution, and returns its stdout, stderr, and any encountered error.
+// If the command has a stdout writer, the stdout is also written to it as the command runs.
 func runAndLog(cmd *exec.Cmd) (string, string, error) {
 	var stdout, stderr bytes.Buffer
 
-	log.Printf("[INFO] Running: %s %s", cmd.Path, strings.Join(cmd.Args[1:], " "))
-	cmd.Stdout = &stdout
+	log.Printf("[INFO] Running: %s %s", cmd.Path, strings.Join(redactArgs(cmd.Args[1:]), " "))
+	if cmd.Stdout != nil {
+		cmd.Stdout = io.MultiWriter(cmd.Stdout, &stdout)
+	} else {
+		cmd.Stdout = &stdout
+	}
 	cmd.Stderr = &stderr
 	err := cmd.Run()
 
@@ -460,14 +615,23 @@ func readVMXConfig(path string) (map[string]string, error) {
 	return ParseVMX(string(vmxBytes)), nil
 }
 
-// readCustomDeviceName retrieves the custom network device name from the .vmx configuration.
-func readCustomDeviceName(vmxData map[string]string) (string, error) {
-	connectionType, ok := vmxData["ethernet0.connectiontype"]
+// networkAdapterIndex returns the index of the network adapter used to
+// discover the host and guest addresses. Defaults to the first network adapter.
+func networkAdapterIndex(state multistep.StateBag) int {
+	if index, ok := state.GetOk("vmnetwork_adapter"); ok {
+		return index.(int)
+	}
+	return 0
+}
+
+// readCustomDeviceName retrieves the custom network device name of the network adapter from the .vmx configuration.
+func readCustomDeviceName(vmxData map[string]string, index int) (string, error) {
+	connectionType, ok := vmxData[fmt.Sprintf("ethernet%d.connectiontype", index)]
 	if !ok || connectionType != "custom" {
 		return "", fmt.Errorf("unable to determine the device name for the connection type : %s", connectionType)
 	}
 
-	device, ok := vmxData["ethernet0.vnet"]
+	device, ok := vmxData[fmt.Sprintf("ethernet%d.vnet", index)]
 	if !ok || device == "" {
 		return "", fmt.Errorf("unable to determine the device name for the connection type \"%s\" : %s", connectionType, device)
 	}
@@ -476,6 +640,11 @@ func readCustomDeviceName(vmxData map[string]string) (string, error) {
 
 // VmwareDriver is a struct that provides methods and paths needed for virtual machine management.
 type VmwareDriver struct {
+	// ProductName and ProductVersion are the name and version of the desktop
+	// hypervisor, which are set when the driver is verified.
+	ProductName    string
+	ProductVersion string
+
 	// These methods define paths that are used by the driver.
 	DhcpLeasesPath   func(string) string
 	DhcpConfPath     func(string) string
@@ -486,9 +655,23 @@ type VmwareDriver struct {
 
 	// GetHostIPForDevice returns the IP address for a given device.
 	GetHostIPForDevice func(device string) (string, error)
+
+	// RestartDhcpService restarts the DHCP service for a given device so that
+	// changes to the DHCP configuration take effect.
+	RestartDhcpService func(device string) error
+
+	// NetworkingConfPath returns the path to the networking configuration
+	// file, which is used for NAT port forwarding when the NAT configuration
+	// file of a device is not found.
+	NetworkingConfPath func() string
+
+	// RestartNatService restarts the NAT service for a given device so that
+	// changes to the NAT configuration take effect.
+	RestartNatService func(device string) error
 }
 
-// GuestAddress retrieves the MAC address of a guest virtual machine from the .vmx configuration.
+// GuestAddress retrieves the MAC address of the network adapter used for
+// address discovery from the .vmx configuration.
 func (d *VmwareDriver) GuestAddress(state multistep.StateBag) (string, error) {
 	vmxPath := state.Get("vmx_path").(string)
 
@@ -498,11 +681,12 @@ func (d *VmwareDriver) GuestAddress(state multistep.StateBag) (string, error) {
 		return "", err
 	}
 
+	index := networkAdapterIndex(state)
 	var ok bool
 	macAddress := ""
-	if macAddress, ok = vmxData["ethernet0.address"]; !ok || macAddress == "" {
-		if macAddress, ok = vmxData["ethernet0.generatedaddress"]; !ok || macAddress == "" {
-			return "", errors.New("unable to determine MAC address")
+	if macAddress, ok = vmxData[fmt.Sprintf("ethernet%d.address", index)]; !ok || macAddress == "" {
+		if macAddress, ok = vmxData[fmt.Sprintf("ethernet%d.generatedaddress", index)]; !ok || macAddress == "" {
+			return "", fmt.Errorf("unable to determine MAC address of ethernet%d", index)
 		}
 	}
 	log.Printf("[INFO] MAC address: %s", macAddress)
@@ -577,7 +761,7 @@ func (d *VmwareDriver) HostAddress(state multistep.StateBag) (string, error) {
 		}
 
 		var device string
-		device, err = readCustomDeviceName(vmxData)
+		device, err = readCustomDeviceName(vmxData, networkAdapterIndex(state))
 		devices = append(devices, device)
 		if err != nil {
 			return "", err
@@ -656,7 +840,7 @@ func (d *VmwareDriver) HostIP(state multistep.StateBag) (string, error) {
 		}
 
 		var device string
-		device, err = readCustomDeviceName(vmxData)
+		device, err = readCustomDeviceName(vmxData, networkAdapterIndex(state))
 		devices = append(devices, device)
 		if err != nil {
 			return "", err
@@ -799,6 +983,19 @@ func GetOvfTool() string {
 	return ovftool
 }
 
+// GetQemuImg returns the path to the `qemu-img` binary if found in the system's PATH, otherwise returns an empty string.
+func GetQemuImg() string {
+	qemuImg := appQemuImg
+	if runtime.GOOS == osWindows {
+		qemuImg += ".exe"
+	}
+
+	if _, err := exec.LookPath(qemuImg); err != nil {
+		return ""
+	}
+	return qemuImg
+}
+
 // VerifyOvfTool ensures the VMware OVF Tool is installed, available in the system's PATH, and meets the required
 // version.
 func (d *VmwareDriver) VerifyOvfTool(SkipExport, _ bool) error {
@@ -880,7 +1077,7 @@ func (d *VmwareDriver) getNetworkDevices(state multistep.StateBag) ([]string, er
 			return nil, err
 		}
 
-		device, err := readCustomDeviceName(vmxData)
+		device, err := readCustomDeviceName(vmxData, networkAdapterIndex(state))
 		if err != nil {
 			return nil, err
 		}
@@ -0,0 +1,225 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/vmware/packer-plugin-vmware/builder/vmware/common/vmdk"
+)
+
+const (
+	// diskConcurrency is the number of disks that are created or compacted at
+	// the same time.
+	diskConcurrency = 4
+
+	// diskProgressStep is the interval, in percent, at which the progress of
+	// an operation on a disk is reported.
+	diskProgressStep = 10
+)
+
+// DiskProgress reports the percentage of an operation on a virtual disk that
+// is done. The operation is named by the virtual disk manager; for example,
+// `Defragment` or `Shrink`.
+type DiskProgress func(operation string, percent int)
+
+// vdiskManagerProgress matches the progress written by the virtual disk
+// manager; for example, `Shrink: 45% done.`.
+var vdiskManagerProgress = regexp.MustCompile(`([A-Za-z][A-Za-z ]*?):\s*(\d+)% done`)
+
+// diskSizeUnits maps the units of a disk size, as specified by the `-s` option
+// of the virtual disk manager, to bytes.
+var diskSizeUnits = map[string]int64{
+	"K":  1024,
+	"KB": 1024,
+	"M":  1024 * 1024,
+	"MB": 1024 * 1024,
+	"G":  1024 * 1024 * 1024,
+	"GB": 1024 * 1024 * 1024,
+}
+
+// parseDiskSize parses a disk size, as specified by the `-s` option of the
+// virtual disk manager; for example, `40000M` or `40GB`. The size is returned
+// in bytes.
+func parseDiskSize(size string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(size))
+	i := strings.IndexFunc(s, func(r rune) bool { return r < '0' || r > '9' })
+	if i <= 0 {
+		return 0, fmt.Errorf("invalid disk size: %s", size)
+	}
+
+	unit, ok := diskSizeUnits[s[i:]]
+	if !ok {
+		return 0, fmt.Errorf("invalid disk size unit: %s; must be one of K, KB, M, MB, G, or GB", size)
+	}
+	n, err := strconv.ParseInt(s[:i], 10, 64)
+	if err != nil || n == 0 {
+		return 0, fmt.Errorf("invalid disk size: %s", size)
+	}
+
+	return n * unit, nil
+}
+
+// createDisk creates a virtual disk without the virtual disk manager. The
+// arguments are the same as those of Driver.CreateDisk.
+func createDisk(output string, size string, adapterType string, typeId string) error {
+	capacity, err := parseDiskSize(size)
+	if err != nil {
+		return err
+	}
+	diskType, err := vmdk.ParseDiskType(typeId)
+	if err != nil {
+		return err
+	}
+
+	absOutput, err := filepath.Abs(filepath.Clean(output))
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[INFO] Creating disk without %s: %s", appVdiskManager, absOutput)
+	return vmdk.Create(absOutput, capacity, diskType, adapterType)
+}
+
+// ErrCompactDiskSkipped is returned by Driver.CompactDisk if the virtual disk
+// is not compacted, because the virtual disk manager is not available.
+var ErrCompactDiskSkipped = fmt.Errorf("%s not found", appVdiskManager)
+
+// skipCompactDisk logs that the virtual disk is not compacted, because the
+// virtual disk manager is not available, and returns ErrCompactDiskSkipped.
+func skipCompactDisk(diskPath string) error {
+	log.Printf("[WARN] %s not found; skipping the compaction of disk: %s", appVdiskManager, diskPath)
+	return ErrCompactDiskSkipped
+}
+
+// expandDiskUnsupported returns the error for expanding a virtual disk without
+// the virtual disk manager.
+func expandDiskUnsupported(diskPath string) error {
+	return fmt.Errorf("%s is required to expand disk: %s", appVdiskManager, diskPath)
+}
+
+// runVdiskManager runs the virtual disk manager command and reports the
+// progress of the command.
+func runVdiskManager(cmd *exec.Cmd, progress DiskProgress) error {
+	w := &progressWriter{progress: progress}
+	cmd.Stdout = w
+	_, _, err := runAndLog(cmd)
+	w.flush()
+	return err
+}
+
+// progressWriter parses the progress written by the virtual disk manager. The
+// progress of an operation is rewritten on the same line, separated by
+// carriage returns.
+type progressWriter struct {
+	progress DiskProgress
+	line     []byte
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	for _, b := range p {
+		if b == '\r' || b == '\n' {
+			w.flush()
+			continue
+		}
+		w.line = append(w.line, b)
+	}
+	return len(p), nil
+}
+
+// flush reports the progress on the current line.
+func (w *progressWriter) flush() {
+	if w.progress != nil {
+		for _, m := range vdiskManagerProgress.FindAllSubmatch(w.line, -1) {
+			percent, err := strconv.Atoi(string(m[2]))
+			if err == nil {
+				w.progress(strings.TrimSpace(string(m[1])), percent)
+			}
+		}
+	}
+	w.line = w.line[:0]
+}
+
+// uiDiskProgress returns a DiskProgress that reports the progress of each
+// operation on the disk to the UI, at every diskProgressStep percent.
+func uiDiskProgress(ui packersdk.Ui, disk string) DiskProgress {
+	var operation string
+	reported := -1
+	return func(op string, percent int) {
+		if op != operation {
+			operation, reported = op, -1
+		}
+		step := percent / diskProgressStep * diskProgressStep
+		if step <= reported {
+			return
+		}
+		reported = step
+		ui.Sayf("%s %s: %d%%", operation, disk, step)
+	}
+}
+
+// forEachDisk calls the function for each of the disks, with up to
+// diskConcurrency calls at the same time. After the first error, the disks
+// that have not started are skipped and the error is returned.
+func forEachDisk(ctx context.Context, disks int, fn func(i int) error) error {
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+	)
+	sem := make(chan struct{}, diskConcurrency)
+
+	for i := 0; i < disks; i++ {
+		wg.Go(func() {
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			mu.Lock()
+			failed := firstErr != nil
+			mu.Unlock()
+			if failed || ctx.Err() != nil {
+				return
+			}
+
+			if err := fn(i); err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+			}
+		})
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
+
+// formatDiskSize returns the size in bytes in a human-readable form; for
+// example, `1.5 GB`.
+func formatDiskSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	value, exp := float64(size)/unit, 0
+	for value >= unit && exp < 3 {
+		value /= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", value, "KMGT"[exp])
+}
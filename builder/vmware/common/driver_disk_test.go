@@ -0,0 +1,168 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/stretchr/testify/assert"
+	"github.com/vmware/packer-plugin-vmware/builder/vmware/common/vmdk"
+)
+
+func TestParseDiskSize(t *testing.T) {
+	tc := []struct {
+		size     string
+		expected int64
+	}{
+		{"40000M", 40000 * 1024 * 1024},
+		{"40000MB", 40000 * 1024 * 1024},
+		{"40GB", 40 * 1024 * 1024 * 1024},
+		{"1g", 1024 * 1024 * 1024},
+		{"512K", 512 * 1024},
+	}
+
+	for _, c := range tc {
+		size, err := parseDiskSize(c.size)
+		if err != nil {
+			t.Fatalf("%s: err: %s", c.size, err)
+		}
+		assert.Equal(t, c.expected, size, c.size)
+	}
+
+	for _, size := range []string{"", "M", "0M", "40000", "40TB", "-1M", "1.5G"} {
+		_, err := parseDiskSize(size)
+		assert.Error(t, err, size)
+	}
+}
+
+func TestCreateDisk(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "disk.vmdk")
+	if err := createDisk(path, "100M", "nvme", "0"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	info, err := vmdk.Stat(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assert.Equal(t, vmdk.CreateTypeMonolithicSparse, info.CreateType())
+	assert.Equal(t, int64(100*1024*1024), info.Capacity())
+
+	assert.Error(t, createDisk(filepath.Join(t.TempDir(), "disk.vmdk"), "100M", "lsilogic", "9"))
+	assert.Error(t, createDisk(filepath.Join(t.TempDir(), "disk.vmdk"), "100", "lsilogic", "0"))
+}
+
+func TestWorkstationDriver_withoutVdiskManager(t *testing.T) {
+	d := &WorkstationDriver{}
+
+	path := filepath.Join(t.TempDir(), "disk.vmdk")
+	if err := d.CreateDisk(path, "10M", "lsilogic", "2", nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := vmdk.Stat(path); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	assert.ErrorIs(t, d.CompactDisk(path, nil), ErrCompactDiskSkipped)
+	assert.Error(t, d.ExpandDisk(path, "20M"))
+}
+
+func TestProgressWriter(t *testing.T) {
+	type report struct {
+		operation string
+		percent   int
+	}
+	var reports []report
+	w := &progressWriter{progress: func(operation string, percent int) {
+		reports = append(reports, report{operation, percent})
+	}}
+
+	// The output is written in chunks that split the lines.
+	for _, chunk := range []string{
+		"Defragment: 10% do", "ne.\rDefragment: 55% done.\r",
+		"Defragment: 100% done.\nDefragmentation completed successfully.\n",
+		"  Shrink: 0% done.\r  Shrink: 100% done.",
+	} {
+		if _, err := w.Write([]byte(chunk)); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+	w.flush()
+
+	assert.Equal(t, []report{
+		{"Defragment", 10},
+		{"Defragment", 55},
+		{"Defragment", 100},
+		{"Shrink", 0},
+		{"Shrink", 100},
+	}, reports)
+}
+
+func TestUIDiskProgress(t *testing.T) {
+	var out bytes.Buffer
+	ui := &packersdk.BasicUi{Reader: new(bytes.Buffer), Writer: &out}
+
+	progress := uiDiskProgress(ui, "disk.vmdk")
+	for _, percent := range []int{0, 3, 10, 15, 42, 100, 100} {
+		progress("Shrink", percent)
+	}
+	progress("Create", 100)
+
+	assert.Equal(t, ""+
+		"Shrink disk.vmdk: 0%\n"+
+		"Shrink disk.vmdk: 10%\n"+
+		"Shrink disk.vmdk: 40%\n"+
+		"Shrink disk.vmdk: 100%\n"+
+		"Create disk.vmdk: 100%\n",
+		out.String())
+}
+
+func TestForEachDisk(t *testing.T) {
+	var running, maxRunning, calls atomic.Int32
+	err := forEachDisk(context.Background(), 10, func(i int) error {
+		calls.Add(1)
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			m := maxRunning.Load()
+			if n <= m || maxRunning.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(10), calls.Load())
+	assert.LessOrEqual(t, maxRunning.Load(), int32(diskConcurrency))
+
+	err = forEachDisk(context.Background(), 10, func(i int) error {
+		if i == 3 {
+			return fmt.Errorf("disk %d", i)
+		}
+		return nil
+	})
+	assert.EqualError(t, err, "disk 3")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = forEachDisk(ctx, 10, func(i int) error {
+		return errors.New("should not be called")
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestFormatDiskSize(t *testing.T) {
+	assert.Equal(t, "512 B", formatDiskSize(512))
+	assert.Equal(t, "1.5 KB", formatDiskSize(1536))
+	assert.Equal(t, "100.0 MB", formatDiskSize(100*1024*1024))
+	assert.Equal(t, "2.0 GB", formatDiskSize(2*1024*1024*1024))
+}
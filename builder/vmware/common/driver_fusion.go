@@ -38,7 +38,11 @@ func NewFusionDriver(dconfig *DriverConfig, config *SSHConfig) Driver {
 	}
 }
 
-func (d *FusionDriver) CompactDisk(diskPath string) error {
+func (d *FusionDriver) CompactDisk(diskPath string, progress DiskProgress) error {
+	if !d.hasVdiskManager() {
+		return skipCompactDisk(diskPath)
+	}
+
 	cleanDiskPath := filepath.Clean(diskPath)
 	absPath, err := filepath.Abs(cleanDiskPath)
 	if err != nil {
@@ -46,19 +50,23 @@ func (d *FusionDriver) CompactDisk(diskPath string) error {
 	}
 
 	defragCmd := exec.Command(d.vdiskManagerPath(), "-d", absPath) //nolint:gosec
-	if _, _, err := runAndLog(defragCmd); err != nil {
+	if err := runVdiskManager(defragCmd, progress); err != nil {
 		return err
 	}
 
 	shrinkCmd := exec.Command(d.vdiskManagerPath(), "-k", absPath) //nolint:gosec
-	if _, _, err := runAndLog(shrinkCmd); err != nil {
+	if err := runVdiskManager(shrinkCmd, progress); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func (d *FusionDriver) CreateDisk(output string, size string, adapterType string, typeId string) error {
+func (d *FusionDriver) CreateDisk(output string, size string, adapterType string, typeId string, progress DiskProgress) error {
+	if !d.hasVdiskManager() {
+		return createDisk(output, size, adapterType, typeId)
+	}
+
 	cleanOutput := filepath.Clean(output)
 	absOutput, err := filepath.Abs(cleanOutput)
 	if err != nil {
@@ -66,6 +74,25 @@ func (d *FusionDriver) CreateDisk(output string, size string, adapterType string
 	}
 
 	cmd := exec.Command(d.vdiskManagerPath(), "-c", "-s", size, "-a", adapterType, "-t", typeId, absOutput) //nolint:gosec
+	if err := runVdiskManager(cmd, progress); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (d *FusionDriver) ExpandDisk(diskPath string, size string) error {
+	if !d.hasVdiskManager() {
+		return expandDiskUnsupported(diskPath)
+	}
+
+	cleanDiskPath := filepath.Clean(diskPath)
+	absPath, err := filepath.Abs(cleanDiskPath)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command(d.vdiskManagerPath(), "-x", size, absPath) //nolint:gosec
 	if _, _, err := runAndLog(cmd); err != nil {
 		return err
 	}
@@ -85,6 +112,96 @@ func (d *FusionDriver) CreateSnapshot(vmxPath string, snapshotName string) error
 	return err
 }
 
+func (d *FusionDriver) ListSnapshots(vmxPath string) ([]string, error) {
+	cleanVmx := filepath.Clean(vmxPath)
+	absVmxPath, err := filepath.Abs(cleanVmx)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command(d.vmrunPath(), "-T", "fusion", "listSnapshots", absVmxPath) //nolint:gosec
+	stdout, _, err := runAndLog(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseSnapshotList(stdout), nil
+}
+
+func (d *FusionDriver) RevertToSnapshot(vmxPath string, snapshotName string) error {
+	cleanVmx := filepath.Clean(vmxPath)
+	absVmxPath, err := filepath.Abs(cleanVmx)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command(d.vmrunPath(), "-T", "fusion", "revertToSnapshot", absVmxPath, snapshotName) //nolint:gosec
+	_, _, err = runAndLog(cmd)
+	return err
+}
+
+func (d *FusionDriver) DeleteSnapshot(vmxPath string, snapshotName string) error {
+	cleanVmx := filepath.Clean(vmxPath)
+	absVmxPath, err := filepath.Abs(cleanVmx)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command(d.vmrunPath(), "-T", "fusion", "deleteSnapshot", absVmxPath, snapshotName) //nolint:gosec
+	_, _, err = runAndLog(cmd)
+	return err
+}
+
+func (d *FusionDriver) RunProgramInGuest(vmxPath string, credentials GuestCredentials, program string, args ...string) (int, error) {
+	cleanVmx := filepath.Clean(vmxPath)
+	absVmxPath, err := filepath.Abs(cleanVmx)
+	if err != nil {
+		return 0, err
+	}
+
+	cmdArgs := guestOpsArgs("fusion", absVmxPath, credentials, "runProgramInGuest", append([]string{program}, args...)...)
+	return runGuestProgram(exec.Command(d.vmrunPath(), cmdArgs...)) //nolint:gosec
+}
+
+func (d *FusionDriver) CopyFileFromHostToGuest(vmxPath string, credentials GuestCredentials, src string, dst string) error {
+	cleanVmx := filepath.Clean(vmxPath)
+	absVmxPath, err := filepath.Abs(cleanVmx)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command(d.vmrunPath(), guestOpsArgs("fusion", absVmxPath, credentials, "copyFileFromHostToGuest", src, dst)...) //nolint:gosec
+	_, _, err = runAndLog(cmd)
+	return err
+}
+
+func (d *FusionDriver) CopyFileFromGuestToHost(vmxPath string, credentials GuestCredentials, src string, dst string) error {
+	cleanVmx := filepath.Clean(vmxPath)
+	absVmxPath, err := filepath.Abs(cleanVmx)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command(d.vmrunPath(), guestOpsArgs("fusion", absVmxPath, credentials, "copyFileFromGuestToHost", src, dst)...) //nolint:gosec
+	_, _, err = runAndLog(cmd)
+	return err
+}
+
+func (d *FusionDriver) DirectoryExistsInGuest(vmxPath string, credentials GuestCredentials, dir string) (bool, error) {
+	cleanVmx := filepath.Clean(vmxPath)
+	absVmxPath, err := filepath.Abs(cleanVmx)
+	if err != nil {
+		return false, err
+	}
+
+	cmd := exec.Command(d.vmrunPath(), guestOpsArgs("fusion", absVmxPath, credentials, "directoryExistsInGuest", dir)...) //nolint:gosec
+	stdout, _, err := runAndLog(cmd)
+	if err != nil {
+		return false, err
+	}
+	return parseDirectoryExists(stdout), nil
+}
+
 func (d *FusionDriver) IsRunning(vmxPath string) (bool, error) {
 	cleanVmx := filepath.Clean(vmxPath)
 	absVmxPath, err := filepath.Abs(cleanVmx)
@@ -184,6 +301,12 @@ func (d *FusionDriver) vdiskManagerPath() string {
 	return d.binaryPath(appVdiskManager)
 }
 
+// hasVdiskManager reports whether the virtual disk manager is installed.
+func (d *FusionDriver) hasVdiskManager() bool {
+	_, err := os.Stat(d.vdiskManagerPath())
+	return err == nil
+}
+
 func (d *FusionDriver) isoFileName(base string) string {
 	return base + ".iso"
 }
@@ -235,6 +358,8 @@ func (d *FusionDriver) Verify() error {
 	}
 
 	log.Printf("[INFO] %s: %s", fusionProductName, fusionVersion)
+	d.ProductName = fusionProductName
+	d.ProductVersion = fusionVersion.String()
 	log.Printf("[INFO] Checking %s paths...", fusionProductName)
 
 	if _, err := os.Stat(d.AppPath); err != nil {
@@ -267,15 +392,14 @@ func (d *FusionDriver) Verify() error {
 
 	log.Printf("[INFO] - %s found at: %s", appVmrun, d.vmrunPath())
 
-	if _, err := os.Stat(d.vdiskManagerPath()); err != nil {
-		if os.IsNotExist(err) {
-			return fmt.Errorf("%s not found at: %s", appVdiskManager, d.vdiskManagerPath())
-		}
-		return err
+	// Disks are created without the virtual disk manager, if it is not
+	// installed.
+	if d.hasVdiskManager() {
+		log.Printf("[INFO] - %s found at: %s", appVdiskManager, d.vdiskManagerPath())
+	} else {
+		log.Printf("[WARN] - %s not found at: %s; disks will not be compacted", appVdiskManager, d.vdiskManagerPath())
 	}
 
-	log.Printf("[INFO] - %s found at: %s", appVdiskManager, d.vdiskManagerPath())
-
 	libpath := d.libPath()
 
 	d.DhcpLeasesPath = func(device string) string {
@@ -290,6 +414,24 @@ func (d *FusionDriver) Verify() error {
 		return filepath.Join(libpath, device, "nat.conf")
 	}
 
+	// The virtual networks are restarted together, since the DHCP and NAT
+	// services of a single virtual network cannot be restarted.
+	restartNetworking := func(string) error {
+		for _, arg := range []string{"--stop", "--start"} {
+			cmd := exec.Command(d.binaryPath(appVmnetCli), arg) //nolint:gosec
+			if _, _, err := runAndLog(cmd); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	d.RestartDhcpService = restartNetworking
+	d.RestartNatService = restartNetworking
+
+	d.NetworkingConfPath = func() string {
+		return filepath.Join(libpath, "networking")
+	}
+
 	d.NetworkMapper = func() (NetworkNameMapper, error) {
 		pathNetworking := filepath.Join(libpath, "networking")
 		if _, err := os.Stat(pathNetworking); err != nil {
@@ -5,7 +5,10 @@
 package common
 
 import (
+	"fmt"
 	"net"
+	"os"
+	"slices"
 	"sync"
 
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
@@ -32,11 +35,57 @@ type DriverMock struct {
 	CreateDiskTypeId      string
 	CreateDiskErr         error
 
+	ExpandDiskCalled bool
+	ExpandDiskPath   string
+	ExpandDiskSize   string
+	ExpandDiskErr    error
+
 	CreateSnapshotCalled  bool
 	CreateSnapshotVMXPath string
 	CreateSnapshotName    string
 	CreateSnapshotErr     error
 
+	ListSnapshotsCalled  bool
+	ListSnapshotsVMXPath string
+	ListSnapshotsResult  []string
+	ListSnapshotsErr     error
+
+	RevertToSnapshotCalled  bool
+	RevertToSnapshotVMXPath string
+	RevertToSnapshotName    string
+	RevertToSnapshotErr     error
+
+	DeleteSnapshotCalled  bool
+	DeleteSnapshotVMXPath string
+	DeleteSnapshotNames   []string
+	DeleteSnapshotErr     error
+
+	// GuestFiles simulates the files in the guest operating system for the
+	// guest operations, keyed by guest path.
+	GuestFiles map[string][]byte
+	// GuestDirectories simulates the directories in the guest operating
+	// system for the guest operations.
+	GuestDirectories []string
+	// GuestCredentials records the credentials of the last guest operation.
+	GuestCredentials GuestCredentials
+
+	RunProgramInGuestCalled  bool
+	RunProgramInGuestProgram string
+	RunProgramInGuestArgs    []string
+	// RunProgramInGuestFunc, if set, is called to simulate the guest program
+	// with the guest files locked and returns the exit code.
+	RunProgramInGuestFunc func(files map[string][]byte, program string, args []string) int
+	RunProgramInGuestErr  error
+
+	CopyFileFromHostToGuestCalled bool
+	CopyFileFromHostToGuestErr    error
+
+	CopyFileFromGuestToHostCalled bool
+	CopyFileFromGuestToHostErr    error
+
+	DirectoryExistsInGuestCalled bool
+	DirectoryExistsInGuestErr    error
+
 	ExportCalled bool
 	ExportArgs   []string
 
@@ -97,9 +146,17 @@ type DriverMock struct {
 	DhcpConfPathCalled bool
 	DhcpConfPathResult string
 
+	RestartDhcpServiceDevices []string
+	RestartDhcpServiceErr     error
+
 	VmnetnatConfPathCalled bool
 	VmnetnatConfPathResult string
 
+	NetworkingConfPathResult string
+
+	RestartNatServiceDevices []string
+	RestartNatServiceErr     error
+
 	NetmapConfPathCalled bool
 	NetmapConfPathResult string
 
@@ -133,13 +190,19 @@ func (d *DriverMock) Clone(dst string, src string, linked bool, snapshot string)
 	return d.CloneErr
 }
 
-func (d *DriverMock) CompactDisk(path string) error {
+func (d *DriverMock) CompactDisk(path string, progress DiskProgress) error {
+	d.Lock()
+	defer d.Unlock()
+
 	d.CompactDiskCalled = true
 	d.CompactDiskPath = path
 	return d.CompactDiskErr
 }
 
-func (d *DriverMock) CreateDisk(output string, size string, adapterType string, typeId string) error {
+func (d *DriverMock) CreateDisk(output string, size string, adapterType string, typeId string, progress DiskProgress) error {
+	d.Lock()
+	defer d.Unlock()
+
 	d.CreateDiskCalled = true
 	d.CreateDiskOutput = output
 	d.CreateDiskSize = size
@@ -148,6 +211,13 @@ func (d *DriverMock) CreateDisk(output string, size string, adapterType string,
 	return d.CreateDiskErr
 }
 
+func (d *DriverMock) ExpandDisk(path string, size string) error {
+	d.ExpandDiskCalled = true
+	d.ExpandDiskPath = path
+	d.ExpandDiskSize = size
+	return d.ExpandDiskErr
+}
+
 func (d *DriverMock) CreateSnapshot(vmxPath string, snapshotName string) error {
 	d.CreateSnapshotCalled = true
 	d.CreateSnapshotVMXPath = vmxPath
@@ -155,6 +225,96 @@ func (d *DriverMock) CreateSnapshot(vmxPath string, snapshotName string) error {
 	return d.CreateSnapshotErr
 }
 
+func (d *DriverMock) ListSnapshots(vmxPath string) ([]string, error) {
+	d.ListSnapshotsCalled = true
+	d.ListSnapshotsVMXPath = vmxPath
+	return d.ListSnapshotsResult, d.ListSnapshotsErr
+}
+
+func (d *DriverMock) RevertToSnapshot(vmxPath string, snapshotName string) error {
+	d.RevertToSnapshotCalled = true
+	d.RevertToSnapshotVMXPath = vmxPath
+	d.RevertToSnapshotName = snapshotName
+	return d.RevertToSnapshotErr
+}
+
+func (d *DriverMock) DeleteSnapshot(vmxPath string, snapshotName string) error {
+	d.DeleteSnapshotCalled = true
+	d.DeleteSnapshotVMXPath = vmxPath
+	d.DeleteSnapshotNames = append(d.DeleteSnapshotNames, snapshotName)
+	return d.DeleteSnapshotErr
+}
+
+func (d *DriverMock) RunProgramInGuest(vmxPath string, credentials GuestCredentials, program string, args ...string) (int, error) {
+	d.Lock()
+	defer d.Unlock()
+
+	d.RunProgramInGuestCalled = true
+	d.RunProgramInGuestProgram = program
+	d.RunProgramInGuestArgs = args
+	d.GuestCredentials = credentials
+	if d.RunProgramInGuestErr != nil {
+		return 0, d.RunProgramInGuestErr
+	}
+	if d.GuestFiles == nil {
+		d.GuestFiles = make(map[string][]byte)
+	}
+	if d.RunProgramInGuestFunc != nil {
+		return d.RunProgramInGuestFunc(d.GuestFiles, program, args), nil
+	}
+	return 0, nil
+}
+
+func (d *DriverMock) CopyFileFromHostToGuest(vmxPath string, credentials GuestCredentials, src string, dst string) error {
+	d.Lock()
+	defer d.Unlock()
+
+	d.CopyFileFromHostToGuestCalled = true
+	d.GuestCredentials = credentials
+	if d.CopyFileFromHostToGuestErr != nil {
+		return d.CopyFileFromHostToGuestErr
+	}
+
+	contents, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	if d.GuestFiles == nil {
+		d.GuestFiles = make(map[string][]byte)
+	}
+	d.GuestFiles[dst] = contents
+	return nil
+}
+
+func (d *DriverMock) CopyFileFromGuestToHost(vmxPath string, credentials GuestCredentials, src string, dst string) error {
+	d.Lock()
+	defer d.Unlock()
+
+	d.CopyFileFromGuestToHostCalled = true
+	d.GuestCredentials = credentials
+	if d.CopyFileFromGuestToHostErr != nil {
+		return d.CopyFileFromGuestToHostErr
+	}
+
+	contents, ok := d.GuestFiles[src]
+	if !ok {
+		return fmt.Errorf("file not found in guest: %s", src)
+	}
+	return os.WriteFile(dst, contents, 0600)
+}
+
+func (d *DriverMock) DirectoryExistsInGuest(vmxPath string, credentials GuestCredentials, dir string) (bool, error) {
+	d.Lock()
+	defer d.Unlock()
+
+	d.DirectoryExistsInGuestCalled = true
+	d.GuestCredentials = credentials
+	if d.DirectoryExistsInGuestErr != nil {
+		return false, d.DirectoryExistsInGuestErr
+	}
+	return slices.Contains(d.GuestDirectories, dir), nil
+}
+
 func (d *DriverMock) IsRunning(path string) (bool, error) {
 	d.Lock()
 	defer d.Unlock()
@@ -290,11 +450,31 @@ func (d *DriverMock) GetVmwareDriver() VmwareDriver {
 		return "/path/to/dhcp.leases"
 	}
 	state.DhcpConfPath = func(string) string {
+		if d.DhcpConfPathResult != "" {
+			return d.DhcpConfPathResult
+		}
 		return "/path/to/dhcp.conf"
 	}
+	state.RestartDhcpService = func(device string) error {
+		d.RestartDhcpServiceDevices = append(d.RestartDhcpServiceDevices, device)
+		return d.RestartDhcpServiceErr
+	}
 	state.VmnetnatConfPath = func(string) string {
+		if d.VmnetnatConfPathResult != "" {
+			return d.VmnetnatConfPathResult
+		}
 		return "/path/to/vmnetnat.conf"
 	}
+	state.NetworkingConfPath = func() string {
+		if d.NetworkingConfPathResult != "" {
+			return d.NetworkingConfPathResult
+		}
+		return "/path/to/networking"
+	}
+	state.RestartNatService = func(device string) error {
+		d.RestartNatServiceDevices = append(d.RestartNatServiceDevices, device)
+		return d.RestartNatServiceErr
+	}
 	state.NetworkMapper = func() (NetworkNameMapper, error) {
 		return &NetworkMapperMock{}, nil
 	}
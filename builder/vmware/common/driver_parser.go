@@ -9,6 +9,7 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"math"
 	"net"
@@ -464,6 +465,7 @@ func parseNetworkMapConfig(in chan string) (NetworkMap, error) {
 // parameters
 type pParameter interface {
 	repr() string
+	text() string
 }
 
 type pParameterInclude struct {
@@ -471,6 +473,7 @@ type pParameterInclude struct {
 }
 
 func (e pParameterInclude) repr() string { return fmt.Sprintf("include-file:filename=%s", e.filename) }
+func (e pParameterInclude) text() string { return fmt.Sprintf("include %s", e.filename) }
 
 type pParameterOption struct {
 	name  string
@@ -478,6 +481,7 @@ type pParameterOption struct {
 }
 
 func (e pParameterOption) repr() string { return fmt.Sprintf("option:%s=%s", e.name, e.value) }
+func (e pParameterOption) text() string { return fmt.Sprintf("option %s %s", e.name, e.value) }
 
 // allow some-kind-of-something
 type pParameterGrant struct {
@@ -486,6 +490,7 @@ type pParameterGrant struct {
 }
 
 func (e pParameterGrant) repr() string { return fmt.Sprintf("grant:%s,%s", e.verb, e.attribute) }
+func (e pParameterGrant) text() string { return fmt.Sprintf("%s %s", e.verb, e.attribute) }
 
 type pParameterAddress4 []string
 
@@ -493,12 +498,20 @@ func (e pParameterAddress4) repr() string {
 	return fmt.Sprintf("fixed-address4:%s", strings.Join(e, ","))
 }
 
+func (e pParameterAddress4) text() string {
+	return fmt.Sprintf("fixed-address %s", strings.Join(e, " "))
+}
+
 type pParameterAddress6 []string
 
 func (e pParameterAddress6) repr() string {
 	return fmt.Sprintf("fixed-address6:%s", strings.Join(e, ","))
 }
 
+func (e pParameterAddress6) text() string {
+	return fmt.Sprintf("fixed-address6 %s", strings.Join(e, " "))
+}
+
 // hardware address 00:00:00:00:00:00
 type pParameterHardware struct {
 	class   string
@@ -515,6 +528,10 @@ func (e pParameterHardware) repr() string {
 	return fmt.Sprintf("hardware-address:%s[%s]", e.class, strings.Join(res, ":"))
 }
 
+func (e pParameterHardware) text() string {
+	return fmt.Sprintf("hardware %s %s", e.class, net.HardwareAddr(e.address).String())
+}
+
 type pParameterBoolean struct {
 	parameter string
 	truancy   bool
@@ -522,6 +539,13 @@ type pParameterBoolean struct {
 
 func (e pParameterBoolean) repr() string { return fmt.Sprintf("boolean:%s=%v", e.parameter, e.truancy) }
 
+func (e pParameterBoolean) text() string {
+	if e.truancy {
+		return e.parameter
+	}
+	return fmt.Sprintf("not %s", e.parameter)
+}
+
 type pParameterClientMatch struct {
 	name string
 	data string
@@ -531,16 +555,28 @@ func (e pParameterClientMatch) repr() string {
 	return fmt.Sprintf("match-client:%s=%s", e.name, e.data)
 }
 
-// range 127.0.0.1 127.0.0.255
+func (e pParameterClientMatch) text() string {
+	return fmt.Sprintf("host-identifier option %s %s", e.name, e.data)
+}
+
+// range [dynamic-bootp] 127.0.0.1 127.0.0.255
 type pParameterRange4 struct {
-	min net.IP
-	max net.IP
+	min   net.IP
+	max   net.IP
+	bootp bool
 }
 
 func (e pParameterRange4) repr() string {
 	return fmt.Sprintf("range4:%s-%s", e.min.String(), e.max.String())
 }
 
+func (e pParameterRange4) text() string {
+	if e.bootp {
+		return fmt.Sprintf("range dynamic-bootp %s %s", e.min.String(), e.max.String())
+	}
+	return fmt.Sprintf("range %s %s", e.min.String(), e.max.String())
+}
+
 type pParameterRange6 struct {
 	min net.IP
 	max net.IP
@@ -550,6 +586,13 @@ func (e pParameterRange6) repr() string {
 	return fmt.Sprintf("range6:%s-%s", e.min.String(), e.max.String())
 }
 
+func (e pParameterRange6) text() string {
+	if e.min.Equal(e.max) {
+		return fmt.Sprintf("range6 %s", e.min.String())
+	}
+	return fmt.Sprintf("range6 %s %s", e.min.String(), e.max.String())
+}
+
 type pParameterPrefix6 struct {
 	min  net.IP
 	max  net.IP
@@ -560,6 +603,10 @@ func (e pParameterPrefix6) repr() string {
 	return fmt.Sprintf("prefix6:/%d:%s-%s", e.bits, e.min.String(), e.max.String())
 }
 
+func (e pParameterPrefix6) text() string {
+	return fmt.Sprintf("prefix6 %s %s /%d", e.min.String(), e.max.String(), e.bits)
+}
+
 // some-kind-of-parameter 1024
 type pParameterOther struct {
 	parameter string
@@ -567,6 +614,7 @@ type pParameterOther struct {
 }
 
 func (e pParameterOther) repr() string { return fmt.Sprintf("parameter:%s=%s", e.parameter, e.value) }
+func (e pParameterOther) text() string { return fmt.Sprintf("%s %s", e.parameter, e.value) }
 
 type pParameterExpression struct {
 	parameter  string
@@ -577,8 +625,13 @@ func (e pParameterExpression) repr() string {
 	return fmt.Sprintf("parameter-expression:%s=\"%s\"", e.parameter, e.expression)
 }
 
+func (e pParameterExpression) text() string {
+	return fmt.Sprintf("%s = %s", e.parameter, e.expression)
+}
+
 type pDeclarationIdentifier interface {
 	repr() string
+	text() string
 }
 
 type pDeclaration struct {
@@ -586,6 +639,9 @@ type pDeclaration struct {
 	parent       *pDeclaration
 	parameters   []pParameter
 	declarations []pDeclaration
+
+	// source is the text of the declaration as it was read, if any.
+	source *dhcpSource
 }
 
 func (e *pDeclaration) short() string {
@@ -612,39 +668,324 @@ func (e *pDeclaration) repr() string {
 	return fmt.Sprintf("%s\n%s\n%s\n", res, strings.Join(parameters, "\n"), strings.Join(groups, "\n"))
 }
 
+// text returns the declaration, its parameters, and its child declarations in
+// the DHCP configuration file format. A declaration that was read from a file
+// is written as it was read, except for the parameters and declarations that
+// are modified; any other declaration is formatted, indented by indent.
+func (e *pDeclaration) text(indent string) string {
+	if e.source != nil {
+		return e.sourceText(indent)
+	}
+	return e.formatText(indent)
+}
+
+// formatText returns the declaration formatted in the DHCP configuration file
+// format. The global declaration is written without enclosing braces; every
+// other declaration is indented by indent.
+func (e *pDeclaration) formatText(indent string) string {
+	var b strings.Builder
+
+	inner := indent
+	if _, ok := e.id.(pDeclarationGlobal); !ok {
+		fmt.Fprintf(&b, "%s%s {\n", indent, e.id.text())
+		inner = indent + "\t"
+	}
+
+	for _, v := range e.parameters {
+		fmt.Fprintf(&b, "%s%s;\n", inner, v.text())
+	}
+
+	for _, v := range e.declarations {
+		// Separate the top-level declarations with an empty line.
+		if inner == "" && b.Len() > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(v.formatText(inner))
+	}
+
+	if inner != indent {
+		fmt.Fprintf(&b, "%s}\n", indent)
+	}
+	return b.String()
+}
+
+// sourceText returns the declaration as it was read. A modified parameter or
+// declaration is formatted in place of its text, a removed one is left out,
+// and the added ones are formatted after the text of the existing ones. The
+// declaration is indented by indent, which is used for its added parameters
+// and declarations.
+func (e *pDeclaration) sourceText(indent string) string {
+	s := e.source
+	_, global := e.id.(pDeclarationGlobal)
+
+	inner := indent + "\t"
+	if global {
+		inner = ""
+	}
+	for _, st := range s.statements {
+		if !st.declaration {
+			inner = dhcpIndent(st.leading)
+		}
+	}
+
+	var b strings.Builder
+	writeLine := func(line string) {
+		if b.Len() > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(line)
+		if b.Len() == len(line) {
+			b.WriteString("\n")
+		}
+	}
+	writeParameters := func() {
+		for _, v := range e.parameters[min(s.parameters, len(e.parameters)):] {
+			writeLine(inner + v.text() + ";")
+		}
+	}
+
+	if !global {
+		if e.id.text() == s.id {
+			b.WriteString(s.header)
+		} else {
+			b.WriteString(e.id.text() + " {")
+		}
+	}
+	if s.parameters == 0 {
+		writeParameters()
+	}
+
+	for _, st := range s.statements {
+		if !st.declaration {
+			if st.index >= len(e.parameters) {
+				continue
+			}
+			b.WriteString(st.leading)
+			if v := e.parameters[st.index].text(); v != st.parameter {
+				b.WriteString(v + ";")
+			} else {
+				b.WriteString(st.text)
+			}
+			b.WriteString(st.trailing)
+			if st.index == s.parameters-1 {
+				writeParameters()
+			}
+			continue
+		}
+
+		if st.index >= len(e.declarations) {
+			continue
+		}
+		d := &e.declarations[st.index]
+		b.WriteString(st.leading)
+		if d.source == st.source {
+			b.WriteString(d.sourceText(dhcpIndent(st.leading)))
+		} else {
+			b.WriteString(strings.TrimSpace(d.formatText(dhcpIndent(st.leading))))
+		}
+		b.WriteString(st.trailing)
+	}
+
+	for _, v := range e.declarations[min(s.declarations, len(e.declarations)):] {
+		// Separate the top-level declarations with an empty line.
+		if global && b.Len() > 0 {
+			b.WriteString("\n")
+		}
+		writeLine(strings.TrimSuffix(v.formatText(inner), "\n"))
+	}
+
+	b.WriteString(s.footer)
+	return b.String()
+}
+
+// dhcpSource is the text of a declaration as it was read from a DHCP
+// configuration file, which is kept so that the file can be written back
+// without losing its comments and formatting.
+type dhcpSource struct {
+	// id is the identifier of the declaration as it was parsed.
+	id string
+	// header is the text of the declaration up to and including the opening
+	// brace and the comment that follows it.
+	header     string
+	statements []dhcpStatement
+	// footer is the text after the last statement, including the closing
+	// brace.
+	footer string
+
+	parameters   int
+	declarations int
+}
+
+// dhcpStatement is the text of a parameter or a child declaration of a
+// declaration read from a DHCP configuration file.
+type dhcpStatement struct {
+	// leading is the whitespace and the comments before the statement.
+	leading string
+	// text is the text of a parameter, including the semicolon.
+	text string
+	// trailing is the whitespace and the comment after the statement on the
+	// same line.
+	trailing string
+
+	declaration bool
+	index       int
+	// parameter is the parameter as it was parsed.
+	parameter string
+	// source is the text of a child declaration.
+	source *dhcpSource
+}
+
+// dhcpIndent returns the indentation of the statement following the
+// whitespace and the comments.
+func dhcpIndent(leading string) string {
+	return leading[strings.LastIndexByte(leading, '\n')+1:]
+}
+
+// scanDhcpSource splits the DHCP configuration into the text of its
+// declarations and statements, following the same rules as the tokenizer and
+// the parser, so that the result matches the parsed declarations.
+func scanDhcpSource(data string) *dhcpSource {
+	root := &dhcpSource{}
+	stack := []*dhcpSource{root}
+
+	// skipComment returns the index of the end of the line of the comment
+	// at i.
+	skipComment := func(i int) int {
+		if j := strings.IndexByte(data[i:], '\n'); j >= 0 {
+			return i + j
+		}
+		return len(data)
+	}
+
+	// scanTrailing returns the index of the end of the whitespace and the
+	// comment that follow a statement on the same line.
+	scanTrailing := func(i int) int {
+		for i < len(data) && (data[i] == ' ' || data[i] == '\t') {
+			i++
+		}
+		if i < len(data) && data[i] == '#' {
+			i = skipComment(i)
+		}
+		return i
+	}
+
+	start, i := 0, 0
+	for i < len(data) {
+		switch data[i] {
+		case '#':
+			i = skipComment(i)
+			continue
+		case ' ', '\t', '\r', '\n':
+			i++
+			continue
+		}
+
+		first := i
+		for i < len(data) && !strings.ContainsRune("{};", rune(data[i])) {
+			switch data[i] {
+			case '#':
+				i = skipComment(i)
+			case '"':
+				for i++; i < len(data) && data[i] != '"'; i++ {
+					if data[i] == '#' {
+						i = skipComment(i) - 1
+					}
+				}
+				i++
+			default:
+				i++
+			}
+		}
+		if i >= len(data) {
+			break
+		}
+
+		node := stack[len(stack)-1]
+		switch data[i] {
+		case ';':
+			end := scanTrailing(i + 1)
+			node.statements = append(node.statements, dhcpStatement{
+				leading:  data[start:first],
+				text:     data[first : i+1],
+				trailing: data[i+1 : end],
+				index:    node.parameters,
+			})
+			node.parameters++
+			start, i = end, end
+
+		case '{':
+			end := scanTrailing(i + 1)
+			child := &dhcpSource{header: data[first:end]}
+			node.statements = append(node.statements, dhcpStatement{
+				leading:     data[start:first],
+				declaration: true,
+				index:       node.declarations,
+				source:      child,
+			})
+			node.declarations++
+			stack = append(stack, child)
+			start, i = end, end
+
+		case '}':
+			if len(stack) == 1 {
+				return nil
+			}
+			end := scanTrailing(i + 1)
+			node.footer = data[start : i+1]
+			stack = stack[:len(stack)-1]
+			parent := stack[len(stack)-1]
+			parent.statements[len(parent.statements)-1].trailing = data[i+1 : end]
+			start, i = end, end
+		}
+	}
+
+	root.footer = data[start:]
+	return root
+}
+
 type pDeclarationGlobal struct{}
 
 func (e pDeclarationGlobal) repr() string { return "{global}" }
+func (e pDeclarationGlobal) text() string { return "" }
 
 type pDeclarationShared struct{ name string }
 
 func (e pDeclarationShared) repr() string { return fmt.Sprintf("{shared-network %s}", e.name) }
+func (e pDeclarationShared) text() string { return fmt.Sprintf("shared-network %s", e.name) }
 
 type pDeclarationSubnet4 struct{ net.IPNet }
 
 func (e pDeclarationSubnet4) repr() string { return fmt.Sprintf("{subnet4 %s}", e.String()) }
 
+func (e pDeclarationSubnet4) text() string {
+	return fmt.Sprintf("subnet %s netmask %s", e.IP.String(), net.IP(e.Mask).String())
+}
+
 type pDeclarationSubnet6 struct{ net.IPNet }
 
 func (e pDeclarationSubnet6) repr() string { return fmt.Sprintf("{subnet6 %s}", e.String()) }
+func (e pDeclarationSubnet6) text() string { return fmt.Sprintf("subnet6 %s", e.String()) }
 
 type pDeclarationHost struct{ name string }
 
 func (e pDeclarationHost) repr() string { return fmt.Sprintf("{host name:%s}", e.name) }
+func (e pDeclarationHost) text() string { return fmt.Sprintf("host %s", e.name) }
 
 type pDeclarationPool struct{}
 
 func (e pDeclarationPool) repr() string { return "{pool}" }
+func (e pDeclarationPool) text() string { return "pool" }
 
 type pDeclarationGroup struct{}
 
 func (e pDeclarationGroup) repr() string { return "{group}" }
+func (e pDeclarationGroup) text() string { return "group" }
 
 /** parsers */
 func parseParameter(val tkParameter) (pParameter, error) {
 	switch val.name {
 	case "include":
-		if len(val.operand) != 2 {
+		if len(val.operand) != 1 {
 			return nil, fmt.Errorf("invalid number of parameters for pParameterInclude : %v", val.operand)
 		}
 
@@ -676,19 +1017,21 @@ func parseParameter(val tkParameter) (pParameter, error) {
 			return nil, fmt.Errorf("invalid number of parameters for pParameterRange4 : %v", val.operand)
 		}
 
-		idxAddress := map[bool]int{true: 1, false: 0}[strings.ToLower(val.operand[0]) == "bootp"]
-		if len(val.operand) > 2+idxAddress {
+		flag := strings.ToLower(val.operand[0])
+		bootp := flag == "bootp" || flag == "dynamic-bootp"
+		idxAddress := map[bool]int{true: 1, false: 0}[bootp]
+		if len(val.operand) > 2+idxAddress || len(val.operand) < 1+idxAddress {
 			return nil, fmt.Errorf("invalid number of parameters for pParameterRange : %v", val.operand)
 		}
 
-		if idxAddress+1 > len(val.operand) {
+		if idxAddress+1 == len(val.operand) {
 			res := net.ParseIP(val.operand[idxAddress])
-			return pParameterRange4{min: res, max: res}, nil
+			return pParameterRange4{min: res, max: res, bootp: bootp}, nil
 		}
 
 		addr1 := net.ParseIP(val.operand[idxAddress])
 		addr2 := net.ParseIP(val.operand[idxAddress+1])
-		return pParameterRange4{min: addr1, max: addr2}, nil
+		return pParameterRange4{min: addr1, max: addr2, bootp: bootp}, nil
 
 	case "range6":
 		if len(val.operand) == 1 {
@@ -745,7 +1088,7 @@ func parseParameter(val tkParameter) (pParameter, error) {
 			return nil, fmt.Errorf("invalid number of parameters for pParameterRange6 : %v", val.operand)
 		}
 
-		bits, err := strconv.Atoi(val.operand[2])
+		bits, err := strconv.Atoi(strings.TrimPrefix(val.operand[2], "/"))
 		if err != nil {
 			return nil, fmt.Errorf("invalid bits for pParameterPrefix6 : %v", val.operand[2])
 		}
@@ -886,13 +1229,20 @@ func parseTokenGroup(val tkGroup) (*pDeclaration, error) {
 	return nil, fmt.Errorf("invalid pDeclaration : %v : %v", val.id.name, params)
 }
 
-func flattenDhcpConfig(root tkGroup) (*pDeclaration, error) {
+// flattenDhcpConfig converts the tkGroup tree into a pDeclaration tree. The
+// source, if any, is the text of the group, which is kept with the
+// declaration if it matches the group.
+func flattenDhcpConfig(root tkGroup, source *dhcpSource) (*pDeclaration, error) {
 	result, err := parseTokenGroup(root)
 
 	if err != nil {
 		return nil, err
 	}
 
+	if source != nil && (source.parameters != len(root.params) || source.declarations != len(root.groups)) {
+		source = nil
+	}
+
 	for _, p := range root.params {
 		param, err := parseParameter(p)
 		if err != nil {
@@ -901,8 +1251,12 @@ func flattenDhcpConfig(root tkGroup) (*pDeclaration, error) {
 		result.parameters = append(result.parameters, param)
 	}
 
-	for _, p := range root.groups {
-		group, err := flattenDhcpConfig(*p)
+	for i, p := range root.groups {
+		var groupSource *dhcpSource
+		if source != nil {
+			groupSource = source.statement(true, i).source
+		}
+		group, err := flattenDhcpConfig(*p, groupSource)
 		if err != nil {
 			return nil, err
 		}
@@ -910,9 +1264,29 @@ func flattenDhcpConfig(root tkGroup) (*pDeclaration, error) {
 		result.declarations = append(result.declarations, *group)
 	}
 
+	if source != nil {
+		source.id = result.id.text()
+		for i := range source.statements {
+			if st := &source.statements[i]; !st.declaration {
+				st.parameter = result.parameters[st.index].text()
+			}
+		}
+		result.source = source
+	}
+
 	return result, nil
 }
 
+// statement returns the text of the parameter or the declaration at the index.
+func (s *dhcpSource) statement(declaration bool, index int) *dhcpStatement {
+	for i := range s.statements {
+		if st := &s.statements[i]; st.declaration == declaration && st.index == index {
+			return st
+		}
+	}
+	return nil
+}
+
 /** reduce the tree into the things that we care about */
 type grant uint
 
@@ -1122,7 +1496,14 @@ func (e *ConfigDeclaration) Hardware() (net.HardwareAddr, error) {
 type DhcpConfiguration []ConfigDeclaration
 
 func ReadDhcpConfiguration(fd *os.File) (DhcpConfiguration, error) {
-	fromfile := consumeFile(fd)
+	// The contents of the file are kept, so that the configuration can be
+	// written back as it was read.
+	contents, err := io.ReadAll(fd)
+	if err != nil {
+		return nil, err
+	}
+
+	fromfile := consumeBytes(contents)
 	uncommented := uncomment(fromfile)
 	tokenized := tokenizeDhcpConfig(uncommented)
 
@@ -1136,7 +1517,7 @@ func ReadDhcpConfiguration(fd *os.File) (DhcpConfiguration, error) {
 	// Flatten the tree into a list of pDeclaration objects. This is responsible
 	// for actually propagating options from the parent pDeclaration into all of
 	// its children.
-	global, err := flattenDhcpConfig(parsetree)
+	global, err := flattenDhcpConfig(parsetree, scanDhcpSource(string(contents)))
 	if err != nil {
 		return nil, err
 	}
@@ -1223,6 +1604,21 @@ func (e *DhcpConfiguration) HostByName(host string) (ConfigDeclaration, error) {
 	return result[0], nil
 }
 
+// WriteDhcpConfiguration writes the DHCP configuration in the ISC dhcpd
+// configuration file format. A configuration read from a file is written back
+// byte for byte, including its comments and formatting, except for the
+// parameters and declarations that are modified, which are formatted in place,
+// and the ones that are added, which are formatted after the existing ones.
+func WriteDhcpConfiguration(w io.Writer, config DhcpConfiguration) error {
+	if len(config) == 0 || len(config[0].composites) != 1 {
+		return errors.New("no global declaration found")
+	}
+
+	global := config[0].composites[0]
+	_, err := io.WriteString(w, global.text(""))
+	return err
+}
+
 // NetworkMap represents a collection of configurations, where each configuration is a map of string key-value pairs.
 type NetworkMap []map[string]string
 
@@ -1617,6 +2013,34 @@ func (e networkingCommandEntry) Repr() string {
 	return fmt.Sprintf("%s -> %v", entryN, result)
 }
 
+// key returns the setting that the entry adds or removes, which matches the
+// key of the networkingRecord describing the setting.
+func (e networkingCommandEntry) key() string {
+	switch e.entry.(type) {
+	case networkingCommandEntryAnswer:
+		return fmt.Sprintf("answer %d %s", e.answer.vnet.Number(), e.answer.vnet.Option())
+	case networkingCommandEntryRemoveAnswer:
+		return fmt.Sprintf("answer %d %s", e.removeAnswer.vnet.Number(), e.removeAnswer.vnet.Option())
+	case networkingCommandEntryAddNatPortFwd:
+		return fmt.Sprintf("add_nat_portfwd %d %s/%d", e.addNatPortFwd.vnet, e.addNatPortFwd.protocol, e.addNatPortFwd.port)
+	case networkingCommandEntryRemoveNatPortFwd:
+		return fmt.Sprintf("add_nat_portfwd %d %s/%d", e.removeNatPortFwd.vnet, e.removeNatPortFwd.protocol, e.removeNatPortFwd.port)
+	case networkingCommandEntryAddDhcpMacToIp:
+		return fmt.Sprintf("add_dhcp_mac_to_ip %d %s", e.addDhcpMacToIp.vnet, e.addDhcpMacToIp.mac.String())
+	case networkingCommandEntryRemoveDhcpMacToIp:
+		return fmt.Sprintf("add_dhcp_mac_to_ip %d %s", e.removeDhcpMacToIp.vnet, e.removeDhcpMacToIp.mac.String())
+	case networkingCommandEntryAddBridgeMapping:
+		return fmt.Sprintf("add_bridge_mapping %s", e.addBridgeMapping.intf.name)
+	case networkingCommandEntryRemoveBridgeMapping:
+		return fmt.Sprintf("add_bridge_mapping %s", e.removeBridgeMapping.intf.name)
+	case networkingCommandEntryAddNatPrefix:
+		return fmt.Sprintf("add_nat_prefix %d", e.addNatPrefix.vnet)
+	case networkingCommandEntryRemoveNatPrefix:
+		return fmt.Sprintf("add_nat_prefix %d", e.removeNatPrefix.vnet)
+	}
+	return ""
+}
+
 // networking command entry parsers
 func parseNetworkingCommandAnswer(row []string) (*networkingCommandEntry, error) {
 	if len(row) != 2 {
@@ -1888,6 +2312,49 @@ type NetworkingConfig struct {
 	//bridge_mapping map[net.Interface]uint64	// XXX: we don't need the actual interface for anything but informing the user.
 	bridgeMapping map[string]int
 	natPrefix     map[int][]int
+
+	// rows contains the rows of the file that the configuration was read
+	// from, which are written back unchanged unless they were modified.
+	rows []networkingRow
+}
+
+// networkingRow is a row of a networking file, including its line ending,
+// along with the command that the row contains, if any.
+type networkingRow struct {
+	text  string
+	entry *networkingCommandEntry
+}
+
+// readNetworkingRows splits the contents of a networking file into its rows.
+// A file using lone carriage returns as line endings is not split, since those
+// rows cannot be written back unchanged.
+func readNetworkingRows(data string) []networkingRow {
+	if strings.Count(data, "\r") != strings.Count(data, "\r\n") {
+		return nil
+	}
+
+	var rows []networkingRow
+	for len(data) > 0 {
+		line := data
+		if index := strings.IndexByte(data, '\n'); index >= 0 {
+			line = data[:index+1]
+		}
+		data = data[len(line):]
+
+		row := networkingRow{text: line}
+		fields := strings.FieldsFunc(line, func(r rune) bool {
+			return r == ' ' || r == '\t' || r == '\r' || r == '\n'
+		})
+		if len(fields) > 0 {
+			if parser := NetworkingParserByCommand(fields[0]); parser != nil {
+				if entry, err := (*parser)(fields[1:]); err == nil {
+					row.entry = entry
+				}
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows
 }
 
 func (c NetworkingConfig) repr() string {
@@ -2013,9 +2480,14 @@ func flattenNetworkingConfig(in chan networkingCommandEntry) NetworkingConfig {
 // ReadNetworkingConfig reads and parses a networking configuration file.
 func ReadNetworkingConfig(fd *os.File) (NetworkingConfig, error) {
 
+	data, err := io.ReadAll(fd)
+	if err != nil {
+		return NetworkingConfig{}, err
+	}
+
 	// start piecing together all the different parts of the file and split
 	// it into its individual rows.
-	fromfile := consumeFile(fd)
+	fromfile := consumeBytes(data)
 	tokenized := tokenizeNetworkingConfig(fromfile)
 	rows := splitNetworkingConfig(tokenized)
 
@@ -2037,7 +2509,258 @@ func ReadNetworkingConfig(fd *os.File) (NetworkingConfig, error) {
 	entries := parseNetworkingConfig(rows)
 
 	// convert what we've parsed into a configuration that's easy to interpret
-	return flattenNetworkingConfig(entries), nil
+	result := flattenNetworkingConfig(entries)
+	result.rows = readNetworkingRows(string(data))
+	return result, nil
+}
+
+// WriteNetworkingConfig writes the networking configuration in the format of
+// the networking file. If the configuration was read from a file, the rows of
+// the file are written back unchanged, except for the entries that were
+// modified or removed. Entries that were added are written after the last row
+// of the same command, or at the end of the file. Otherwise, the entries are
+// written sorted by virtual network.
+func WriteNetworkingConfig(w io.Writer, config NetworkingConfig) error {
+	records, err := networkingRecords(config)
+	if err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	if config.rows == nil {
+		b.WriteString("VERSION=1,0\n")
+		for _, r := range records {
+			for _, line := range r.lines {
+				b.WriteString(line + "\n")
+			}
+		}
+	} else if err := writeNetworkingRows(&b, config.rows, records); err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+// networkingRecord contains the rows of the networking file that describe a
+// single setting, which is identified by its key.
+type networkingRecord struct {
+	key   string
+	lines []string
+}
+
+func (r networkingRecord) command() string {
+	command, _, _ := strings.Cut(r.key, " ")
+	return command
+}
+
+// networkingRecords returns the rows describing the networking configuration,
+// sorted by virtual network.
+func networkingRecords(config NetworkingConfig) ([]networkingRecord, error) {
+	var records []networkingRecord
+
+	for _, vnet := range sortedVnets(config.answer) {
+		answers := config.answer[vnet]
+
+		options := make([]string, 0, len(answers))
+		for option := range answers {
+			options = append(options, option)
+		}
+		sort.Strings(options)
+
+		for _, option := range options {
+			value := answers[option]
+			if value == "" || strings.ContainsAny(value, " \t\r\n") {
+				return nil, fmt.Errorf("invalid value for answer VNET_%d_%s : %q", vnet, option, value)
+			}
+			records = append(records, networkingRecord{
+				key:   fmt.Sprintf("answer %d %s", vnet, option),
+				lines: []string{fmt.Sprintf("answer VNET_%d_%s %s", vnet, option, value)},
+			})
+		}
+	}
+
+	for _, vnet := range sortedVnets(config.natPortFwd) {
+		portfwds := config.natPortFwd[vnet]
+
+		var entries []networkingCommandEntryAddNatPortFwd
+		for protoport, target := range portfwds {
+			protocol, port, ok := strings.Cut(protoport, "/")
+			if !ok {
+				return nil, fmt.Errorf("invalid nat port-forward on interface %s%d : %v", NetworkingInterfacePrefix, vnet, protoport)
+			}
+			sport, err := strconv.Atoi(port)
+			if err != nil {
+				return nil, fmt.Errorf("invalid nat port-forward on interface %s%d : %v", NetworkingInterfacePrefix, vnet, protoport)
+			}
+
+			host, port, err := net.SplitHostPort(target)
+			if err != nil {
+				return nil, fmt.Errorf("invalid nat port-forward target on interface %s%d : %v", NetworkingInterfacePrefix, vnet, target)
+			}
+			dport, err := strconv.Atoi(port)
+			if err != nil {
+				return nil, fmt.Errorf("invalid nat port-forward target on interface %s%d : %v", NetworkingInterfacePrefix, vnet, target)
+			}
+
+			entries = append(entries, networkingCommandEntryAddNatPortFwd{vnet: vnet, protocol: protocol, port: sport, targetHost: net.ParseIP(host), targetPort: dport})
+		}
+
+		sort.Slice(entries, func(i, j int) bool {
+			if entries[i].protocol != entries[j].protocol {
+				return entries[i].protocol < entries[j].protocol
+			}
+			return entries[i].port < entries[j].port
+		})
+
+		for _, e := range entries {
+			if e.targetHost == nil {
+				return nil, fmt.Errorf("invalid nat port-forward target on interface %s%d : %s/%d", NetworkingInterfacePrefix, vnet, e.protocol, e.port)
+			}
+			records = append(records, networkingRecord{
+				key:   fmt.Sprintf("add_nat_portfwd %d %s/%d", vnet, e.protocol, e.port),
+				lines: []string{fmt.Sprintf("add_nat_portfwd %d %s %d %s %d", e.vnet+1, e.protocol, e.port, e.targetHost.String(), e.targetPort)},
+			})
+		}
+	}
+
+	for _, vnet := range sortedVnets(config.dhcpMacToIp) {
+		dhcpmacs := config.dhcpMacToIp[vnet]
+
+		macs := make([]string, 0, len(dhcpmacs))
+		for mac := range dhcpmacs {
+			macs = append(macs, mac)
+		}
+		sort.Strings(macs)
+
+		for _, mac := range macs {
+			records = append(records, networkingRecord{
+				key:   fmt.Sprintf("add_dhcp_mac_to_ip %d %s", vnet, mac),
+				lines: []string{fmt.Sprintf("add_dhcp_mac_to_ip %d %s %s", vnet+1, mac, dhcpmacs[mac].String())},
+			})
+		}
+	}
+
+	intfs := make([]string, 0, len(config.bridgeMapping))
+	for intf := range config.bridgeMapping {
+		intfs = append(intfs, intf)
+	}
+	sort.Strings(intfs)
+
+	for _, intf := range intfs {
+		records = append(records, networkingRecord{
+			key:   fmt.Sprintf("add_bridge_mapping %s", intf),
+			lines: []string{fmt.Sprintf("add_bridge_mapping %s %d", intf, config.bridgeMapping[intf]+1)},
+		})
+	}
+
+	for _, vnet := range sortedVnets(config.natPrefix) {
+		if len(config.natPrefix[vnet]) == 0 {
+			continue
+		}
+		record := networkingRecord{key: fmt.Sprintf("add_nat_prefix %d", vnet)}
+		for _, prefix := range config.natPrefix[vnet] {
+			record.lines = append(record.lines, fmt.Sprintf("add_nat_prefix %d /%d", vnet+1, prefix))
+		}
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+// writeNetworkingRows writes the rows of a networking file, replacing the rows
+// of the settings that differ from the records.
+func writeNetworkingRows(b *strings.Builder, rows []networkingRow, records []networkingRecord) error {
+	// Replay the rows to determine the original value of each setting.
+	entries := make(chan networkingCommandEntry)
+	go func() {
+		for _, row := range rows {
+			if row.entry != nil {
+				entries <- *row.entry
+			}
+		}
+		close(entries)
+	}()
+	originals, err := networkingRecords(flattenNetworkingConfig(entries))
+	if err != nil {
+		return err
+	}
+
+	original := make(map[string]string)
+	for _, r := range originals {
+		original[r.key] = strings.Join(r.lines, "\n")
+	}
+	current := make(map[string]networkingRecord)
+	for _, r := range records {
+		current[r.key] = r
+	}
+
+	newline := "\n"
+	last := make(map[string]int)
+	lastCommand := make(map[string]int)
+	for index, row := range rows {
+		if strings.HasSuffix(row.text, "\r\n") {
+			newline = "\r\n"
+		}
+		if row.entry != nil {
+			key := row.entry.key()
+			last[key] = index
+			lastCommand[networkingRecord{key: key}.command()] = index
+		}
+	}
+
+	// Settings that are not in the file follow the last row of the same
+	// command, or the end of the file.
+	added := make(map[int][]networkingRecord)
+	for _, r := range records {
+		if _, ok := last[r.key]; ok {
+			continue
+		}
+		index, ok := lastCommand[r.command()]
+		if !ok {
+			index = len(rows) - 1
+		}
+		added[index] = append(added[index], r)
+	}
+
+	writeLines := func(lines []string) {
+		if s := b.String(); len(s) > 0 && !strings.HasSuffix(s, "\n") {
+			b.WriteString(newline)
+		}
+		for _, line := range lines {
+			b.WriteString(line + newline)
+		}
+	}
+
+	for index, row := range rows {
+		if row.entry == nil {
+			b.WriteString(row.text)
+		} else {
+			key := row.entry.key()
+			r, ok := current[key]
+			if strings.Join(r.lines, "\n") == original[key] {
+				b.WriteString(row.text)
+			} else if ok && last[key] == index {
+				// A modified setting replaces its last row.
+				writeLines(r.lines)
+			}
+		}
+		for _, r := range added[index] {
+			writeLines(r.lines)
+		}
+	}
+	return nil
+}
+
+// sortedVnets returns the virtual networks indexing the map in ascending
+// order.
+func sortedVnets[V any](m map[int]V) []int {
+	keys := make([]int, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	return keys
 }
 
 // NetworkingType represents the type of network configuration.
@@ -2163,6 +2886,18 @@ func (c NetworkingConfig) DeviceIntoName(device string) (string, error) {
 	return "", fmt.Errorf("unable to determine network type for device %s%d", NetworkingInterfacePrefix, vmnet)
 }
 
+/** generic async byte reader */
+func consumeBytes(data []byte) chan byte {
+	fromBytes := make(chan byte)
+	go func() {
+		for _, b := range data {
+			fromBytes <- b
+		}
+		close(fromBytes)
+	}()
+	return fromBytes
+}
+
 /** generic async file reader */
 func consumeFile(fd *os.File) chan byte {
 	fromFile := make(chan byte)
@@ -2579,3 +3314,18 @@ func ReadAppleDhcpdLeaseEntries(fd *os.File) ([]appleDhcpLeaseEntry, error) {
 	}
 	return result, nil
 }
+
+// parseSnapshotList parses the output of `vmrun listSnapshots` and returns the
+// names of the snapshots. The first line of the output reports the total
+// number of snapshots and is followed by one snapshot name per line.
+func parseSnapshotList(stdout string) []string {
+	var snapshots []string
+	for _, line := range strings.Split(stdout, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "Total snapshots:") {
+			continue
+		}
+		snapshots = append(snapshots, line)
+	}
+	return snapshots
+}
@@ -9,8 +9,10 @@ import (
 
 	"bytes"
 	"encoding/hex"
+	"net"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 )
 
@@ -372,6 +374,260 @@ parameters : map[default-lease-time:1800 max-lease-time:7200]
 	}
 }
 
+func readDhcpConfigFromString(t *testing.T, s string) DhcpConfiguration {
+	path := filepath.Join(t.TempDir(), "dhcpd.conf")
+	if err := os.WriteFile(path, []byte(s), 0644); err != nil {
+		t.Fatalf("Failed to write dhcpd.conf: %s", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open dhcpd.conf: %s", err)
+	}
+	defer f.Close()
+
+	config, err := ReadDhcpConfiguration(f)
+	if err != nil {
+		t.Fatalf("Failed to read dhcpd.conf: %s", err)
+	}
+	return config
+}
+
+func TestParserWriteDhcpConfig(t *testing.T) {
+	expected, err := os.ReadFile(filepath.Join("testdata", "dhcpd-example.conf"))
+	if err != nil {
+		t.Fatalf("Failed to read dhcpd.conf sample: %s", err)
+	}
+
+	f, err := os.Open(filepath.Join("testdata", "dhcpd-example.conf"))
+	if err != nil {
+		t.Fatalf("Failed to open dhcpd.conf sample: %s", err)
+	}
+	defer f.Close()
+
+	config, err := ReadDhcpConfiguration(f)
+	if err != nil {
+		t.Fatalf("Failed to read dhcpd.conf sample: %s", err)
+	}
+
+	// The sample is written back byte for byte, including its comments.
+	var b bytes.Buffer
+	if err := WriteDhcpConfiguration(&b, config); err != nil {
+		t.Fatalf("Failed to write dhcpd.conf sample: %s", err)
+	}
+	if b.String() != string(expected) {
+		t.Errorf("Writing of dhcpd.conf sample did not match the sample")
+		t.Logf("Result from writing:\n%s", b.String())
+		t.Logf("Expected to write:\n%s", expected)
+	}
+}
+
+func TestParserWriteDhcpConfigModified(t *testing.T) {
+	f, err := os.Open(filepath.Join("testdata", "dhcpd-example.conf"))
+	if err != nil {
+		t.Fatalf("Failed to open dhcpd.conf sample: %s", err)
+	}
+	defer f.Close()
+
+	config, err := ReadDhcpConfiguration(f)
+	if err != nil {
+		t.Fatalf("Failed to read dhcpd.conf sample: %s", err)
+	}
+
+	global := config[0].composites[0]
+	global.parameters = append(global.parameters, pParameterOther{parameter: "ddns-update-style", value: "none"})
+
+	subnet := &global.declarations[0]
+	subnet.parameters[2] = pParameterOther{parameter: "default-lease-time", value: "3600"}
+	subnet.declarations = append(subnet.declarations, pDeclaration{
+		id:         pDeclarationPool{},
+		parameters: []pParameter{pParameterRange4{min: net.ParseIP("172.33.33.10"), max: net.ParseIP("172.33.33.20")}},
+	})
+
+	host := &global.declarations[1]
+	host.parameters = host.parameters[:2]
+
+	global.declarations = append(global.declarations, pDeclaration{
+		id:         pDeclarationHost{name: "packer"},
+		parameters: []pParameter{pParameterAddress4{"172.33.33.5"}},
+	})
+	config[0].composites[0] = global
+
+	// Only the modified parameters and declarations are rewritten.
+	expected := "allow unknown-clients;\t\t# global.grants\n" +
+		"default-lease-time 1800;    # global.parameters\n" +
+		"max-lease-time 7200;        # global.parameters\n" +
+		"ddns-update-style none;\n" +
+		"\n" +
+		"subnet 172.33.33.0 netmask 255.255.255.0 {\t\t# subnet4\n" +
+		"\trange 172.33.33.128 172.33.33.254;\t\t\t# subnet4.address\n" +
+		"\toption broadcast-address 172.33.33.255;\t\t# subnet4.options\n" +
+		"#\tallow unknown-clients;\t\t\t\t\t\t# subnet4.grants\n" +
+		"\tdefault-lease-time 3600;                \t# subnet4.parameters\n" +
+		"\tmax-lease-time 9600;                    \t# subnet4.parameters\n" +
+		"\toption routers 172.33.33.2;\t\t\t\t\t# subnet4.options\n" +
+		"\tpool {\n" +
+		"\t\trange 172.33.33.10 172.33.33.20;\n" +
+		"\t}\n" +
+		"}\n" +
+		"host vmnet8 {\t\t\t\t\t\t\t\t# host\n" +
+		"\thardware ethernet 00:50:56:C0:00:08;\t# host.address\n" +
+		"\tfixed-address 172.33.33.1;\t\t\t\t# host.address\n" +
+		"#\tallow unknown-clients;\t\t\t\t\t# subnet4.grants\n" +
+		"#\tdefault-lease-time 1800;               \t# subnet4.parameters\n" +
+		"#\tmax-lease-time 7200;                   \t# subnet4.parameters\n" +
+		"}\n" +
+		"\n" +
+		"host packer {\n" +
+		"\tfixed-address 172.33.33.5;\n" +
+		"}\n"
+
+	var b bytes.Buffer
+	if err := WriteDhcpConfiguration(&b, config); err != nil {
+		t.Fatalf("Failed to write DHCP configuration: %s", err)
+	}
+	if b.String() != expected {
+		t.Errorf("Writing of the modified dhcpd.conf sample did not match what was expected")
+		t.Logf("Result from writing:\n%s", b.String())
+		t.Logf("Expected to write:\n%s", expected)
+	}
+
+	// The written configuration must parse into the modified declarations.
+	result := readDhcpConfigFromString(t, b.String())
+	if len(result) != len(config)+2 {
+		t.Fatalf("expected %d entries, got %d", len(config)+2, len(result))
+	}
+	if decl, err := result.HostByName("packer"); err != nil {
+		t.Errorf("expected the added host declaration: %s", err)
+	} else if ip, err := decl.IP4(); err != nil || !ip.Equal(net.ParseIP("172.33.33.5")) {
+		t.Errorf("expected the address of the added host declaration, got %v: %v", ip, err)
+	}
+}
+
+func TestParserWriteDhcpConfigFormatted(t *testing.T) {
+	expected := `allow unknown-clients;
+default-lease-time 1800;
+
+subnet 172.33.33.0 netmask 255.255.255.0 {
+	range 172.33.33.128 172.33.33.254;
+	host vmnet8 {
+		hardware ethernet 00:50:56:c0:00:08;
+	}
+}
+`
+
+	// A configuration that was not read from a file is formatted.
+	_, subnet, _ := net.ParseCIDR("172.33.33.0/24")
+	global := pDeclaration{
+		id: pDeclarationGlobal{},
+		parameters: []pParameter{
+			pParameterGrant{verb: "allow", attribute: "unknown-clients"},
+			pParameterOther{parameter: "default-lease-time", value: "1800"},
+		},
+		declarations: []pDeclaration{{
+			id:         pDeclarationSubnet4{*subnet},
+			parameters: []pParameter{pParameterRange4{min: net.ParseIP("172.33.33.128"), max: net.ParseIP("172.33.33.254")}},
+			declarations: []pDeclaration{{
+				id:         pDeclarationHost{name: "vmnet8"},
+				parameters: []pParameter{pParameterHardware{class: "ethernet", address: []byte{0x00, 0x50, 0x56, 0xc0, 0x00, 0x08}}},
+			}},
+		}},
+	}
+
+	var b bytes.Buffer
+	if err := WriteDhcpConfiguration(&b, DhcpConfiguration{createDeclaration(global)}); err != nil {
+		t.Fatalf("Failed to write DHCP configuration: %s", err)
+	}
+	if b.String() != expected {
+		t.Errorf("Writing of DHCP configuration did not match what was expected")
+		t.Logf("Result from writing:\n%s", b.String())
+		t.Logf("Expected to write:\n%s", expected)
+	}
+}
+
+func TestParserWriteDhcpConfigRoundTrip(t *testing.T) {
+	// Every kind of parameter and declaration in the format that is written,
+	// so that reading and writing it reproduces the input exactly.
+	input := `include "/etc/dhcpd.d/packer.conf";
+ddns-update-style none;
+authoritative;
+not ignore-client-uids;
+deny bootp;
+ignore client-updates;
+option domain-name-servers 172.33.33.2;
+ddns-hostname = concat("packer-",binary-to-ascii(16,8,":",substring(hardware,1,6)));
+
+shared-network packer {
+	subnet 172.33.33.0 netmask 255.255.255.0 {
+		option routers 172.33.33.2;
+		pool {
+			range dynamic-bootp 172.33.33.100 172.33.33.127;
+			range 172.33.33.128 172.33.33.254;
+		}
+	}
+}
+
+subnet6 fd00:33::/64 {
+	range6 fd00:33::100 fd00:33::1ff;
+	range6 fd00:33::3;
+	prefix6 fd00:33:0:100:: fd00:33:0:f00:: /56;
+}
+
+group {
+	default-lease-time 3600;
+	host vmnet8 {
+		hardware ethernet 00:50:56:c0:00:08;
+		fixed-address 172.33.33.1;
+		fixed-address6 fd00:33::1;
+		host-identifier option dhcp6.client-id 00:01:00:01:00:00:00:00:00:50:56:c0:00:08;
+	}
+}
+`
+
+	config := readDhcpConfigFromString(t, input)
+
+	var b bytes.Buffer
+	if err := WriteDhcpConfiguration(&b, config); err != nil {
+		t.Fatalf("Failed to write DHCP configuration: %s", err)
+	}
+	if b.String() != input {
+		t.Errorf("Round-trip of DHCP configuration did not match the input")
+		t.Logf("Result from round-trip:\n%s", b.String())
+		t.Logf("Input:\n%s", input)
+	}
+}
+
+func TestParserWriteDhcpConfigUnmodified(t *testing.T) {
+	// Configurations that are written back byte for byte.
+	tests := []string{
+		"",
+		"# only a comment\n",
+		"default-lease-time 1800;",
+		"default-lease-time 1800; max-lease-time 7200;\r\nhost a { fixed-address 10.0.0.1; } # a\r\n",
+		"option domain-name \"packer # test\";\n\n\n",
+		"subnet 10.0.0.0 netmask 255.0.0.0 {\n  pool {\n    range 10.0.0.2 10.0.0.9;  # pool\n  }\n\n  # no hosts\n}  # end",
+	}
+
+	for testnum, test := range tests {
+		config := readDhcpConfigFromString(t, test)
+
+		var b bytes.Buffer
+		if err := WriteDhcpConfiguration(&b, config); err != nil {
+			t.Fatalf("test %d failed to write DHCP configuration: %s", 1+testnum, err)
+		}
+		if b.String() != test {
+			t.Errorf("test %d did not match the input: %q", 1+testnum, b.String())
+		}
+	}
+}
+
+func TestParserWriteDhcpConfigEmpty(t *testing.T) {
+	var b bytes.Buffer
+	if err := WriteDhcpConfiguration(&b, DhcpConfiguration{}); err == nil {
+		t.Errorf("expected an error writing a configuration without a global declaration")
+	}
+}
+
 func TestParserTokenizeNetworkMap(t *testing.T) {
 
 	test1 := "group.attribute = \"string\""
@@ -1286,3 +1542,175 @@ func TestParserReadNetworingConfig(t *testing.T) {
 		t.Errorf("unable to find VNET_%d answer", 8-1)
 	}
 }
+
+func TestParserWriteNetworkingConfig(t *testing.T) {
+	expected, err := os.ReadFile(filepath.Join("testdata", "networking-example"))
+	if err != nil {
+		t.Fatalf("Unable to read networking-example sample: %s", err)
+	}
+
+	f, err := os.Open(filepath.Join("testdata", "networking-example"))
+	if err != nil {
+		t.Fatalf("Unable to open networking-example sample: %s", err)
+	}
+	defer f.Close()
+
+	config, err := ReadNetworkingConfig(f)
+	if err != nil {
+		t.Fatalf("error parsing networking-example: %s", err)
+	}
+
+	var b bytes.Buffer
+	if err := WriteNetworkingConfig(&b, config); err != nil {
+		t.Fatalf("error writing networking-example: %s", err)
+	}
+
+	if b.String() != string(expected) {
+		t.Errorf("Writing of networking-example did not match the sample")
+		t.Logf("Result from writing:\n%s", b.String())
+		t.Logf("Expected to write:\n%s", expected)
+	}
+}
+
+func TestParserWriteNetworkingConfigModified(t *testing.T) {
+	input := strings.Join([]string{
+		"VERSION=1,0",
+		"answer VNET_1_DHCP yes",
+		"answer VNET_1_NAT no",
+		"answer VNET_8_NAT yes",
+		"add_nat_portfwd 8 tcp 2222 172.16.41.129 22",
+		"add_nat_portfwd 8 tcp 2200 172.16.41.129 3389",
+		"add_nat_portfwd 8  udp 53 172.16.41.2 53",
+		"add_bridge_mapping en0 1",
+	}, "\r\n")
+
+	path := filepath.Join(t.TempDir(), "networking")
+	if err := os.WriteFile(path, []byte(input), 0644); err != nil {
+		t.Fatalf("error writing networking configuration: %s", err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("error opening networking configuration: %s", err)
+	}
+	defer f.Close()
+
+	config, err := ReadNetworkingConfig(f)
+	if err != nil {
+		t.Fatalf("error parsing networking configuration: %s", err)
+	}
+
+	config.answer[1]["NAT"] = "yes"
+	delete(config.natPortFwd[7], "tcp/2200")
+	config.natPortFwd[7]["tcp/2201"] = "172.16.41.130:3389"
+	config.dhcpMacToIp[7] = map[string]net.IP{"00:50:56:00:00:01": net.ParseIP("172.16.41.3")}
+
+	// Only the modified rows differ, and the added rows follow the rows of
+	// the same command.
+	expected := strings.Join([]string{
+		"VERSION=1,0",
+		"answer VNET_1_DHCP yes",
+		"answer VNET_1_NAT yes",
+		"answer VNET_8_NAT yes",
+		"add_nat_portfwd 8 tcp 2222 172.16.41.129 22",
+		"add_nat_portfwd 8  udp 53 172.16.41.2 53",
+		"add_nat_portfwd 8 tcp 2201 172.16.41.130 3389",
+		"add_bridge_mapping en0 1",
+		"add_dhcp_mac_to_ip 8 00:50:56:00:00:01 172.16.41.3",
+		"",
+	}, "\r\n")
+
+	var b bytes.Buffer
+	if err := WriteNetworkingConfig(&b, config); err != nil {
+		t.Fatalf("error writing networking configuration: %s", err)
+	}
+	if b.String() != expected {
+		t.Errorf("Writing of networking configuration did not match what was expected")
+		t.Logf("Result from writing:\n%q", b.String())
+		t.Logf("Expected to write:\n%q", expected)
+	}
+}
+
+func TestParserWriteNetworkingConfigRoundTrip(t *testing.T) {
+	config := NetworkingConfig{
+		answer: map[int]map[string]string{
+			8: {"DHCP": "yes", "NAT": "yes"},
+			1: {"DHCP": "no"},
+		},
+		natPortFwd: map[int]map[string]string{
+			7: {"udp/53": "172.16.41.2:53", "tcp/2222": "172.16.41.129:22", "tcp/443": "172.16.41.129:443"},
+		},
+		dhcpMacToIp: map[int]map[string]net.IP{
+			7: {"00:50:56:00:00:01": net.ParseIP("172.16.41.3")},
+		},
+		bridgeMapping: map[string]int{"en0": 0},
+		natPrefix:     map[int][]int{7: {24, 16}},
+	}
+
+	expected := `VERSION=1,0
+answer VNET_1_DHCP no
+answer VNET_8_DHCP yes
+answer VNET_8_NAT yes
+add_nat_portfwd 8 tcp 443 172.16.41.129 443
+add_nat_portfwd 8 tcp 2222 172.16.41.129 22
+add_nat_portfwd 8 udp 53 172.16.41.2 53
+add_dhcp_mac_to_ip 8 00:50:56:00:00:01 172.16.41.3
+add_bridge_mapping en0 1
+add_nat_prefix 8 /24
+add_nat_prefix 8 /16
+`
+
+	var b bytes.Buffer
+	if err := WriteNetworkingConfig(&b, config); err != nil {
+		t.Fatalf("error writing networking configuration: %s", err)
+	}
+	if b.String() != expected {
+		t.Errorf("Writing of networking configuration did not match what was expected")
+		t.Logf("Result from writing:\n%s", b.String())
+		t.Logf("Expected to write:\n%s", expected)
+	}
+
+	path := filepath.Join(t.TempDir(), "networking")
+	if err := os.WriteFile(path, b.Bytes(), 0644); err != nil {
+		t.Fatalf("error writing networking configuration: %s", err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("error opening networking configuration: %s", err)
+	}
+	defer f.Close()
+
+	result, err := ReadNetworkingConfig(f)
+	if err != nil {
+		t.Fatalf("error parsing networking configuration: %s", err)
+	}
+	if result.repr() != config.repr() {
+		t.Errorf("Round-trip of networking configuration did not match the original")
+		t.Logf("Result from round-trip:\n%s", result.repr())
+		t.Logf("Original:\n%s", config.repr())
+	}
+}
+
+func TestParserWriteNetworkingConfigInvalid(t *testing.T) {
+	config := NetworkingConfig{
+		answer: map[int]map[string]string{1: {"DHCP": "not valid"}},
+	}
+
+	var b bytes.Buffer
+	if err := WriteNetworkingConfig(&b, config); err == nil {
+		t.Errorf("expected an error writing an answer containing whitespace")
+	}
+}
+
+func TestParserParseSnapshotList(t *testing.T) {
+	stdout := "Total snapshots: 3\nbase\npatched \n\nprovisioned\n"
+
+	expected := []string{"base", "patched", "provisioned"}
+	result := parseSnapshotList(stdout)
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("expected %v, got %v", expected, result)
+	}
+
+	if result := parseSnapshotList("Total snapshots: 0"); len(result) != 0 {
+		t.Fatalf("expected no snapshots, got %v", result)
+	}
+}
@@ -0,0 +1,70 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGuestOpsArgs(t *testing.T) {
+	args := guestOpsArgs("ws", "foo.vmx", GuestCredentials{Username: "packer", Password: "secret"},
+		"copyFileFromHostToGuest", "src", "dst")
+
+	assert.Equal(t, []string{
+		"-T", "ws", "-gu", "packer", "-gp", "secret",
+		"copyFileFromHostToGuest", "foo.vmx", "src", "dst",
+	}, args)
+	assert.Equal(t, []string{
+		"-T", "ws", "-gu", "packer", "-gp", "<sensitive>",
+		"copyFileFromHostToGuest", "foo.vmx", "src", "dst",
+	}, redactArgs(args))
+	assert.Equal(t, "secret", args[5], "should not modify the arguments")
+}
+
+func TestGuestProgramExitCode(t *testing.T) {
+	matches := guestProgramExitCode.FindStringSubmatch("error: Guest program exited with non-zero exit code: 3")
+	assert.Equal(t, []string{"non-zero exit code: 3", "3"}, matches)
+
+	assert.True(t, parseDirectoryExists("The directory exists."))
+	assert.False(t, parseDirectoryExists("The directory does not exist."))
+}
+
+func TestVmwareDriverGuestAddress(t *testing.T) {
+	vmxPath := filepath.Join(t.TempDir(), "test.vmx")
+	contents := `ethernet0.generatedAddress = "00:0c:29:00:00:01"
+ethernet1.addressType = "static"
+ethernet1.address = "00:50:56:00:00:02"
+`
+	if err := os.WriteFile(vmxPath, []byte(contents), 0644); err != nil { //nolint:gosec
+		t.Fatalf("err: %s", err)
+	}
+
+	state := new(multistep.BasicStateBag)
+	state.Put("vmx_path", vmxPath)
+
+	d := new(VmwareDriver)
+	addr, err := d.GuestAddress(state)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assert.Equal(t, "00:0c:29:00:00:01", addr)
+
+	state.Put("vmnetwork_adapter", 1)
+	addr, err = d.GuestAddress(state)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assert.Equal(t, "00:50:56:00:00:02", addr)
+
+	state.Put("vmnetwork_adapter", 2)
+	if _, err := d.GuestAddress(state); err == nil {
+		t.Fatal("should error for a network adapter without an address")
+	}
+}
@@ -132,14 +132,18 @@ func (d *WorkstationDriver) Clone(dst, src string, linked bool, snapshot string)
 }
 
 // CompactDisk defragments and compacts the virtual disk to reclaim unused space.
-func (d *WorkstationDriver) CompactDisk(diskPath string) error {
+func (d *WorkstationDriver) CompactDisk(diskPath string, progress DiskProgress) error {
+	if d.VdiskManagerPath == "" {
+		return skipCompactDisk(diskPath)
+	}
+
 	defragCmd := exec.Command(d.VdiskManagerPath, "-d", diskPath)
-	if _, _, err := runAndLog(defragCmd); err != nil {
+	if err := runVdiskManager(defragCmd, progress); err != nil {
 		return err
 	}
 
 	shrinkCmd := exec.Command(d.VdiskManagerPath, "-k", diskPath)
-	if _, _, err := runAndLog(shrinkCmd); err != nil {
+	if err := runVdiskManager(shrinkCmd, progress); err != nil {
 		return err
 	}
 
@@ -147,8 +151,26 @@ func (d *WorkstationDriver) CompactDisk(diskPath string) error {
 }
 
 // CreateDisk creates a new virtual disk with the specified parameters.
-func (d *WorkstationDriver) CreateDisk(output string, size string, adapterType string, typeId string) error {
+func (d *WorkstationDriver) CreateDisk(output string, size string, adapterType string, typeId string, progress DiskProgress) error {
+	if d.VdiskManagerPath == "" {
+		return createDisk(output, size, adapterType, typeId)
+	}
+
 	cmd := exec.Command(d.VdiskManagerPath, "-c", "-s", size, "-a", adapterType, "-t", typeId, output)
+	if err := runVdiskManager(cmd, progress); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ExpandDisk expands the virtual disk to the specified size.
+func (d *WorkstationDriver) ExpandDisk(diskPath string, size string) error {
+	if d.VdiskManagerPath == "" {
+		return expandDiskUnsupported(diskPath)
+	}
+
+	cmd := exec.Command(d.VdiskManagerPath, "-x", size, diskPath)
 	if _, _, err := runAndLog(cmd); err != nil {
 		return err
 	}
@@ -163,6 +185,61 @@ func (d *WorkstationDriver) CreateSnapshot(vmxPath string, snapshotName string)
 	return err
 }
 
+// ListSnapshots returns the names of the snapshots of the virtual machine.
+func (d *WorkstationDriver) ListSnapshots(vmxPath string) ([]string, error) {
+	cmd := exec.Command(d.VmrunPath, "-T", "ws", "listSnapshots", vmxPath)
+	stdout, _, err := runAndLog(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseSnapshotList(stdout), nil
+}
+
+// RevertToSnapshot reverts the virtual machine to the named snapshot.
+func (d *WorkstationDriver) RevertToSnapshot(vmxPath string, snapshotName string) error {
+	cmd := exec.Command(d.VmrunPath, "-T", "ws", "revertToSnapshot", vmxPath, snapshotName)
+	_, _, err := runAndLog(cmd)
+	return err
+}
+
+// DeleteSnapshot deletes the named snapshot of the virtual machine.
+func (d *WorkstationDriver) DeleteSnapshot(vmxPath string, snapshotName string) error {
+	cmd := exec.Command(d.VmrunPath, "-T", "ws", "deleteSnapshot", vmxPath, snapshotName)
+	_, _, err := runAndLog(cmd)
+	return err
+}
+
+// RunProgramInGuest runs a program in the guest operating system and returns its exit code.
+func (d *WorkstationDriver) RunProgramInGuest(vmxPath string, credentials GuestCredentials, program string, args ...string) (int, error) {
+	cmdArgs := guestOpsArgs("ws", vmxPath, credentials, "runProgramInGuest", append([]string{program}, args...)...)
+	return runGuestProgram(exec.Command(d.VmrunPath, cmdArgs...))
+}
+
+// CopyFileFromHostToGuest copies a file from the host to the guest operating system.
+func (d *WorkstationDriver) CopyFileFromHostToGuest(vmxPath string, credentials GuestCredentials, src string, dst string) error {
+	cmd := exec.Command(d.VmrunPath, guestOpsArgs("ws", vmxPath, credentials, "copyFileFromHostToGuest", src, dst)...)
+	_, _, err := runAndLog(cmd)
+	return err
+}
+
+// CopyFileFromGuestToHost copies a file from the guest operating system to the host.
+func (d *WorkstationDriver) CopyFileFromGuestToHost(vmxPath string, credentials GuestCredentials, src string, dst string) error {
+	cmd := exec.Command(d.VmrunPath, guestOpsArgs("ws", vmxPath, credentials, "copyFileFromGuestToHost", src, dst)...)
+	_, _, err := runAndLog(cmd)
+	return err
+}
+
+// DirectoryExistsInGuest checks if a directory exists in the guest operating system.
+func (d *WorkstationDriver) DirectoryExistsInGuest(vmxPath string, credentials GuestCredentials, dir string) (bool, error) {
+	cmd := exec.Command(d.VmrunPath, guestOpsArgs("ws", vmxPath, credentials, "directoryExistsInGuest", dir)...)
+	stdout, _, err := runAndLog(cmd)
+	if err != nil {
+		return false, err
+	}
+	return parseDirectoryExists(stdout), nil
+}
+
 // IsRunning checks if the virtual machine is currently powered on.
 func (d *WorkstationDriver) IsRunning(vmxPath string) (bool, error) {
 	vmxPath, err := filepath.Abs(vmxPath)
@@ -229,9 +306,12 @@ func (d *WorkstationDriver) SuppressMessages(vmxPath string) error {
 func (d *WorkstationDriver) Verify() error {
 	log.Printf("[INFO] Searching for %s...", workstationProductName)
 
-	if err := workstationVerifyVersion(workstationMinVersionObj.String()); err != nil {
+	productVersion, err := workstationVerifyVersion(workstationMinVersionObj.String())
+	if err != nil {
 		return fmt.Errorf("version verification failed: %s", err)
 	}
+	d.ProductName = workstationProductName
+	d.ProductVersion = productVersion
 
 	components := map[string]*string{
 		appVmware:       &d.AppPath,
@@ -254,6 +334,12 @@ func (d *WorkstationDriver) Verify() error {
 			}
 
 			if foundPath, err := finderFunc(); err != nil {
+				// Disks are created without the virtual disk manager, if
+				// it is not installed.
+				if name == appVdiskManager {
+					log.Printf("[WARN] - %s not found: %s; disks will not be compacted", name, err)
+					continue
+				}
 				return fmt.Errorf("%s not found: %s", name, err)
 			} else {
 				*path = foundPath
@@ -279,6 +365,19 @@ func (d *WorkstationDriver) Verify() error {
 		return workstationNatConfPath(device)
 	}
 
+	d.RestartDhcpService = func(device string) error {
+		return workstationRestartDhcpService(device)
+	}
+
+	d.NetworkingConfPath = func() string {
+		libpath, _ := workstationInstallationPath()
+		return filepath.Join(libpath, "networking")
+	}
+
+	d.RestartNatService = func(device string) error {
+		return workstationRestartNatService(device)
+	}
+
 	d.NetworkMapper = func() (NetworkNameMapper, error) {
 		// Check if the network mapper configuration file exists.
 		mapper, err := checkNetmapConfExists()
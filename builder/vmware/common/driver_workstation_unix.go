@@ -27,13 +27,15 @@ import (
 var (
 	_ = workstationInstallationPathKey
 	_ = workstationDhcpRegistryKey
+	_ = dhcpVmnetService
+	_ = natVmnetService
 )
 
 // workstationCheckLicense checks for the presence of a VMware Workstation
 // license file.
 func workstationCheckLicense() error {
 
-	err := workstationVerifyVersion(workstationNoLicenseVersion)
+	_, err := workstationVerifyVersion(workstationNoLicenseVersion)
 	if err == nil {
 		// Reference: Free for commercial, educational, and personal use.
 		log.Printf("[INFO] Skipping license check for version >= %s", workstationNoLicenseVersion)
@@ -126,6 +128,28 @@ func workstationNatConfPath(device string) string {
 	return filepath.Join(base, device, "nat/nat.conf")
 }
 
+// workstationRestartDhcpService restarts the virtual networks, since the DHCP
+// service of a single virtual network cannot be restarted.
+func workstationRestartDhcpService(device string) error {
+	path, err := exec.LookPath(appVmwareNetworking)
+	if err != nil {
+		return err
+	}
+
+	for _, arg := range []string{"--stop", "--start"} {
+		if _, _, err := runAndLog(exec.Command(path, arg)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// workstationRestartNatService restarts the virtual networks, since the NAT
+// service of a single virtual network cannot be restarted.
+func workstationRestartNatService(device string) error {
+	return workstationRestartDhcpService(device)
+}
+
 // workstationNetmapConfPath returns the path to the network mapping
 // configuration file.
 func workstationNetmapConfPath() string {
@@ -138,10 +162,10 @@ func workstationNetmapConfPath() string {
 }
 
 // workstationVerifyVersion verifies the VMware Workstation version against the
-// required version using workstationTestVersion.
-func workstationVerifyVersion(version string) error {
+// required version using workstationTestVersion, and returns the version.
+func workstationVerifyVersion(version string) (string, error) {
 	if runtime.GOOS != osLinux {
-		return fmt.Errorf("driver is only supported on Linux, not %s", runtime.GOOS)
+		return "", fmt.Errorf("driver is only supported on Linux, not %s", runtime.GOOS)
 	}
 
 	vmxPath := filepath.Join(linuxAppPath, appVmx)
@@ -150,31 +174,31 @@ func workstationVerifyVersion(version string) error {
 	cmd := exec.Command(vmxPath, "-v")
 	cmd.Stderr = &stderr
 	if err := cmd.Run(); err != nil {
-		return err
+		return "", err
 	}
 	return workstationTestVersion(version, stderr.String())
 }
 
 // workstationTestVersion verifies the VMware Workstation version against the
-// required version.
-func workstationTestVersion(requiredVersion, versionOutput string) error {
+// required version, and returns the version.
+func workstationTestVersion(requiredVersion, versionOutput string) (string, error) {
 	versionRe := regexp.MustCompile(`(?i)VMware Workstation (\d+\.\d+\.\d+)`)
 	matches := versionRe.FindStringSubmatch(versionOutput)
 	if matches == nil {
-		return fmt.Errorf("error parsing version output: %s", versionOutput)
+		return "", fmt.Errorf("error parsing version output: %s", versionOutput)
 	}
 	fullVersion := matches[1]
 	log.Printf("[INFO] %s: %s", workstationProductName, fullVersion)
 
 	parsedVersionFound, err := version.NewVersion(fullVersion)
 	if err != nil {
-		return fmt.Errorf("invalid version found: %w", err)
+		return "", fmt.Errorf("invalid version found: %w", err)
 	}
 
 	parsedVersionRequired, err := version.NewVersion(requiredVersion)
 	if err != nil {
-		return fmt.Errorf("invalid version required: %w", err)
+		return "", fmt.Errorf("invalid version required: %w", err)
 	}
 
-	return compareVersionObjects(parsedVersionFound, parsedVersionRequired, workstationProductName)
+	return fullVersion, compareVersionObjects(parsedVersionFound, parsedVersionRequired, workstationProductName)
 }
@@ -96,6 +96,28 @@ func workstationNatConfPath(device string) string {
 	return findFile(natVmnetConfFile, workstationDataFilePaths())
 }
 
+// workstationRestartDhcpService restarts the DHCP service, which serves all
+// the virtual networks.
+func workstationRestartDhcpService(device string) error {
+	for _, arg := range []string{"stop", "start"} {
+		if _, _, err := runAndLog(exec.Command("net", arg, dhcpVmnetService)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// workstationRestartNatService restarts the NAT service, which serves all the
+// virtual networks.
+func workstationRestartNatService(device string) error {
+	for _, arg := range []string{"stop", "start"} {
+		if _, _, err := runAndLog(exec.Command("net", arg, natVmnetService)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // workstationNetmapConfPath returns the path to the network mapping
 // configuration file.
 func workstationNetmapConfPath() string {
@@ -179,11 +201,11 @@ func workstationDataFilePaths() []string {
 }
 
 // workstationVerifyVersion verifies the VMware Workstation version against
-// the required version.
-func workstationVerifyVersion(requiredVersion string) error {
+// the required version, and returns the version.
+func workstationVerifyVersion(requiredVersion string) (string, error) {
 	productVersion, err := workstationGetVersionFromRegistry()
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	return workstationTestVersion(requiredVersion, productVersion)
@@ -204,12 +226,12 @@ func workstationGetVersionFromRegistry() (string, error) {
 }
 
 // workstationTestVersion checks if the product version matches the required
-// version.
-func workstationTestVersion(requiredVersion, productVersion string) error {
+// version, and returns the version.
+func workstationTestVersion(requiredVersion, productVersion string) (string, error) {
 	versionRe := regexp.MustCompile(`^(\d+)\.(\d+)\.(\d+)`)
 	matches := versionRe.FindStringSubmatch(productVersion)
 	if matches == nil || len(matches) < 4 {
-		return fmt.Errorf("error parsing product version: '%s'", productVersion)
+		return "", fmt.Errorf("error parsing product version: '%s'", productVersion)
 	}
 
 	fullVersion := fmt.Sprintf("%s.%s.%s", matches[1], matches[2], matches[3])
@@ -217,15 +239,15 @@ func workstationTestVersion(requiredVersion, productVersion string) error {
 
 	parsedVersionFound, err := version.NewVersion(fullVersion)
 	if err != nil {
-		return fmt.Errorf("invalid version found: %w", err)
+		return "", fmt.Errorf("invalid version found: %w", err)
 	}
 
 	parsedVersionRequired, err := version.NewVersion(requiredVersion)
 	if err != nil {
-		return fmt.Errorf("invalid version required: %w", err)
+		return "", fmt.Errorf("invalid version required: %w", err)
 	}
 
-	return compareVersionObjects(parsedVersionFound, parsedVersionRequired, workstationProductName)
+	return fullVersion, compareVersionObjects(parsedVersionFound, parsedVersionRequired, workstationProductName)
 }
 
 // readRegString reads a string value from the registry.
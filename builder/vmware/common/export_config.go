@@ -8,23 +8,47 @@ package common
 
 import (
 	"fmt"
+	"os"
 	"slices"
 	"strings"
 
 	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+	"github.com/vmware/packer-plugin-vmware/builder/vmware/common/diskimage"
 )
 
 type ExportConfig struct {
 	// The output format of the exported virtual machine. Allowed values are
-	// `ova`, `ovf`, or `vmx`. Defaults to `vmx`.
+	// `ova`, `ovf`, `vagrant`, `vmx`, `qcow2`, `raw`, or `vhdx`. Defaults to
+	// `vmx`.
 	//
-	// ~> **Note:** Ensure VMware OVF Tool is installed. For the latest version,
-	// visit [VMware OVF Tool](https://developer.broadcom.com/tools/open-virtualization-format-ovf-tool/latest).
+	// The `vagrant` format packages the virtual machine as a Vagrant box for
+	// the `vmware_desktop` provider. The box is created in the output directory
+	// and VMware OVF Tool is not required.
+	//
+	// The `qcow2`, `raw`, and `vhdx` formats convert each disk of the virtual
+	// machine to a disk image named `<vm_name>-disk<n>.<format>`, for use with
+	// other hypervisors, such as KVM and Hyper-V. Refer to
+	// `disk_conversion_engine`. VMware OVF Tool is not required.
+	//
+	// ~> **Note:** Ensure VMware OVF Tool is installed, unless `export_engine`
+	// is set to `native`. For the latest version, visit
+	// [VMware OVF Tool](https://developer.broadcom.com/tools/open-virtualization-format-ovf-tool/latest).
 	//
 	// ~> **Note:** The plugin will create a `.vmx` and supporting files in the
 	// output directory and will then export the virtual machine to the specified
 	// format. These files are **not** automatically cleaned up after the export process.
 	Format string `mapstructure:"format" required:"false"`
+	// The output formats of the exported virtual machine. Allowed values are
+	// `ova`, `ovf`, `vagrant`, `vmx`, `qcow2`, `raw`, and `vhdx`. Use this option instead of `format`
+	// to export the virtual machine to multiple formats in a single build.
+	//
+	// Each format is exported from the same final state of the virtual
+	// machine to a subdirectory of the output directory named for the format.
+	// For example, `output/ova`. The `vmx` format refers to the virtual
+	// machine files in the output directory and is not exported.
+	//
+	// ~> **Note:** This option is mutually exclusive with `format`.
+	Formats []string `mapstructure:"formats" required:"false"`
 	// Additional command-line arguments to send to VMware OVF Tool during the
 	// export process. Each string in the array represents a separate
 	// command-line argument.
@@ -35,6 +59,32 @@ type ExportConfig struct {
 	// ~> **Note:** Ensure VMware OVF Tool is installed. For the latest version,
 	// visit [VMware OVF Tool](https://developer.broadcom.com/tools/open-virtualization-format-ovf-tool/latest).
 	OVFToolOptions []string `mapstructure:"ovftool_options" required:"false"`
+	// The engine used to export the virtual machine to the `ova` and `ovf`
+	// formats.
+	// Allowed values are `ovftool` and `native`. Defaults to `ovftool`.
+	//
+	// The `native` engine generates the OVF descriptor, the stream-optimized
+	// disks, and the manifest without VMware OVF Tool. For the `ova` format,
+	// the OVF package is then packaged as an OVA. The OVF Tool is not required
+	// when using the `native` engine.
+	//
+	// ~> **Note:** The `native` engine does not support `ovftool_options`,
+	// and generates OVF 1.0 descriptors only.
+	ExportEngine string `mapstructure:"export_engine" required:"false"`
+	// The engine used to convert the disks to the `qcow2`, `raw`, and `vhdx`
+	// formats. Allowed values are `native` and `qemu-img`. Defaults to
+	// `native`.
+	//
+	// The `native` engine writes the disk images without external tools. The
+	// `vhdx` disk images are dynamic disks. The `qemu-img` engine uses the
+	// QEMU disk image utility, which must be installed and included in your
+	// PATH.
+	DiskConversionEngine string `mapstructure:"disk_conversion_engine" required:"false"`
+	// The path to a template to use as the Vagrantfile of the Vagrant box when
+	// exporting to the `vagrant` format. The template is rendered using the Packer template
+	// engine, and the name of the virtual machine is available as `{{ .Name }}`.
+	// If not set, the box does not include a Vagrantfile.
+	VagrantfileTemplate string `mapstructure:"vagrantfile_template" required:"false"`
 	// Skips the export of the virtual machine. This is useful if the build
 	// output is not the resultant image, but created inside the virtual
 	// machine. This is useful for debugging purposes. Defaults to `false`.
@@ -42,8 +92,22 @@ type ExportConfig struct {
 	// At the end of the build process, the plugin defragments and compacts the
 	// disks using `vmware-vdiskmanager`. In some cases, this process may result
 	// in slightly larger disk sizes. If this occurs, you can opt to skip the
-	// disk compaction step by using this setting. Defaults to `false`.
+	// disk compaction step by using this setting. Disks are not compacted if
+	// `vmware-vdiskmanager` is not installed. Defaults to `false`.
 	SkipCompaction bool `mapstructure:"skip_compaction" required:"false"`
+	// The checksum algorithms used to create the checksum files of the
+	// artifact. Allowed values are `md5`, `sha1`, `sha256`, and `sha512`.
+	// Defaults to `["sha256"]`.
+	//
+	// At the end of the build, a checksum file is written to the output
+	// directory for each algorithm, such as `SHA256SUMS`. The checksum file
+	// lists the checksum of each file in the output directory, including the
+	// exported files, in the format of the `sha256sum` utility. The checksums
+	// are available to post-processors from the artifact state as
+	// `checksums`.
+	ChecksumTypes []string `mapstructure:"checksum_types" required:"false"`
+	// Skips the creation of the checksum files. Defaults to `false`.
+	SkipChecksum bool `mapstructure:"skip_checksum" required:"false"`
 }
 
 // Prepare validates and sets default values for the export configuration.
@@ -54,5 +118,98 @@ func (c *ExportConfig) Prepare(ctx *interpolate.Context) []error {
 		errs = append(errs, fmt.Errorf("invalid 'format' type specified: %s; must be one of %s", c.Format, strings.Join(allowedExportFormats, ", ")))
 	}
 
+	if len(c.Formats) > 0 {
+		if c.Format != "" {
+			errs = append(errs, fmt.Errorf("'format' and 'formats' are mutually exclusive"))
+		}
+
+		for i, format := range c.Formats {
+			if !slices.Contains(allowedExportFormats, format) {
+				errs = append(errs, fmt.Errorf("invalid 'formats' type specified: %s; must be one of %s", format, strings.Join(allowedExportFormats, ", ")))
+			}
+			if slices.Contains(c.Formats[:i], format) {
+				errs = append(errs, fmt.Errorf("duplicate 'formats' type specified: %s", format))
+			}
+		}
+	}
+
+	if c.ExportEngine == "" {
+		c.ExportEngine = ExportEngineOvfTool
+	}
+
+	if !slices.Contains(allowedExportEngines, c.ExportEngine) {
+		errs = append(errs, fmt.Errorf("invalid 'export_engine' specified: %s; must be one of %s", c.ExportEngine, strings.Join(allowedExportEngines, ", ")))
+	}
+
+	if c.ExportEngine == ExportEngineNative && len(c.OVFToolOptions) > 0 {
+		errs = append(errs, fmt.Errorf("'ovftool_options' cannot be used with the '%s' export engine", ExportEngineNative))
+	}
+
+	if c.DiskConversionEngine == "" {
+		c.DiskConversionEngine = DiskConversionEngineNative
+	}
+
+	if !slices.Contains(allowedDiskConversionEngines, c.DiskConversionEngine) {
+		errs = append(errs, fmt.Errorf("invalid 'disk_conversion_engine' specified: %s; must be one of %s", c.DiskConversionEngine, strings.Join(allowedDiskConversionEngines, ", ")))
+	} else if c.DiskConversionEngine == DiskConversionEngineQemuImg && c.ConvertsDisks() && GetQemuImg() == "" {
+		errs = append(errs, fmt.Errorf("%s is required by the '%s' disk conversion engine but was not found in PATH", appQemuImg, DiskConversionEngineQemuImg))
+	}
+
+	if len(c.ChecksumTypes) == 0 {
+		c.ChecksumTypes = []string{checksumTypeSha256}
+	}
+
+	for i, checksumType := range c.ChecksumTypes {
+		c.ChecksumTypes[i] = strings.ToLower(checksumType)
+		if !slices.Contains(allowedChecksumTypes, c.ChecksumTypes[i]) {
+			errs = append(errs, fmt.Errorf("invalid 'checksum_types' specified: %s; must be one of %s", checksumType, strings.Join(allowedChecksumTypes, ", ")))
+		} else if slices.Contains(c.ChecksumTypes[:i], c.ChecksumTypes[i]) {
+			errs = append(errs, fmt.Errorf("duplicate 'checksum_types' specified: %s", checksumType))
+		}
+	}
+
+	if c.VagrantfileTemplate != "" {
+		if !slices.Contains(c.ExportFormats(), exportFormatVagrant) {
+			errs = append(errs, fmt.Errorf("'vagrantfile_template' can only be used with the '%s' format", exportFormatVagrant))
+		} else if _, err := os.Stat(c.VagrantfileTemplate); err != nil {
+			errs = append(errs, fmt.Errorf("'vagrantfile_template' is invalid: %s", err))
+		}
+	}
+
 	return errs
 }
+
+// ExportFormats returns the formats to export the virtual machine to, from
+// either `formats` or `format`.
+func (c *ExportConfig) ExportFormats() []string {
+	if len(c.Formats) > 0 {
+		return c.Formats
+	}
+	if c.Format != "" {
+		return []string{c.Format}
+	}
+	return nil
+}
+
+// UsesOvfTool reports whether VMware OVF Tool is used to export the virtual
+// machine.
+func (c *ExportConfig) UsesOvfTool() bool {
+	if c.SkipExport || c.ExportEngine != ExportEngineOvfTool {
+		return false
+	}
+	return slices.Contains(c.ExportFormats(), ExportFormatOvf) || slices.Contains(c.ExportFormats(), exportFormatOva)
+}
+
+// ConvertsDisks reports whether the disks of the virtual machine are converted
+// to a disk image format.
+func (c *ExportConfig) ConvertsDisks() bool {
+	if c.SkipExport {
+		return false
+	}
+	for _, format := range c.ExportFormats() {
+		if slices.Contains(diskimage.Formats, format) {
+			return true
+		}
+	}
+	return false
+}
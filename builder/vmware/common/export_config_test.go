@@ -0,0 +1,181 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportConfigPrepare(t *testing.T) {
+	tc := []struct {
+		name           string
+		config         ExportConfig
+		expectedEngine string
+		expectedErr    bool
+	}{
+		{
+			name:           "default engine",
+			config:         ExportConfig{Format: "ova"},
+			expectedEngine: ExportEngineOvfTool,
+		},
+		{
+			name:           "native engine with ovf format",
+			config:         ExportConfig{Format: "ovf", ExportEngine: ExportEngineNative},
+			expectedEngine: ExportEngineNative,
+		},
+		{
+			name:           "native engine with ova format",
+			config:         ExportConfig{Format: "ova", ExportEngine: ExportEngineNative},
+			expectedEngine: ExportEngineNative,
+		},
+		{
+			name: "native engine with ovftool options",
+			config: ExportConfig{
+				Format:         "ovf",
+				ExportEngine:   ExportEngineNative,
+				OVFToolOptions: []string{"--compress=9"},
+			},
+			expectedErr: true,
+		},
+		{
+			name:        "vagrantfile template without vagrant format",
+			config:      ExportConfig{Format: "ovf", VagrantfileTemplate: "export_config_test.go"},
+			expectedErr: true,
+		},
+		{
+			name:           "vagrantfile template with vagrant format",
+			config:         ExportConfig{Format: "vagrant", VagrantfileTemplate: "export_config_test.go"},
+			expectedEngine: ExportEngineOvfTool,
+		},
+		{
+			name:        "missing vagrantfile template",
+			config:      ExportConfig{Format: "vagrant", VagrantfileTemplate: "missing"},
+			expectedErr: true,
+		},
+		{
+			name:           "multiple formats",
+			config:         ExportConfig{Formats: []string{"vmx", "ova", "vagrant"}},
+			expectedEngine: ExportEngineOvfTool,
+		},
+		{
+			name:        "format and formats",
+			config:      ExportConfig{Format: "ova", Formats: []string{"ovf"}},
+			expectedErr: true,
+		},
+		{
+			name:        "invalid formats",
+			config:      ExportConfig{Formats: []string{"ova", "invalid"}},
+			expectedErr: true,
+		},
+		{
+			name:        "duplicate formats",
+			config:      ExportConfig{Formats: []string{"ova", "ova"}},
+			expectedErr: true,
+		},
+		{
+			name:        "invalid engine",
+			config:      ExportConfig{Format: "ovf", ExportEngine: "invalid"},
+			expectedErr: true,
+		},
+		{
+			name:           "disk image formats",
+			config:         ExportConfig{Formats: []string{"vmx", "qcow2", "raw", "vhdx"}},
+			expectedEngine: ExportEngineOvfTool,
+		},
+		{
+			name:        "invalid disk conversion engine",
+			config:      ExportConfig{Format: "qcow2", DiskConversionEngine: "invalid"},
+			expectedErr: true,
+		},
+		{
+			name:           "checksum types",
+			config:         ExportConfig{Format: "ova", ChecksumTypes: []string{"SHA256", "md5"}},
+			expectedEngine: ExportEngineOvfTool,
+		},
+		{
+			name:        "invalid checksum types",
+			config:      ExportConfig{Format: "ova", ChecksumTypes: []string{"sha256", "crc32"}},
+			expectedErr: true,
+		},
+		{
+			name:        "duplicate checksum types",
+			config:      ExportConfig{Format: "ova", ChecksumTypes: []string{"sha256", "SHA256"}},
+			expectedErr: true,
+		},
+	}
+
+	for _, c := range tc {
+		t.Run(c.name, func(t *testing.T) {
+			errs := c.config.Prepare(interpolate.NewContext())
+			if c.expectedErr {
+				if len(errs) == 0 {
+					t.Fatal("should have error")
+				}
+				return
+			}
+			if len(errs) > 0 {
+				t.Fatalf("should not have error: %s", errs)
+			}
+			if c.config.ExportEngine != c.expectedEngine {
+				t.Fatalf("expected engine %s, got %s", c.expectedEngine, c.config.ExportEngine)
+			}
+		})
+	}
+}
+
+func TestExportConfig_ConvertsDisks(t *testing.T) {
+	tc := []struct {
+		config   ExportConfig
+		expected bool
+	}{
+		{ExportConfig{Format: "qcow2"}, true},
+		{ExportConfig{Formats: []string{"vmx", "vhdx"}}, true},
+		{ExportConfig{Formats: []string{"vmx", "ova"}}, false},
+		{ExportConfig{Format: "raw", SkipExport: true}, false},
+	}
+
+	for _, c := range tc {
+		if actual := c.config.ConvertsDisks(); actual != c.expected {
+			t.Fatalf("expected %t for %#v, got %t", c.expected, c.config, actual)
+		}
+	}
+}
+
+func TestExportConfig_UsesOvfTool(t *testing.T) {
+	tc := []struct {
+		config   ExportConfig
+		expected bool
+	}{
+		{ExportConfig{Format: "ova", ExportEngine: ExportEngineOvfTool}, true},
+		{ExportConfig{Format: "ova", ExportEngine: ExportEngineNative}, false},
+		{ExportConfig{Format: "vagrant", ExportEngine: ExportEngineOvfTool}, false},
+		{ExportConfig{Format: "vmx", ExportEngine: ExportEngineOvfTool, SkipExport: true}, false},
+		{ExportConfig{Formats: []string{"vmx", "vagrant"}, ExportEngine: ExportEngineOvfTool}, false},
+		{ExportConfig{Formats: []string{"vagrant", "ovf"}, ExportEngine: ExportEngineOvfTool}, true},
+	}
+
+	for _, c := range tc {
+		if actual := c.config.UsesOvfTool(); actual != c.expected {
+			t.Fatalf("expected %t for %#v, got %t", c.expected, c.config, actual)
+		}
+	}
+}
+
+func TestExportConfigPrepare_checksumTypes(t *testing.T) {
+	c := ExportConfig{Format: "ova"}
+	if errs := c.Prepare(interpolate.NewContext()); len(errs) > 0 {
+		t.Fatalf("should not have error: %s", errs)
+	}
+	assert.Equal(t, []string{"sha256"}, c.ChecksumTypes)
+
+	c = ExportConfig{Format: "ova", ChecksumTypes: []string{"SHA512", "md5"}}
+	if errs := c.Prepare(interpolate.NewContext()); len(errs) > 0 {
+		t.Fatalf("should not have error: %s", errs)
+	}
+	assert.Equal(t, []string{"sha512", "md5"}, c.ChecksumTypes)
+}
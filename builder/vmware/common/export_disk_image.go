@@ -0,0 +1,90 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/vmware/packer-plugin-vmware/builder/vmware/common/diskimage"
+	"github.com/vmware/packer-plugin-vmware/builder/vmware/common/vmdk"
+)
+
+// exportDiskImages converts each disk to a disk image in the format in the
+// export directory. The disk images are named for the virtual machine and the
+// position of the disk.
+func (s *StepExport) exportDiskImages(ui packersdk.Ui, diskPaths []string, exportOutputPath string, format string) error {
+	for i, source := range diskPaths {
+		name := fmt.Sprintf("%s-disk%d.%s", s.VMName, i+1, format)
+		destination := filepath.Join(exportOutputPath, name)
+
+		ui.Sayf("Converting disk %s to %s...", filepath.Base(source), name)
+
+		var err error
+		if s.DiskConversionEngine == DiskConversionEngineQemuImg {
+			err = convertDiskQemuImg(source, destination, format)
+		} else {
+			err = convertDiskNative(ui, source, destination, format)
+		}
+		if err != nil {
+			return fmt.Errorf("error converting disk %s: %s", source, err)
+		}
+	}
+
+	return nil
+}
+
+// convertDiskNative writes the virtual disk at the source path as a disk image
+// in the format to the destination path, reporting the progress.
+func convertDiskNative(ui packersdk.Ui, source string, destination string, format string) error {
+	disk, err := vmdk.Open(source)
+	if err != nil {
+		return err
+	}
+	defer disk.Close()
+
+	f, err := os.Create(destination)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	capacity := disk.Capacity()
+	reported := 0
+	progress := func(written int64) {
+		percent := int(written * 100 / capacity)
+		if percent >= reported+diskProgressStep {
+			reported = percent / diskProgressStep * diskProgressStep
+			ui.Sayf("Converted %d%% of %s", reported, filepath.Base(source))
+		}
+	}
+
+	if err := diskimage.Write(f, format, disk, capacity, progress); err != nil {
+		os.Remove(destination)
+		return err
+	}
+
+	return f.Close()
+}
+
+// convertDiskQemuImg converts the virtual disk at the source path to a disk
+// image in the format at the destination path using the QEMU disk image
+// utility.
+func convertDiskQemuImg(source string, destination string, format string) error {
+	qemuImg := GetQemuImg()
+	if qemuImg == "" {
+		return fmt.Errorf("%s not found in PATH", appQemuImg)
+	}
+
+	cmd := exec.Command(qemuImg, "convert", "-p", "-f", "vmdk", "-O", format, source, destination) //nolint:gosec
+	if _, _, err := runAndLog(cmd); err != nil {
+		return err
+	}
+
+	return nil
+}
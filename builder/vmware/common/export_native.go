@@ -0,0 +1,143 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/vmware/packer-plugin-vmware/builder/vmware/common/vmdk"
+)
+
+// ovfDDBKeys are the disk database entries copied from the source virtual
+// disks to the exported virtual disks.
+var ovfDDBKeys = []string{
+	"ddb.adapterType",
+	"ddb.geometry.cylinders",
+	"ddb.geometry.heads",
+	"ddb.geometry.sectors",
+	"ddb.virtualHWVersion",
+}
+
+// exportNative exports the virtual machine described by the .vmx file to an
+// OVF package in the output directory without VMware OVF Tool. The package
+// consists of the OVF descriptor, the stream-optimized virtual disks, and the
+// manifest.
+func exportNative(ui packersdk.Ui, vmxPath string, outputDir string, vmName string) error {
+	vmxData, err := ReadVMX(vmxPath)
+	if err != nil {
+		return fmt.Errorf("error reading .vmx file: %s", err)
+	}
+
+	devices := ovfDevicesFromVMX(vmxData)
+	vmxDir := filepath.Dir(vmxPath)
+
+	type manifestEntry struct {
+		name   string
+		digest string
+	}
+	var manifest []manifestEntry
+
+	diskCount := 0
+	for _, device := range devices {
+		if device.CDROM {
+			continue
+		}
+		diskCount++
+
+		source := device.Filename
+		if !filepath.IsAbs(source) {
+			source = filepath.Join(vmxDir, source)
+		}
+		name := fmt.Sprintf("%s-disk%d.vmdk", vmName, diskCount)
+
+		ui.Sayf("Exporting disk %s to %s...", filepath.Base(source), name)
+		disk, digest, err := exportNativeDisk(source, filepath.Join(outputDir, name))
+		if err != nil {
+			return fmt.Errorf("error exporting disk %s: %s", source, err)
+		}
+		device.Disk = disk
+		manifest = append(manifest, manifestEntry{name: name, digest: digest})
+	}
+
+	descriptor, err := newOvfEnvelope(vmName, vmxData, devices).Marshal()
+	if err != nil {
+		return fmt.Errorf("error generating OVF descriptor: %s", err)
+	}
+
+	ovfName := vmName + "." + ExportFormatOvf
+	log.Printf("[INFO] Writing OVF descriptor to: %s", filepath.Join(outputDir, ovfName))
+	if err := os.WriteFile(filepath.Join(outputDir, ovfName), descriptor, 0644); err != nil { //nolint:gosec
+		return fmt.Errorf("error writing OVF descriptor: %s", err)
+	}
+
+	digest := sha256.Sum256(descriptor)
+	manifest = append([]manifestEntry{{name: ovfName, digest: hex.EncodeToString(digest[:])}}, manifest...)
+
+	var mf strings.Builder
+	for _, entry := range manifest {
+		fmt.Fprintf(&mf, "SHA256(%s)= %s\n", entry.name, entry.digest)
+	}
+
+	mfPath := filepath.Join(outputDir, vmName+".mf")
+	log.Printf("[INFO] Writing OVF manifest to: %s", mfPath)
+	if err := os.WriteFile(mfPath, []byte(mf.String()), 0644); err != nil { //nolint:gosec
+		return fmt.Errorf("error writing OVF manifest: %s", err)
+	}
+
+	return nil
+}
+
+// exportNativeDisk writes the virtual disk at the source path as a
+// stream-optimized virtual disk to the destination path and returns the
+// exported disk and the SHA256 digest of the destination file.
+func exportNativeDisk(source string, destination string) (*ovfExportedDisk, string, error) {
+	disk, err := vmdk.Open(source)
+	if err != nil {
+		return nil, "", err
+	}
+	defer disk.Close()
+
+	ddb := make(map[string]string)
+	for _, key := range ovfDDBKeys {
+		if value, ok := disk.Descriptor.DDB[key]; ok {
+			ddb[key] = value
+		}
+	}
+
+	f, err := os.Create(destination)
+	if err != nil {
+		return nil, "", err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	w := bufio.NewWriter(f)
+	stats, err := vmdk.WriteStreamOptimized(io.MultiWriter(w, hash), filepath.Base(destination), disk, disk.Capacity(), ddb)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := w.Flush(); err != nil {
+		return nil, "", err
+	}
+	if err := f.Close(); err != nil {
+		return nil, "", err
+	}
+
+	return &ovfExportedDisk{
+		Href:          filepath.Base(destination),
+		Size:          stats.Size,
+		Capacity:      disk.Capacity(),
+		PopulatedSize: stats.PopulatedSize,
+	}, hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,101 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/stretchr/testify/assert"
+	"github.com/vmware/packer-plugin-vmware/builder/vmware/common/vmdk"
+)
+
+// testNativeExportVM creates a virtual machine with a flat virtual disk and
+// returns the path to the .vmx file and the contents of the disk.
+func testNativeExportVM(t *testing.T, dir string) (string, []byte) {
+	image := make([]byte, 256*1024)
+	copy(image[128*1024:], "packer")
+
+	if err := os.WriteFile(filepath.Join(dir, "disk-flat.vmdk"), image, 0644); err != nil { //nolint:gosec
+		t.Fatalf("err: %s", err)
+	}
+	descriptor := fmt.Sprintf("version=1\nCID=fffffffe\nparentCID=ffffffff\ncreateType=\"monolithicFlat\"\n"+
+		"RW %d FLAT \"disk-flat.vmdk\" 0\nddb.adapterType = \"lsilogic\"\n", len(image)/vmdk.SectorSize)
+	if err := os.WriteFile(filepath.Join(dir, "disk.vmdk"), []byte(descriptor), 0644); err != nil { //nolint:gosec
+		t.Fatalf("err: %s", err)
+	}
+
+	vmxPath := filepath.Join(dir, "vm.vmx")
+	vmxData := map[string]string{
+		"displayname":      "vm",
+		"numvcpus":         "2",
+		"memsize":          "1024",
+		"scsi0.present":    "TRUE",
+		"scsi0:0.present":  "TRUE",
+		"scsi0:0.filename": "disk.vmdk",
+	}
+	if err := WriteVMX(vmxPath, vmxData); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	return vmxPath, image
+}
+
+func TestExportNative(t *testing.T) {
+	vmDir := t.TempDir()
+	outputDir := t.TempDir()
+	vmxPath, image := testNativeExportVM(t, vmDir)
+
+	ui := &packersdk.BasicUi{Reader: new(bytes.Buffer), Writer: new(bytes.Buffer)}
+	if err := exportNative(ui, vmxPath, outputDir, "vm"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The manifest covers the descriptor and the disk.
+	mf, err := os.ReadFile(filepath.Join(outputDir, "vm.mf"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(mf)), "\n")
+	assert.Len(t, lines, 2)
+	for i, name := range []string{"vm.ovf", "vm-disk1.vmdk"} {
+		data, err := os.ReadFile(filepath.Join(outputDir, name))
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		digest := sha256.Sum256(data)
+		assert.Equal(t, fmt.Sprintf("SHA256(%s)= %s", name, hex.EncodeToString(digest[:])), lines[i])
+	}
+
+	ovf, err := os.ReadFile(filepath.Join(outputDir, "vm.ovf"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assert.Contains(t, string(ovf), `ovf:href="vm-disk1.vmdk"`)
+	assert.Contains(t, string(ovf), fmt.Sprintf(`ovf:capacity="%d"`, len(image)))
+	assert.Contains(t, string(ovf), vmdk.StreamOptimizedFormat)
+
+	// The exported disk has the same contents as the source disk.
+	disk, err := vmdk.Open(filepath.Join(outputDir, "vm-disk1.vmdk"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer disk.Close()
+
+	assert.Equal(t, "lsilogic", disk.Descriptor.DDB["ddb.adapterType"])
+	contents, err := io.ReadAll(io.NewSectionReader(disk, 0, disk.Capacity()))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assert.True(t, bytes.Equal(image, contents), "exported disk contents should match the source")
+}
@@ -26,11 +26,14 @@ type HWConfig struct {
 	CpuCount int `mapstructure:"cpus" required:"false"`
 	// The number of virtual CPU cores per socket for the virtual machine.
 	CoreCount int `mapstructure:"cores" required:"false"`
-	// The amount of memory for the virtual machine in MB. Defaults to `512`.
+	// The amount of memory for the virtual machine in MB. For the `vmware-iso`
+	// builder, defaults to `512`. For the `vmware-vmx` builder, the memory of
+	// the source virtual machine is kept if not set.
 	MemorySize int `mapstructure:"memory" required:"false"`
 	// The network which the virtual machine will connect for desktop
 	// hypervisors. Recommended values are `nat`, `hostonly`, or `bridged`.
-	// Defaults to `nat`.
+	// For the `vmware-iso` builder, defaults to `nat`. For the `vmware-vmx`
+	// builder, the network of the source virtual machine is kept if not set.
 	//
 	// ~> **Note:** If not set to one of these recommended values, then
 	// it is assumed to be a custom network device configuration.
@@ -45,6 +48,20 @@ type HWConfig struct {
 	// the network adapter types supported by the guest operating system
 	// and the CPU architecture (`amd64/x86_64` vs `arm64/aarch64`).
 	NetworkAdapterType string `mapstructure:"network_adapter_type" required:"false"`
+	// The network adapters for the virtual machine. Each network adapter is
+	// added as `ethernetN`, where `N` is the position of the adapter in the
+	// list, starting from `0`. Up to 10 network adapters are supported.
+	// Refer to the [Network Adapter Configuration](#network-adapter-configuration)
+	// for the options.
+	//
+	// ~> **Note:** Cannot be used with `network`. The network adapters
+	// replace any network adapters in the template or the source virtual
+	// machine.
+	NetworkAdapters []NetworkAdapterConfig `mapstructure:"network_adapters" required:"false"`
+	// The index of the network adapter used to discover the IP address of the
+	// guest for the communicator; for example, `1` for `ethernet1`. Defaults
+	// to `0`.
+	CommunicatorNetworkAdapter int `mapstructure:"communicator_network_adapter" required:"false"`
 	// Enable virtual sound card device. Defaults to `false`.
 	Sound bool `mapstructure:"sound" required:"false"`
 	// Enable USB controller for the virtual machine.
@@ -128,6 +145,62 @@ type HWConfig struct {
 func (c *HWConfig) Prepare(ctx *interpolate.Context) []error {
 	var errs []error
 
+	if c.NetworkAdapterType == "" && len(c.NetworkAdapters) == 0 {
+		errs = append(errs, fmt.Errorf("'network_adapter_type' is required; must be one of %s", strings.Join(allowedNetworkAdapterTypes, ", ")))
+	}
+
+	errs = append(errs, c.validate()...)
+
+	if !c.Sound {
+		c.Sound = false
+	}
+
+	// VMware Fusion on Apple Silicon requires USB controllers for the plugin
+	// to work properly. Auto-enable if not explicitly configured.
+	if runtime.GOOS == "darwin" && runtime.GOARCH == "arm64" && !c.USB && c.USBVersion == "" {
+		log.Printf("[INFO] Auto-enabling USB 3.1 on Apple Silicon for plugin functionality")
+		c.USB = true
+		c.USBVersion = UsbVersion31
+	}
+
+	if c.Parallel == "" {
+		c.Parallel = "none"
+	}
+
+	if c.Serial == "" {
+		c.Serial = "none"
+	}
+
+	return errs
+}
+
+// PrepareClone validates the hardware configuration for a cloned virtual
+// machine. Unlike Prepare, all options are optional and no defaults are set,
+// since any option left unset retains the value from the source virtual
+// machine.
+func (c *HWConfig) PrepareClone(ctx *interpolate.Context) []error {
+	errs := c.validate()
+
+	if c.Serial != "" {
+		if _, err := c.ReadSerial(); err != nil {
+			errs = append(errs, fmt.Errorf("invalid 'serial' specified: %s", err))
+		}
+	}
+
+	if c.Parallel != "" {
+		if _, err := c.ReadParallel(); err != nil {
+			errs = append(errs, fmt.Errorf("invalid 'parallel' specified: %s", err))
+		}
+	}
+
+	return errs
+}
+
+// validate checks and normalizes the hardware configuration options that are
+// common to both new and cloned virtual machines.
+func (c *HWConfig) validate() []error {
+	var errs []error
+
 	if (c.Firmware != "") && (!slices.Contains(allowedFirmwareTypes, c.Firmware)) {
 		errs = append(errs, fmt.Errorf("invalid 'firmware' type specified: %s; must be one of %s", c.Firmware, strings.Join(allowedFirmwareTypes, ", ")))
 	}
@@ -144,14 +217,28 @@ func (c *HWConfig) Prepare(ctx *interpolate.Context) []error {
 		errs = append(errs, fmt.Errorf("invalid amount of memory specified (memory < 0): %d", c.MemorySize))
 	}
 
-	if c.NetworkAdapterType == "" {
-		errs = append(errs, fmt.Errorf("'network_adapter_type' is required; must be one of %s", strings.Join(allowedNetworkAdapterTypes, ", ")))
-	} else if !slices.Contains(allowedNetworkAdapterTypes, c.NetworkAdapterType) {
+	if (c.NetworkAdapterType != "") && (!slices.Contains(allowedNetworkAdapterTypes, c.NetworkAdapterType)) {
 		errs = append(errs, fmt.Errorf("invalid 'network_adapter_type' type specified: %s; must be one of %s", c.NetworkAdapterType, strings.Join(allowedNetworkAdapterTypes, ", ")))
 	}
 
-	if !c.Sound {
-		c.Sound = false
+	if len(c.NetworkAdapters) > 0 && c.Network != "" {
+		errs = append(errs, fmt.Errorf("'network' and 'network_adapters' cannot both be specified"))
+	}
+
+	if len(c.NetworkAdapters) > maxNetworkAdapters {
+		errs = append(errs, fmt.Errorf("invalid 'network_adapters' specified: %d network adapters; up to %d are supported", len(c.NetworkAdapters), maxNetworkAdapters))
+	}
+
+	for i := range c.NetworkAdapters {
+		for _, err := range c.NetworkAdapters[i].Prepare(c.NetworkAdapterType) {
+			errs = append(errs, fmt.Errorf("network_adapters[%d]: %s", i, err))
+		}
+	}
+
+	if c.CommunicatorNetworkAdapter < 0 {
+		errs = append(errs, fmt.Errorf("invalid 'communicator_network_adapter' specified (communicator_network_adapter < 0): %d", c.CommunicatorNetworkAdapter))
+	} else if len(c.NetworkAdapters) > 0 && c.CommunicatorNetworkAdapter >= len(c.NetworkAdapters) {
+		errs = append(errs, fmt.Errorf("invalid 'communicator_network_adapter' specified: %d; only %d network adapter(s) specified", c.CommunicatorNetworkAdapter, len(c.NetworkAdapters)))
 	}
 
 	if c.USB {
@@ -166,22 +253,6 @@ func (c *HWConfig) Prepare(ctx *interpolate.Context) []error {
 		errs = append(errs, fmt.Errorf("'usb_version' can only be set when 'usb' is 'true'"))
 	}
 
-	// VMware Fusion on Apple Silicon requires USB controllers for the plugin
-	// to work properly. Auto-enable if not explicitly configured.
-	if runtime.GOOS == "darwin" && runtime.GOARCH == "arm64" && !c.USB && c.USBVersion == "" {
-		log.Printf("[INFO] Auto-enabling USB 3.1 on Apple Silicon for plugin functionality")
-		c.USB = true
-		c.USBVersion = UsbVersion31
-	}
-
-	if c.Parallel == "" {
-		c.Parallel = "none"
-	}
-
-	if c.Serial == "" {
-		c.Serial = "none"
-	}
-
 	return errs
 }
 
@@ -494,3 +494,70 @@ func TestHWConfigUSBValidation_VersionWithoutUSB(t *testing.T) {
 		t.Errorf("expected error message not found. Got errors: %v", errs)
 	}
 }
+
+func TestHWConfigNetworkAdapters(t *testing.T) {
+	c := new(HWConfig)
+	c.NetworkAdapters = []NetworkAdapterConfig{
+		{AdapterType: "e1000e"},
+		{Network: "vmnet2", AdapterType: "vmxnet3"},
+	}
+	c.CommunicatorNetworkAdapter = 1
+
+	if errs := c.Prepare(interpolate.NewContext()); len(errs) > 0 {
+		t.Fatalf("err: %#v", errs)
+	}
+
+	if c.NetworkAdapters[0].Network != DefaultNetworkType {
+		t.Errorf("network should default to %s: %s", DefaultNetworkType, c.NetworkAdapters[0].Network)
+	}
+}
+
+func TestHWConfigNetworkAdapters_invalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		config HWConfig
+	}{
+		{
+			name: "network and network adapters",
+			config: HWConfig{
+				Network:         "nat",
+				NetworkAdapters: []NetworkAdapterConfig{{AdapterType: "e1000"}},
+			},
+		},
+		{
+			name: "invalid network adapter",
+			config: HWConfig{
+				NetworkAdapters: []NetworkAdapterConfig{{AdapterType: "vlance"}},
+			},
+		},
+		{
+			name: "communicator network adapter out of range",
+			config: HWConfig{
+				NetworkAdapters:            []NetworkAdapterConfig{{AdapterType: "e1000"}},
+				CommunicatorNetworkAdapter: 1,
+			},
+		},
+		{
+			name: "too many network adapters",
+			config: HWConfig{
+				NetworkAdapters:    make([]NetworkAdapterConfig, 11),
+				NetworkAdapterType: "e1000",
+			},
+		},
+		{
+			name: "negative communicator network adapter",
+			config: HWConfig{
+				NetworkAdapterType:         "e1000",
+				CommunicatorNetworkAdapter: -1,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if errs := tt.config.Prepare(interpolate.NewContext()); len(errs) != 1 {
+				t.Fatalf("expected one error, got: %#v", errs)
+			}
+		})
+	}
+}
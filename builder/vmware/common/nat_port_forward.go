@@ -0,0 +1,268 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	// natPortForwardHost is the address of the host used to connect to a
+	// forwarded port.
+	natPortForwardHost = "127.0.0.1"
+	// natIncomingTCPSection is the section of the NAT configuration file that
+	// defines the forwarded TCP ports.
+	natIncomingTCPSection = "[incomingtcp]"
+)
+
+// NatPortForward is a TCP port on the host forwarded to a port of the guest by
+// the NAT service of a virtual network.
+type NatPortForward struct {
+	// The virtual network device; for example, `vmnet8`.
+	Device string
+	// The path to the NAT configuration file, or to the networking
+	// configuration file if the NAT configuration file is not found.
+	ConfPath string
+	// The forwarded port on the host.
+	HostPort int
+	// The IP address of the guest.
+	GuestAddress net.IP
+	// The port of the guest.
+	GuestPort int
+
+	networking bool
+}
+
+// natPortForwardName returns the name of the port forward for the host port.
+func natPortForwardName(hostPort int) string {
+	return fmt.Sprintf("%s-portfwd-%d", DefaultNamePrefix, hostPort)
+}
+
+// AddNatPortForward forwards the host port to the port of the guest address by
+// adding an `[incomingtcp]` entry to the NAT configuration file of the device.
+// If the NAT configuration file is not found, an `add_nat_portfwd` entry is
+// added to the networking configuration file instead. The NAT service must be
+// restarted for the port forward to take effect.
+func AddNatPortForward(driver VmwareDriver, device string, hostPort int, guestAddress net.IP, guestPort int) (*NatPortForward, error) {
+	f := &NatPortForward{
+		Device:       device,
+		HostPort:     hostPort,
+		GuestAddress: guestAddress,
+		GuestPort:    guestPort,
+	}
+
+	if driver.VmnetnatConfPath != nil {
+		if path := driver.VmnetnatConfPath(device); path != "" {
+			if _, err := os.Stat(path); err == nil {
+				f.ConfPath = path
+			}
+		}
+	}
+	if f.ConfPath == "" && driver.NetworkingConfPath != nil {
+		if path := driver.NetworkingConfPath(); path != "" {
+			if _, err := os.Stat(path); err == nil {
+				f.ConfPath, f.networking = path, true
+			}
+		}
+	}
+	if f.ConfPath == "" {
+		return nil, fmt.Errorf("unable to locate the NAT configuration of %s", device)
+	}
+
+	unlock, err := lockConfigFile(f.ConfPath)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	log.Printf("[INFO] Forwarding host port %d to %s:%d in %s", hostPort, guestAddress, guestPort, f.ConfPath)
+	if f.networking {
+		err = f.addNetworking()
+	} else {
+		err = f.addNatConf()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// Remove removes the port forward from the configuration file. The NAT service
+// must be restarted for the removal to take effect.
+func (f *NatPortForward) Remove() error {
+	unlock, err := lockConfigFile(f.ConfPath)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	log.Printf("[INFO] Removing forward of host port %d to %s:%d from %s", f.HostPort, f.GuestAddress, f.GuestPort, f.ConfPath)
+	if f.networking {
+		return f.removeNetworking()
+	}
+	return f.removeNatConf()
+}
+
+// addNatConf adds the port forward to the `[incomingtcp]` section of the NAT
+// configuration file, adding the section if it does not exist.
+func (f *NatPortForward) addNatConf() error {
+	contents, err := os.ReadFile(f.ConfPath)
+	if err != nil {
+		return err
+	}
+
+	block := configBlock(natPortForwardName(f.HostPort), fmt.Sprintf("%d = %s:%d\n", f.HostPort, f.GuestAddress, f.GuestPort))
+
+	var b strings.Builder
+	var section string
+	var added bool
+	for _, line := range strings.SplitAfter(string(contents), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]") {
+			section = strings.ToLower(trimmed)
+		} else if section == natIncomingTCPSection && !strings.HasPrefix(trimmed, "#") && !strings.HasPrefix(trimmed, ";") {
+			if port, _, ok := strings.Cut(trimmed, "="); ok && strings.TrimSpace(port) == strconv.Itoa(f.HostPort) {
+				return fmt.Errorf("host port %d is already forwarded in %s", f.HostPort, f.ConfPath)
+			}
+		}
+
+		b.WriteString(line)
+		if section == natIncomingTCPSection && !added {
+			if !strings.HasSuffix(line, "\n") {
+				b.WriteString("\n")
+			}
+			b.WriteString(block)
+			added = true
+		}
+	}
+
+	if !added {
+		if b.Len() > 0 && !strings.HasSuffix(b.String(), "\n") {
+			b.WriteString("\n")
+		}
+		b.WriteString("\n" + natIncomingTCPSection + "\n" + block)
+	}
+
+	return writeFileAtomic(f.ConfPath, []byte(b.String()))
+}
+
+// removeNatConf removes the port forward from the NAT configuration file.
+func (f *NatPortForward) removeNatConf() error {
+	contents, err := os.ReadFile(f.ConfPath)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(f.ConfPath, []byte(removeConfigBlock(string(contents), natPortForwardName(f.HostPort))))
+}
+
+// addNetworking appends an `add_nat_portfwd` row for the port forward to the
+// networking configuration file, leaving the other rows untouched.
+func (f *NatPortForward) addNetworking() error {
+	config, vnet, err := f.readNetworking()
+	if err != nil {
+		return err
+	}
+	if _, ok := config.natPortFwd[vnet][fmt.Sprintf("tcp/%d", f.HostPort)]; ok {
+		return fmt.Errorf("host port %d is already forwarded in %s", f.HostPort, f.ConfPath)
+	}
+
+	contents, err := os.ReadFile(f.ConfPath)
+	if err != nil {
+		return err
+	}
+
+	newline := "\n"
+	if bytes.Contains(contents, []byte("\r\n")) {
+		newline = "\r\n"
+	}
+	if len(contents) > 0 && !bytes.HasSuffix(contents, []byte("\n")) {
+		contents = append(contents, newline...)
+	}
+	contents = append(contents, f.networkingRow(vnet)+newline...)
+
+	return writeFileAtomic(f.ConfPath, contents)
+}
+
+// removeNetworking removes the `add_nat_portfwd` row of the port forward from
+// the networking configuration file, leaving the other rows untouched.
+func (f *NatPortForward) removeNetworking() error {
+	vnet, err := f.networkingVnet()
+	if err != nil {
+		return err
+	}
+
+	contents, err := os.ReadFile(f.ConfPath)
+	if err != nil {
+		return err
+	}
+
+	// Remove the last matching row, which is the one that was appended.
+	lines := strings.SplitAfter(string(contents), "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		if strings.Join(strings.Fields(lines[i]), " ") != f.networkingRow(vnet) {
+			continue
+		}
+		lines = append(lines[:i], lines[i+1:]...)
+		return writeFileAtomic(f.ConfPath, []byte(strings.Join(lines, "")))
+	}
+	return nil
+}
+
+// networkingRow returns the `add_nat_portfwd` row of the port forward.
+func (f *NatPortForward) networkingRow(vnet int) string {
+	return fmt.Sprintf("add_nat_portfwd %d tcp %d %s %d", vnet+1, f.HostPort, f.GuestAddress, f.GuestPort)
+}
+
+// networkingVnet returns the index of the device in the NAT port forwards of
+// the networking configuration.
+func (f *NatPortForward) networkingVnet() (int, error) {
+	number, err := strconv.Atoi(strings.TrimPrefix(f.Device, NetworkingInterfacePrefix))
+	if err != nil {
+		return 0, fmt.Errorf("invalid virtual network device: %s", f.Device)
+	}
+
+	// The NAT port forwards of device vmnetN are indexed by N-1.
+	return number - 1, nil
+}
+
+// readNetworking reads the networking configuration file and returns the
+// index of the device in the NAT port forwards of the configuration.
+func (f *NatPortForward) readNetworking() (NetworkingConfig, int, error) {
+	vnet, err := f.networkingVnet()
+	if err != nil {
+		return NetworkingConfig{}, 0, err
+	}
+
+	fd, err := os.Open(f.ConfPath)
+	if err != nil {
+		return NetworkingConfig{}, 0, err
+	}
+	defer fd.Close()
+
+	config, err := ReadNetworkingConfig(fd)
+	if err != nil {
+		return NetworkingConfig{}, 0, fmt.Errorf("error reading networking configuration: %s", err)
+	}
+
+	return config, vnet, nil
+}
+
+// freeHostPort returns a TCP port on the host that is not in use.
+func freeHostPort() (int, error) {
+	l, err := net.Listen("tcp", net.JoinHostPort(natPortForwardHost, "0"))
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
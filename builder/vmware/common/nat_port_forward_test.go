@@ -0,0 +1,179 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testNatConf = `[host]
+ip = 172.16.41.2
+netmask = 255.255.255.0
+
+[incomingtcp]
+# Use these with care - anyone can enter into your VM through these...
+#8080 = 172.16.41.128:80
+8888 = 172.16.41.130:80
+
+[incomingudp]
+`
+
+func testNatPortForwardDriver(t *testing.T, natConf string, networking string) VmwareDriver {
+	dir := t.TempDir()
+	natConfPath := filepath.Join(dir, "nat.conf")
+	networkingPath := filepath.Join(dir, "networking")
+
+	if natConf != "" {
+		if err := os.WriteFile(natConfPath, []byte(natConf), 0644); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+	if networking != "" {
+		if err := os.WriteFile(networkingPath, []byte(networking), 0644); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	return VmwareDriver{
+		VmnetnatConfPath:   func(string) string { return natConfPath },
+		NetworkingConfPath: func() string { return networkingPath },
+	}
+}
+
+func TestAddNatPortForward(t *testing.T) {
+	driver := testNatPortForwardDriver(t, testNatConf, "")
+
+	f, err := AddNatPortForward(driver, "vmnet8", 2222, net.ParseIP("172.16.41.129"), 22)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assert.Equal(t, driver.VmnetnatConfPath("vmnet8"), f.ConfPath)
+
+	contents, err := os.ReadFile(f.ConfPath)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assert.Equal(t, `[host]
+ip = 172.16.41.2
+netmask = 255.255.255.0
+
+[incomingtcp]
+# BEGIN packer-portfwd-2222
+2222 = 172.16.41.129:22
+# END packer-portfwd-2222
+# Use these with care - anyone can enter into your VM through these...
+#8080 = 172.16.41.128:80
+8888 = 172.16.41.130:80
+
+[incomingudp]
+`, string(contents))
+
+	if err := f.Remove(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	contents, err = os.ReadFile(f.ConfPath)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assert.Equal(t, testNatConf, string(contents), "port forward should be removed")
+
+	_, err = os.Stat(f.ConfPath + ".packer-lock")
+	assert.True(t, os.IsNotExist(err), "lock file should be removed")
+}
+
+func TestAddNatPortForward_noSection(t *testing.T) {
+	driver := testNatPortForwardDriver(t, "[host]\nip = 172.16.41.2", "")
+
+	f, err := AddNatPortForward(driver, "vmnet8", 2222, net.ParseIP("172.16.41.129"), 22)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	contents, err := os.ReadFile(f.ConfPath)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assert.Equal(t, `[host]
+ip = 172.16.41.2
+
+[incomingtcp]
+# BEGIN packer-portfwd-2222
+2222 = 172.16.41.129:22
+# END packer-portfwd-2222
+`, string(contents))
+}
+
+func TestAddNatPortForward_inUse(t *testing.T) {
+	driver := testNatPortForwardDriver(t, testNatConf, "")
+
+	if _, err := AddNatPortForward(driver, "vmnet8", 8888, net.ParseIP("172.16.41.129"), 22); err == nil {
+		t.Fatal("should error when the host port is already forwarded")
+	}
+
+	contents, err := os.ReadFile(driver.VmnetnatConfPath("vmnet8"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assert.Equal(t, testNatConf, string(contents), "configuration should not be modified")
+}
+
+func TestAddNatPortForward_networking(t *testing.T) {
+	networking, err := os.ReadFile(filepath.Join("testdata", "networking-example"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	driver := testNatPortForwardDriver(t, "", string(networking))
+
+	f, err := AddNatPortForward(driver, "vmnet8", 2300, net.ParseIP("172.16.41.129"), 22)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assert.Equal(t, driver.NetworkingConfPath(), f.ConfPath)
+
+	contents, err := os.ReadFile(f.ConfPath)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assert.Equal(t, string(networking)+"add_nat_portfwd 8 tcp 2300 172.16.41.129 22\n", string(contents), "only the port forward should be added")
+
+	fd, err := os.Open(f.ConfPath)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	config, err := ReadNetworkingConfig(fd)
+	fd.Close()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assert.Equal(t, "172.16.41.129:22", config.natPortFwd[8-1]["tcp/2300"])
+
+	if _, err := AddNatPortForward(driver, "vmnet8", 2222, net.ParseIP("172.16.41.129"), 22); err == nil {
+		t.Fatal("should error when the host port is already forwarded")
+	}
+
+	if err := f.Remove(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	contents, err = os.ReadFile(f.ConfPath)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assert.Equal(t, string(networking), string(contents), "port forward should be removed")
+}
+
+func TestAddNatPortForward_notFound(t *testing.T) {
+	driver := testNatPortForwardDriver(t, "", "")
+
+	if _, err := AddNatPortForward(driver, "vmnet8", 2222, net.ParseIP("172.16.41.129"), 22); err == nil {
+		t.Fatal("should error without a NAT configuration")
+	}
+}
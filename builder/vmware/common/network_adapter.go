@@ -0,0 +1,115 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"fmt"
+	"log"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// networkAdapterKey matches the keys of the network adapters in the .vmx data.
+var networkAdapterKey = regexp.MustCompile(`^ethernet[[:digit:]]+\.`)
+
+// NetworkConnection describes how a network adapter connects to a network.
+type NetworkConnection struct {
+	// The connection type of the network adapter; for example, `nat`,
+	// `bridged`, or `custom`.
+	Type string
+	// The virtual network device for a custom connection; for example,
+	// `vmnet2`. Empty if the desktop hypervisor chooses the device.
+	Device string
+	// The network that is used to discover the host and guest addresses.
+	Network string
+}
+
+// ResolveNetwork returns the network connection for the network. If the
+// network maps to one or more devices, the desktop hypervisor chooses the
+// device. Otherwise, the network is assumed to be a custom device.
+func ResolveNetwork(driver VmwareDriver, network string) (*NetworkConnection, error) {
+	if driver.NetworkMapper == nil {
+		return &NetworkConnection{
+			Type:    DefaultNetworkType,
+			Device:  network,
+			Network: DefaultNetworkType,
+		}, nil
+	}
+
+	netmap, err := driver.NetworkMapper()
+	if err != nil {
+		return nil, fmt.Errorf("error reading network map configuration: %s", err)
+	}
+
+	// If multiple devices exist, for example for network "nat", the desktop
+	// hypervisor chooses the actual device. Only type "custom" allows the
+	// exact choice of a specific virtual network. For device-specific
+	// operations, all the devices that match the network are searched.
+	devices, err := netmap.NameIntoDevices(network)
+	if err == nil && len(devices) > 0 {
+		return &NetworkConnection{Type: network, Network: network}, nil
+	}
+
+	return &NetworkConnection{Type: "custom", Device: network, Network: network}, nil
+}
+
+// networkDevice returns the virtual network device of the network; for
+// example, `vmnet8`. If the network maps to multiple devices, the first device
+// is returned.
+func networkDevice(driver VmwareDriver, network string) (string, error) {
+	if driver.NetworkMapper == nil {
+		return "", fmt.Errorf("unable to determine the device of network %s", network)
+	}
+
+	conn, err := ResolveNetwork(driver, network)
+	if err != nil {
+		return "", err
+	}
+	if conn.Device != "" {
+		return conn.Device, nil
+	}
+
+	netmap, err := driver.NetworkMapper()
+	if err != nil {
+		return "", err
+	}
+	devices, err := netmap.NameIntoDevices(conn.Network)
+	if err != nil {
+		return "", err
+	}
+	if len(devices) > 1 {
+		log.Printf("[WARN] Network %s maps to multiple devices %v; using %s", conn.Network, devices, devices[0])
+	}
+	return devices[0], nil
+}
+
+// ApplyNetworkAdapters replaces the network adapters in the .vmx document with
+// the network adapters, in order, and returns the network connection of each.
+func ApplyNetworkAdapters(driver VmwareDriver, adapters []NetworkAdapterConfig, vmx *VMXDocument) ([]*NetworkConnection, error) {
+	for _, k := range vmx.Keys() {
+		if networkAdapterKey.MatchString(strings.ToLower(k)) {
+			vmx.Delete(k)
+		}
+	}
+
+	conns := make([]*NetworkConnection, 0, len(adapters))
+	for i := range adapters {
+		conn, err := ResolveNetwork(driver, adapters[i].Network)
+		if err != nil {
+			return nil, err
+		}
+
+		vmxData := adapters[i].VMXData(i, conn)
+		for _, k := range slices.Sorted(maps.Keys(vmxData)) {
+			log.Printf("[INFO] Setting VMX: '%s' = '%s'", k, vmxData[k])
+			vmx.Set(k, vmxData[k])
+		}
+		conns = append(conns, conn)
+	}
+
+	return conns, nil
+}
@@ -0,0 +1,226 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type NetworkAdapterConfig
+
+package common
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/template/config"
+)
+
+// maxNetworkAdapters is the number of network adapters supported by the
+// desktop hypervisor, from `ethernet0` to `ethernet9`.
+const maxNetworkAdapters = 10
+
+// The range of MAC addresses that can be statically assigned to a virtual
+// machine by the desktop hypervisor.
+var (
+	staticMACAddressFirst = net.HardwareAddr{0x00, 0x50, 0x56, 0x00, 0x00, 0x00}
+	staticMACAddressLast  = net.HardwareAddr{0x00, 0x50, 0x56, 0x3f, 0xff, 0xff}
+)
+
+// NetworkAdapterConfig defines a network adapter of the virtual machine.
+//
+// HCL Example:
+//
+// ```hcl
+//
+//	network_adapters {
+//	  network      = "nat"
+//	  adapter_type = "vmxnet3"
+//	}
+//
+//	network_adapters {
+//	  network            = "vmnet2"
+//	  adapter_type       = "e1000e"
+//	  mac_address        = "00:50:56:00:00:02"
+//	  incoming_bandwidth = 10240
+//	}
+//
+// ```
+//
+// JSON Example:
+//
+// ```json
+//
+//	"network_adapters": [
+//	  {
+//	    "network": "nat",
+//	    "adapter_type": "vmxnet3"
+//	  },
+//	  {
+//	    "network": "vmnet2",
+//	    "adapter_type": "e1000e",
+//	    "mac_address": "00:50:56:00:00:02",
+//	    "incoming_bandwidth": 10240
+//	  }
+//	]
+//
+// ```
+type NetworkAdapterConfig struct {
+	// The network to which the network adapter is connected. Recommended
+	// values are `nat`, `hostonly`, or `bridged`. Defaults to `nat`.
+	//
+	// ~> **Note:** If not set to one of these recommended values, then
+	// it is assumed to be a custom virtual network device; for example,
+	// `vmnet2`.
+	Network string `mapstructure:"network" required:"false"`
+	// The network adapter type. Allowed values are `vmxnet3`, `e1000e`, and
+	// `e1000`. Defaults to the value of `network_adapter_type`.
+	AdapterType string `mapstructure:"adapter_type" required:"false"`
+	// A static MAC address for the network adapter; for example,
+	// `00:50:56:00:00:01`. The address must be in the range
+	// `00:50:56:00:00:00` to `00:50:56:3f:ff:ff`. If not set, the desktop
+	// hypervisor generates an address.
+	MACAddress string `mapstructure:"mac_address" required:"false"`
+	// Reserve a fixed IP address for the network adapter in the DHCP
+	// configuration of the virtual network, so that the guest is assigned a
+	// deterministic IP address. A free address outside the dynamic range of
+	// the subnet is reserved for the MAC address of the network adapter. If
+	// `mac_address` is not set, a static MAC address is generated. The
+	// reservation is removed at the end of the build. Defaults to `false`.
+	//
+	// ~> **Note:** The DHCP service is restarted after the DHCP configuration
+	// is updated, which requires administrative privileges and briefly
+	// interrupts the virtual networks of the desktop hypervisor. Not
+	// supported for `bridged` networks.
+	DHCPReservation bool `mapstructure:"dhcp_reservation" required:"false"`
+	// Connect the network adapter when the virtual machine is powered on.
+	// Defaults to `true`.
+	StartConnected config.Trilean `mapstructure:"start_connected" required:"false"`
+	// The bandwidth limit for incoming traffic in Kbps. Defaults to `0`,
+	// which is unlimited.
+	IncomingBandwidth int `mapstructure:"incoming_bandwidth" required:"false"`
+	// The bandwidth limit for outgoing traffic in Kbps. Defaults to `0`,
+	// which is unlimited.
+	OutgoingBandwidth int `mapstructure:"outgoing_bandwidth" required:"false"`
+	// The percentage of incoming packets to drop, from `0` to `100`.
+	// Defaults to `0`.
+	IncomingPacketLoss float64 `mapstructure:"incoming_packet_loss" required:"false"`
+	// The percentage of outgoing packets to drop, from `0` to `100`.
+	// Defaults to `0`.
+	OutgoingPacketLoss float64 `mapstructure:"outgoing_packet_loss" required:"false"`
+}
+
+// Prepare validates and sets default values for the network adapter
+// configuration. The adapter type defaults to defaultAdapterType.
+func (c *NetworkAdapterConfig) Prepare(defaultAdapterType string) []error {
+	var errs []error
+
+	if c.Network == "" {
+		c.Network = DefaultNetworkType
+	}
+
+	if c.AdapterType == "" {
+		c.AdapterType = defaultAdapterType
+	}
+	c.AdapterType = strings.ToLower(c.AdapterType)
+
+	if c.AdapterType == "" {
+		errs = append(errs, fmt.Errorf("'adapter_type' is required when 'network_adapter_type' is not set; must be one of %s", strings.Join(allowedNetworkAdapterTypes, ", ")))
+	} else if !slices.Contains(allowedNetworkAdapterTypes, c.AdapterType) {
+		errs = append(errs, fmt.Errorf("invalid 'adapter_type' specified: %s; must be one of %s", c.AdapterType, strings.Join(allowedNetworkAdapterTypes, ", ")))
+	}
+
+	if c.MACAddress != "" {
+		mac, err := net.ParseMAC(c.MACAddress)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid 'mac_address' specified: %s", err))
+		} else if len(mac) != len(staticMACAddressFirst) ||
+			bytes.Compare(mac, staticMACAddressFirst) < 0 || bytes.Compare(mac, staticMACAddressLast) > 0 {
+			errs = append(errs, fmt.Errorf("invalid 'mac_address' specified: %s; must be in the range %s to %s", c.MACAddress, staticMACAddressFirst, staticMACAddressLast))
+		} else {
+			c.MACAddress = mac.String()
+		}
+	}
+
+	if c.DHCPReservation {
+		if strings.EqualFold(c.Network, "bridged") {
+			errs = append(errs, fmt.Errorf("'dhcp_reservation' is not supported for bridged networks"))
+		}
+		if c.MACAddress == "" {
+			c.MACAddress = generateStaticMACAddress().String()
+		}
+	}
+
+	if c.IncomingBandwidth < 0 {
+		errs = append(errs, fmt.Errorf("invalid 'incoming_bandwidth' specified (incoming_bandwidth < 0): %d", c.IncomingBandwidth))
+	}
+
+	if c.OutgoingBandwidth < 0 {
+		errs = append(errs, fmt.Errorf("invalid 'outgoing_bandwidth' specified (outgoing_bandwidth < 0): %d", c.OutgoingBandwidth))
+	}
+
+	if c.IncomingPacketLoss < 0 || c.IncomingPacketLoss > 100 {
+		errs = append(errs, fmt.Errorf("invalid 'incoming_packet_loss' specified: %v; must be from 0 to 100", c.IncomingPacketLoss))
+	}
+
+	if c.OutgoingPacketLoss < 0 || c.OutgoingPacketLoss > 100 {
+		errs = append(errs, fmt.Errorf("invalid 'outgoing_packet_loss' specified: %v; must be from 0 to 100", c.OutgoingPacketLoss))
+	}
+
+	return errs
+}
+
+// generateStaticMACAddress returns a random MAC address in the range that can
+// be statically assigned to a virtual machine.
+func generateStaticMACAddress() net.HardwareAddr {
+	mac := slices.Clone(staticMACAddressFirst)
+	n := rand.Uint32N(1 << 22)
+	mac[3] = byte(n >> 16)
+	mac[4] = byte(n >> 8)
+	mac[5] = byte(n)
+	return mac
+}
+
+// VMXData returns the .vmx data for the network adapter at the index,
+// connected using the network connection.
+func (c *NetworkAdapterConfig) VMXData(index int, conn *NetworkConnection) map[string]string {
+	prefix := fmt.Sprintf("ethernet%d.", index)
+	vmxData := map[string]string{
+		prefix + "present":        "TRUE",
+		prefix + "connectiontype": conn.Type,
+		prefix + "virtualdev":     c.AdapterType,
+		prefix + "startconnected": strings.ToUpper(strconv.FormatBool(!c.StartConnected.False())),
+		prefix + "addresstype":    "generated",
+		prefix + "wakeonpcktrcv":  "FALSE",
+	}
+
+	if conn.Device != "" {
+		vmxData[prefix+"vnet"] = conn.Device
+	}
+
+	if c.MACAddress != "" {
+		vmxData[prefix+"addresstype"] = "static"
+		vmxData[prefix+"address"] = c.MACAddress
+	}
+
+	if c.IncomingBandwidth > 0 {
+		vmxData[prefix+"rxbw.limit"] = strconv.Itoa(c.IncomingBandwidth)
+	}
+
+	if c.OutgoingBandwidth > 0 {
+		vmxData[prefix+"txbw.limit"] = strconv.Itoa(c.OutgoingBandwidth)
+	}
+
+	if c.IncomingPacketLoss > 0 {
+		vmxData[prefix+"rxfi.pktloss"] = strconv.FormatFloat(c.IncomingPacketLoss, 'f', -1, 64)
+	}
+
+	if c.OutgoingPacketLoss > 0 {
+		vmxData[prefix+"txfi.pktloss"] = strconv.FormatFloat(c.OutgoingPacketLoss, 'f', -1, 64)
+	}
+
+	return vmxData
+}
@@ -0,0 +1,47 @@
+// Code generated by "packer-sdc mapstructure-to-hcl2"; DO NOT EDIT.
+
+package common
+
+import (
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// FlatNetworkAdapterConfig is an auto-generated flat version of NetworkAdapterConfig.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatNetworkAdapterConfig struct {
+	Network            *string  `mapstructure:"network" required:"false" cty:"network" hcl:"network"`
+	AdapterType        *string  `mapstructure:"adapter_type" required:"false" cty:"adapter_type" hcl:"adapter_type"`
+	MACAddress         *string  `mapstructure:"mac_address" required:"false" cty:"mac_address" hcl:"mac_address"`
+	DHCPReservation    *bool    `mapstructure:"dhcp_reservation" required:"false" cty:"dhcp_reservation" hcl:"dhcp_reservation"`
+	StartConnected     *bool    `mapstructure:"start_connected" required:"false" cty:"start_connected" hcl:"start_connected"`
+	IncomingBandwidth  *int     `mapstructure:"incoming_bandwidth" required:"false" cty:"incoming_bandwidth" hcl:"incoming_bandwidth"`
+	OutgoingBandwidth  *int     `mapstructure:"outgoing_bandwidth" required:"false" cty:"outgoing_bandwidth" hcl:"outgoing_bandwidth"`
+	IncomingPacketLoss *float64 `mapstructure:"incoming_packet_loss" required:"false" cty:"incoming_packet_loss" hcl:"incoming_packet_loss"`
+	OutgoingPacketLoss *float64 `mapstructure:"outgoing_packet_loss" required:"false" cty:"outgoing_packet_loss" hcl:"outgoing_packet_loss"`
+}
+
+// FlatMapstructure returns a new FlatNetworkAdapterConfig.
+// FlatNetworkAdapterConfig is an auto-generated flat version of NetworkAdapterConfig.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*NetworkAdapterConfig) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatNetworkAdapterConfig)
+}
+
+// HCL2Spec returns the hcl spec of a NetworkAdapterConfig.
+// This spec is used by HCL to read the fields of NetworkAdapterConfig.
+// The decoded values from this spec will then be applied to a FlatNetworkAdapterConfig.
+func (*FlatNetworkAdapterConfig) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"network":              &hcldec.AttrSpec{Name: "network", Type: cty.String, Required: false},
+		"adapter_type":         &hcldec.AttrSpec{Name: "adapter_type", Type: cty.String, Required: false},
+		"mac_address":          &hcldec.AttrSpec{Name: "mac_address", Type: cty.String, Required: false},
+		"dhcp_reservation":     &hcldec.AttrSpec{Name: "dhcp_reservation", Type: cty.Bool, Required: false},
+		"start_connected":      &hcldec.AttrSpec{Name: "start_connected", Type: cty.Bool, Required: false},
+		"incoming_bandwidth":   &hcldec.AttrSpec{Name: "incoming_bandwidth", Type: cty.Number, Required: false},
+		"outgoing_bandwidth":   &hcldec.AttrSpec{Name: "outgoing_bandwidth", Type: cty.Number, Required: false},
+		"incoming_packet_loss": &hcldec.AttrSpec{Name: "incoming_packet_loss", Type: cty.Number, Required: false},
+		"outgoing_packet_loss": &hcldec.AttrSpec{Name: "outgoing_packet_loss", Type: cty.Number, Required: false},
+	}
+	return s
+}
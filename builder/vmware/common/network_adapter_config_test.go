@@ -0,0 +1,108 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/template/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNetworkAdapterConfigPrepare(t *testing.T) {
+	c := &NetworkAdapterConfig{MACAddress: "00-50-56-3F-FF-FF"}
+	if errs := c.Prepare("VMXNET3"); len(errs) > 0 {
+		t.Fatalf("err: %#v", errs)
+	}
+
+	assert.Equal(t, DefaultNetworkType, c.Network)
+	assert.Equal(t, networkAdapterVmxnet3, c.AdapterType)
+	assert.Equal(t, "00:50:56:3f:ff:ff", c.MACAddress)
+}
+
+func TestNetworkAdapterConfigPrepare_invalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		config NetworkAdapterConfig
+	}{
+		{"no adapter type", NetworkAdapterConfig{}},
+		{"bad adapter type", NetworkAdapterConfig{AdapterType: "vlance"}},
+		{"bad mac address", NetworkAdapterConfig{AdapterType: "e1000", MACAddress: "not-a-mac"}},
+		{"mac address out of range", NetworkAdapterConfig{AdapterType: "e1000", MACAddress: "00:50:56:40:00:00"}},
+		{"negative bandwidth", NetworkAdapterConfig{AdapterType: "e1000", OutgoingBandwidth: -1}},
+		{"packet loss out of range", NetworkAdapterConfig{AdapterType: "e1000", IncomingPacketLoss: 100.5}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if errs := tt.config.Prepare(""); len(errs) != 1 {
+				t.Fatalf("expected one error, got: %#v", errs)
+			}
+		})
+	}
+}
+
+func TestNetworkAdapterConfigVMXData(t *testing.T) {
+	c := &NetworkAdapterConfig{
+		AdapterType:        "e1000e",
+		MACAddress:         "00:50:56:00:00:02",
+		StartConnected:     config.TriFalse,
+		IncomingBandwidth:  10240,
+		OutgoingBandwidth:  1024,
+		IncomingPacketLoss: 2,
+		OutgoingPacketLoss: 0.5,
+	}
+
+	vmxData := c.VMXData(1, &NetworkConnection{Type: "custom", Device: "vmnet2", Network: "vmnet2"})
+
+	assert.Equal(t, map[string]string{
+		"ethernet1.present":        "TRUE",
+		"ethernet1.connectiontype": "custom",
+		"ethernet1.vnet":           "vmnet2",
+		"ethernet1.virtualdev":     "e1000e",
+		"ethernet1.startconnected": "FALSE",
+		"ethernet1.addresstype":    "static",
+		"ethernet1.address":        "00:50:56:00:00:02",
+		"ethernet1.wakeonpcktrcv":  "FALSE",
+		"ethernet1.rxbw.limit":     "10240",
+		"ethernet1.txbw.limit":     "1024",
+		"ethernet1.rxfi.pktloss":   "2",
+		"ethernet1.txfi.pktloss":   "0.5",
+	}, vmxData)
+}
+
+func TestNetworkAdapterConfigVMXData_defaults(t *testing.T) {
+	c := &NetworkAdapterConfig{AdapterType: "vmxnet3"}
+
+	vmxData := c.VMXData(0, &NetworkConnection{Type: "nat", Network: "nat"})
+
+	assert.Equal(t, map[string]string{
+		"ethernet0.present":        "TRUE",
+		"ethernet0.connectiontype": "nat",
+		"ethernet0.virtualdev":     "vmxnet3",
+		"ethernet0.startconnected": "TRUE",
+		"ethernet0.addresstype":    "generated",
+		"ethernet0.wakeonpcktrcv":  "FALSE",
+	}, vmxData)
+}
+
+func TestNetworkAdapterConfigPrepare_dhcpReservation(t *testing.T) {
+	c := &NetworkAdapterConfig{AdapterType: "e1000", DHCPReservation: true}
+	if errs := c.Prepare(""); len(errs) > 0 {
+		t.Fatalf("err: %#v", errs)
+	}
+	assert.Regexp(t, `^00:50:56:[0-3][0-9a-f]:[0-9a-f]{2}:[0-9a-f]{2}$`, c.MACAddress)
+
+	mac := c.MACAddress
+	if errs := c.Prepare(""); len(errs) > 0 {
+		t.Fatalf("err: %#v", errs)
+	}
+	assert.Equal(t, mac, c.MACAddress, "generated MAC address should be retained")
+
+	c = &NetworkAdapterConfig{Network: "bridged", AdapterType: "e1000", DHCPReservation: true}
+	if errs := c.Prepare(""); len(errs) != 1 {
+		t.Fatalf("expected one error, got: %#v", errs)
+	}
+}
@@ -0,0 +1,96 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testNetworkMapper maps the names of networks to devices.
+type testNetworkMapper map[string][]string
+
+func (m testNetworkMapper) NameIntoDevices(name string) ([]string, error) {
+	if devices, ok := m[name]; ok {
+		return devices, nil
+	}
+	return nil, fmt.Errorf("network name not found: %s", name)
+}
+
+func (m testNetworkMapper) DeviceIntoName(device string) (string, error) {
+	for name, devices := range m {
+		for _, d := range devices {
+			if d == device {
+				return name, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("device not found: %s", device)
+}
+
+func testNetworkDriver() VmwareDriver {
+	return VmwareDriver{
+		NetworkMapper: func() (NetworkNameMapper, error) {
+			return testNetworkMapper{"nat": {"vmnet8"}, "hostonly": {"vmnet1"}}, nil
+		},
+	}
+}
+
+func TestResolveNetwork(t *testing.T) {
+	conn, err := ResolveNetwork(testNetworkDriver(), "hostonly")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assert.Equal(t, &NetworkConnection{Type: "hostonly", Network: "hostonly"}, conn)
+
+	conn, err = ResolveNetwork(testNetworkDriver(), "vmnet2")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assert.Equal(t, &NetworkConnection{Type: "custom", Device: "vmnet2", Network: "vmnet2"}, conn)
+
+	conn, err = ResolveNetwork(VmwareDriver{}, "vmnet2")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assert.Equal(t, &NetworkConnection{Type: DefaultNetworkType, Device: "vmnet2", Network: DefaultNetworkType}, conn)
+}
+
+func TestApplyNetworkAdapters(t *testing.T) {
+	vmx := ParseVMXDocument(`displayName = "test"
+ethernet0.connectionType = "bridged"
+ethernet0.pciSlotNumber = "33"
+ethernet2.present = "TRUE"
+`)
+	adapters := []NetworkAdapterConfig{
+		{Network: "nat", AdapterType: "vmxnet3"},
+		{Network: "vmnet2", AdapterType: "e1000e"},
+	}
+
+	conns, err := ApplyNetworkAdapters(testNetworkDriver(), adapters, vmx)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	assert.Equal(t, []*NetworkConnection{
+		{Type: "nat", Network: "nat"},
+		{Type: "custom", Device: "vmnet2", Network: "vmnet2"},
+	}, conns)
+
+	vmxData := vmx.Map()
+	assert.Equal(t, "nat", vmxData["ethernet0.connectiontype"])
+	assert.Equal(t, "vmxnet3", vmxData["ethernet0.virtualdev"])
+	assert.Equal(t, "custom", vmxData["ethernet1.connectiontype"])
+	assert.Equal(t, "vmnet2", vmxData["ethernet1.vnet"])
+	assert.Equal(t, "test", vmxData["displayname"])
+
+	for _, k := range []string{"ethernet0.pcislotnumber", "ethernet0.vnet", "ethernet2.present"} {
+		_, ok := vmxData[k]
+		assert.False(t, ok, "existing network adapter key %s should be removed", k)
+	}
+	assert.Contains(t, vmx.String(), "ethernet0.connectionType = \"nat\"\n")
+}
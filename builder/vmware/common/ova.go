@@ -0,0 +1,192 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ovaMaxUSTARSize is the size of the largest file that a USTAR header can
+// describe. The header of a larger file, such as a disk of a multi-GB image,
+// is written in the PAX format, which tools that read OVAs also support.
+const ovaMaxUSTARSize = 1<<33 - 1
+
+// ovaMaxUSTARName is the length of the longest name without a `/` that a
+// USTAR header can describe. The header of an entry with a longer name, such
+// as the disk of a virtual machine with a long name, is written in the PAX
+// format.
+const ovaMaxUSTARName = 100
+
+// ovaEntry is a file packaged in an OVA.
+type ovaEntry struct {
+	name   string
+	path   string
+	size   int64
+	digest string
+}
+
+// ovfReferences is the subset of an OVF descriptor that lists the files
+// referenced by the package.
+type ovfReferences struct {
+	Files []struct {
+		Href string `xml:"href,attr"`
+	} `xml:"References>File"`
+}
+
+// PackOVA packages the OVF descriptor at ovfPath and the files it references
+// into an OVA at ovaPath. The entries are ordered as required by the OVF
+// specification: the descriptor first, followed by the manifest, and then the
+// referenced files in the order they are listed in the descriptor. The
+// manifest is generated with the SHA256 digests of the descriptor and the
+// referenced files, replacing any existing manifest.
+//
+// The files are streamed into the archive, so they are never held in memory.
+func PackOVA(ovfPath string, ovaPath string) error {
+	descriptor, err := os.ReadFile(ovfPath)
+	if err != nil {
+		return fmt.Errorf("error reading OVF descriptor: %s", err)
+	}
+
+	var refs ovfReferences
+	if err := xml.Unmarshal(descriptor, &refs); err != nil {
+		return fmt.Errorf("error parsing OVF descriptor: %s", err)
+	}
+
+	ovfName := filepath.Base(ovfPath)
+	ovfDigest := sha256.Sum256(descriptor)
+	entries := []*ovaEntry{{
+		name:   ovfName,
+		path:   ovfPath,
+		size:   int64(len(descriptor)),
+		digest: hex.EncodeToString(ovfDigest[:]),
+	}}
+
+	dir := filepath.Dir(ovfPath)
+	for _, file := range refs.Files {
+		if file.Href == "" || strings.Contains(file.Href, "://") || filepath.Base(file.Href) != file.Href {
+			return fmt.Errorf("unsupported file reference in OVF descriptor: %q", file.Href)
+		}
+
+		entry, err := newOvaEntry(filepath.Join(dir, file.Href))
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry)
+	}
+
+	var mf strings.Builder
+	for _, entry := range entries {
+		fmt.Fprintf(&mf, "SHA256(%s)= %s\n", entry.name, entry.digest)
+	}
+	manifest := mf.String()
+	mfName := strings.TrimSuffix(ovfName, filepath.Ext(ovfName)) + ".mf"
+
+	log.Printf("[INFO] Packaging OVA: %s", ovaPath)
+	f, err := os.Create(ovaPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	tw := tar.NewWriter(f)
+	now := time.Now().Truncate(time.Second)
+
+	if err := writeOvaEntry(tw, entries[0], now); err != nil {
+		return err
+	}
+
+	header := &tar.Header{
+		Name:    mfName,
+		Mode:    0644,
+		Size:    int64(len(manifest)),
+		ModTime: now,
+		Format:  ovaHeaderFormat(mfName, int64(len(manifest))),
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("error writing %s to OVA: %s", mfName, err)
+	}
+	if _, err := io.WriteString(tw, manifest); err != nil {
+		return fmt.Errorf("error writing %s to OVA: %s", mfName, err)
+	}
+
+	for _, entry := range entries[1:] {
+		if err := writeOvaEntry(tw, entry, now); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// newOvaEntry returns the OVA entry for the file, including its SHA256 digest.
+func newOvaEntry(path string) (*ovaEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading OVF file: %s", err)
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, f)
+	if err != nil {
+		return nil, fmt.Errorf("error computing digest of %s: %s", path, err)
+	}
+
+	return &ovaEntry{
+		name:   filepath.Base(path),
+		path:   path,
+		size:   size,
+		digest: hex.EncodeToString(hash.Sum(nil)),
+	}, nil
+}
+
+// writeOvaEntry streams the file into the OVA.
+func writeOvaEntry(tw *tar.Writer, entry *ovaEntry, modTime time.Time) error {
+	f, err := os.Open(entry.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	header := &tar.Header{
+		Name:    entry.name,
+		Mode:    0644,
+		Size:    entry.size,
+		ModTime: modTime,
+		Format:  ovaHeaderFormat(entry.name, entry.size),
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("error writing %s to OVA: %s", entry.name, err)
+	}
+
+	if _, err := io.CopyN(tw, f, entry.size); err != nil {
+		return fmt.Errorf("error writing %s to OVA: %s", entry.name, err)
+	}
+
+	return nil
+}
+
+// ovaHeaderFormat returns the format of the header of an OVA entry with the
+// name and the size. The USTAR format is used unless the name is too long or
+// the entry is too large for it.
+func ovaHeaderFormat(name string, size int64) tar.Format {
+	if len(name) > ovaMaxUSTARName || size > ovaMaxUSTARSize {
+		return tar.FormatPAX
+	}
+	return tar.FormatUSTAR
+}
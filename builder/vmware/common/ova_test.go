@@ -0,0 +1,213 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testOvaDescriptor = `<?xml version="1.0" encoding="UTF-8"?>
+<Envelope xmlns="http://schemas.dmtf.org/ovf/envelope/1" xmlns:ovf="http://schemas.dmtf.org/ovf/envelope/1">
+  <References>
+    <File ovf:href="vm-disk2.vmdk" ovf:id="file1" ovf:size="6"/>
+    <File ovf:href="vm-disk1.vmdk" ovf:id="file2" ovf:size="6"/>
+  </References>
+</Envelope>
+`
+
+func testOvaFiles(t *testing.T, descriptor string) string {
+	dir := t.TempDir()
+	files := map[string]string{
+		"vm.ovf":        descriptor,
+		"vm.mf":         "SHA1(vm.ovf)= stale\n",
+		"vm-disk1.vmdk": "disk-1",
+		"vm-disk2.vmdk": "disk-2",
+	}
+	for name, contents := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0644); err != nil { //nolint:gosec
+			t.Fatalf("err: %s", err)
+		}
+	}
+	return dir
+}
+
+func sha256Hex(s string) string {
+	digest := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(digest[:])
+}
+
+func TestPackOVA(t *testing.T) {
+	dir := testOvaFiles(t, testOvaDescriptor)
+	ovaPath := filepath.Join(t.TempDir(), "vm.ova")
+
+	if err := PackOVA(filepath.Join(dir, "vm.ovf"), ovaPath); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	f, err := os.Open(ovaPath)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer f.Close()
+
+	var names []string
+	contents := make(map[string]string)
+	tr := tar.NewReader(f)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		assert.Equal(t, tar.FormatUSTAR, header.Format)
+
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		names = append(names, header.Name)
+		contents[header.Name] = string(data)
+	}
+
+	// The descriptor is first, followed by the manifest, and then the files in
+	// the order they are referenced.
+	assert.Equal(t, []string{"vm.ovf", "vm.mf", "vm-disk2.vmdk", "vm-disk1.vmdk"}, names)
+	assert.Equal(t, testOvaDescriptor, contents["vm.ovf"])
+	assert.Equal(t, "disk-2", contents["vm-disk2.vmdk"])
+
+	expectedManifest := fmt.Sprintf("SHA256(vm.ovf)= %s\nSHA256(vm-disk2.vmdk)= %s\nSHA256(vm-disk1.vmdk)= %s\n",
+		sha256Hex(testOvaDescriptor), sha256Hex("disk-2"), sha256Hex("disk-1"))
+	assert.Equal(t, expectedManifest, contents["vm.mf"])
+}
+
+func TestPackOVA_invalidReference(t *testing.T) {
+	for _, href := range []string{"../vm-disk1.vmdk", "http://example.com/vm-disk1.vmdk", "missing.vmdk"} {
+		descriptor := fmt.Sprintf(`<Envelope><References><File href=%q/></References></Envelope>`, href)
+		dir := testOvaFiles(t, descriptor)
+
+		if err := PackOVA(filepath.Join(dir, "vm.ovf"), filepath.Join(dir, "vm.ova")); err == nil {
+			t.Fatalf("should have error for reference %q", href)
+		}
+	}
+}
+
+// headWriter keeps the first bytes written to it and discards the rest.
+type headWriter struct {
+	bytes.Buffer
+	limit int
+}
+
+func (w *headWriter) Write(p []byte) (int, error) {
+	if n := w.limit - w.Len(); n > 0 {
+		w.Buffer.Write(p[:min(n, len(p))])
+	}
+	return len(p), nil
+}
+
+func TestWriteOvaEntry_large(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping large OVA entry in short mode")
+	}
+
+	// The sparse file is larger than a USTAR header can describe.
+	path := filepath.Join(t.TempDir(), "vm-disk1.vmdk")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	size := int64(ovaMaxUSTARSize + 1)
+	if err := f.Truncate(size); err != nil {
+		f.Close()
+		t.Fatalf("err: %s", err)
+	}
+	f.Close()
+
+	w := &headWriter{limit: 4096}
+	tw := tar.NewWriter(w)
+	entry := &ovaEntry{name: "vm-disk1.vmdk", path: path, size: size}
+	if err := writeOvaEntry(tw, entry, time.Now().Truncate(time.Second)); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := tw.Flush(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	header, err := tar.NewReader(bytes.NewReader(w.Bytes())).Next()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assert.Equal(t, "vm-disk1.vmdk", header.Name)
+	assert.Equal(t, size, header.Size)
+	assert.Equal(t, tar.FormatPAX, header.Format)
+}
+
+func TestPackOVA_longName(t *testing.T) {
+	// The names of the descriptor and the disk are too long for a USTAR
+	// header, but the name of the manifest is not.
+	name := strings.Repeat("a", ovaMaxUSTARName-len(".mf"))
+	dir := t.TempDir()
+	descriptor := fmt.Sprintf(`<Envelope><References><File href="%s-disk1.vmdk"/></References></Envelope>`, name)
+	files := map[string]string{
+		name + ".ovf":        descriptor,
+		name + "-disk1.vmdk": "disk-1",
+	}
+	for file, contents := range files {
+		if err := os.WriteFile(filepath.Join(dir, file), []byte(contents), 0644); err != nil { //nolint:gosec
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	ovaPath := filepath.Join(t.TempDir(), "vm.ova")
+	if err := PackOVA(filepath.Join(dir, name+".ovf"), ovaPath); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	f, err := os.Open(ovaPath)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer f.Close()
+
+	formats := make(map[string]tar.Format)
+	tr := tar.NewReader(f)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		formats[header.Name] = header.Format
+	}
+
+	assert.Equal(t, map[string]tar.Format{
+		name + ".ovf":        tar.FormatPAX,
+		name + ".mf":         tar.FormatUSTAR,
+		name + "-disk1.vmdk": tar.FormatPAX,
+	}, formats)
+}
+
+func TestOvaHeaderFormat(t *testing.T) {
+	assert.Equal(t, tar.FormatUSTAR, ovaHeaderFormat("vm-disk1.vmdk", 0))
+	assert.Equal(t, tar.FormatUSTAR, ovaHeaderFormat("vm-disk1.vmdk", ovaMaxUSTARSize))
+	assert.Equal(t, tar.FormatPAX, ovaHeaderFormat("vm-disk1.vmdk", ovaMaxUSTARSize+1))
+	assert.Equal(t, tar.FormatUSTAR, ovaHeaderFormat(strings.Repeat("a", ovaMaxUSTARName), 0))
+	assert.Equal(t, tar.FormatPAX, ovaHeaderFormat(strings.Repeat("a", ovaMaxUSTARName+1), 0))
+}
@@ -0,0 +1,485 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/vmware/packer-plugin-vmware/builder/vmware/common/devices"
+	"github.com/vmware/packer-plugin-vmware/builder/vmware/common/vmdk"
+)
+
+// OVF XML namespaces.
+const (
+	ovfNamespaceEnvelope = "http://schemas.dmtf.org/ovf/envelope/1"
+	ovfNamespaceCim      = "http://schemas.dmtf.org/wbem/wscim/1/common"
+	ovfNamespaceRasd     = "http://schemas.dmtf.org/wbem/wscim/1/cim-schema/2/CIM_ResourceAllocationSettingData"
+	ovfNamespaceVmw      = "http://www.vmware.com/schema/ovf"
+	ovfNamespaceVssd     = "http://schemas.dmtf.org/wbem/wscim/1/cim-schema/2/CIM_VirtualSystemSettingData"
+	ovfNamespaceXsi      = "http://www.w3.org/2001/XMLSchema-instance"
+)
+
+// CIM resource types used in the virtual hardware section.
+const (
+	ovfResourceProcessor      = 3
+	ovfResourceMemory         = 4
+	ovfResourceIdeController  = 5
+	ovfResourceScsiController = 6
+	ovfResourceEthernet       = 10
+	ovfResourceCdrom          = 15
+	ovfResourceDisk           = 17
+	ovfResourceOtherStorage   = 20
+)
+
+// CIM operating system types.
+const (
+	ovfOsTypeOther   = 1
+	ovfOsTypeOther64 = 102
+)
+
+// ovfScsiSubTypes maps the .vmx SCSI controller types to the OVF resource subtypes.
+var ovfScsiSubTypes = map[string]string{
+	"lsilogic":   "lsilogic",
+	"lsisas1068": "lsilogicsas",
+	"pvscsi":     "VirtualSCSI",
+	"buslogic":   "buslogic",
+}
+
+// ovfEthernetSubTypes maps the .vmx network adapter types to the OVF resource subtypes.
+var ovfEthernetSubTypes = map[string]string{
+	networkAdapterE1000:   "E1000",
+	networkAdapterE1000E:  "E1000e",
+	networkAdapterVmxnet3: "VmxNet3",
+}
+
+type ovfEnvelope struct {
+	XMLName        xml.Name           `xml:"Envelope"`
+	Xmlns          string             `xml:"xmlns,attr"`
+	XmlnsCim       string             `xml:"xmlns:cim,attr"`
+	XmlnsOvf       string             `xml:"xmlns:ovf,attr"`
+	XmlnsRasd      string             `xml:"xmlns:rasd,attr"`
+	XmlnsVmw       string             `xml:"xmlns:vmw,attr"`
+	XmlnsVssd      string             `xml:"xmlns:vssd,attr"`
+	XmlnsXsi       string             `xml:"xmlns:xsi,attr"`
+	References     []ovfFile          `xml:"References>File"`
+	DiskSection    *ovfDiskSection    `xml:"DiskSection,omitempty"`
+	NetworkSection *ovfNetworkSection `xml:"NetworkSection,omitempty"`
+	VirtualSystem  ovfVirtualSystem   `xml:"VirtualSystem"`
+}
+
+type ovfFile struct {
+	Href string `xml:"ovf:href,attr"`
+	ID   string `xml:"ovf:id,attr"`
+	Size int64  `xml:"ovf:size,attr"`
+}
+
+type ovfDiskSection struct {
+	Info  string    `xml:"Info"`
+	Disks []ovfDisk `xml:"Disk"`
+}
+
+type ovfDisk struct {
+	Capacity                int64  `xml:"ovf:capacity,attr"`
+	CapacityAllocationUnits string `xml:"ovf:capacityAllocationUnits,attr"`
+	DiskID                  string `xml:"ovf:diskId,attr"`
+	FileRef                 string `xml:"ovf:fileRef,attr"`
+	Format                  string `xml:"ovf:format,attr"`
+	PopulatedSize           int64  `xml:"ovf:populatedSize,attr"`
+}
+
+type ovfNetworkSection struct {
+	Info     string       `xml:"Info"`
+	Networks []ovfNetwork `xml:"Network"`
+}
+
+type ovfNetwork struct {
+	Name        string `xml:"ovf:name,attr"`
+	Description string `xml:"Description"`
+}
+
+type ovfVirtualSystem struct {
+	ID                     string                    `xml:"ovf:id,attr"`
+	Info                   string                    `xml:"Info"`
+	Name                   string                    `xml:"Name"`
+	OperatingSystemSection ovfOperatingSystemSection `xml:"OperatingSystemSection"`
+	VirtualHardwareSection ovfVirtualHardwareSection `xml:"VirtualHardwareSection"`
+}
+
+type ovfOperatingSystemSection struct {
+	ID     int    `xml:"ovf:id,attr"`
+	OsType string `xml:"vmw:osType,attr,omitempty"`
+	Info   string `xml:"Info"`
+}
+
+type ovfVirtualHardwareSection struct {
+	Info    string      `xml:"Info"`
+	System  ovfSystem   `xml:"System"`
+	Items   []ovfItem   `xml:"Item"`
+	Configs []ovfConfig `xml:"vmw:Config"`
+}
+
+type ovfSystem struct {
+	ElementName             string `xml:"vssd:ElementName"`
+	InstanceID              int    `xml:"vssd:InstanceID"`
+	VirtualSystemIdentifier string `xml:"vssd:VirtualSystemIdentifier"`
+	VirtualSystemType       string `xml:"vssd:VirtualSystemType"`
+}
+
+// ovfItem is a virtual hardware item. The elements are in the order required
+// by the CIM schema.
+type ovfItem struct {
+	Required            *bool              `xml:"ovf:required,attr,omitempty"`
+	Address             string             `xml:"rasd:Address,omitempty"`
+	AddressOnParent     string             `xml:"rasd:AddressOnParent,omitempty"`
+	AllocationUnits     string             `xml:"rasd:AllocationUnits,omitempty"`
+	AutomaticAllocation *bool              `xml:"rasd:AutomaticAllocation,omitempty"`
+	Connection          string             `xml:"rasd:Connection,omitempty"`
+	Description         string             `xml:"rasd:Description,omitempty"`
+	ElementName         string             `xml:"rasd:ElementName"`
+	HostResource        string             `xml:"rasd:HostResource,omitempty"`
+	InstanceID          int                `xml:"rasd:InstanceID"`
+	Parent              int                `xml:"rasd:Parent,omitempty"`
+	ResourceSubType     string             `xml:"rasd:ResourceSubType,omitempty"`
+	ResourceType        int                `xml:"rasd:ResourceType"`
+	VirtualQuantity     int                `xml:"rasd:VirtualQuantity,omitempty"`
+	CoresPerSocket      *ovfCoresPerSocket `xml:"vmw:CoresPerSocket,omitempty"`
+}
+
+type ovfCoresPerSocket struct {
+	Required bool `xml:"ovf:required,attr"`
+	Value    int  `xml:",chardata"`
+}
+
+type ovfConfig struct {
+	Required bool   `xml:"ovf:required,attr"`
+	Key      string `xml:"vmw:key,attr"`
+	Value    string `xml:"vmw:value,attr"`
+}
+
+// ovfDevice is a disk or CD-ROM device attached to a controller in the .vmx file.
+type ovfDevice struct {
+	Bus        string
+	Controller int
+	Unit       int
+	CDROM      bool
+	Filename   string
+
+	// Disk is the exported virtual disk, set for disk devices.
+	Disk *ovfExportedDisk
+}
+
+// ovfExportedDisk is a virtual disk exported to an OVF package.
+type ovfExportedDisk struct {
+	Href          string
+	Size          int64
+	Capacity      int64
+	PopulatedSize int64
+}
+
+var ovfDeviceKeyRe = regexp.MustCompile(`^(scsi|sata|ide|nvme)(\d+):(\d+)\.present$`)
+
+// ovfDevicesFromVMX returns the disk and CD-ROM devices that are present in
+// the .vmx data, ordered by bus, controller, and unit.
+func ovfDevicesFromVMX(vmxData map[string]string) []*ovfDevice {
+	var devices []*ovfDevice
+
+	for key, value := range vmxData {
+		matches := ovfDeviceKeyRe.FindStringSubmatch(key)
+		if matches == nil || !strings.EqualFold(value, "TRUE") {
+			continue
+		}
+
+		prefix := strings.TrimSuffix(key, ".present")
+		controller, _ := strconv.Atoi(matches[2])
+		unit, _ := strconv.Atoi(matches[3])
+		device := &ovfDevice{
+			Bus:        matches[1],
+			Controller: controller,
+			Unit:       unit,
+			Filename:   vmxData[prefix+".filename"],
+		}
+
+		deviceType := strings.ToLower(vmxData[prefix+".devicetype"])
+		switch {
+		case strings.Contains(deviceType, "cdrom"):
+			device.CDROM = true
+		case strings.HasSuffix(strings.ToLower(device.Filename), ".vmdk"):
+		default:
+			continue
+		}
+
+		devices = append(devices, device)
+	}
+
+	sort.Slice(devices, func(i, j int) bool {
+		a, b := devices[i], devices[j]
+		if a.Bus != b.Bus {
+			return a.Bus < b.Bus
+		}
+		if a.Controller != b.Controller {
+			return a.Controller < b.Controller
+		}
+		return a.Unit < b.Unit
+	})
+
+	return devices
+}
+
+// ovfNetworkName returns the network name for the network adapter.
+func ovfNetworkName(vmxData map[string]string, adapter string) string {
+	connectionType := vmxData[adapter+".connectiontype"]
+	switch connectionType {
+	case "":
+		return DefaultNetworkType
+	case "custom":
+		if vnet := vmxData[adapter+".vnet"]; vnet != "" {
+			return vnet
+		}
+	}
+	return connectionType
+}
+
+// ovfNetworkAdapters returns the network adapters that are present, in the
+// order of their numbers.
+func ovfNetworkAdapters(vmxData map[string]string) []devices.NIC {
+	var adapters []devices.NIC
+	for _, nic := range devices.Decode(vmxData).NICs {
+		if nic.Present {
+			adapters = append(adapters, nic)
+		}
+	}
+	return adapters
+}
+
+// newOvfEnvelope creates the OVF envelope for the virtual machine from the
+// .vmx data and the disk and CD-ROM devices.
+func newOvfEnvelope(vmName string, vmxData map[string]string, devices []*ovfDevice) *ovfEnvelope {
+	name := vmxData["displayname"]
+	if name == "" {
+		name = vmName
+	}
+
+	env := &ovfEnvelope{
+		Xmlns:     ovfNamespaceEnvelope,
+		XmlnsCim:  ovfNamespaceCim,
+		XmlnsOvf:  ovfNamespaceEnvelope,
+		XmlnsRasd: ovfNamespaceRasd,
+		XmlnsVmw:  ovfNamespaceVmw,
+		XmlnsVssd: ovfNamespaceVssd,
+		XmlnsXsi:  ovfNamespaceXsi,
+	}
+
+	hardwareVersion := vmxData["virtualhw.version"]
+	if hardwareVersion == "" {
+		hardwareVersion = strconv.Itoa(DefaultHardwareVersion)
+	}
+
+	osType := ovfOsTypeOther
+	if strings.HasSuffix(vmxData["guestos"], "-64") {
+		osType = ovfOsTypeOther64
+	}
+
+	vs := &env.VirtualSystem
+	vs.ID = name
+	vs.Info = "A virtual machine"
+	vs.Name = name
+	vs.OperatingSystemSection = ovfOperatingSystemSection{
+		ID:     osType,
+		OsType: vmxData["guestos"],
+		Info:   "The kind of installed guest operating system",
+	}
+
+	hw := &vs.VirtualHardwareSection
+	hw.Info = "Virtual hardware requirements"
+	hw.System = ovfSystem{
+		ElementName:             "Virtual Hardware Family",
+		InstanceID:              0,
+		VirtualSystemIdentifier: name,
+		VirtualSystemType:       "vmx-" + hardwareVersion,
+	}
+
+	instanceID := 0
+	addItem := func(item ovfItem) int {
+		instanceID++
+		item.InstanceID = instanceID
+		hw.Items = append(hw.Items, item)
+		return instanceID
+	}
+
+	// Processors and memory.
+	cpus := 1
+	if v, err := strconv.Atoi(vmxData["numvcpus"]); err == nil && v > 0 {
+		cpus = v
+	}
+	cpu := ovfItem{
+		AllocationUnits: "hertz * 10^6",
+		Description:     "Number of Virtual CPUs",
+		ElementName:     fmt.Sprintf("%d virtual CPU(s)", cpus),
+		ResourceType:    ovfResourceProcessor,
+		VirtualQuantity: cpus,
+	}
+	if v, err := strconv.Atoi(vmxData["cpuid.corespersocket"]); err == nil && v > 0 {
+		cpu.CoresPerSocket = &ovfCoresPerSocket{Value: v}
+	}
+	addItem(cpu)
+
+	memory := DefaultMemorySize
+	if v, err := strconv.Atoi(vmxData["memsize"]); err == nil && v > 0 {
+		memory = v
+	}
+	addItem(ovfItem{
+		AllocationUnits: "byte * 2^20",
+		Description:     "Memory Size",
+		ElementName:     fmt.Sprintf("%dMB of memory", memory),
+		ResourceType:    ovfResourceMemory,
+		VirtualQuantity: memory,
+	})
+
+	// Controllers, in the order of the devices.
+	controllers := make(map[string]int)
+	for _, device := range devices {
+		key := fmt.Sprintf("%s%d", device.Bus, device.Controller)
+		if _, ok := controllers[key]; ok {
+			continue
+		}
+
+		item := ovfItem{Address: strconv.Itoa(device.Controller)}
+		switch device.Bus {
+		case "ide":
+			item.Description = "IDE Controller"
+			item.ElementName = fmt.Sprintf("IDE %d", device.Controller)
+			item.ResourceType = ovfResourceIdeController
+		case "scsi":
+			item.Description = "SCSI Controller"
+			item.ElementName = fmt.Sprintf("SCSI Controller %d", device.Controller)
+			item.ResourceType = ovfResourceScsiController
+			item.ResourceSubType = ovfScsiSubTypes[strings.ToLower(vmxData[key+".virtualdev"])]
+			if item.ResourceSubType == "" {
+				item.ResourceSubType = "lsilogic"
+			}
+		case "sata":
+			item.Description = "SATA Controller"
+			item.ElementName = fmt.Sprintf("SATA Controller %d", device.Controller)
+			item.ResourceType = ovfResourceOtherStorage
+			item.ResourceSubType = "vmware.sata.ahci"
+		case "nvme":
+			item.Description = "NVMe Controller"
+			item.ElementName = fmt.Sprintf("NVMe Controller %d", device.Controller)
+			item.ResourceType = ovfResourceOtherStorage
+			item.ResourceSubType = "vmware.nvme.controller"
+		}
+		controllers[key] = addItem(item)
+	}
+
+	// Disks and CD-ROMs.
+	diskCount := 0
+	for _, device := range devices {
+		parent := controllers[fmt.Sprintf("%s%d", device.Bus, device.Controller)]
+
+		if device.CDROM {
+			addItem(ovfItem{
+				Required:            ovfBool(false),
+				AddressOnParent:     strconv.Itoa(device.Unit),
+				AutomaticAllocation: ovfBool(false),
+				ElementName:         fmt.Sprintf("CD/DVD drive %d", device.Unit),
+				Parent:              parent,
+				ResourceType:        ovfResourceCdrom,
+			})
+			continue
+		}
+
+		if device.Disk == nil {
+			continue
+		}
+
+		diskCount++
+		fileID := fmt.Sprintf("file%d", diskCount)
+		diskID := fmt.Sprintf("vmdisk%d", diskCount)
+
+		env.References = append(env.References, ovfFile{
+			Href: device.Disk.Href,
+			ID:   fileID,
+			Size: device.Disk.Size,
+		})
+		if env.DiskSection == nil {
+			env.DiskSection = &ovfDiskSection{Info: "Virtual disk information"}
+		}
+		env.DiskSection.Disks = append(env.DiskSection.Disks, ovfDisk{
+			Capacity:                device.Disk.Capacity,
+			CapacityAllocationUnits: "byte",
+			DiskID:                  diskID,
+			FileRef:                 fileID,
+			Format:                  vmdk.StreamOptimizedFormat,
+			PopulatedSize:           device.Disk.PopulatedSize,
+		})
+
+		addItem(ovfItem{
+			AddressOnParent: strconv.Itoa(device.Unit),
+			ElementName:     fmt.Sprintf("Hard Disk %d", diskCount),
+			HostResource:    "ovf:/disk/" + diskID,
+			Parent:          parent,
+			ResourceType:    ovfResourceDisk,
+		})
+	}
+
+	// Network adapters.
+	networks := make(map[string]bool)
+	for i, adapter := range ovfNetworkAdapters(vmxData) {
+		network := ovfNetworkName(vmxData, adapter.Name())
+		if !networks[network] {
+			networks[network] = true
+			if env.NetworkSection == nil {
+				env.NetworkSection = &ovfNetworkSection{Info: "The list of logical networks"}
+			}
+			env.NetworkSection.Networks = append(env.NetworkSection.Networks, ovfNetwork{
+				Name:        network,
+				Description: fmt.Sprintf("The %s network", network),
+			})
+		}
+
+		subType := ovfEthernetSubTypes[strings.ToLower(adapter.VirtualDev)]
+		if subType == "" {
+			subType = ovfEthernetSubTypes[networkAdapterE1000]
+		}
+		addItem(ovfItem{
+			AddressOnParent:     strconv.Itoa(i),
+			AutomaticAllocation: ovfBool(true),
+			Connection:          network,
+			Description:         fmt.Sprintf("%s ethernet adapter on %s", subType, network),
+			ElementName:         fmt.Sprintf("Network adapter %d", i+1),
+			ResourceSubType:     subType,
+			ResourceType:        ovfResourceEthernet,
+		})
+	}
+
+	// Firmware.
+	if firmware := vmxData["firmware"]; firmware != "" {
+		hw.Configs = append(hw.Configs, ovfConfig{Key: "firmware", Value: firmware})
+		if firmware == FirmwareTypeUEFI && strings.EqualFold(vmxData["uefi.secureboot.enabled"], "TRUE") {
+			hw.Configs = append(hw.Configs, ovfConfig{Key: "uefi.secureBoot.enabled", Value: "true"})
+		}
+	}
+
+	return env
+}
+
+// ovfBool returns a pointer to the boolean value.
+func ovfBool(b bool) *bool {
+	return &b
+}
+
+// Marshal returns the OVF descriptor for the envelope.
+func (e *ovfEnvelope) Marshal() ([]byte, error) {
+	data, err := xml.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(data, '\n')...), nil
+}
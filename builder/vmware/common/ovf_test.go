@@ -0,0 +1,155 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"encoding/xml"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testOvfVMXData = map[string]string{
+	"displayname":              "packer-test",
+	"guestos":                  "ubuntu-64",
+	"virtualhw.version":        "21",
+	"numvcpus":                 "4",
+	"cpuid.corespersocket":     "2",
+	"memsize":                  "2048",
+	"firmware":                 "efi",
+	"uefi.secureboot.enabled":  "TRUE",
+	"scsi0.present":            "TRUE",
+	"scsi0.virtualdev":         "pvscsi",
+	"scsi0:0.present":          "TRUE",
+	"scsi0:0.filename":         "disk.vmdk",
+	"scsi0:1.present":          "TRUE",
+	"scsi0:1.filename":         "disk-1.vmdk",
+	"sata0.present":            "TRUE",
+	"sata0:1.present":          "TRUE",
+	"sata0:1.devicetype":       "cdrom-image",
+	"sata0:1.filename":         "/path/to/os.iso",
+	"ide0:0.present":           "FALSE",
+	"ide0:0.filename":          "unused.vmdk",
+	"ethernet0.present":        "TRUE",
+	"ethernet0.virtualdev":     "vmxnet3",
+	"ethernet0.connectiontype": "nat",
+	"ethernet1.present":        "TRUE",
+	"ethernet1.connectiontype": "custom",
+	"ethernet1.vnet":           "vmnet2",
+}
+
+func TestOvfDevicesFromVMX(t *testing.T) {
+	devices := ovfDevicesFromVMX(testOvfVMXData)
+
+	expected := []*ovfDevice{
+		{Bus: "sata", Controller: 0, Unit: 1, CDROM: true, Filename: "/path/to/os.iso"},
+		{Bus: "scsi", Controller: 0, Unit: 0, Filename: "disk.vmdk"},
+		{Bus: "scsi", Controller: 0, Unit: 1, Filename: "disk-1.vmdk"},
+	}
+	assert.Equal(t, expected, devices)
+}
+
+func TestNewOvfEnvelope(t *testing.T) {
+	devices := ovfDevicesFromVMX(testOvfVMXData)
+	devices[1].Disk = &ovfExportedDisk{Href: "vm-disk1.vmdk", Size: 1024, Capacity: 4096, PopulatedSize: 2048}
+	devices[2].Disk = &ovfExportedDisk{Href: "vm-disk2.vmdk", Size: 512, Capacity: 8192, PopulatedSize: 0}
+
+	env := newOvfEnvelope("vm", testOvfVMXData, devices)
+
+	assert.Equal(t, "packer-test", env.VirtualSystem.Name)
+	assert.Equal(t, ovfOsTypeOther64, env.VirtualSystem.OperatingSystemSection.ID)
+	assert.Equal(t, "ubuntu-64", env.VirtualSystem.OperatingSystemSection.OsType)
+	assert.Equal(t, "vmx-21", env.VirtualSystem.VirtualHardwareSection.System.VirtualSystemType)
+
+	assert.Equal(t, []ovfFile{
+		{Href: "vm-disk1.vmdk", ID: "file1", Size: 1024},
+		{Href: "vm-disk2.vmdk", ID: "file2", Size: 512},
+	}, env.References)
+	assert.Len(t, env.DiskSection.Disks, 2)
+	assert.Equal(t, int64(8192), env.DiskSection.Disks[1].Capacity)
+
+	assert.Equal(t, []ovfNetwork{
+		{Name: "nat", Description: "The nat network"},
+		{Name: "vmnet2", Description: "The vmnet2 network"},
+	}, env.NetworkSection.Networks)
+
+	items := make(map[int][]ovfItem)
+	for _, item := range env.VirtualSystem.VirtualHardwareSection.Items {
+		items[item.ResourceType] = append(items[item.ResourceType], item)
+	}
+
+	assert.Equal(t, 4, items[ovfResourceProcessor][0].VirtualQuantity)
+	assert.Equal(t, 2, items[ovfResourceProcessor][0].CoresPerSocket.Value)
+	assert.Equal(t, 2048, items[ovfResourceMemory][0].VirtualQuantity)
+	assert.Equal(t, "VirtualSCSI", items[ovfResourceScsiController][0].ResourceSubType)
+	assert.Equal(t, "vmware.sata.ahci", items[ovfResourceOtherStorage][0].ResourceSubType)
+	assert.Len(t, items[ovfResourceDisk], 2)
+	assert.Equal(t, items[ovfResourceScsiController][0].InstanceID, items[ovfResourceDisk][1].Parent)
+	assert.Equal(t, "1", items[ovfResourceDisk][1].AddressOnParent)
+	assert.Equal(t, items[ovfResourceOtherStorage][0].InstanceID, items[ovfResourceCdrom][0].Parent)
+	assert.Equal(t, "VmxNet3", items[ovfResourceEthernet][0].ResourceSubType)
+	assert.Equal(t, "E1000", items[ovfResourceEthernet][1].ResourceSubType)
+	assert.Equal(t, "vmnet2", items[ovfResourceEthernet][1].Connection)
+
+	assert.Equal(t, []ovfConfig{
+		{Key: "firmware", Value: "efi"},
+		{Key: "uefi.secureBoot.enabled", Value: "true"},
+	}, env.VirtualSystem.VirtualHardwareSection.Configs)
+}
+
+func TestNewOvfEnvelope_networkAdapters(t *testing.T) {
+	vmxData := map[string]string{
+		"ethernet10.present":        "TRUE",
+		"ethernet10.connectiontype": "hostonly",
+		"ethernet2.present":         "TRUE",
+		"ethernet2.connectiontype":  "bridged",
+		"ethernet1.present":         "FALSE",
+		"ethernet1.connectiontype":  "nat",
+	}
+
+	env := newOvfEnvelope("vm", vmxData, nil)
+
+	var adapters []ovfItem
+	for _, item := range env.VirtualSystem.VirtualHardwareSection.Items {
+		if item.ResourceType == ovfResourceEthernet {
+			adapters = append(adapters, item)
+		}
+	}
+
+	// The network adapters are numbered in the order of their numbers, and
+	// the adapters that are not present are skipped.
+	if assert.Len(t, adapters, 2) {
+		assert.Equal(t, "bridged", adapters[0].Connection)
+		assert.Equal(t, "0", adapters[0].AddressOnParent)
+		assert.Equal(t, "Network adapter 1", adapters[0].ElementName)
+		assert.Equal(t, "hostonly", adapters[1].Connection)
+		assert.Equal(t, "1", adapters[1].AddressOnParent)
+		assert.Equal(t, "Network adapter 2", adapters[1].ElementName)
+	}
+}
+
+func TestOvfEnvelopeMarshal(t *testing.T) {
+	env := newOvfEnvelope("vm", map[string]string{}, nil)
+
+	data, err := env.Marshal()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	assert.Contains(t, string(data), `<Envelope xmlns="http://schemas.dmtf.org/ovf/envelope/1"`)
+	assert.Contains(t, string(data), `xmlns:ovf="http://schemas.dmtf.org/ovf/envelope/1"`)
+	assert.Contains(t, string(data), `<VirtualSystem ovf:id="vm">`)
+	assert.Contains(t, string(data), `<rasd:ResourceType>3</rasd:ResourceType>`)
+	assert.NotContains(t, string(data), "DiskSection")
+
+	// The descriptor must be well-formed.
+	var v struct {
+		XMLName xml.Name
+	}
+	if err := xml.Unmarshal(data, &v); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assert.Equal(t, "Envelope", v.XMLName.Local)
+}
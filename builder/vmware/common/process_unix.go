@@ -0,0 +1,18 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build !windows
+
+package common
+
+import (
+	"errors"
+	"syscall"
+)
+
+// processRunning reports whether a process with the given ID is running.
+func processRunning(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
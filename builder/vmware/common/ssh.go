@@ -17,10 +17,11 @@ import (
 )
 
 // CommHost returns a function that determines the IP address of the guest that
-// is ready to accept connections.
+// is ready to accept connections. If a host port is forwarded to the guest by
+// the NAT service, the address of the host is returned instead. The IP address
+// of the guest is stored in the state as "guest_ip".
 func CommHost(config *SSHConfig) func(multistep.StateBag) (string, error) {
 	return func(state multistep.StateBag) (string, error) {
-		driver := state.Get("driver").(Driver)
 		comm := config.Comm
 
 		host := comm.Host()
@@ -28,44 +29,15 @@ func CommHost(config *SSHConfig) func(multistep.StateBag) (string, error) {
 			return host, nil
 		}
 
-		port := comm.Port()
-
-		// Check if this is a bridged network (case-insensitive).
-		network := state.Get("vmnetwork").(string)
-		isBridged := strings.EqualFold(network, "bridged")
-
-		var hosts []string
-		var err error
-
-		if isBridged {
-			// For bridged networks, wait for VMware Tools to provide the IP address.
-			if state.Get("vmtools_ip_attempt") == nil {
-				log.Printf("[INFO] Waiting for guest IP address from VMware Tools...")
-				state.Put("vmtools_ip_attempt", true)
-			}
-
-			vmxPath := state.Get("vmx_path").(string)
-			if addr, vmrunErr := driver.GetGuestIPAddress(vmxPath); vmrunErr == nil && addr != "" {
-				hosts = []string{addr}
-			} else {
-				return "", fmt.Errorf("waiting for VMware Tools to start: %s", vmrunErr)
-			}
-		} else {
-			// For NAT/host-only networks, use DHCP leases as the primary method.
-			hosts, err = driver.PotentialGuestIP(state)
-			if err != nil {
-				// Fallback: Check to see if VMware Tools can provide the IP address.
-				vmxPath := state.Get("vmx_path").(string)
-				if addr, vmrunErr := driver.GetGuestIPAddress(vmxPath); vmrunErr == nil && addr != "" {
-					hosts = []string{addr}
-				} else {
-					return "", fmt.Errorf("failed to lookup guest IP address: %s", err)
-				}
-			}
+		if _, ok := state.GetOk("nat_port_forward_host_port"); ok {
+			return natPortForwardHost, nil
 		}
 
-		if len(hosts) == 0 {
-			return "", errors.New("connection not ready, no IP yet")
+		port := comm.Port()
+
+		hosts, err := guestAddresses(state)
+		if err != nil {
+			return "", err
 		}
 
 		var pAddr string
@@ -100,6 +72,7 @@ func CommHost(config *SSHConfig) func(multistep.StateBag) (string, error) {
 				}
 
 				log.Printf("[INFO] Guest Operating System IP address: %s", host)
+				state.Put("guest_ip", host)
 				return host, nil
 			}
 		}
@@ -107,3 +80,66 @@ func CommHost(config *SSHConfig) func(multistep.StateBag) (string, error) {
 		return "", errors.New("connection not ready")
 	}
 }
+
+// CommPort returns a function that determines the port of the guest to connect
+// to. If a host port is forwarded to the guest by the NAT service, the host
+// port is returned instead.
+func CommPort(config *SSHConfig) func(multistep.StateBag) (int, error) {
+	return func(state multistep.StateBag) (int, error) {
+		if port, ok := state.GetOk("nat_port_forward_host_port"); ok {
+			return port.(int), nil
+		}
+		return config.Comm.Port(), nil
+	}
+}
+
+// guestAddresses returns the potential IP addresses of the guest, starting with
+// the IP address reserved for the guest, if any.
+func guestAddresses(state multistep.StateBag) ([]string, error) {
+	driver := state.Get("driver").(Driver)
+
+	// Check if this is a bridged network (case-insensitive).
+	network := state.Get("vmnetwork").(string)
+	isBridged := strings.EqualFold(network, "bridged")
+
+	var hosts []string
+
+	// Try the IP address reserved for the guest first, if any.
+	if addr, ok := state.GetOk("vmnetwork_reserved_address"); ok {
+		hosts = append(hosts, addr.(string))
+	}
+
+	if isBridged {
+		// For bridged networks, wait for VMware Tools to provide the IP address.
+		if state.Get("vmtools_ip_attempt") == nil {
+			log.Printf("[INFO] Waiting for guest IP address from VMware Tools...")
+			state.Put("vmtools_ip_attempt", true)
+		}
+
+		vmxPath := state.Get("vmx_path").(string)
+		if addr, vmrunErr := driver.GetGuestIPAddress(vmxPath); vmrunErr == nil && addr != "" {
+			hosts = append(hosts, addr)
+		} else {
+			return nil, fmt.Errorf("waiting for VMware Tools to start: %s", vmrunErr)
+		}
+	} else {
+		// For NAT/host-only networks, use DHCP leases as the primary method.
+		addrs, err := driver.PotentialGuestIP(state)
+		if err != nil {
+			// Fallback: Check to see if VMware Tools can provide the IP address.
+			vmxPath := state.Get("vmx_path").(string)
+			if addr, vmrunErr := driver.GetGuestIPAddress(vmxPath); vmrunErr == nil && addr != "" {
+				addrs = []string{addr}
+			} else if len(hosts) == 0 {
+				return nil, fmt.Errorf("failed to lookup guest IP address: %s", err)
+			}
+		}
+		hosts = append(hosts, addrs...)
+	}
+
+	if len(hosts) == 0 {
+		return nil, errors.New("connection not ready, no IP yet")
+	}
+
+	return hosts, nil
+}
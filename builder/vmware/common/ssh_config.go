@@ -7,15 +7,100 @@
 package common
 
 import (
+	"errors"
+	"fmt"
+	"time"
+
 	"github.com/hashicorp/packer-plugin-sdk/communicator"
 	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
 )
 
 type SSHConfig struct {
 	Comm communicator.Config `mapstructure:",squash"`
+	// The amount of time to wait for VMware Tools guest operations to become
+	// available in the guest when using the `vmtools` communicator. The
+	// `vmtools` communicator uses VMware Tools guest operations and does not
+	// require network connectivity to the guest. The guest operating system
+	// account is set with `ssh_username` and `ssh_password`, or with
+	// `winrm_username` and `winrm_password`. Defaults to `5m`.
+	VMToolsTimeout time.Duration `mapstructure:"vmtools_timeout" required:"false"`
+	// Forward a port on the host to the communicator port of the guest using
+	// the NAT service of the virtual network, and connect to the guest at
+	// `127.0.0.1`. Use when the host cannot route to the subnet of the virtual
+	// network; for example, in containers or on restricted build runners. The
+	// port forward is added to the NAT configuration file of the virtual
+	// network, or to the networking configuration file if not found, and is
+	// removed at the end of the build. Defaults to `false`.
+	//
+	// ~> **Note:** The NAT service is restarted after the NAT configuration is
+	// updated, which requires administrative privileges and briefly
+	// interrupts the virtual networks of the desktop hypervisor. Requires the
+	// communicator network adapter to be connected to a NAT network.
+	NATPortForward bool `mapstructure:"nat_port_forward" required:"false"`
+	// The port on the host forwarded to the communicator port of the guest
+	// when `nat_port_forward` is enabled. Defaults to a random free port.
+	NATPortForwardHostPort int `mapstructure:"nat_port_forward_host_port" required:"false"`
 }
 
 // Prepare validates and prepares the SSH configuration for use.
 func (c *SSHConfig) Prepare(ctx *interpolate.Context) []error {
-	return c.Comm.Prepare(ctx)
+	var errs []error
+
+	if c.Comm.Type != CommunicatorVMTools {
+		errs = c.Comm.Prepare(ctx)
+	} else {
+		// The credentials of the vmtools communicator are set in the
+		// configuration of the SSH or WinRM communicator, which is prepared
+		// as the communicator that they are set for.
+		if c.VMToolsCredentials().Username == "" {
+			errs = append(errs, errors.New("'ssh_username' or 'winrm_username' is required when using the vmtools communicator"))
+		} else {
+			c.Comm.Type = c.vmToolsCredentialsType()
+			errs = c.Comm.Prepare(ctx)
+			c.Comm.Type = CommunicatorVMTools
+		}
+		if c.VMToolsTimeout == 0 {
+			c.VMToolsTimeout = guestOpsDefaultTimeout
+		}
+	}
+
+	if c.NATPortForward {
+		switch {
+		case c.Comm.Type != "ssh" && c.Comm.Type != "winrm":
+			errs = append(errs, fmt.Errorf("'nat_port_forward' is not supported for the %s communicator", c.Comm.Type))
+		case c.Comm.Host() != "":
+			errs = append(errs, errors.New("'nat_port_forward' cannot be used with 'ssh_host' or 'winrm_host'"))
+		}
+	}
+
+	if c.NATPortForwardHostPort < 0 || c.NATPortForwardHostPort > 65535 {
+		errs = append(errs, fmt.Errorf("invalid 'nat_port_forward_host_port' specified: %d; must be from 1 to 65535", c.NATPortForwardHostPort))
+	}
+
+	return errs
+}
+
+// VMToolsCredentials returns the guest credentials for the vmtools
+// communicator, which are the credentials of the SSH communicator or, if not
+// set, the WinRM communicator.
+func (c *SSHConfig) VMToolsCredentials() GuestCredentials {
+	if c.vmToolsCredentialsType() == "winrm" {
+		return GuestCredentials{
+			Username: c.Comm.WinRMUser,
+			Password: c.Comm.WinRMPassword,
+		}
+	}
+	return GuestCredentials{
+		Username: c.Comm.SSHUsername,
+		Password: c.Comm.SSHPassword,
+	}
+}
+
+// vmToolsCredentialsType returns the type of the communicator whose
+// credentials are used by the vmtools communicator.
+func (c *SSHConfig) vmToolsCredentialsType() string {
+	if c.Comm.SSHUsername == "" && c.Comm.WinRMUser != "" {
+		return "winrm"
+	}
+	return "ssh"
 }
@@ -10,6 +10,7 @@ import (
 
 	"github.com/hashicorp/packer-plugin-sdk/communicator"
 	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+	"github.com/stretchr/testify/assert"
 )
 
 func testSSHConfig() *SSHConfig {
@@ -118,3 +119,97 @@ eHLc1nkCgYEApm/motCTPN32nINZ+Vvywbv64ZD+gtpeMNP3CLrbe1X9O+H52AXa
 `
 
 //nolint:gosec
+
+func TestSSHConfigPrepare_vmtools(t *testing.T) {
+	c := &SSHConfig{
+		Comm: communicator.Config{
+			Type: CommunicatorVMTools,
+			SSH: communicator.SSH{
+				SSHUsername: "packer",
+				SSHPassword: "secret",
+			},
+		},
+	}
+	if errs := c.Prepare(interpolate.NewContext()); len(errs) > 0 {
+		t.Fatalf("err: %#v", errs)
+	}
+	if c.Comm.Type != CommunicatorVMTools {
+		t.Errorf("bad communicator: %s", c.Comm.Type)
+	}
+	if c.VMToolsTimeout != guestOpsDefaultTimeout {
+		t.Errorf("bad vmtools timeout: %s", c.VMToolsTimeout)
+	}
+	assert.Equal(t, GuestCredentials{Username: "packer", Password: "secret"}, c.VMToolsCredentials())
+
+	c = &SSHConfig{
+		Comm: communicator.Config{
+			Type: CommunicatorVMTools,
+			WinRM: communicator.WinRM{
+				WinRMUser:     "Administrator",
+				WinRMPassword: "secret",
+			},
+		},
+	}
+	if errs := c.Prepare(interpolate.NewContext()); len(errs) > 0 {
+		t.Fatalf("err: %#v", errs)
+	}
+	assert.Equal(t, GuestCredentials{Username: "Administrator", Password: "secret"}, c.VMToolsCredentials())
+
+	c = &SSHConfig{
+		Comm: communicator.Config{Type: CommunicatorVMTools},
+	}
+	if errs := c.Prepare(interpolate.NewContext()); len(errs) == 0 {
+		t.Fatal("should have error")
+	}
+
+	// The configuration of the communicator that the credentials are set
+	// for is validated.
+	c = &SSHConfig{
+		Comm: communicator.Config{
+			Type: CommunicatorVMTools,
+			SSH: communicator.SSH{
+				SSHUsername:           "packer",
+				SSHFileTransferMethod: "bad",
+			},
+		},
+	}
+	if errs := c.Prepare(interpolate.NewContext()); len(errs) == 0 {
+		t.Fatal("should have error")
+	}
+}
+
+func TestSSHConfigPrepare_natPortForward(t *testing.T) {
+	c := testSSHConfig()
+	c.NATPortForward = true
+	c.NATPortForwardHostPort = 2222
+	if errs := c.Prepare(interpolate.NewContext()); len(errs) > 0 {
+		t.Fatalf("err: %#v", errs)
+	}
+
+	c = testSSHConfig()
+	c.NATPortForward = true
+	c.Comm.SSHHost = "127.0.0.1"
+	if errs := c.Prepare(interpolate.NewContext()); len(errs) == 0 {
+		t.Fatal("should have error with ssh_host")
+	}
+
+	c = &SSHConfig{
+		Comm: communicator.Config{
+			Type: CommunicatorVMTools,
+			SSH: communicator.SSH{
+				SSHUsername: "packer",
+			},
+		},
+		NATPortForward: true,
+	}
+	if errs := c.Prepare(interpolate.NewContext()); len(errs) == 0 {
+		t.Fatal("should have error with the vmtools communicator")
+	}
+
+	c = testSSHConfig()
+	c.NATPortForward = true
+	c.NATPortForwardHostPort = 65536
+	if errs := c.Prepare(interpolate.NewContext()); len(errs) == 0 {
+		t.Fatal("should have error with an invalid host port")
+	}
+}
@@ -31,3 +31,41 @@ func TestCommHost(t *testing.T) {
 		t.Fatalf("Should have respected ssh override.")
 	}
 }
+
+func TestCommHost_natPortForward(t *testing.T) {
+	state := testState(t)
+	state.Put("nat_port_forward_host_port", 2222)
+	config := SSHConfig{
+		Comm: communicator.Config{
+			Type: "ssh",
+			SSH: communicator.SSH{
+				SSHPort: 22,
+			},
+		},
+		NATPortForward: true,
+	}
+
+	out, err := CommHost(&config)(state)
+	if err != nil {
+		t.Fatalf("Should not have had an error: %s", err)
+	}
+	if out != "127.0.0.1" {
+		t.Fatalf("Should have connected to the forwarded host port, got: %s", out)
+	}
+
+	port, err := CommPort(&config)(state)
+	if err != nil {
+		t.Fatalf("Should not have had an error: %s", err)
+	}
+	if port != 2222 {
+		t.Fatalf("Should have returned the forwarded host port, got: %d", port)
+	}
+
+	port, err = CommPort(&config)(testState(t))
+	if err != nil {
+		t.Fatalf("Should not have had an error: %s", err)
+	}
+	if port != 22 {
+		t.Fatalf("Should have returned the communicator port, got: %d", port)
+	}
+}
@@ -67,7 +67,7 @@ func (s *StepAttachToolsCDROM) Run(ctx context.Context, state multistep.StateBag
 	vmxPath := state.Get("vmx_path").(string)
 	log.Printf("[INFO] Attaching VMware Tools ISO as CD-ROM: %s", toolsPath)
 
-	vmxData, err := ReadVMX(vmxPath)
+	vmx, err := ReadVMXDocument(vmxPath)
 	if err != nil {
 		err = fmt.Errorf("error reading VMX file: %s", err)
 		state.Put("error", err)
@@ -82,7 +82,7 @@ func (s *StepAttachToolsCDROM) Run(ctx context.Context, state multistep.StateBag
 	}
 
 	// Find the next available slot.
-	devicePath, err := FindNextAvailableCDROMSlot(vmxData, adapterType)
+	devicePath, err := FindNextAvailableCDROMSlot(vmx, adapterType)
 	if err != nil {
 		err = fmt.Errorf("error finding available CD-ROM slot: %s", err)
 		state.Put("error", err)
@@ -93,7 +93,7 @@ func (s *StepAttachToolsCDROM) Run(ctx context.Context, state multistep.StateBag
 	log.Printf("[INFO] Using CD-ROM device slot: %s", devicePath)
 
 	// Attach the CD-ROM device.
-	err = AttachCDROMDevice(vmxData, devicePath, toolsPath, adapterType)
+	err = AttachCDROMDevice(vmx, devicePath, toolsPath, adapterType)
 	if err != nil {
 		err = fmt.Errorf("error attaching tools CD-ROM device: %s", err)
 		state.Put("error", err)
@@ -102,7 +102,7 @@ func (s *StepAttachToolsCDROM) Run(ctx context.Context, state multistep.StateBag
 	}
 
 	// Write the updated .vmx configuration file.
-	err = WriteVMX(vmxPath, vmxData)
+	err = WriteVMXDocument(vmxPath, vmx)
 	if err != nil {
 		err = fmt.Errorf("error writing VMX file: %s", err)
 		state.Put("error", err)
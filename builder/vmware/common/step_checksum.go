@@ -0,0 +1,196 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"crypto/md5"  //nolint:gosec
+	"crypto/sha1" //nolint:gosec
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// checksumHashes maps the checksum types to their hash functions.
+var checksumHashes = map[string]func() hash.Hash{
+	checksumTypeMd5:    md5.New,
+	checksumTypeSha1:   sha1.New,
+	checksumTypeSha256: sha256.New,
+	checksumTypeSha512: sha512.New,
+}
+
+// ChecksumFileName returns the name of the checksum file for the checksum
+// type; for example, `SHA256SUMS`.
+func ChecksumFileName(checksumType string) string {
+	return strings.ToUpper(checksumType) + "SUMS"
+}
+
+// StepChecksum writes a checksum file for each checksum type to the output
+// directory, which lists the checksums of the files in the output directory.
+//
+// Uses:
+// dir OutputDir
+// ui  packersdk.Ui
+//
+// Produces:
+// checksums map[string]map[string]string - The checksums of each file, keyed
+// by the path of the file and the checksum type.
+type StepChecksum struct {
+	ChecksumTypes []string
+	SkipChecksum  bool
+}
+
+// Run computes the checksums of the files in the output directory
+// concurrently and writes the checksum files.
+func (s *StepChecksum) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	if s.SkipChecksum || len(s.ChecksumTypes) == 0 {
+		return multistep.ActionContinue
+	}
+
+	dir := state.Get("dir").(OutputDir)
+	ui := state.Get("ui").(packersdk.Ui)
+
+	halt := func(err error) multistep.StepAction {
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	var checksumFiles []string
+	for _, checksumType := range s.ChecksumTypes {
+		checksumFiles = append(checksumFiles, ChecksumFileName(checksumType))
+	}
+
+	files, err := dir.ListFiles()
+	if err != nil {
+		return halt(fmt.Errorf("error listing output directory: %s", err))
+	}
+
+	// The checksum files of a previous run are not included.
+	files = slices.DeleteFunc(files, func(file string) bool {
+		rel, err := filepath.Rel(dir.String(), file)
+		return err == nil && slices.Contains(checksumFiles, rel)
+	})
+	slices.Sort(files)
+
+	ui.Say("Computing checksums of artifact files...")
+	checksums, err := computeChecksums(ctx, files, s.ChecksumTypes)
+	if err != nil {
+		return halt(fmt.Errorf("error computing checksums: %s", err))
+	}
+
+	for _, checksumType := range s.ChecksumTypes {
+		var b strings.Builder
+		for _, file := range files {
+			rel, err := filepath.Rel(dir.String(), file)
+			if err != nil {
+				return halt(fmt.Errorf("error computing checksums: %s", err))
+			}
+			fmt.Fprintf(&b, "%s  %s\n", checksums[file][checksumType], filepath.ToSlash(rel))
+		}
+
+		path := filepath.Join(dir.String(), ChecksumFileName(checksumType))
+		log.Printf("[INFO] Writing checksum file: %s", path)
+		if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil { //nolint:gosec
+			return halt(fmt.Errorf("error writing checksum file: %s", err))
+		}
+	}
+
+	state.Put("checksums", checksums)
+
+	return multistep.ActionContinue
+}
+
+// computeChecksums computes the checksums of the files concurrently. Each file
+// is read once for all the checksum types.
+func computeChecksums(ctx context.Context, files []string, checksumTypes []string) (map[string]map[string]string, error) {
+	checksums := make(map[string]map[string]string, len(files))
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+	)
+	sem := make(chan struct{}, runtime.NumCPU())
+
+	for _, file := range files {
+		wg.Go(func() {
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			if ctx.Err() != nil {
+				return
+			}
+
+			sums, err := checksumFile(file, checksumTypes)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = fmt.Errorf("%s: %s", file, err)
+				}
+				return
+			}
+			checksums[file] = sums
+		})
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return checksums, nil
+}
+
+// checksumFile returns the checksums of the file, keyed by checksum type.
+func checksumFile(path string, checksumTypes []string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	hashes := make([]hash.Hash, len(checksumTypes))
+	writers := make([]io.Writer, len(checksumTypes))
+	for i, checksumType := range checksumTypes {
+		newHash, ok := checksumHashes[checksumType]
+		if !ok {
+			return nil, fmt.Errorf("unsupported checksum type: %s", checksumType)
+		}
+		hashes[i] = newHash()
+		writers[i] = hashes[i]
+	}
+
+	if _, err := io.Copy(io.MultiWriter(writers...), f); err != nil {
+		return nil, err
+	}
+
+	sums := make(map[string]string, len(checksumTypes))
+	for i, checksumType := range checksumTypes {
+		sums[checksumType] = hex.EncodeToString(hashes[i].Sum(nil))
+	}
+	return sums, nil
+}
+
+// Cleanup performs any necessary cleanup after the step completes.
+func (s *StepChecksum) Cleanup(multistep.StateBag) {}
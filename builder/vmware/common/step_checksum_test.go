@@ -0,0 +1,104 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStepChecksum_impl(t *testing.T) {
+	var _ multistep.Step = new(StepChecksum)
+}
+
+func testStepChecksumState(t *testing.T) (multistep.StateBag, string) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"vm.vmx":            "config",
+		"vm.vmdk":           "disk",
+		"ova/vm.ova":        "archive",
+		"SHA256SUMS":        "stale",
+		"vm.nvram":          "",
+		"ovf/vm-disk1.vmdk": "exported",
+	}
+	for name, contents := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	state := testState(t)
+	state.Put("dir", &LocalOutputDir{dir: dir})
+	return state, dir
+}
+
+func TestStepChecksum(t *testing.T) {
+	state, dir := testStepChecksumState(t)
+	step := &StepChecksum{ChecksumTypes: []string{"sha256", "md5"}}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); ok {
+		t.Fatal("should NOT have error")
+	}
+
+	sha256sums, err := os.ReadFile(filepath.Join(dir, "SHA256SUMS"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assert.Equal(t, ""+
+		"0eb3e36bfb24dcd9bb1d1bece1531216b59539a8fde17ee80224af0653c92aa3  ova/vm.ova\n"+
+		"427da8ba22c9532ad8030312c9c265f2e239456d024bc2c38b99cd9d5fabadab  ovf/vm-disk1.vmdk\n"+
+		"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855  vm.nvram\n"+
+		"1044dec7206e8d7c9fbb4ae8f766668406d2567fc7fc1a160a9d4700fcf8f8e9  vm.vmdk\n"+
+		"b79606fb3afea5bd1609ed40b622142f1c98125abcfe89a76a661b0e8e343910  vm.vmx\n",
+		string(sha256sums))
+
+	md5sums, err := os.ReadFile(filepath.Join(dir, "MD5SUMS"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assert.Contains(t, string(md5sums), "d41d8cd98f00b204e9800998ecf8427e  vm.nvram\n")
+
+	checksums := state.Get("checksums").(map[string]map[string]string)
+	assert.Len(t, checksums, 5)
+	assert.Equal(t, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+		checksums[filepath.Join(dir, "vm.nvram")]["sha256"])
+	assert.Equal(t, "d41d8cd98f00b204e9800998ecf8427e",
+		checksums[filepath.Join(dir, "vm.nvram")]["md5"])
+}
+
+func TestStepChecksum_skip(t *testing.T) {
+	state, dir := testStepChecksumState(t)
+	step := &StepChecksum{ChecksumTypes: []string{"sha256"}, SkipChecksum: true}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	sha256sums, err := os.ReadFile(filepath.Join(dir, "SHA256SUMS"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assert.Equal(t, "stale", string(sha256sums))
+	if _, ok := state.GetOk("checksums"); ok {
+		t.Fatal("should NOT have checksums")
+	}
+}
+
+func TestChecksumFileName(t *testing.T) {
+	assert.Equal(t, "SHA256SUMS", ChecksumFileName("sha256"))
+	assert.Equal(t, "MD5SUMS", ChecksumFileName("md5"))
+}
@@ -28,7 +28,18 @@ func (StepCleanFiles) Run(ctx context.Context, state multistep.StateBag) multist
 		return multistep.ActionHalt
 	}
 
+	// The backup of the .vmx file is kept until the end of the build and is
+	// removed when the output directory step is cleaned up.
+	var backupPath string
+	if vmxPath, ok := state.GetOk("vmx_path"); ok {
+		backupPath = VMXBackupPath(vmxPath.(string))
+	}
+
 	for _, path := range files {
+		if backupPath != "" && filepath.Clean(path) == filepath.Clean(backupPath) {
+			continue
+		}
+
 		// If the file isn't critical to the function of the
 		// virtual machine, we get rid of it.
 		keep := false
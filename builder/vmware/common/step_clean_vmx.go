@@ -8,13 +8,26 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/vmware/packer-plugin-vmware/builder/vmware/common/devices"
+	"github.com/vmware/packer-plugin-vmware/builder/vmware/common/vmdk"
 )
 
 // StepCleanVMX cleans up the VMX configuration by removing temporary build devices.
+//
+// Uses:
+// disk_full_paths []string
+// temporaryDevices []string
+//
+// Produces:
+// disk_full_paths []string - The paths to the disks, excluding the detached
+// disks.
 type StepCleanVMX struct {
 	RemoveEthernetInterfaces bool
 	VNCEnabled               bool
@@ -23,7 +36,7 @@ type StepCleanVMX struct {
 // cleanupToolsCDROM removes the VMware Tools CD-ROM devices from the .vmx
 // configuration file. It identifies CD-ROM devices using state bag information and
 // removes only those devices other CD-ROM devices.
-func (s StepCleanVMX) cleanupToolsCDROM(ui packersdk.Ui, vmxData map[string]string, state multistep.StateBag) {
+func (s StepCleanVMX) cleanupToolsCDROM(ui packersdk.Ui, vmx *VMXDocument, state multistep.StateBag) {
 	toolsCDROMDevice, ok := state.GetOk("tools_cdrom_device")
 	if !ok {
 		log.Printf("[INFO] No VMware Tools ISO CD-ROM device found in state bag, skipping tools CD-ROM cleanup.")
@@ -35,47 +48,66 @@ func (s StepCleanVMX) cleanupToolsCDROM(ui packersdk.Ui, vmxData map[string]stri
 	ui.Sayf("Removing VMware Tools ISO CD-ROM device %s...", devicePath)
 
 	// Remove all VMX entries for the tools CD-ROM device
-	var keysToDelete []string
-	devicePrefix := fmt.Sprintf("%s.", devicePath)
-
-	for key := range vmxData {
-		if strings.HasPrefix(key, devicePrefix) {
-			keysToDelete = append(keysToDelete, key)
-		}
-	}
-
-	for _, key := range keysToDelete {
-		log.Printf("[INFO] Deleting tools CD-ROM key: %s", key)
-		delete(vmxData, key)
-	}
+	vmx.DeleteDevice(devicePath)
 
 	presentKey := fmt.Sprintf("%s.present", devicePath)
-	vmxData[presentKey] = "FALSE"
+	vmx.Set(presentKey, "FALSE")
 
 	log.Printf("[INFO] Successfully cleaned up VMware Tools ISO CD-ROM device: %s", devicePath)
 }
 
+// detachDisk removes the disk device from the .vmx configuration file and
+// removes the disk from the disks of the build. It returns the path to the
+// disk if the disk is in the directory of the virtual machine, or an empty
+// string if the disk is referenced in place.
+func (s StepCleanVMX) detachDisk(ui packersdk.Ui, vmx *VMXDocument, vmxPath string, device string, state multistep.StateBag) string {
+	fileName, _ := vmx.Get(fmt.Sprintf("%s.fileName", device))
+	ui.Sayf("Detaching disk %s from %s...", fileName, device)
+
+	log.Printf("[INFO] Deleting keys for disk device: %s", device)
+	vmx.DeleteDevice(device)
+
+	if filepath.IsAbs(fileName) {
+		return ""
+	}
+
+	diskPath := filepath.Join(filepath.Dir(vmxPath), fileName)
+	if diskFullPaths, ok := state.Get("disk_full_paths").([]string); ok {
+		state.Put("disk_full_paths", slices.DeleteFunc(slices.Clone(diskFullPaths), func(p string) bool {
+			return filepath.Clean(p) == diskPath
+		}))
+	}
+	return diskPath
+}
+
 // Run executes the VMX cleanup step, removing temporary devices and configurations.
 func (s StepCleanVMX) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	driver := state.Get("driver").(Driver)
 	ui := state.Get("ui").(packersdk.Ui)
 	vmxPath := state.Get("vmx_path").(string)
 
 	ui.Say("Cleaning .vmx configuration file prior to finishing up...")
 
-	vmxData, err := ReadVMX(vmxPath)
+	if err := WaitForVMXUnlocked(driver, vmxPath, vmxUnlockTimeout); err != nil {
+		state.Put("error", fmt.Errorf("error cleaning VMX: %s", err))
+		return multistep.ActionHalt
+	}
+
+	vmx, err := ReadVMXDocument(vmxPath)
 	if err != nil {
 		state.Put("error", fmt.Errorf("error reading VMX: %s", err))
 		return multistep.ActionHalt
 	}
 
 	// Handle the VMware Tools ISO CD-ROM cleanup first if present.
-	s.cleanupToolsCDROM(ui, vmxData, state)
+	s.cleanupToolsCDROM(ui, vmx, state)
 
 	// Grab our list of devices added during the build out of the state bag.
 	temporaryDevices, ok := state.GetOk("temporaryDevices")
 	if !ok {
 		temporaryDevices = []string{}
 	}
+	var detachedDisks []string
 	for _, device := range temporaryDevices.([]string) {
 		if toolsCDROMDevice, ok := state.GetOk("tools_cdrom_device"); ok && device == toolsCDROMDevice.(string) {
 			log.Printf("[INFO] Skipping tools CD-ROM device %s in general cleanup (handled separately)", device)
@@ -84,30 +116,34 @@ func (s StepCleanVMX) Run(ctx context.Context, state multistep.StateBag) multist
 
 		// Walk through all the devices that were temporarily added and figure
 		// out which type it is in order to figure out how to disable it.
-		// Right now only floppy, cdrom devices, ethernet, and devices that use
-		// ".present" are supported.
-		if strings.HasPrefix(device, "floppy") {
+		// Right now only disks, floppy, cdrom devices, ethernet, and devices
+		// that use ".present" are supported.
+		fileName, _ := vmx.Get(fmt.Sprintf("%s.fileName", device))
+		deviceType, _ := vmx.Get(fmt.Sprintf("%s.deviceType", device))
+		if strings.HasSuffix(strings.ToLower(fileName), ".vmdk") {
+			// We can identify a disk because its filename is a virtual disk.
+			if diskPath := s.detachDisk(ui, vmx, vmxPath, device, state); diskPath != "" {
+				detachedDisks = append(detachedDisks, diskPath)
+			}
+
+		} else if strings.HasPrefix(device, "floppy") {
 			// We can identify a floppy device because it begins with "floppy"
 			ui.Sayf("Unmounting %s from VMX...", device)
 
 			// Delete the floppy%d entries so the floppy is no longer mounted
-			for k := range vmxData {
-				if strings.HasPrefix(k, fmt.Sprintf("%s.", device)) {
-					log.Printf("[INFO] Deleting key for floppy device: %s", k)
-					delete(vmxData, k)
-				}
-			}
-			vmxData[fmt.Sprintf("%s.present", device)] = "FALSE"
+			log.Printf("[INFO] Deleting keys for floppy device: %s", device)
+			vmx.DeleteDevice(device)
+			vmx.Set(fmt.Sprintf("%s.present", device), "FALSE")
 
-		} else if strings.HasPrefix(vmxData[fmt.Sprintf("%s.devicetype", device)], "cdrom-") {
+		} else if strings.HasPrefix(deviceType, "cdrom-") {
 			// We can identify something is a cdrom if it has a ".devicetype"
 			// attribute that begins with "cdrom-"
 			ui.Sayf("Detaching ISO from CD-ROM device %s...", device)
 
 			// Simply turn the CD-ROM device into a native cdrom instead of an iso
-			vmxData[fmt.Sprintf("%s.devicetype", device)] = "cdrom-raw"
-			vmxData[fmt.Sprintf("%s.filename", device)] = "auto detect"
-			vmxData[fmt.Sprintf("%s.clientdevice", device)] = "TRUE"
+			vmx.Set(fmt.Sprintf("%s.deviceType", device), "cdrom-raw")
+			vmx.Set(fmt.Sprintf("%s.fileName", device), "auto detect")
+			vmx.Set(fmt.Sprintf("%s.clientDevice", device), "TRUE")
 
 		} else if strings.HasPrefix(device, "ethernet") && s.RemoveEthernetInterfaces {
 			// We can identify an ethernet device because it begins with "ethernet"
@@ -117,21 +153,17 @@ func (s StepCleanVMX) Run(ctx context.Context, state multistep.StateBag) multist
 
 			// Delete the ethernet%d entries so the ethernet interface is removed.
 			// This corresponds to the same logic defined below.
-			for k := range vmxData {
-				if strings.HasPrefix(k, fmt.Sprintf("%s.", device)) {
-					log.Printf("[INFO] Deleting key for ethernet device: %s", k)
-					delete(vmxData, k)
-				}
-			}
+			log.Printf("[INFO] Deleting keys for ethernet device: %s", device)
+			vmx.DeleteDevice(device)
 		} else {
 			// Check to see if the device can be disabled.
-			if _, ok := vmxData[fmt.Sprintf("%s.present", device)]; ok {
+			if _, ok := vmx.Get(fmt.Sprintf("%s.present", device)); ok {
 				ui.Sayf("Disabling device %s of an unknown device type...", device)
-				vmxData[fmt.Sprintf("%s.present", device)] = "FALSE"
+				vmx.Set(fmt.Sprintf("%s.present", device), "FALSE")
 			} else {
 				log.Printf("[INFO] Refusing to remove device due to being of an unsupported type: %s\n", device)
-				for k := range vmxData {
-					if strings.HasPrefix(k, fmt.Sprintf("%s.", device)) {
+				for _, k := range vmx.Keys() {
+					if strings.HasPrefix(strings.ToLower(k), strings.ToLower(device)+".") {
 						log.Printf("[INFO] Leaving unsupported device key: %s\n", k)
 					}
 				}
@@ -142,26 +174,41 @@ func (s StepCleanVMX) Run(ctx context.Context, state multistep.StateBag) multist
 	// Disable the VNC server, if necessary.
 	if s.VNCEnabled {
 		ui.Say("Disabling VNC server...")
-		vmxData["remotedisplay.vnc.enabled"] = "FALSE"
+		vmx.Set("RemoteDisplay.vnc.enabled", "FALSE")
 	}
 
 	// Remove any ethernet devices, if necessary.
 	if s.RemoveEthernetInterfaces {
 		ui.Say("Removing Ethernet devices...")
-		for k := range vmxData {
-			if strings.HasPrefix(k, "ethernet") {
-				log.Printf("[INFO] Deleting key for ethernet device: %s", k)
-				delete(vmxData, k)
-			}
+		for _, nic := range devices.Decode(vmx.Map()).NICs {
+			log.Printf("[INFO] Deleting keys for ethernet device: %s", nic.Name())
+			vmx.DeleteDevice(nic.Name())
 		}
 	}
 
 	// Write to the VMX.
-	if err := WriteVMX(vmxPath, vmxData); err != nil {
+	if err := WriteVMXDocument(vmxPath, vmx); err != nil {
 		state.Put("error", fmt.Errorf("error writing VMX: %s", err))
 		return multistep.ActionHalt
 	}
 
+	// The detached disks are no longer used by the virtual machine, so the
+	// disks in the output directory are removed.
+	for _, diskPath := range detachedDisks {
+		log.Printf("[INFO] Removing detached disk: %s", diskPath)
+		if err := vmdk.Remove(diskPath); err != nil && !os.IsNotExist(err) {
+			state.Put("error", fmt.Errorf("error removing detached disk: %s", err))
+			return multistep.ActionHalt
+		}
+	}
+
+	// This is the last change to the VMX, so the backup of the original
+	// version is no longer needed before the export and checksum steps. The
+	// output directory step also removes it when the build ends early.
+	if err := RemoveVMXBackup(vmxPath); err != nil {
+		log.Printf("[WARN] Failed to remove VMX backup: %s", err)
+	}
+
 	return multistep.ActionContinue
 }
 
@@ -7,9 +7,13 @@ package common
 import (
 	"context"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/stretchr/testify/assert"
+	"github.com/vmware/packer-plugin-vmware/builder/vmware/common/vmdk"
 )
 
 func TestStepCleanVMX_impl(t *testing.T) {
@@ -199,6 +203,59 @@ func TestStepCleanVMX_ethernet(t *testing.T) {
 	}
 }
 
+func TestStepCleanVMX_detachDisks(t *testing.T) {
+	state := testState(t)
+	step := new(StepCleanVMX)
+
+	vmxPath := testVMXFile(t)
+	dir := filepath.Dir(vmxPath)
+	source := filepath.Join(t.TempDir(), "source.vmdk")
+	for _, path := range []string{filepath.Join(dir, "disk.vmdk"), filepath.Join(dir, "data.vmdk"), source} {
+		if err := vmdk.Create(path, 1024*1024, vmdk.DiskTypeMonolithicFlat, "lsilogic"); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+	if err := WriteVMX(vmxPath, map[string]string{
+		"scsi0:0.present":  "TRUE",
+		"scsi0:0.filename": "disk.vmdk",
+		"scsi0:1.present":  "TRUE",
+		"scsi0:1.filename": "data.vmdk",
+		"scsi0:2.present":  "TRUE",
+		"scsi0:2.filename": source,
+		"scsi0:2.mode":     "independent-nonpersistent",
+	}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	state.Put("vmx_path", vmxPath)
+	state.Put("temporaryDevices", []string{"scsi0:1", "scsi0:2"})
+	state.Put("disk_full_paths", []string{filepath.Join(dir, "disk.vmdk"), filepath.Join(dir, "data.vmdk")})
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); ok {
+		t.Fatal("should NOT have error")
+	}
+
+	vmxData, err := ReadVMX(vmxPath)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assert.Equal(t, "disk.vmdk", vmxData["scsi0:0.filename"])
+	for _, key := range []string{"scsi0:1.present", "scsi0:1.filename", "scsi0:2.present", "scsi0:2.filename", "scsi0:2.mode"} {
+		assert.NotContains(t, vmxData, key)
+	}
+
+	assert.Equal(t, []string{filepath.Join(dir, "disk.vmdk")}, state.Get("disk_full_paths"))
+
+	// The copied disk is removed; the referenced disk is not.
+	assert.NoFileExists(t, filepath.Join(dir, "data.vmdk"))
+	assert.NoFileExists(t, filepath.Join(dir, "data-flat.vmdk"))
+	assert.FileExists(t, filepath.Join(dir, "disk-flat.vmdk"))
+	assert.FileExists(t, source)
+}
+
 const testVMXFloppyPath = `
 floppy0.present = "TRUE"
 floppy0.filetype = "file"
@@ -500,3 +557,25 @@ ide1:0.filename = "user-defined.iso"
 ide1:0.present = "TRUE"
 foo = "bar"
 `
+
+func TestStepCleanVMX_running(t *testing.T) {
+	state := testState(t)
+	step := new(StepCleanVMX)
+
+	vmxPath := testVMXFile(t)
+	defer os.Remove(vmxPath)
+	state.Put("vmx_path", vmxPath)
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.IsRunningResult = true
+
+	defer func(timeout time.Duration) { vmxUnlockTimeout = timeout }(vmxUnlockTimeout)
+	vmxUnlockTimeout = 0
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("should have error")
+	}
+}
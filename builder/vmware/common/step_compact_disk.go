@@ -6,13 +6,23 @@ package common
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
+	"slices"
 
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/vmware/packer-plugin-vmware/builder/vmware/common/vmdk"
 )
 
 // StepCompactDisk represents a step for compacting attached virtual disks.
+// Disks in disk_skip_compaction are not compacted, and neither are the disks
+// that compaction would not shrink. Several disks are compacted at a time.
+//
+// Uses:
+// disk_full_paths []string
+// disk_skip_compaction []string
 type StepCompactDisk struct {
 	Skip bool
 }
@@ -29,16 +39,61 @@ func (s StepCompactDisk) Run(ctx context.Context, state multistep.StateBag) mult
 	}
 
 	ui.Say("Compacting all attached virtual disks...")
-	for i, diskFullPath := range diskFullPaths {
+	skipCompaction, _ := state.Get("disk_skip_compaction").([]string)
+	err := forEachDisk(ctx, len(diskFullPaths), func(i int) error {
+		diskFullPath := diskFullPaths[i]
+		if slices.Contains(skipCompaction, diskFullPath) {
+			ui.Sayf("Skipping compaction of virtual disk %d", i+1)
+			return nil
+		}
+
+		// The size of a disk that cannot be read is not reported, and the
+		// driver reports the error, if any.
+		before, err := vmdk.Stat(diskFullPath)
+		if err != nil {
+			log.Printf("[WARN] Failed to read virtual disk %s: %s", diskFullPath, err)
+		} else if reason := compactionSkipReason(before); reason != "" {
+			ui.Sayf("Skipping compaction of virtual disk %d: %s", i+1, reason)
+			return nil
+		}
+
 		ui.Sayf("Compacting virtual disk %d", i+1)
-		if err := driver.CompactDisk(diskFullPath); err != nil {
-			state.Put("error", fmt.Errorf("error compacting disk: %s", err))
-			return multistep.ActionHalt
+		progress := uiDiskProgress(ui, fmt.Sprintf("virtual disk %d", i+1))
+		if err := driver.CompactDisk(diskFullPath, progress); errors.Is(err, ErrCompactDiskSkipped) {
+			ui.Sayf("Skipped compaction of virtual disk %d: %s", i+1, err)
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("error compacting disk: %s", err)
+		}
+
+		if before != nil {
+			if after, err := vmdk.Stat(diskFullPath); err == nil {
+				ui.Sayf("Compacted virtual disk %d from %s to %s", i+1, formatDiskSize(before.Size()), formatDiskSize(after.Size()))
+			}
 		}
+		return nil
+	})
+	if err != nil {
+		state.Put("error", err)
+		return multistep.ActionHalt
 	}
 
 	return multistep.ActionContinue
 }
 
+// compactionSkipReason returns the reason that compaction would not shrink
+// the virtual disk, or an empty string if the disk can be compacted. The space
+// of a preallocated disk is allocated when the disk is created, and a
+// stream-optimized disk is already compacted.
+func compactionSkipReason(info *vmdk.Info) string {
+	if info.CreateType() == vmdk.CreateTypeStreamOptimized {
+		return "stream-optimized disks are already compacted"
+	}
+	if !slices.ContainsFunc(info.Extents, func(e vmdk.ExtentInfo) bool { return e.Type == vmdk.ExtentTypeSparse }) {
+		return "preallocated disks cannot be compacted"
+	}
+	return ""
+}
+
 // Cleanup performs any necessary cleanup after the disk compaction step completes.
 func (StepCompactDisk) Cleanup(multistep.StateBag) {}
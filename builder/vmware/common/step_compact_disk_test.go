@@ -5,10 +5,16 @@
 package common
 
 import (
+	"bytes"
 	"context"
+	"fmt"
+	"path/filepath"
 	"testing"
 
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/stretchr/testify/assert"
+	"github.com/vmware/packer-plugin-vmware/builder/vmware/common/vmdk"
 )
 
 func TestStepCompactDisk_impl(t *testing.T) {
@@ -64,3 +70,89 @@ func TestStepCompactDisk_skip(t *testing.T) {
 		t.Fatal("should not have called")
 	}
 }
+
+func TestStepCompactDisk_skipDisks(t *testing.T) {
+	state := testState(t)
+	step := new(StepCompactDisk)
+
+	state.Put("disk_full_paths", []string{"foo", "bar"})
+	state.Put("disk_skip_compaction", []string{"foo"})
+
+	driver := state.Get("driver").(*DriverMock)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if driver.CompactDiskPath != "bar" {
+		t.Fatalf("should only compact bar, got: %s", driver.CompactDiskPath)
+	}
+}
+
+func TestStepCompactDisk_skipPreallocated(t *testing.T) {
+	state := testState(t)
+	step := new(StepCompactDisk)
+
+	dir := t.TempDir()
+	var diskFullPaths []string
+	for i, diskType := range []int{vmdk.DiskTypeMonolithicFlat, vmdk.DiskTypeStreamOptimized, vmdk.DiskTypeSplitSparse} {
+		path := filepath.Join(dir, fmt.Sprintf("disk-%d.vmdk", i))
+		if err := vmdk.Create(path, 1024*1024, diskType, "lsilogic"); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		diskFullPaths = append(diskFullPaths, path)
+	}
+	state.Put("disk_full_paths", diskFullPaths)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); ok {
+		t.Fatal("should NOT have error")
+	}
+
+	// Only the sparse disk is compacted.
+	driver := state.Get("driver").(*DriverMock)
+	assert.Equal(t, diskFullPaths[2], driver.CompactDiskPath)
+}
+
+func TestStepCompactDisk_error(t *testing.T) {
+	state := testState(t)
+	step := new(StepCompactDisk)
+
+	state.Put("disk_full_paths", []string{"foo", "bar"})
+	driver := state.Get("driver").(*DriverMock)
+	driver.CompactDiskErr = fmt.Errorf("compaction failed")
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("should have error")
+	}
+}
+
+func TestStepCompactDisk_vdiskManagerNotFound(t *testing.T) {
+	state := testState(t)
+	step := new(StepCompactDisk)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "disk.vmdk")
+	if err := vmdk.Create(path, 1024*1024, vmdk.DiskTypeMonolithicSparse, "lsilogic"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	state.Put("disk_full_paths", []string{path})
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.CompactDiskErr = ErrCompactDiskSkipped
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); ok {
+		t.Fatal("should NOT have error")
+	}
+
+	output := state.Get("ui").(*packersdk.BasicUi).Writer.(*bytes.Buffer).String()
+	assert.Contains(t, output, "Skipped compaction of virtual disk 1: vmware-vdiskmanager not found")
+	assert.NotContains(t, output, "Compacted virtual disk 1")
+}
@@ -9,16 +9,21 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"maps"
 	"regexp"
+	"slices"
 	"strings"
 
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/vmware/packer-plugin-vmware/builder/vmware/common/devices"
 )
 
 // StepConfigureVMX represents the configuration settings for a VMX configuration file.
 type StepConfigureVMX struct {
 	CustomData       map[string]string
+	RemoveData       []string
+	Patches          []VMXPatchConfig
 	DisplayName      string
 	SkipDevices      bool
 	VMName           string
@@ -33,8 +38,17 @@ func (s *StepConfigureVMX) Run(ctx context.Context, state multistep.StateBag) mu
 	var err error
 	ui := state.Get("ui").(packersdk.Ui)
 
+	driver := state.Get("driver").(Driver)
+
 	vmxPath := state.Get("vmx_path").(string)
-	vmxData, err := ReadVMX(vmxPath)
+	if err := WaitForVMXUnlocked(driver, vmxPath, vmxUnlockTimeout); err != nil {
+		err = fmt.Errorf("error configuring VMX file: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	vmx, err := ReadVMXDocument(vmxPath)
 	if err != nil {
 		err = fmt.Errorf("error reading VMX file: %s", err)
 		state.Put("error", err)
@@ -42,26 +56,44 @@ func (s *StepConfigureVMX) Run(ctx context.Context, state multistep.StateBag) mu
 		return multistep.ActionHalt
 	}
 
+	original := vmx.Map()
+
 	// Set this so that no dialogs ever appear from Packer.
-	vmxData["msg.autoanswer"] = "true"
+	vmx.Set("msg.autoAnswer", "true")
 
 	// Create a new UUID for this VM, since it is a new VM
-	vmxData["uuid.action"] = "create"
+	vmx.Set("uuid.action", "create")
 
 	// Delete any generated addresses since we want to regenerate
 	// them. Conflicting MAC addresses is a bad time.
 	addrRegex := regexp.MustCompile(`(?i)^ethernet\d+\.generatedAddress`)
-	for k := range vmxData {
+	for _, k := range vmx.Keys() {
 		if addrRegex.MatchString(k) {
-			delete(vmxData, k)
+			vmx.Delete(k)
 		}
 	}
 
-	// Set custom data
-	for k, v := range s.CustomData {
-		log.Printf("[INFO] Setting VMX: '%s' = '%s'", k, v)
-		k = strings.ToLower(k)
-		vmxData[k] = v
+	// Remove data, such as keys inherited from the source virtual machine or
+	// the template, before the custom data is set.
+	for _, k := range s.RemoveData {
+		log.Printf("[INFO] Deleting VMX: '%s'", k)
+		vmx.Delete(k)
+	}
+
+	// Set custom data, in the case of the keys as specified.
+	for _, k := range slices.Sorted(maps.Keys(s.CustomData)) {
+		log.Printf("[INFO] Setting VMX: '%s' = '%s'", k, s.CustomData[k])
+		vmx.Set(k, s.CustomData[k])
+	}
+
+	// Apply the patches, in order, after the custom data.
+	for i := range s.Patches {
+		if err := s.Patches[i].Apply(vmx); err != nil {
+			err = fmt.Errorf("error applying VMX patch: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
 	}
 
 	// StepConfigureVMX runs both before and after provisioning (for VmxDataPost),
@@ -73,9 +105,9 @@ func (s *StepConfigureVMX) Run(ctx context.Context, state multistep.StateBag) mu
 		// Set a floppy disk if we have one
 		if floppyPathRaw, ok := state.GetOk("floppy_path"); ok {
 			log.Println("Floppy path present, setting in VMX")
-			vmxData["floppy0.present"] = "TRUE"
-			vmxData["floppy0.filetype"] = "file"
-			vmxData["floppy0.filename"] = floppyPathRaw.(string)
+			vmx.Set("floppy0.present", "TRUE")
+			vmx.Set("floppy0.fileType", "file")
+			vmx.Set("floppy0.fileName", floppyPathRaw.(string))
 
 			// Add it to our list of build devices to later remove
 			tmpBuildDevices = append(tmpBuildDevices, "floppy0")
@@ -89,12 +121,12 @@ func (s *StepConfigureVMX) Run(ctx context.Context, state multistep.StateBag) mu
 
 				// Ensure the CD-ROM adapter is present.
 				adapterKey := diskAndCDConfigData.CdromType + "1.present"
-				vmxData[adapterKey] = "TRUE"
+				vmx.Set(adapterKey, "TRUE")
 
 				// Configure the CD-ROM device.
-				vmxData[cdromPrefix+".present"] = "TRUE"
-				vmxData[cdromPrefix+".filename"] = cdPath.(string)
-				vmxData[cdromPrefix+".devicetype"] = "cdrom-image"
+				vmx.Set(cdromPrefix+".present", "TRUE")
+				vmx.Set(cdromPrefix+".fileName", cdPath.(string))
+				vmx.Set(cdromPrefix+".deviceType", "cdrom-image")
 
 				// Add both the adapter and device to our list of build devices to later remove.
 				tmpBuildDevices = append(tmpBuildDevices, adapterKey, cdromPrefix)
@@ -106,11 +138,11 @@ func (s *StepConfigureVMX) Run(ctx context.Context, state multistep.StateBag) mu
 	}
 
 	if s.DisplayName != "" {
-		vmxData["displayname"] = s.DisplayName
+		vmx.Set("displayName", s.DisplayName)
 		state.Put("display_name", s.DisplayName)
 	} else {
-		displayName, ok := vmxData["displayname"]
-		if !ok { // Packer converts key names to lowercase!
+		displayName, ok := vmx.Get("displayName")
+		if !ok {
 			err := errors.New("error returning value of displayName from VMX data")
 			state.Put("error", err)
 			ui.Error(err.Error())
@@ -126,12 +158,27 @@ func (s *StepConfigureVMX) Run(ctx context.Context, state multistep.StateBag) mu
 	// the displayName if it was empty VMware would make a file named ".vmxf".
 	// The ".vmxf" file would not get deleted when the VM got deleted.
 	if s.DisplayName != "" {
-		vmxData["extendedconfigfile"] = fmt.Sprintf("%s.vmxf", s.DisplayName)
+		vmx.Set("extendedConfigFile", fmt.Sprintf("%s.vmxf", s.DisplayName))
 	} else {
-		vmxData["extendedconfigfile"] = fmt.Sprintf("%s.vmxf", s.VMName)
+		vmx.Set("extendedConfigFile", fmt.Sprintf("%s.vmxf", s.VMName))
+	}
+
+	// Check the devices for conflicts before the virtual machine is powered
+	// on, rather than failing to power it on.
+	if !s.SkipDevices {
+		if err := devices.Decode(vmx.Map()).Validate(); err != nil {
+			err = fmt.Errorf("error validating VMX devices: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	if diff := VMXDiff(original, vmx.Map()); diff != "" {
+		log.Printf("[INFO] Changes to VMX:\n%s", diff)
 	}
 
-	err = WriteVMX(vmxPath, vmxData)
+	err = WriteVMXDocument(vmxPath, vmx)
 
 	if err != nil {
 		err = fmt.Errorf("error writing VMX file: %s", err)
@@ -7,6 +7,7 @@ package common
 import (
 	"context"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
@@ -14,19 +15,16 @@ import (
 )
 
 func testVMXFile(t *testing.T) string {
-	tf, err := os.CreateTemp("", "packer")
-	if err != nil {
-		t.Fatalf("err: %s", err)
-	}
+	// The directory is removed with the backup of the .vmx file.
+	vmxPath := filepath.Join(t.TempDir(), "packer.vmx")
 
 	// displayName must always be set
-	err = WriteVMX(tf.Name(), map[string]string{"displayName": "PackerBuild"})
+	err := WriteVMX(vmxPath, map[string]string{"displayName": "PackerBuild"})
 	if err != nil {
 		t.Fatalf("error writing .vmx file: %s", err)
 	}
-	tf.Close()
 
-	return tf.Name()
+	return vmxPath
 }
 
 func TestStepConfigureVMX_impl(t *testing.T) {
@@ -133,6 +131,9 @@ func TestStepConfigureVMX_floppyPath(t *testing.T) {
 		}
 	}
 
+	// Keys that are added are written in the case used by the hypervisor.
+	assert.Contains(t, string(vmxContents), "floppy0.fileType = \"file\"\n")
+	assert.Contains(t, string(vmxContents), "msg.autoAnswer = \"true\"\n")
 }
 
 func TestStepConfigureVMX_generatedAddresses(t *testing.T) {
@@ -432,3 +433,75 @@ func TestStepConfigureVMX_CDROMAdapter(t *testing.T) {
 	expectedDevices := []string{"sata1.present", "sata1:0"}
 	assert.ElementsMatch(t, expectedDevices, tmpDevices)
 }
+
+func TestStepConfigureVMX_deviceConflict(t *testing.T) {
+	state := testState(t)
+	step := new(StepConfigureVMX)
+	step.CustomData = map[string]string{
+		"scsi0.present":    "TRUE",
+		"scsi0:7.present":  "TRUE",
+		"scsi0:7.filename": "disk.vmdk",
+	}
+
+	vmxPath := testVMXFile(t)
+	defer os.Remove(vmxPath)
+	state.Put("vmx_path", vmxPath)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("should have error")
+	}
+
+	// Devices are not checked after provisioning.
+	state = testState(t)
+	state.Put("vmx_path", vmxPath)
+	step.SkipDevices = true
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+}
+
+func TestStepConfigureVMX_removeAndPatch(t *testing.T) {
+	state := testState(t)
+	step := new(StepConfigureVMX)
+	step.RemoveData = []string{"Sound.Present", "foo"}
+	step.CustomData = map[string]string{
+		"foo": "bar",
+	}
+	step.Patches = []VMXPatchConfig{
+		{Op: "delete_prefix", Key: "serial0."},
+		{Op: "set_if_absent", Key: "displayName", Value: "Ignored"},
+		{Op: "set", Key: "foo", Value: "baz"},
+	}
+
+	vmxPath := testVMXFile(t)
+	if err := WriteVMX(vmxPath, map[string]string{
+		"displayname":      "PackerBuild",
+		"sound.present":    "TRUE",
+		"serial0.present":  "TRUE",
+		"serial0.filetype": "file",
+	}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	state.Put("vmx_path", vmxPath)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); ok {
+		t.Fatal("should NOT have error")
+	}
+
+	vmxData, err := ReadVMX(vmxPath)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	assert.Equal(t, "baz", vmxData["foo"])
+	assert.Equal(t, "PackerBuild", vmxData["displayname"])
+	assert.NotContains(t, vmxData, "sound.present")
+	assert.NotContains(t, vmxData, "serial0.present")
+	assert.NotContains(t, vmxData, "serial0.filetype")
+}
@@ -36,7 +36,7 @@ type StepConfigureVNC struct {
 
 type VNCAddressFinder interface {
 	VNCAddress(context.Context, string, int, int) (string, int, error)
-	UpdateVMX(vncAddress, vncPassword string, vncPort int, vmxData map[string]string)
+	UpdateVMX(vncAddress, vncPassword string, vncPort int, vmx *VMXDocument)
 }
 
 // VNCAddress finds an available VNC port within the specified range and returns the address and port.
@@ -86,7 +86,7 @@ func (s *StepConfigureVNC) Run(ctx context.Context, state multistep.StateBag) mu
 	ui := state.Get("ui").(packersdk.Ui)
 	vmxPath := state.Get("vmx_path").(string)
 
-	vmxData, err := ReadVMX(vmxPath)
+	vmx, err := ReadVMXDocument(vmxPath)
 	if err != nil {
 		err = fmt.Errorf("error reading VMX file: %s", err)
 		state.Put("error", err)
@@ -115,9 +115,9 @@ func (s *StepConfigureVNC) Run(ctx context.Context, state multistep.StateBag) mu
 
 	log.Printf("[INFO] Found available VNC port: %s:%d", vncBindAddress, vncPort)
 
-	vncFinder.UpdateVMX(vncBindAddress, vncPassword, vncPort, vmxData)
+	vncFinder.UpdateVMX(vncBindAddress, vncPassword, vncPort, vmx)
 
-	if err := WriteVMX(vmxPath, vmxData); err != nil {
+	if err := WriteVMXDocument(vmxPath, vmx); err != nil {
 		err = fmt.Errorf("error writing VMX data: %s", err)
 		state.Put("error", err)
 		ui.Error(err.Error())
@@ -132,12 +132,12 @@ func (s *StepConfigureVNC) Run(ctx context.Context, state multistep.StateBag) mu
 }
 
 // UpdateVMX updates the VMX configuration with VNC settings.
-func (*StepConfigureVNC) UpdateVMX(address, password string, port int, data map[string]string) {
-	data["remotedisplay.vnc.enabled"] = "TRUE"
-	data["remotedisplay.vnc.port"] = fmt.Sprintf("%d", port)
-	data["remotedisplay.vnc.ip"] = address
+func (*StepConfigureVNC) UpdateVMX(address, password string, port int, vmx *VMXDocument) {
+	vmx.Set("RemoteDisplay.vnc.enabled", "TRUE")
+	vmx.Set("RemoteDisplay.vnc.port", fmt.Sprintf("%d", port))
+	vmx.Set("RemoteDisplay.vnc.ip", address)
 	if len(password) > 0 {
-		data["remotedisplay.vnc.password"] = password
+		vmx.Set("RemoteDisplay.vnc.password", password)
 	}
 }
 
@@ -15,8 +15,9 @@ func TestStepConfigureVNC_implVNCAddressFinder(t *testing.T) {
 
 func TestStepConfigureVNC_UpdateVMX(t *testing.T) {
 	var s StepConfigureVNC
-	data := make(map[string]string)
-	s.UpdateVMX("0.0.0.0", "", 5900, data)
+	vmx := NewVMXDocument()
+	s.UpdateVMX("0.0.0.0", "", 5900, vmx)
+	data := vmx.Map()
 	if ip := data["remotedisplay.vnc.ip"]; ip != "0.0.0.0" {
 		t.Errorf("bad VMX data for key remotedisplay.vnc.ip: %v", ip)
 	}
@@ -0,0 +1,76 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// StepConnectVMTools waits for VMware Tools guest operations to become
+// available in the guest and sets the vmtools communicator in the state.
+type StepConnectVMTools struct {
+	Config *SSHConfig
+}
+
+// Run waits for the guest operations and stores the communicator in the state.
+func (s *StepConnectVMTools) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	driver := state.Get("driver").(Driver)
+	ui := state.Get("ui").(packersdk.Ui)
+	vmxPath := state.Get("vmx_path").(string)
+
+	halt := func(err error) multistep.StepAction {
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	vmxData, err := ReadVMX(vmxPath)
+	if err != nil {
+		return halt(fmt.Errorf("error reading .vmx file: %s", err))
+	}
+	windows := strings.HasPrefix(strings.ToLower(vmxData["guestos"]), "win")
+
+	credentials := s.Config.VMToolsCredentials()
+	root := "/"
+	if windows {
+		root = "C:\\"
+	}
+
+	ui.Say("Waiting for VMware Tools guest operations to become available...")
+	timeout := time.After(s.Config.VMToolsTimeout)
+	for {
+		exists, err := driver.DirectoryExistsInGuest(vmxPath, credentials, root)
+		if err == nil && exists {
+			break
+		}
+		if err == nil {
+			err = fmt.Errorf("directory %s not found in guest", root)
+		}
+		log.Printf("[DEBUG] Guest operations are not available: %v", err)
+
+		select {
+		case <-ctx.Done():
+			return halt(fmt.Errorf("cancelled while waiting for VMware Tools guest operations"))
+		case <-timeout:
+			return halt(fmt.Errorf("timeout waiting for VMware Tools guest operations: %v", err))
+		case <-time.After(guestOpsPollInterval):
+		}
+	}
+
+	ui.Say("Connected to the guest using VMware Tools guest operations.")
+	state.Put("communicator", NewVMToolsCommunicator(driver, vmxPath, credentials, windows))
+
+	return multistep.ActionContinue
+}
+
+// Cleanup performs any necessary cleanup after the step completes.
+func (s *StepConnectVMTools) Cleanup(multistep.StateBag) {}
@@ -0,0 +1,88 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/communicator"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/stretchr/testify/assert"
+)
+
+func testStepConnectVMToolsState(t *testing.T, guestOS string) multistep.StateBag {
+	vmxPath := filepath.Join(t.TempDir(), "foo.vmx")
+	if err := os.WriteFile(vmxPath, []byte("guestOS = \""+guestOS+"\"\n"), 0644); err != nil { //nolint:gosec
+		t.Fatalf("err: %s", err)
+	}
+
+	state := testState(t)
+	state.Put("vmx_path", vmxPath)
+	return state
+}
+
+func TestStepConnectVMTools_impl(t *testing.T) {
+	var _ multistep.Step = new(StepConnectVMTools)
+}
+
+func TestStepConnectVMTools(t *testing.T) {
+	state := testStepConnectVMToolsState(t, "windows2019srv-64")
+	step := &StepConnectVMTools{
+		Config: &SSHConfig{
+			Comm: communicator.Config{
+				WinRM: communicator.WinRM{
+					WinRMUser:     "Administrator",
+					WinRMPassword: "secret",
+				},
+			},
+			VMToolsTimeout: time.Minute,
+		},
+	}
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.GuestDirectories = []string{"C:\\"}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); ok {
+		t.Fatal("should not error")
+	}
+
+	comm, ok := state.Get("communicator").(*VMToolsCommunicator)
+	if !ok {
+		t.Fatal("should set the vmtools communicator")
+	}
+	assert.True(t, comm.windows)
+	assert.Equal(t, GuestCredentials{Username: "Administrator", Password: "secret"}, driver.GuestCredentials)
+}
+
+func TestStepConnectVMTools_timeout(t *testing.T) {
+	state := testStepConnectVMToolsState(t, "ubuntu-64")
+	step := &StepConnectVMTools{
+		Config: &SSHConfig{
+			Comm: communicator.Config{
+				SSH: communicator.SSH{
+					SSHUsername: "packer",
+				},
+			},
+			VMToolsTimeout: time.Millisecond,
+		},
+	}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("should error")
+	}
+	if _, ok := state.GetOk("communicator"); ok {
+		t.Fatal("should not set the communicator")
+	}
+}
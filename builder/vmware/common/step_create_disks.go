@@ -12,17 +12,34 @@ import (
 
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/vmware/packer-plugin-vmware/builder/vmware/common/vmdk"
 )
 
-// StepCreateDisks creates the virtual disks for the VM.
+// StepCreateDisks creates the virtual disks for the VM, several at a time.
+// The disks with a source path are copied to the output directory, and the
+// referenced disks are attached in place by a later step.
+//
+// Produces:
+// disk_full_paths []string - The paths to the created disks.
+// disk_skip_compaction []string - The paths to the created disks that are
+// not compacted.
 type StepCreateDisks struct {
-	OutputDir          *string
-	CreateMainDisk     bool
-	DiskName           string
-	MainDiskSize       uint
-	AdditionalDiskSize []uint
-	DiskAdapterType    string
-	DiskTypeId         string
+	OutputDir       *string
+	CreateMainDisk  bool
+	DiskName        string
+	MainDiskSize    uint
+	AdditionalDisks []AdditionalDiskConfig
+	DiskAdapterType string
+	DiskTypeId      string
+}
+
+// diskSpec is the specification of a disk to create.
+type diskSpec struct {
+	path        string
+	sourcePath  string
+	size        string
+	adapterType string
+	typeId      string
 }
 
 // Run executes the disk creation step, creating the main disk and any additional disks.
@@ -34,42 +51,75 @@ func (s *StepCreateDisks) Run(ctx context.Context, state multistep.StateBag) mul
 
 	// Users can configure disks at several locations in the template so
 	// first collate all the disk requirements
-	var diskFullPaths, diskSizes []string
+	var disks []diskSpec
+	var skipCompaction []string
 	// The 'main' or 'default' disk, only used in vmware-iso
 	if s.CreateMainDisk {
-		diskFullPaths = append(diskFullPaths, filepath.Join(*s.OutputDir, s.DiskName+".vmdk"))
-		diskSizes = append(diskSizes, fmt.Sprintf("%dM", uint64(s.MainDiskSize)))
+		disks = append(disks, diskSpec{
+			path:        filepath.Join(*s.OutputDir, s.DiskName+".vmdk"),
+			size:        fmt.Sprintf("%dM", uint64(s.MainDiskSize)),
+			adapterType: s.DiskAdapterType,
+			typeId:      s.DiskTypeId,
+		})
 	}
-	// Additional disks
-	if len(s.AdditionalDiskSize) > 0 {
-		incrementer := 1
-		for i, diskSize := range s.AdditionalDiskSize {
-			// scsi slot 7 is reserved, so we skip it.
-			if i+incrementer == 7 {
-				incrementer = 2
-			}
-			path := filepath.Join(*s.OutputDir, fmt.Sprintf("%s-%d.vmdk", s.DiskName, i+incrementer))
-			diskFullPaths = append(diskFullPaths, path)
-			size := fmt.Sprintf("%dM", uint64(diskSize))
-			diskSizes = append(diskSizes, size)
+	// Additional disks default to the adapter type and disk type of the
+	// main disk.
+	for _, disk := range s.AdditionalDisks {
+		if disk.Reference() {
+			continue
+		}
+		spec := diskSpec{
+			path:        filepath.Join(*s.OutputDir, disk.FileName()),
+			sourcePath:  disk.SourcePath,
+			size:        fmt.Sprintf("%dM", uint64(disk.Size)),
+			adapterType: disk.AdapterType,
+			typeId:      disk.DiskTypeId,
+		}
+		if spec.adapterType == "" {
+			spec.adapterType = s.DiskAdapterType
+		}
+		if spec.typeId == "" {
+			spec.typeId = s.DiskTypeId
+		}
+		disks = append(disks, spec)
+
+		if disk.SkipCompaction {
+			skipCompaction = append(skipCompaction, spec.path)
 		}
 	}
 
-	// Create all required disks
-	for i, diskFullPath := range diskFullPaths {
-		log.Printf("[INFO] Creating disk with Path: %s and Size: %s", diskFullPath, diskSizes[i])
-		// Additional disks currently use the same adapter type and disk
-		// type as specified for the main disk
-		if err := driver.CreateDisk(diskFullPath, diskSizes[i], s.DiskAdapterType, s.DiskTypeId); err != nil {
-			err := fmt.Errorf("error creating disk: %s", err)
-			state.Put("error", err)
-			ui.Error(err.Error())
-			return multistep.ActionHalt
+	// Create all required disks, several at a time.
+	err := forEachDisk(ctx, len(disks), func(i int) error {
+		disk := disks[i]
+		if disk.sourcePath != "" {
+			ui.Sayf("Copying disk %s to %s...", disk.sourcePath, filepath.Base(disk.path))
+			if err := vmdk.Copy(disk.sourcePath, disk.path); err != nil {
+				return fmt.Errorf("error copying disk: %s", err)
+			}
+			return nil
+		}
+
+		log.Printf("[INFO] Creating disk with Path: %s and Size: %s", disk.path, disk.size)
+		progress := uiDiskProgress(ui, filepath.Base(disk.path))
+		if err := driver.CreateDisk(disk.path, disk.size, disk.adapterType, disk.typeId, progress); err != nil {
+			return fmt.Errorf("error creating disk: %s", err)
 		}
+		return nil
+	})
+	if err != nil {
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	var diskFullPaths []string
+	for _, disk := range disks {
+		diskFullPaths = append(diskFullPaths, disk.path)
 	}
 
 	// Stash the disk paths so we can retrieve later e.g. when compacting
 	state.Put("disk_full_paths", diskFullPaths)
+	state.Put("disk_skip_compaction", skipCompaction)
 	return multistep.ActionContinue
 }
 
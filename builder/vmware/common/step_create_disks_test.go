@@ -11,6 +11,7 @@ import (
 
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	"github.com/stretchr/testify/assert"
+	"github.com/vmware/packer-plugin-vmware/builder/vmware/common/vmdk"
 )
 
 func TestStepCreateDisks_impl(t *testing.T) {
@@ -22,16 +23,24 @@ func strPtr(s string) *string {
 }
 func NewTestCreateDiskStep() *StepCreateDisks {
 	return &StepCreateDisks{
-		OutputDir:          strPtr("output_dir"),
-		CreateMainDisk:     true,
-		DiskName:           "disk_name",
-		MainDiskSize:       uint(1024),
-		AdditionalDiskSize: []uint{},
-		DiskAdapterType:    "fake_adapter",
-		DiskTypeId:         "1",
+		OutputDir:       strPtr("output_dir"),
+		CreateMainDisk:  true,
+		DiskName:        "disk_name",
+		MainDiskSize:    uint(1024),
+		AdditionalDisks: []AdditionalDiskConfig{},
+		DiskAdapterType: "fake_adapter",
+		DiskTypeId:      "1",
 	}
 }
 
+func testAdditionalDisks(t *testing.T, diskName string, sizes ...uint) []AdditionalDiskConfig {
+	c := &DiskConfig{DiskName: diskName, AdditionalDiskSize: sizes}
+	if errs := c.Prepare(nil); len(errs) > 0 {
+		t.Fatalf("bad: %v", errs)
+	}
+	return c.AdditionalDisks
+}
+
 func TestStepCreateDisks_MainOnly(t *testing.T) {
 	state := testState(t)
 	step := NewTestCreateDiskStep()
@@ -62,7 +71,7 @@ func TestStepCreateDisks_MainOnly(t *testing.T) {
 func TestStepCreateDisks_MainAndExtra(t *testing.T) {
 	state := testState(t)
 	step := NewTestCreateDiskStep()
-	step.AdditionalDiskSize = []uint{1024, 2048, 4096}
+	step.AdditionalDisks = testAdditionalDisks(t, step.DiskName, 1024, 2048, 4096)
 
 	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
 		t.Fatalf("bad action: %#v", action)
@@ -96,7 +105,7 @@ func TestStepCreateDisks_ExtraOnly(t *testing.T) {
 	state := testState(t)
 	step := NewTestCreateDiskStep()
 	step.CreateMainDisk = false
-	step.AdditionalDiskSize = []uint{1024, 2048, 4096}
+	step.AdditionalDisks = testAdditionalDisks(t, step.DiskName, 1024, 2048, 4096)
 
 	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
 		t.Fatalf("bad action: %#v", action)
@@ -126,6 +135,47 @@ func TestStepCreateDisks_ExtraOnly(t *testing.T) {
 	step.Cleanup(state)
 }
 
+func TestStepCreateDisks_Source(t *testing.T) {
+	state := testState(t)
+	step := NewTestCreateDiskStep()
+	step.CreateMainDisk = false
+	step.OutputDir = strPtr(t.TempDir())
+
+	source := filepath.Join(t.TempDir(), "source.vmdk")
+	if err := vmdk.Create(source, 1024*1024, vmdk.DiskTypeMonolithicFlat, "lsilogic"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	c := &DiskConfig{
+		DiskName: step.DiskName,
+		AdditionalDisks: []AdditionalDiskConfig{
+			{SourcePath: source, Name: "copied"},
+			{SourcePath: source, SourceMode: DiskSourceModeReference, Detach: true},
+		},
+	}
+	if errs := c.Prepare(nil); len(errs) > 0 {
+		t.Fatalf("bad: %v", errs)
+	}
+	step.AdditionalDisks = c.AdditionalDisks
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); ok {
+		t.Fatal("should NOT have error")
+	}
+
+	driver := state.Get("driver").(*DriverMock)
+	if driver.CreateDiskCalled {
+		t.Fatalf("Should not have called create disk.")
+	}
+
+	copied := filepath.Join(*step.OutputDir, "copied.vmdk")
+	assert.Equal(t, []string{copied}, state.Get("disk_full_paths"))
+	if _, err := vmdk.Stat(copied); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
 func TestStepCreateDisks_Nothing(t *testing.T) {
 	state := testState(t)
 	step := NewTestCreateDiskStep()
@@ -146,3 +196,23 @@ func TestStepCreateDisks_Nothing(t *testing.T) {
 	// Cleanup
 	step.Cleanup(state)
 }
+
+func TestStepCreateDisks_perDisk(t *testing.T) {
+	state := testState(t)
+	step := NewTestCreateDiskStep()
+	step.CreateMainDisk = false
+	step.AdditionalDisks = []AdditionalDiskConfig{
+		{Size: 2048, Name: "data", AdapterType: "nvme", DiskTypeId: "0", SkipCompaction: true},
+	}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	driver := state.Get("driver").(*DriverMock)
+	assert.Equal(t, filepath.Join("output_dir", "data.vmdk"), driver.CreateDiskOutput)
+	assert.Equal(t, "2048M", driver.CreateDiskSize)
+	assert.Equal(t, "nvme", driver.CreateDiskAdapterType)
+	assert.Equal(t, "0", driver.CreateDiskTypeId)
+	assert.Equal(t, []string{filepath.Join("output_dir", "data.vmdk")}, state.Get("disk_skip_compaction"))
+}
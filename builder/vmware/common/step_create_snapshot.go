@@ -28,8 +28,34 @@ func (s *StepCreateSnapshot) Run(ctx context.Context, state multistep.StateBag)
 	driver := state.Get("driver").(Driver)
 	ui := state.Get("ui").(packersdk.Ui)
 
-	ui.Say("Creating snapshot of virtual machine...")
 	vmFullPath := state.Get("vmx_path").(string)
+
+	// Replace the existing snapshots with the same name instead of creating a
+	// duplicate.
+	tree, err := ReadSnapshotTree(vmFullPath)
+	if err != nil {
+		err := fmt.Errorf("error reading snapshot database: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	var existing []*Snapshot
+	for _, snapshot := range tree.LeavesFirst() {
+		if snapshot.DisplayName == *s.SnapshotName {
+			existing = append(existing, snapshot)
+		}
+	}
+	if len(existing) > 0 {
+		ui.Sayf("Replacing existing snapshot: %s", *s.SnapshotName)
+		if err := DeleteSnapshots(driver, vmFullPath, existing); err != nil {
+			err := fmt.Errorf("error deleting existing snapshot: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	ui.Say("Creating snapshot of virtual machine...")
 	if err := driver.CreateSnapshot(vmFullPath, *s.SnapshotName); err != nil {
 		err := fmt.Errorf("error creating snapshot: %s", err)
 		state.Put("error", err)
@@ -6,6 +6,10 @@ package common
 
 import (
 	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
 	"testing"
 
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
@@ -21,6 +25,16 @@ func NewTestCreateSnapshotStep() *StepCreateSnapshot {
 	}
 }
 
+// testSnapshotVMXPath returns the path to a .vmx file in a temporary directory
+// with the snapshot database.
+func testSnapshotVMXPath(t *testing.T, vmsd string) string {
+	vmxPath := filepath.Join(t.TempDir(), "foo.vmx")
+	if err := os.WriteFile(VMSDPath(vmxPath), []byte(vmsd), 0644); err != nil { //nolint:gosec
+		t.Fatalf("err: %s", err)
+	}
+	return vmxPath
+}
+
 func TestStepCreateSnapshot(t *testing.T) {
 	state := testState(t)
 	step := NewTestCreateSnapshotStep()
@@ -40,6 +54,10 @@ func TestStepCreateSnapshot(t *testing.T) {
 		t.Fatalf("should call create snapshot")
 	}
 
+	if driver.DeleteSnapshotCalled {
+		t.Fatalf("should not delete a snapshot")
+	}
+
 	if _, ok := state.GetOk("snapshot_skipped"); ok {
 		t.Fatalf("should not skip snapshot")
 	}
@@ -72,3 +90,63 @@ func TestStepCreateSnapshot_skip(t *testing.T) {
 
 	step.Cleanup(state)
 }
+
+func TestStepCreateSnapshot_replace(t *testing.T) {
+	state := testState(t)
+	step := NewTestCreateSnapshotStep()
+
+	// Both snapshots with the name are replaced, by their paths, children
+	// first.
+	vmxPath := testSnapshotVMXPath(t, `snapshot.lastUID = "3"
+snapshot0.uid = "1"
+snapshot0.displayName = "snapshot_name"
+snapshot1.uid = "2"
+snapshot1.parent = "1"
+snapshot1.displayName = "base"
+snapshot2.uid = "3"
+snapshot2.parent = "2"
+snapshot2.displayName = "snapshot_name"
+`)
+	state.Put("vmx_path", vmxPath)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); ok {
+		t.Fatal("should not error")
+	}
+
+	driver := state.Get("driver").(*DriverMock)
+	if driver.DeleteSnapshotVMXPath != vmxPath {
+		t.Fatalf("should delete snapshots of the virtual machine")
+	}
+	expected := []string{"snapshot_name/base/snapshot_name", "snapshot_name"}
+	if !reflect.DeepEqual(driver.DeleteSnapshotNames, expected) {
+		t.Fatalf("should delete only the existing snapshots: %v", driver.DeleteSnapshotNames)
+	}
+	if !driver.CreateSnapshotCalled {
+		t.Fatalf("should call create snapshot")
+	}
+}
+
+func TestStepCreateSnapshot_deleteError(t *testing.T) {
+	state := testState(t)
+	step := NewTestCreateSnapshotStep()
+
+	state.Put("vmx_path", testSnapshotVMXPath(t, `snapshot0.uid = "1"
+snapshot0.displayName = "snapshot_name"
+`))
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.DeleteSnapshotErr = errors.New("error")
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("should error")
+	}
+	if driver.CreateSnapshotCalled {
+		t.Fatalf("should not call create snapshot")
+	}
+}
@@ -0,0 +1,123 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// StepDhcpReservation reserves a fixed IP address in the DHCP configuration of
+// the virtual network for each network adapter with a DHCP reservation. The
+// reserved address of the network adapter used by the communicator is stored
+// so that the guest is reached at a deterministic IP address. The reservations
+// are removed during cleanup.
+type StepDhcpReservation struct {
+	NetworkAdapters            []NetworkAdapterConfig
+	CommunicatorNetworkAdapter int
+
+	reservations []*DhcpReservation
+}
+
+// Run adds the DHCP reservations and restarts the DHCP service.
+func (s *StepDhcpReservation) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+	driver := state.Get("driver").(Driver).GetVmwareDriver()
+
+	for i, adapter := range s.NetworkAdapters {
+		if !adapter.DHCPReservation {
+			continue
+		}
+
+		if len(s.reservations) == 0 {
+			ui.Say("Reserving guest IP addresses...")
+		}
+
+		r, err := s.reserve(driver, adapter)
+		if err != nil {
+			err := fmt.Errorf("error reserving IP address for ethernet%d: %s", i, err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		s.reservations = append(s.reservations, r)
+
+		ui.Sayf("Reserved IP address %s for ethernet%d on %s.", r.Address, i, r.Device)
+		if i == s.CommunicatorNetworkAdapter {
+			state.Put("vmnetwork_reserved_address", r.Address.String())
+		}
+	}
+
+	s.restartDhcpService(ui, driver)
+
+	return multistep.ActionContinue
+}
+
+// reserve adds a DHCP reservation for the network adapter.
+func (s *StepDhcpReservation) reserve(driver VmwareDriver, adapter NetworkAdapterConfig) (*DhcpReservation, error) {
+	if driver.NetworkMapper == nil || driver.DhcpConfPath == nil {
+		return nil, fmt.Errorf("unable to locate the DHCP configuration of network %s", adapter.Network)
+	}
+
+	device, err := networkDevice(driver, adapter.Network)
+	if err != nil {
+		return nil, err
+	}
+
+	hwaddr, err := net.ParseMAC(adapter.MACAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	return AddDhcpReservation(driver.DhcpConfPath(device), device, hwaddr)
+}
+
+// restartDhcpService restarts the DHCP service of each device with a
+// reservation, so that changes to the reservations take effect. Errors are
+// reported, but otherwise ignored.
+func (s *StepDhcpReservation) restartDhcpService(ui packersdk.Ui, driver VmwareDriver) {
+	restarted := make(map[string]bool)
+	for _, r := range s.reservations {
+		if restarted[r.Device] {
+			continue
+		}
+		restarted[r.Device] = true
+
+		if driver.RestartDhcpService == nil {
+			ui.Errorf("Unable to restart the DHCP service for %s; restart the service to apply the DHCP reservations.", r.Device)
+			continue
+		}
+
+		log.Printf("[INFO] Restarting DHCP service for %s", r.Device)
+		if err := driver.RestartDhcpService(r.Device); err != nil {
+			ui.Errorf("Error restarting the DHCP service for %s; restart the service to apply the DHCP reservations: %s", r.Device, err)
+		}
+	}
+}
+
+// Cleanup removes the DHCP reservations and restarts the DHCP service.
+func (s *StepDhcpReservation) Cleanup(state multistep.StateBag) {
+	if len(s.reservations) == 0 {
+		return
+	}
+
+	ui := state.Get("ui").(packersdk.Ui)
+	driver := state.Get("driver").(Driver).GetVmwareDriver()
+
+	ui.Say("Removing guest IP address reservations...")
+	for _, r := range s.reservations {
+		if err := r.Remove(); err != nil {
+			ui.Errorf("Error removing the DHCP reservation %s from %s: %s", r.Name, r.ConfPath, err)
+		}
+	}
+
+	s.restartDhcpService(ui, driver)
+	s.reservations = nil
+}
@@ -0,0 +1,76 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStepDhcpReservation_impl(t *testing.T) {
+	var _ multistep.Step = new(StepDhcpReservation)
+}
+
+func TestStepDhcpReservation(t *testing.T) {
+	contents, err := os.ReadFile(filepath.Join("testdata", "dhcpd-example.conf"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	confPath := testDhcpConfig(t, string(contents))
+
+	state := testState(t)
+	driver := state.Get("driver").(*DriverMock)
+	driver.DhcpConfPathResult = confPath
+
+	step := &StepDhcpReservation{
+		NetworkAdapters: []NetworkAdapterConfig{
+			{Network: "vmnet8", MACAddress: "00:50:56:00:00:01"},
+			{Network: "vmnet8", MACAddress: "00:50:56:00:00:02", DHCPReservation: true},
+		},
+		CommunicatorNetworkAdapter: 1,
+	}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); ok {
+		t.Fatal("should NOT have error")
+	}
+
+	assert.Equal(t, "172.33.33.3", state.Get("vmnetwork_reserved_address"))
+	assert.Equal(t, []string{"vmnet8"}, driver.RestartDhcpServiceDevices)
+
+	step.Cleanup(state)
+
+	result, err := os.ReadFile(confPath)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assert.Equal(t, string(contents), string(result), "reservation should be removed")
+	assert.Equal(t, []string{"vmnet8", "vmnet8"}, driver.RestartDhcpServiceDevices)
+}
+
+func TestStepDhcpReservation_none(t *testing.T) {
+	state := testState(t)
+	driver := state.Get("driver").(*DriverMock)
+
+	step := &StepDhcpReservation{
+		NetworkAdapters: []NetworkAdapterConfig{{Network: "nat"}},
+	}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	step.Cleanup(state)
+
+	_, ok := state.GetOk("vmnetwork_reserved_address")
+	assert.False(t, ok)
+	assert.Empty(t, driver.RestartDhcpServiceDevices)
+}
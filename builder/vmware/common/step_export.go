@@ -9,34 +9,49 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/vmware/packer-plugin-vmware/builder/vmware/common/diskimage"
 )
 
 // StepExport represents a step to export a virtual machines to specific formats.
 type StepExport struct {
 	Format         string
+	ExportEngine   string
 	SkipExport     bool
 	VMName         string
 	OVFToolOptions []string
 	OutputDir      *string
+
+	// Formats are the formats to export to, each in a subdirectory of the
+	// output directory named for the format. If not set, the virtual machine
+	// is exported to Format in the output directory.
+	Formats []string
+
+	// VagrantfileTemplate is the path to the Vagrantfile template for the
+	// Vagrant box format.
+	VagrantfileTemplate string
+
+	// DiskConversionEngine is the engine used to convert the disks to the
+	// disk image formats.
+	DiskConversionEngine string
 }
 
 // generateExportArgs creates ovftool arguments for exporting from the hypervisor.
-func (s *StepExport) generateExportArgs(exportOutputPath string) ([]string, error) {
+func (s *StepExport) generateExportArgs(exportOutputPath string, targetPath string, format string) ([]string, error) {
 	args := []string{
 		filepath.Join(exportOutputPath, s.VMName+".vmx"),
-		filepath.Join(exportOutputPath, s.VMName+"."+s.Format),
+		filepath.Join(targetPath, s.VMName+"."+format),
 	}
-	return append(s.OVFToolOptions, args...), nil
+	return append(slices.Clone(s.OVFToolOptions), args...), nil
 }
 
-// Run executes the export step, converting the virtual machine to the specified format.
+// Run executes the export step, converting the virtual machine to the specified formats.
 func (s *StepExport) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
 	ui := state.Get("ui").(packersdk.Ui)
-	driver := state.Get("driver").(Driver)
 
 	// Skip export if requested
 	if s.SkipExport {
@@ -55,39 +70,180 @@ func (s *StepExport) Run(ctx context.Context, state multistep.StateBag) multiste
 		}
 	}
 
-	err := os.MkdirAll(exportOutputPath, 0755)
-	if err != nil {
-		err = fmt.Errorf("error creating export directory: %s", err)
-		state.Put("error", err)
-		ui.Error(err.Error())
-		return multistep.ActionHalt
+	formats := s.Formats
+	if len(formats) == 0 {
+		formats = []string{s.Format}
+	}
+
+	// The files exported for each format.
+	exportedFiles := make(map[string][]string)
+
+	for _, format := range formats {
+		// The virtual machine files in the output directory are the vmx format.
+		if format == ExportFormatVmx {
+			continue
+		}
+
+		targetPath := exportOutputPath
+		if len(s.Formats) > 0 {
+			targetPath = filepath.Join(exportOutputPath, format)
+		}
+
+		err := os.MkdirAll(targetPath, 0755)
+		if err != nil {
+			err = fmt.Errorf("error creating export directory: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+
+		existing, err := listExportFiles(targetPath)
+		if err != nil {
+			err = fmt.Errorf("error listing export directory: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+
+		if len(s.Formats) > 0 {
+			ui.Sayf("Exporting virtual machine to %s...", format)
+		} else {
+			ui.Say("Exporting virtual machine...")
+		}
+
+		if err := s.export(ui, state, format, exportOutputPath, targetPath); err != nil {
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+
+		files, err := listExportFiles(targetPath)
+		if err != nil {
+			err = fmt.Errorf("error listing export directory: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+
+		// Only the files created by the export belong to the format.
+		for _, file := range files {
+			if !slices.Contains(existing, file) {
+				exportedFiles[format] = append(exportedFiles[format], file)
+			}
+		}
+	}
+
+	state.Put("exported_files", exportedFiles)
+
+	return multistep.ActionContinue
+}
+
+// export exports the virtual machine in the output directory to the format in
+// the target directory.
+func (s *StepExport) export(ui packersdk.Ui, state multistep.StateBag, format string, exportOutputPath string, targetPath string) error {
+	driver := state.Get("driver").(Driver)
+
+	if format == exportFormatVagrant {
+		vmxPath := state.Get("vmx_path").(string)
+		if err := s.exportVagrant(ui, vmxPath, targetPath); err != nil {
+			return fmt.Errorf("error packaging Vagrant box: %s", err)
+		}
+		return nil
 	}
 
-	ui.Say("Exporting virtual machine...")
+	if slices.Contains(diskimage.Formats, format) {
+		diskFullPaths := state.Get("disk_full_paths").([]string)
+		if err := s.exportDiskImages(ui, diskFullPaths, targetPath, format); err != nil {
+			return fmt.Errorf("error converting disks: %s", err)
+		}
+		return nil
+	}
 
-	var args []string
+	if s.ExportEngine == ExportEngineNative {
+		vmxPath := state.Get("vmx_path").(string)
+		if err := s.exportNative(ui, vmxPath, targetPath, format); err != nil {
+			return fmt.Errorf("error performing native export: %s", err)
+		}
+		return nil
+	}
 
 	ovftool := GetOvfTool()
 
-	args, err = s.generateExportArgs(exportOutputPath)
+	args, err := s.generateExportArgs(exportOutputPath, targetPath, format)
 	if err != nil {
-		err = fmt.Errorf("error generating ovftool export args: %s", err)
-		state.Put("error", err)
-		ui.Error(err.Error())
-		return multistep.ActionHalt
+		return fmt.Errorf("error generating ovftool export args: %s", err)
 	}
 
 	ui.Sayf("Executing: %s %s", ovftool, strings.Join(args, " "))
 
 	if err := driver.Export(args); err != nil {
-		err = fmt.Errorf("error performing ovftool export: %s", err)
-		state.Put("error", err)
-		ui.Error(err.Error())
-		return multistep.ActionHalt
+		return fmt.Errorf("error performing ovftool export: %s", err)
 	}
 
-	return multistep.ActionContinue
+	return nil
+}
+
+// listExportFiles returns the files in the export directory, including the
+// files in subdirectories.
+func listExportFiles(dir string) ([]string, error) {
+	var files []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// exportNative exports the virtual machine with the native export engine. An
+// OVA is packaged from the OVF exported to a staging directory, which is
+// removed after packaging.
+func (s *StepExport) exportNative(ui packersdk.Ui, vmxPath string, exportOutputPath string, format string) error {
+	if format != exportFormatOva {
+		return exportNative(ui, vmxPath, exportOutputPath, s.VMName)
+	}
+
+	stagingDir, err := os.MkdirTemp(exportOutputPath, ".ovf-")
+	if err != nil {
+		return fmt.Errorf("error creating staging directory: %s", err)
+	}
+	defer os.RemoveAll(stagingDir)
+
+	if err := exportNative(ui, vmxPath, stagingDir, s.VMName); err != nil {
+		return err
+	}
+
+	ui.Say("Packaging OVA...")
+	ovfPath := filepath.Join(stagingDir, s.VMName+"."+ExportFormatOvf)
+	ovaPath := filepath.Join(exportOutputPath, s.VMName+"."+exportFormatOva)
+	if err := PackOVA(ovfPath, ovaPath); err != nil {
+		return fmt.Errorf("error packaging OVA: %s", err)
+	}
+
+	return nil
+}
+
+// exportVagrant packages the virtual machine files as a Vagrant box.
+func (s *StepExport) exportVagrant(ui packersdk.Ui, vmxPath string, exportOutputPath string) error {
+	files, err := vagrantBoxFiles(filepath.Dir(vmxPath))
+	if err != nil {
+		return err
+	}
+
+	var vagrantfile string
+	if s.VagrantfileTemplate != "" {
+		if vagrantfile, err = renderVagrantfile(s.VagrantfileTemplate, s.VMName); err != nil {
+			return err
+		}
+	}
 
+	boxPath := filepath.Join(exportOutputPath, s.VMName+vagrantBoxExtension)
+	ui.Sayf("Packaging Vagrant box: %s", boxPath)
+	return PackVagrantBox(files, vagrantfile, boxPath)
 }
 
 // Cleanup performs any necessary cleanup after the export step completes.
@@ -6,6 +6,7 @@ package common
 
 import (
 	"context"
+	"os"
 	"path/filepath"
 	"testing"
 
@@ -147,3 +148,193 @@ func TestStepExport_localArgs_OvftoolOptions(t *testing.T) {
 	// Cleanup
 	step.Cleanup(state)
 }
+
+func TestStepExport_nativeEngine(t *testing.T) {
+	vmDir := t.TempDir()
+	vmxPath, _ := testNativeExportVM(t, vmDir)
+
+	state := testState(t)
+	state.Put("vmx_path", vmxPath)
+	step := new(StepExport)
+
+	step.SkipExport = false
+	step.OutputDir = stringPointer(t.TempDir())
+	step.VMName = "test-name"
+	step.Format = "ovf"
+	step.ExportEngine = ExportEngineNative
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); ok {
+		t.Fatal("should NOT have error")
+	}
+
+	// The native engine does not use the driver's Export func.
+	d := state.Get("driver").(*DriverMock)
+	if d.ExportCalled {
+		t.Fatal("Should not have called the driver export func")
+	}
+
+	for _, name := range []string{"test-name.ovf", "test-name.mf", "test-name-disk1.vmdk"} {
+		assert.FileExists(t, filepath.Join(*step.OutputDir, name))
+	}
+}
+
+func TestStepExport_nativeEngineOva(t *testing.T) {
+	vmDir := t.TempDir()
+	vmxPath, _ := testNativeExportVM(t, vmDir)
+
+	state := testState(t)
+	state.Put("vmx_path", vmxPath)
+	step := new(StepExport)
+
+	step.SkipExport = false
+	step.OutputDir = stringPointer(t.TempDir())
+	step.VMName = "test-name"
+	step.Format = "ova"
+	step.ExportEngine = ExportEngineNative
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); ok {
+		t.Fatal("should NOT have error")
+	}
+
+	// Only the OVA remains; the staging directory is removed.
+	entries, err := os.ReadDir(*step.OutputDir)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "test-name.ova", entries[0].Name())
+}
+
+func TestStepExport_vagrant(t *testing.T) {
+	vmDir := t.TempDir()
+	vmxPath, _ := testNativeExportVM(t, vmDir)
+
+	state := testState(t)
+	state.Put("vmx_path", vmxPath)
+	step := new(StepExport)
+
+	step.SkipExport = false
+	step.OutputDir = stringPointer(vmDir)
+	step.VMName = "test-name"
+	step.Format = "vagrant"
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); ok {
+		t.Fatal("should NOT have error")
+	}
+
+	d := state.Get("driver").(*DriverMock)
+	if d.ExportCalled {
+		t.Fatal("Should not have called the driver export func")
+	}
+
+	box := readVagrantBox(t, filepath.Join(vmDir, "test-name.box"))
+	assert.Contains(t, box, "metadata.json")
+	assert.Contains(t, box, "vm.vmx")
+	assert.Contains(t, box, "disk.vmdk")
+	assert.Contains(t, box, "disk-flat.vmdk")
+	assert.NotContains(t, box, "Vagrantfile")
+}
+
+func TestStepExport_formats(t *testing.T) {
+	vmDir := t.TempDir()
+	vmxPath, _ := testNativeExportVM(t, vmDir)
+
+	state := testState(t)
+	state.Put("vmx_path", vmxPath)
+	step := new(StepExport)
+
+	step.SkipExport = false
+	step.OutputDir = stringPointer(vmDir)
+	step.VMName = "test-name"
+	step.Formats = []string{"vmx", "ovf", "ova", "vagrant"}
+	step.ExportEngine = ExportEngineNative
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); ok {
+		t.Fatal("should NOT have error")
+	}
+
+	// Each format is exported to its own subdirectory.
+	exported := state.Get("exported_files").(map[string][]string)
+	assert.ElementsMatch(t, []string{
+		filepath.Join(vmDir, "ovf", "test-name.ovf"),
+		filepath.Join(vmDir, "ovf", "test-name.mf"),
+		filepath.Join(vmDir, "ovf", "test-name-disk1.vmdk"),
+	}, exported["ovf"])
+	assert.Equal(t, []string{filepath.Join(vmDir, "ova", "test-name.ova")}, exported["ova"])
+	assert.Equal(t, []string{filepath.Join(vmDir, "vagrant", "test-name.box")}, exported["vagrant"])
+	assert.NotContains(t, exported, "vmx")
+
+	// The Vagrant box only includes the virtual machine files.
+	box := readVagrantBox(t, filepath.Join(vmDir, "vagrant", "test-name.box"))
+	assert.Len(t, box, 4)
+}
+
+func TestStepExport_formatsOvftool(t *testing.T) {
+	state := testState(t)
+	step := new(StepExport)
+
+	outputDir := t.TempDir()
+	step.SkipExport = false
+	step.OutputDir = stringPointer(outputDir)
+	step.VMName = "test-name"
+	step.Formats = []string{"ova"}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	d := state.Get("driver").(*DriverMock)
+	assert.Equal(t, []string{
+		filepath.Join(outputDir, "test-name.vmx"),
+		filepath.Join(outputDir, "ova", "test-name.ova")}, d.ExportArgs)
+}
+
+func TestStepExport_diskImages(t *testing.T) {
+	vmDir := t.TempDir()
+	_, image := testNativeExportVM(t, vmDir)
+
+	state := testState(t)
+	state.Put("disk_full_paths", []string{filepath.Join(vmDir, "disk.vmdk")})
+	step := new(StepExport)
+
+	step.SkipExport = false
+	step.OutputDir = stringPointer(vmDir)
+	step.VMName = "test-name"
+	step.Formats = []string{"vmx", "qcow2", "raw", "vhdx"}
+	step.DiskConversionEngine = DiskConversionEngineNative
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); ok {
+		t.Fatal("should NOT have error")
+	}
+
+	exported := state.Get("exported_files").(map[string][]string)
+	for _, format := range []string{"qcow2", "raw", "vhdx"} {
+		assert.Equal(t, []string{filepath.Join(vmDir, format, "test-name-disk1."+format)}, exported[format])
+	}
+
+	raw, err := os.ReadFile(filepath.Join(vmDir, "raw", "test-name-disk1.raw"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assert.Equal(t, image, raw)
+
+	d := state.Get("driver").(*DriverMock)
+	if d.ExportCalled {
+		t.Fatal("Should not have called the driver export func")
+	}
+}
@@ -0,0 +1,112 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"log"
+	"strconv"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/packerbuilderdata"
+)
+
+// GeneratedDataKeys returns the names of the data that the builders generate
+// for provisioners and post-processors, which are available as
+// `build.<name>`.
+func GeneratedDataKeys() []string {
+	return []string{
+		"VMName",
+		"VMXPath",
+		"GuestIP",
+		"GuestMAC",
+		"HTTPIP",
+		"HTTPPort",
+		"VNCIP",
+		"VNCPort",
+		"HypervisorProduct",
+		"HypervisorVersion",
+		"HardwareVersion",
+		"GuestOSType",
+	}
+}
+
+// StepGeneratedData publishes the generated data of the build for provisioners
+// and post-processors. The data that is not known, such as the address of the
+// VNC server when VNC is disabled, is empty.
+//
+// Uses:
+// driver   Driver
+// vmx_path string
+// guest_ip string
+// http_ip  string
+// http_port int
+// vnc_ip   string
+// vnc_port int
+//
+// Produces:
+// generated_data map[string]interface{} - The generated data of the build.
+type StepGeneratedData struct {
+	VMName string
+}
+
+// Run publishes the generated data of the build.
+func (s *StepGeneratedData) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	driver := state.Get("driver").(Driver)
+	vmxPath := state.Get("vmx_path").(string)
+
+	data := &packerbuilderdata.GeneratedData{State: state}
+	for _, key := range GeneratedDataKeys() {
+		data.Put(key, "")
+	}
+
+	data.Put("VMName", s.VMName)
+	data.Put("VMXPath", vmxPath)
+
+	// The IP address of the guest is known if the communicator connected to
+	// the guest; otherwise, VMware Tools may report the address.
+	if ip, ok := state.GetOk("guest_ip"); ok {
+		data.Put("GuestIP", ip.(string))
+	} else if ip, err := driver.GetGuestIPAddress(vmxPath); err == nil {
+		data.Put("GuestIP", ip)
+	} else {
+		log.Printf("[INFO] Guest IP address is not available: %s", err)
+	}
+
+	if mac, err := driver.GuestAddress(state); err == nil {
+		data.Put("GuestMAC", mac)
+	} else {
+		log.Printf("[INFO] Guest MAC address is not available: %s", err)
+	}
+
+	if ip, ok := state.GetOk("http_ip"); ok {
+		data.Put("HTTPIP", ip.(string))
+	}
+	if port, ok := state.GetOk("http_port"); ok && port.(int) > 0 {
+		data.Put("HTTPPort", strconv.Itoa(port.(int)))
+	}
+	if ip, ok := state.GetOk("vnc_ip"); ok {
+		data.Put("VNCIP", ip.(string))
+	}
+	if port, ok := state.GetOk("vnc_port"); ok {
+		data.Put("VNCPort", strconv.Itoa(port.(int)))
+	}
+
+	vmwareDriver := driver.GetVmwareDriver()
+	data.Put("HypervisorProduct", vmwareDriver.ProductName)
+	data.Put("HypervisorVersion", vmwareDriver.ProductVersion)
+
+	if vmxData, err := ReadVMX(vmxPath); err == nil {
+		data.Put("HardwareVersion", vmxData["virtualhw.version"])
+		data.Put("GuestOSType", vmxData["guestos"])
+	} else {
+		log.Printf("[WARN] Failed to read .vmx file for generated data: %s", err)
+	}
+
+	return multistep.ActionContinue
+}
+
+// Cleanup performs any necessary cleanup after the step completes.
+func (s *StepGeneratedData) Cleanup(multistep.StateBag) {}
@@ -0,0 +1,85 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStepGeneratedData_impl(t *testing.T) {
+	var _ multistep.Step = new(StepGeneratedData)
+}
+
+func TestStepGeneratedData(t *testing.T) {
+	vmxPath := filepath.Join(t.TempDir(), "packer.vmx")
+	err := WriteVMX(vmxPath, map[string]string{
+		"displayName":       "PackerBuild",
+		"virtualHW.version": "21",
+		"guestOS":           "ubuntu-64",
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	state := testState(t)
+	state.Put("vmx_path", vmxPath)
+	state.Put("guest_ip", "10.0.0.5")
+	state.Put("http_ip", "10.0.0.1")
+	state.Put("http_port", 8080)
+	state.Put("vnc_ip", "127.0.0.1")
+	state.Put("vnc_port", 5901)
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.GuestAddressResult = "00:50:56:00:00:01"
+
+	step := &StepGeneratedData{VMName: "packer"}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	data := state.Get("generated_data").(map[string]interface{})
+	assert.Equal(t, "packer", data["VMName"])
+	assert.Equal(t, vmxPath, data["VMXPath"])
+	assert.Equal(t, "10.0.0.5", data["GuestIP"])
+	assert.Equal(t, "00:50:56:00:00:01", data["GuestMAC"])
+	assert.Equal(t, "10.0.0.1", data["HTTPIP"])
+	assert.Equal(t, "8080", data["HTTPPort"])
+	assert.Equal(t, "127.0.0.1", data["VNCIP"])
+	assert.Equal(t, "5901", data["VNCPort"])
+	assert.Equal(t, "21", data["HardwareVersion"])
+	assert.Equal(t, "ubuntu-64", data["GuestOSType"])
+}
+
+func TestStepGeneratedData_unknown(t *testing.T) {
+	state := testState(t)
+	state.Put("vmx_path", testVMXFile(t))
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.GuestAddressErr = errors.New("unable to determine MAC address")
+
+	step := new(StepGeneratedData)
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	data := state.Get("generated_data").(map[string]interface{})
+	for _, key := range GeneratedDataKeys() {
+		assert.Contains(t, data, key)
+	}
+
+	// The IP address reported by VMware Tools is used if the communicator
+	// did not connect to the guest.
+	assert.Equal(t, "192.168.1.100", data["GuestIP"])
+	assert.Equal(t, "", data["GuestMAC"])
+	assert.Equal(t, "", data["HTTPPort"])
+	assert.Equal(t, "", data["VNCPort"])
+	assert.Equal(t, "", data["HardwareVersion"])
+}
@@ -0,0 +1,156 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// natPortForwardInterval is the interval at which the IP address of the guest
+// is looked up before the port forward is added.
+var natPortForwardInterval = 5 * time.Second
+
+// StepNatPortForward forwards a port on the host to the communicator port of
+// the guest using the NAT service of the virtual network, so that the guest is
+// reached at 127.0.0.1 when the host cannot route to the virtual network. The
+// port forward is removed during cleanup.
+//
+// Uses:
+// driver Driver
+// ui     packersdk.Ui
+// vmnetwork string
+//
+// Produces:
+// nat_port_forward_host_port int - The forwarded port on the host.
+type StepNatPortForward struct {
+	Config *SSHConfig
+
+	forward *NatPortForward
+}
+
+// Run waits for the IP address of the guest, adds the port forward, and
+// restarts the NAT service.
+func (s *StepNatPortForward) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	if !s.Config.NATPortForward {
+		return multistep.ActionContinue
+	}
+
+	ui := state.Get("ui").(packersdk.Ui)
+	driver := state.Get("driver").(Driver).GetVmwareDriver()
+
+	halt := func(err error) multistep.StepAction {
+		err = fmt.Errorf("error forwarding host port to guest: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	network := state.Get("vmnetwork").(string)
+	if strings.EqualFold(network, "bridged") {
+		return halt(errors.New("not supported for bridged networks"))
+	}
+
+	device, err := networkDevice(driver, network)
+	if err != nil {
+		return halt(err)
+	}
+
+	hostPort := s.Config.NATPortForwardHostPort
+	if hostPort == 0 {
+		if hostPort, err = freeHostPort(); err != nil {
+			return halt(err)
+		}
+	}
+
+	ui.Say("Waiting for the guest IP address to forward a host port...")
+	guestAddress, err := s.waitForGuestAddress(ctx, state)
+	if err != nil {
+		return halt(err)
+	}
+
+	guestPort := s.Config.Comm.Port()
+	s.forward, err = AddNatPortForward(driver, device, hostPort, guestAddress, guestPort)
+	if err != nil {
+		return halt(err)
+	}
+	ui.Sayf("Forwarded %s:%d to %s:%d on %s.", natPortForwardHost, hostPort, guestAddress, guestPort, device)
+
+	if driver.RestartNatService == nil {
+		return halt(fmt.Errorf("unable to restart the NAT service for %s", device))
+	}
+	log.Printf("[INFO] Restarting NAT service for %s", device)
+	if err := driver.RestartNatService(device); err != nil {
+		return halt(fmt.Errorf("error restarting the NAT service for %s: %s", device, err))
+	}
+
+	state.Put("nat_port_forward_host_port", hostPort)
+
+	return multistep.ActionContinue
+}
+
+// waitForGuestAddress returns the IP address of the guest, waiting until the
+// address is known or the communicator timeout is reached.
+func (s *StepNatPortForward) waitForGuestAddress(ctx context.Context, state multistep.StateBag) (net.IP, error) {
+	timeout := s.Config.Comm.SSHTimeout
+	if s.Config.Comm.Type == "winrm" {
+		timeout = s.Config.Comm.WinRMTimeout
+	}
+	deadline := time.Now().Add(timeout)
+
+	for {
+		hosts, err := guestAddresses(state)
+		if err == nil {
+			for _, host := range hosts {
+				if addr := net.ParseIP(host); addr != nil {
+					return addr, nil
+				}
+			}
+			err = fmt.Errorf("no valid guest IP address found: %v", hosts)
+		}
+		log.Printf("[DEBUG] Waiting for guest IP address: %s", err)
+
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timeout waiting for guest IP address: %s", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(natPortForwardInterval):
+		}
+	}
+}
+
+// Cleanup removes the port forward and restarts the NAT service.
+func (s *StepNatPortForward) Cleanup(state multistep.StateBag) {
+	if s.forward == nil {
+		return
+	}
+
+	ui := state.Get("ui").(packersdk.Ui)
+	driver := state.Get("driver").(Driver).GetVmwareDriver()
+
+	ui.Say("Removing host port forward...")
+	if err := s.forward.Remove(); err != nil {
+		ui.Errorf("Error removing the port forward of host port %d from %s: %s", s.forward.HostPort, s.forward.ConfPath, err)
+	}
+
+	if driver.RestartNatService == nil {
+		ui.Errorf("Unable to restart the NAT service for %s; restart the service to remove the port forward.", s.forward.Device)
+	} else if err := driver.RestartNatService(s.forward.Device); err != nil {
+		ui.Errorf("Error restarting the NAT service for %s; restart the service to remove the port forward: %s", s.forward.Device, err)
+	}
+
+	s.forward = nil
+}
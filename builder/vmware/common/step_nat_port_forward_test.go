@@ -0,0 +1,99 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/communicator"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStepNatPortForward_impl(t *testing.T) {
+	var _ multistep.Step = new(StepNatPortForward)
+}
+
+func TestStepNatPortForward(t *testing.T) {
+	confPath := filepath.Join(t.TempDir(), "nat.conf")
+	if err := os.WriteFile(confPath, []byte(testNatConf), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	state := testState(t)
+	state.Put("vmnetwork", "vmnet8")
+	driver := state.Get("driver").(*DriverMock)
+	driver.VmnetnatConfPathResult = confPath
+	driver.PotentialGuestIPResult = []string{"172.16.41.129"}
+
+	step := &StepNatPortForward{
+		Config: &SSHConfig{
+			Comm: communicator.Config{
+				Type: "ssh",
+				SSH:  communicator.SSH{SSHPort: 22},
+			},
+			NATPortForward:         true,
+			NATPortForwardHostPort: 2222,
+		},
+	}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); ok {
+		t.Fatal("should NOT have error")
+	}
+
+	assert.Equal(t, 2222, state.Get("nat_port_forward_host_port"))
+	assert.Equal(t, []string{"vmnet8"}, driver.RestartNatServiceDevices)
+
+	contents, err := os.ReadFile(confPath)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assert.True(t, strings.Contains(string(contents), "2222 = 172.16.41.129:22\n"), "port forward should be added")
+
+	step.Cleanup(state)
+
+	contents, err = os.ReadFile(confPath)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assert.Equal(t, testNatConf, string(contents), "port forward should be removed")
+	assert.Equal(t, []string{"vmnet8", "vmnet8"}, driver.RestartNatServiceDevices)
+}
+
+func TestStepNatPortForward_disabled(t *testing.T) {
+	state := testState(t)
+	driver := state.Get("driver").(*DriverMock)
+
+	step := &StepNatPortForward{Config: &SSHConfig{}}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	_, ok := state.GetOk("nat_port_forward_host_port")
+	assert.False(t, ok, "host port should not be set")
+
+	step.Cleanup(state)
+	assert.Empty(t, driver.RestartNatServiceDevices)
+}
+
+func TestStepNatPortForward_bridged(t *testing.T) {
+	state := testState(t)
+	state.Put("vmnetwork", "bridged")
+
+	step := &StepNatPortForward{Config: &SSHConfig{NATPortForward: true}}
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("should have error")
+	}
+}
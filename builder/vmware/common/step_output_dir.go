@@ -81,12 +81,19 @@ func (s *StepOutputDir) Run(ctx context.Context, state multistep.StateBag) multi
 	return multistep.ActionContinue
 }
 
-// Cleanup removes the output directory if the build was cancelled or halted.
+// Cleanup removes the backup of the .vmx file and removes the output directory
+// if the build was cancelled or halted.
 func (s *StepOutputDir) Cleanup(state multistep.StateBag) {
 	if !s.success {
 		return
 	}
 
+	if vmxPath, ok := state.GetOk("vmx_path"); ok {
+		if err := RemoveVMXBackup(vmxPath.(string)); err != nil {
+			log.Printf("[WARN] Failed to remove VMX backup: %s", err)
+		}
+	}
+
 	_, cancelled := state.GetOk(multistep.StateCancelled)
 	_, halted := state.GetOk(multistep.StateHalted)
 
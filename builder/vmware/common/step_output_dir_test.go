@@ -7,6 +7,7 @@ package common
 import (
 	"context"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
@@ -193,3 +194,35 @@ func TestStepOutputDir_halt(t *testing.T) {
 		t.Fatal("directory should not exist")
 	}
 }
+
+func TestStepOutputDir_removeVMXBackup(t *testing.T) {
+	state := testState(t)
+	td := testOutputDir(t)
+	defer os.RemoveAll(td)
+
+	step := &StepOutputDir{
+		OutputConfig: &OutputConfig{OutputDir: td},
+		VMName:       "testVM",
+	}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	vmxPath := filepath.Join(td, "testVM.vmx")
+	if err := os.WriteFile(vmxPath, []byte("a = \"1\"\n"), 0644); err != nil { //nolint:gosec
+		t.Fatalf("err: %s", err)
+	}
+	if err := WriteVMXDocument(vmxPath, ParseVMXDocument("a = \"2\"\n")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	state.Put("vmx_path", vmxPath)
+
+	step.Cleanup(state)
+	if _, err := os.Stat(VMXBackupPath(vmxPath)); !os.IsNotExist(err) {
+		t.Fatal("VMX backup should be removed")
+	}
+	if _, err := os.Stat(vmxPath); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
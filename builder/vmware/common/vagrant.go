@@ -0,0 +1,160 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+// vagrantProvider is the Vagrant provider for boxes built by the plugin.
+const vagrantProvider = "vmware_desktop"
+
+// vagrantBoxExtension is the file extension of a Vagrant box.
+const vagrantBoxExtension = ".box"
+
+// vagrantfileTemplateData is the data available to the Vagrantfile template.
+type vagrantfileTemplateData struct {
+	// The name of the virtual machine.
+	Name string
+}
+
+// vagrantBoxFiles returns the files of the virtual machine in the directory
+// that are included in a Vagrant box.
+func vagrantBoxFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, entry := range entries {
+		if entry.IsDir() || !slices.Contains(skipCleanFileExtensions, filepath.Ext(entry.Name())) {
+			continue
+		}
+		files = append(files, filepath.Join(dir, entry.Name()))
+	}
+
+	return files, nil
+}
+
+// renderVagrantfile renders the Vagrantfile template at the path.
+func renderVagrantfile(path string, vmName string) (string, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("error reading Vagrantfile template: %s", err)
+	}
+
+	ctx := &interpolate.Context{
+		Data: &vagrantfileTemplateData{Name: vmName},
+	}
+	vagrantfile, err := interpolate.Render(string(contents), ctx)
+	if err != nil {
+		return "", fmt.Errorf("error rendering Vagrantfile template: %s", err)
+	}
+
+	return vagrantfile, nil
+}
+
+// PackVagrantBox packages the virtual machine files into a Vagrant box for the
+// vmware_desktop provider at boxPath. The box includes a metadata.json file
+// and, if not empty, the Vagrantfile. The files are streamed into the box, so
+// they are never held in memory.
+func PackVagrantBox(files []string, vagrantfile string, boxPath string) error {
+	metadata, err := json.Marshal(map[string]string{"provider": vagrantProvider})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[INFO] Packaging Vagrant box: %s", boxPath)
+	f, err := os.Create(boxPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gw)
+	now := time.Now().Truncate(time.Second)
+
+	writeContents := func(name string, contents []byte) error {
+		header := &tar.Header{
+			Name:    name,
+			Mode:    0644,
+			Size:    int64(len(contents)),
+			ModTime: now,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return fmt.Errorf("error writing %s to box: %s", name, err)
+		}
+		if _, err := tw.Write(contents); err != nil {
+			return fmt.Errorf("error writing %s to box: %s", name, err)
+		}
+		return nil
+	}
+
+	if err := writeContents("metadata.json", metadata); err != nil {
+		return err
+	}
+	if vagrantfile != "" {
+		if err := writeContents("Vagrantfile", []byte(vagrantfile)); err != nil {
+			return err
+		}
+	}
+
+	for _, path := range files {
+		if err := writeVagrantBoxFile(tw, path, now); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gw.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// writeVagrantBoxFile streams the file into the box.
+func writeVagrantBoxFile(tw *tar.Writer, path string, modTime time.Time) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	name := filepath.Base(path)
+	header := &tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    info.Size(),
+		ModTime: modTime,
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("error writing %s to box: %s", name, err)
+	}
+	if _, err := io.CopyN(tw, f, info.Size()); err != nil {
+		return fmt.Errorf("error writing %s to box: %s", name, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,97 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// readVagrantBox returns the contents of the files in the Vagrant box.
+func readVagrantBox(t *testing.T, path string) map[string]string {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer f.Close()
+
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	contents := make(map[string]string)
+	tr := tar.NewReader(gr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		contents[header.Name] = string(data)
+	}
+
+	return contents
+}
+
+func TestVagrantBoxFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"vm.vmx", "vm.vmdk", "vm.nvram", "vmware.log", "vm.box"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0644); err != nil { //nolint:gosec
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	files, err := vagrantBoxFiles(dir)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	assert.Equal(t, []string{
+		filepath.Join(dir, "vm.nvram"),
+		filepath.Join(dir, "vm.vmdk"),
+		filepath.Join(dir, "vm.vmx"),
+	}, files)
+}
+
+func TestPackVagrantBox(t *testing.T) {
+	dir := t.TempDir()
+	vmxPath := filepath.Join(dir, "vm.vmx")
+	if err := os.WriteFile(vmxPath, []byte(`displayname = "vm"`), 0644); err != nil { //nolint:gosec
+		t.Fatalf("err: %s", err)
+	}
+
+	templatePath := filepath.Join(dir, "Vagrantfile.tpl")
+	if err := os.WriteFile(templatePath, []byte(`config.vm.define "{{ .Name }}"`), 0644); err != nil { //nolint:gosec
+		t.Fatalf("err: %s", err)
+	}
+	vagrantfile, err := renderVagrantfile(templatePath, "packer-vm")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	boxPath := filepath.Join(dir, "vm.box")
+	if err := PackVagrantBox([]string{vmxPath}, vagrantfile, boxPath); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	assert.Equal(t, map[string]string{
+		"metadata.json": `{"provider":"vmware_desktop"}`,
+		"Vagrantfile":   `config.vm.define "packer-vm"`,
+		"vm.vmx":        `displayname = "vm"`,
+	}, readVagrantBox(t, boxPath))
+}
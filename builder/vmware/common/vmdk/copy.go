@@ -0,0 +1,132 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+package vmdk
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Copy copies the virtual disk at the source path to the destination path.
+// The extent files of a virtual disk with a text descriptor are copied next
+// to the destination path and renamed for the destination. Delta disks are not
+// copied, because the copy would share the parent of the source.
+func Copy(src string, dst string) error {
+	info, err := Stat(src)
+	if err != nil {
+		return err
+	}
+	if info.Descriptor.HasParent() {
+		return fmt.Errorf("error copying virtual disk %s: delta disks are not supported", src)
+	}
+	if _, err := os.Stat(dst); err == nil {
+		return fmt.Errorf("virtual disk already exists: %s", dst)
+	}
+
+	// A virtual disk with an embedded descriptor is a single file.
+	if !slices.ContainsFunc(info.Extents, func(e ExtentInfo) bool { return e.Path != "" && e.Path != src }) {
+		return copyFile(src, dst)
+	}
+
+	d := *info.Descriptor
+	d.Extents = slices.Clone(d.Extents)
+
+	dir := filepath.Dir(dst)
+	srcBase := strings.TrimSuffix(filepath.Base(src), filepath.Ext(src))
+	dstBase := strings.TrimSuffix(filepath.Base(dst), filepath.Ext(dst))
+
+	// An extent file shared by several extents is copied once.
+	names := make(map[string]string)
+	var created []string
+	for i, e := range info.Extents {
+		if e.Path == "" {
+			continue
+		}
+		name, ok := names[e.Path]
+		if !ok {
+			name = filepath.Base(e.Path)
+			if rest, found := strings.CutPrefix(name, srcBase); found {
+				name = dstBase + rest
+			} else {
+				name = fmt.Sprintf("%s-extent%d.vmdk", dstBase, len(names)+1)
+			}
+			if err := copyFile(e.Path, filepath.Join(dir, name)); err != nil {
+				removeFiles(created)
+				return fmt.Errorf("error copying extent %s: %s", e.Filename, err)
+			}
+			names[e.Path] = name
+			created = append(created, filepath.Join(dir, name))
+		}
+		d.Extents[i].Filename = name
+	}
+
+	if err := os.WriteFile(dst, []byte(d.String()), 0o644); err != nil { //nolint:gosec
+		removeFiles(created)
+		return err
+	}
+
+	return nil
+}
+
+// Remove removes the descriptor and the extent files of the virtual disk at
+// the path. The parent of a delta disk is not removed.
+func Remove(path string) error {
+	d, err := ReadDescriptor(path)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(path)
+	for _, e := range d.Extents {
+		if e.Type == ExtentTypeZero {
+			continue
+		}
+		extentPath := filepath.Join(dir, e.Filename)
+		if extentPath == path {
+			continue
+		}
+		if err := os.Remove(extentPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return os.Remove(path)
+}
+
+// copyFile copies the file at the source path to a new file at the
+// destination path.
+func copyFile(src string, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644) //nolint:gosec
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(dst)
+		return err
+	}
+	return nil
+}
+
+// removeFiles removes the files, ignoring errors.
+func removeFiles(paths []string) {
+	for _, p := range paths {
+		os.Remove(p)
+	}
+}
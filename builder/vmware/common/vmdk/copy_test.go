@@ -0,0 +1,96 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+package vmdk
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCopy(t *testing.T) {
+	tc := []struct {
+		name     string
+		diskType int
+		files    []string
+	}{
+		{"monolithic sparse", DiskTypeMonolithicSparse, []string{"data.vmdk"}},
+		{"split sparse", DiskTypeSplitSparse, []string{"data-s001.vmdk", "data.vmdk"}},
+		{"monolithic flat", DiskTypeMonolithicFlat, []string{"data-flat.vmdk", "data.vmdk"}},
+		{"stream optimized", DiskTypeStreamOptimized, []string{"data.vmdk"}},
+	}
+
+	for _, c := range tc {
+		t.Run(c.name, func(t *testing.T) {
+			src := filepath.Join(t.TempDir(), "source.vmdk")
+			if err := Create(src, 4*1024*1024, c.diskType, "lsilogic"); err != nil {
+				t.Fatalf("err: %s", err)
+			}
+
+			dir := t.TempDir()
+			dst := filepath.Join(dir, "data.vmdk")
+			if err := Copy(src, dst); err != nil {
+				t.Fatalf("err: %s", err)
+			}
+
+			var files []string
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			for _, entry := range entries {
+				files = append(files, entry.Name())
+			}
+			assert.Equal(t, c.files, files)
+
+			info, err := Stat(dst)
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			assert.Equal(t, int64(4*1024*1024), info.Capacity())
+
+			assert.Error(t, Copy(src, dst), "should not overwrite the destination")
+
+			if err := Remove(dst); err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			entries, err = os.ReadDir(dir)
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			assert.Empty(t, entries)
+		})
+	}
+}
+
+func TestCopy_parent(t *testing.T) {
+	dir := t.TempDir()
+	parent := filepath.Join(dir, "parent.vmdk")
+	if err := Create(parent, 1024*1024, DiskTypeMonolithicSparse, "lsilogic"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	pd, err := ReadDescriptor(parent)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := Create(filepath.Join(dir, "child.vmdk"), 1024*1024, DiskTypeMonolithicFlat, "lsilogic"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	cd, err := ReadDescriptor(filepath.Join(dir, "child.vmdk"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	cd.ParentCID = pd.CID
+	cd.ParentFileNameHint = "parent.vmdk"
+	if err := os.WriteFile(filepath.Join(dir, "child.vmdk"), []byte(cd.String()), 0o644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	err = Copy(filepath.Join(dir, "child.vmdk"), filepath.Join(t.TempDir(), "copy.vmdk"))
+	assert.ErrorContains(t, err, "delta disks are not supported")
+}
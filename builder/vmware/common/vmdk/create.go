@@ -0,0 +1,315 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+package vmdk
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Virtual disk types, as specified by the `-t` option of the virtual disk
+// manager.
+const (
+	// DiskTypeMonolithicSparse is a growable virtual disk contained in a
+	// single file.
+	DiskTypeMonolithicSparse = 0
+	// DiskTypeSplitSparse is a growable virtual disk split into 2GB files.
+	DiskTypeSplitSparse = 1
+	// DiskTypeMonolithicFlat is a preallocated virtual disk contained in a
+	// single file.
+	DiskTypeMonolithicFlat = 2
+	// DiskTypeSplitFlat is a preallocated virtual disk split into 2GB files.
+	DiskTypeSplitFlat = 3
+	// DiskTypeVmfsFlat is a preallocated virtual disk compatible with ESXi.
+	DiskTypeVmfsFlat = 4
+	// DiskTypeStreamOptimized is a compressed virtual disk optimized for
+	// streaming.
+	DiskTypeStreamOptimized = 5
+)
+
+const (
+	// splitExtentSectors is the maximum size of an extent of a split virtual
+	// disk in sectors.
+	splitExtentSectors = 4192256
+
+	// embeddedDescriptorSectors is the size of the descriptor embedded in a
+	// monolithic sparse extent in sectors.
+	embeddedDescriptorSectors = 20
+
+	// flagRedundantGrainTable indicates that a sparse extent has a redundant
+	// grain directory and grain tables.
+	flagRedundantGrainTable uint32 = 1 << 1
+
+	// virtualHWVersion is the virtual hardware version recorded in the disk
+	// database of a created virtual disk.
+	virtualHWVersion = "4"
+)
+
+// ParseDiskType parses a virtual disk type, as specified by the `-t` option
+// of the virtual disk manager.
+func ParseDiskType(s string) (int, error) {
+	diskType, err := strconv.Atoi(s)
+	if err != nil || diskType < DiskTypeMonolithicSparse || diskType > DiskTypeStreamOptimized {
+		return 0, fmt.Errorf("invalid disk type: %s; must be from %d to %d", s, DiskTypeMonolithicSparse, DiskTypeStreamOptimized)
+	}
+	return diskType, nil
+}
+
+// Create creates an empty virtual disk at the path, with the capacity in
+// bytes, the disk type, and the adapter type recorded in the disk database.
+// The capacity is rounded up to a whole number of sectors. The extent files
+// of the virtual disk are created next to the path.
+func Create(path string, capacity int64, diskType int, adapterType string) error {
+	if capacity <= 0 {
+		return errors.New("capacity must be greater than zero")
+	}
+	sectors := (capacity + SectorSize - 1) / SectorSize
+
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("virtual disk already exists: %s", path)
+	}
+
+	d := &Descriptor{
+		Version:   1,
+		Encoding:  "UTF-8",
+		CID:       rand.Uint32(),
+		ParentCID: NoParentCID,
+		DDB:       diskDatabase(sectors, adapterType),
+	}
+
+	dir := filepath.Dir(path)
+	base := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+
+	switch diskType {
+	case DiskTypeMonolithicSparse:
+		d.CreateType = CreateTypeMonolithicSparse
+		d.Extents = []Extent{{Access: "RW", Sectors: sectors, Type: ExtentTypeSparse, Filename: filepath.Base(path)}}
+		return createSparseExtent(path, sectors, d)
+	case DiskTypeSplitSparse:
+		d.CreateType = CreateTypeTwoGbMaxExtentSparse
+		d.Extents = splitExtents(base, "s", sectors, ExtentTypeSparse)
+	case DiskTypeMonolithicFlat:
+		d.CreateType = CreateTypeMonolithicFlat
+		d.Extents = []Extent{{Access: "RW", Sectors: sectors, Type: ExtentTypeFlat, Filename: base + "-flat.vmdk"}}
+	case DiskTypeSplitFlat:
+		d.CreateType = CreateTypeTwoGbMaxExtentFlat
+		d.Extents = splitExtents(base, "f", sectors, ExtentTypeFlat)
+	case DiskTypeVmfsFlat:
+		d.CreateType = CreateTypeVmfs
+		d.Extents = []Extent{{Access: "RW", Sectors: sectors, Type: ExtentTypeVmfs, Filename: base + "-flat.vmdk"}}
+	case DiskTypeStreamOptimized:
+		return createStreamOptimized(path, sectors*SectorSize, d.DDB)
+	default:
+		return fmt.Errorf("unsupported disk type: %d", diskType)
+	}
+
+	var created []string
+	for _, e := range d.Extents {
+		extentPath := filepath.Join(dir, e.Filename)
+		var err error
+		if e.Type == ExtentTypeSparse {
+			err = createSparseExtent(extentPath, e.Sectors, nil)
+		} else {
+			err = createFlatExtent(extentPath, e.Sectors)
+		}
+		if err != nil {
+			for _, p := range created {
+				os.Remove(p)
+			}
+			return fmt.Errorf("error creating extent %s: %s", e.Filename, err)
+		}
+		created = append(created, extentPath)
+	}
+
+	if err := os.WriteFile(path, []byte(d.String()), 0o644); err != nil { //nolint:gosec
+		for _, p := range created {
+			os.Remove(p)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// diskDatabase returns the disk database of a virtual disk with the capacity
+// in sectors and the adapter type. The adapter types of the controllers that
+// are not recorded in a disk database, such as `sata` and `nvme`, are recorded
+// as `ide` and `lsilogic`.
+func diskDatabase(sectors int64, adapterType string) map[string]string {
+	var heads, maxCylinders int64 = 255, 65535
+	switch strings.ToLower(adapterType) {
+	case "ide", "sata":
+		adapterType = "ide"
+		heads, maxCylinders = 16, 16383
+	case "buslogic":
+		adapterType = "buslogic"
+	default:
+		adapterType = "lsilogic"
+	}
+
+	const sectorsPerTrack = 63
+	cylinders := min(sectors/(heads*sectorsPerTrack), maxCylinders)
+
+	return map[string]string{
+		"ddb.adapterType":        adapterType,
+		"ddb.geometry.cylinders": strconv.FormatInt(cylinders, 10),
+		"ddb.geometry.heads":     strconv.FormatInt(heads, 10),
+		"ddb.geometry.sectors":   strconv.Itoa(sectorsPerTrack),
+		"ddb.virtualHWVersion":   virtualHWVersion,
+	}
+}
+
+// splitExtents returns the extents of a split virtual disk with the capacity
+// in sectors. The extent files are named `<base>-<kind><nnn>.vmdk`.
+func splitExtents(base string, kind string, sectors int64, extentType string) []Extent {
+	var extents []Extent
+	for i := 1; sectors > 0; i++ {
+		size := min(sectors, splitExtentSectors)
+		extents = append(extents, Extent{
+			Access:   "RW",
+			Sectors:  size,
+			Type:     extentType,
+			Filename: fmt.Sprintf("%s-%s%03d.vmdk", base, kind, i),
+		})
+		sectors -= size
+	}
+	return extents
+}
+
+// createFlatExtent creates a flat extent with the size in sectors. The file
+// is extended to the size without writing the data, so the file is sparse on
+// file systems that support sparse files.
+func createFlatExtent(path string, sectors int64) error {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o644) //nolint:gosec
+	if err != nil {
+		return err
+	}
+	if err := f.Truncate(sectors * SectorSize); err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+	return f.Close()
+}
+
+// createSparseExtent creates an empty hosted sparse extent with the capacity
+// in sectors. The descriptor, if any, is embedded in the extent. The extent
+// has a redundant grain directory, and the grain tables of both grain
+// directories are allocated, like the extents created by the virtual disk
+// manager.
+func createSparseExtent(path string, sectors int64, d *Descriptor) error {
+	var descriptor []byte
+	var descriptorSectors uint64
+	if d != nil {
+		descriptor = []byte(d.String())
+		descriptorSectors = embeddedDescriptorSectors
+		if len(descriptor) > embeddedDescriptorSectors*SectorSize {
+			return errors.New("descriptor is too large to be embedded")
+		}
+	}
+
+	numGrains := (sectors + defaultGrainSize - 1) / defaultGrainSize
+	numGTs := (numGrains + defaultNumGTEsPerGT - 1) / defaultNumGTEsPerGT
+	gdSectors := (numGTs*4 + SectorSize - 1) / SectorSize
+	gtSectors := int64(defaultNumGTEsPerGT * 4 / SectorSize)
+
+	// The redundant grain directory and its grain tables follow the
+	// descriptor, followed by the grain directory and its grain tables. The
+	// grains begin at the first grain boundary after the metadata.
+	rgdOffset := int64(1 + descriptorSectors)
+	gdOffset := rgdOffset + gdSectors + numGTs*gtSectors
+	metadataEnd := gdOffset + gdSectors + numGTs*gtSectors
+	overhead := (metadataEnd + defaultGrainSize - 1) / defaultGrainSize * defaultGrainSize
+
+	header := SparseExtentHeader{
+		MagicNumber:        sparseMagic,
+		Version:            1,
+		Flags:              flagValidNewLineDetection | flagRedundantGrainTable,
+		Capacity:           uint64(sectors),
+		GrainSize:          defaultGrainSize,
+		NumGTEsPerGT:       defaultNumGTEsPerGT,
+		RgdOffset:          uint64(rgdOffset),
+		GdOffset:           uint64(gdOffset),
+		OverHead:           uint64(overhead),
+		SingleEndLineChar:  '\n',
+		NonEndLineChar:     ' ',
+		DoubleEndLineChar1: '\r',
+		DoubleEndLineChar2: '\n',
+	}
+	if d != nil {
+		header.DescriptorOffset = 1
+		header.DescriptorSize = descriptorSectors
+	}
+
+	buf := make([]byte, overhead*SectorSize)
+	w := &sliceWriter{buf: buf}
+	if err := binary.Write(w, binary.LittleEndian, &header); err != nil {
+		return err
+	}
+	copy(buf[SectorSize:], descriptor)
+
+	for _, offset := range []int64{rgdOffset, gdOffset} {
+		gd := buf[offset*SectorSize:]
+		for i := int64(0); i < numGTs; i++ {
+			gt := offset + gdSectors + i*gtSectors
+			binary.LittleEndian.PutUint32(gd[i*4:], uint32(gt))
+		}
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o644) //nolint:gosec
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(buf); err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+	return f.Close()
+}
+
+// createStreamOptimized creates an empty stream-optimized virtual disk with
+// the capacity in bytes.
+func createStreamOptimized(path string, capacity int64, ddb map[string]string) error {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o644) //nolint:gosec
+	if err != nil {
+		return err
+	}
+	if _, err := WriteStreamOptimized(f, filepath.Base(path), zeroReader{}, capacity, ddb); err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+	return f.Close()
+}
+
+// sliceWriter writes to the beginning of a buffer.
+type sliceWriter struct {
+	buf []byte
+	n   int
+}
+
+func (w *sliceWriter) Write(p []byte) (int, error) {
+	n := copy(w.buf[w.n:], p)
+	w.n += n
+	if n < len(p) {
+		return n, errors.New("buffer is full")
+	}
+	return n, nil
+}
+
+// zeroReader reads zeros at any offset.
+type zeroReader struct{}
+
+func (zeroReader) ReadAt(p []byte, off int64) (int, error) {
+	clear(p)
+	return len(p), nil
+}
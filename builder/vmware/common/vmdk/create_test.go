@@ -0,0 +1,134 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+package vmdk
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreate(t *testing.T) {
+	// The capacity spans two extents of a split virtual disk.
+	const capacity = splitExtentSectors*SectorSize + 1024*1024
+
+	tc := []struct {
+		name       string
+		diskType   int
+		createType string
+		extents    []string
+	}{
+		{"monolithic sparse", DiskTypeMonolithicSparse, CreateTypeMonolithicSparse, []string{"disk.vmdk"}},
+		{"split sparse", DiskTypeSplitSparse, CreateTypeTwoGbMaxExtentSparse, []string{"disk-s001.vmdk", "disk-s002.vmdk"}},
+		{"monolithic flat", DiskTypeMonolithicFlat, CreateTypeMonolithicFlat, []string{"disk-flat.vmdk"}},
+		{"split flat", DiskTypeSplitFlat, CreateTypeTwoGbMaxExtentFlat, []string{"disk-f001.vmdk", "disk-f002.vmdk"}},
+		{"vmfs flat", DiskTypeVmfsFlat, CreateTypeVmfs, []string{"disk-flat.vmdk"}},
+		{"stream optimized", DiskTypeStreamOptimized, CreateTypeStreamOptimized, []string{"disk.vmdk"}},
+	}
+
+	for _, c := range tc {
+		t.Run(c.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "disk.vmdk")
+			if err := Create(path, capacity, c.diskType, "lsilogic"); err != nil {
+				t.Fatalf("err: %s", err)
+			}
+
+			info, err := Stat(path)
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			assert.Equal(t, c.createType, info.CreateType())
+			assert.Equal(t, int64(capacity), info.Capacity())
+			assert.Equal(t, "lsilogic", info.Descriptor.DDB["ddb.adapterType"])
+
+			var extents []string
+			for _, e := range info.Extents {
+				extents = append(extents, filepath.Base(e.Path))
+			}
+			assert.Equal(t, c.extents, extents)
+
+			d, err := Open(path)
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			defer d.Close()
+
+			assert.Equal(t, int64(capacity), d.Capacity())
+			buf := make([]byte, 64*1024)
+			for _, off := range []int64{0, splitExtentSectors*SectorSize - 1024, capacity - int64(len(buf))} {
+				buf[0] = 0xff
+				if _, err := d.ReadAt(buf, off); err != nil {
+					t.Fatalf("err: %s", err)
+				}
+				assert.True(t, isZero(buf), "offset %d", off)
+			}
+		})
+	}
+}
+
+func TestCreate_sparseLayout(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "disk.vmdk")
+	if err := Create(path, 1024*1024*1024, DiskTypeMonolithicSparse, "ide"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer f.Close()
+
+	h, err := readSparseExtentHeader(f, 0)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assert.Equal(t, uint64(2097152), h.Capacity)
+	assert.Equal(t, uint64(1), h.DescriptorOffset)
+	assert.Equal(t, uint64(embeddedDescriptorSectors), h.DescriptorSize)
+	assert.Equal(t, uint64(21), h.RgdOffset)
+	assert.Greater(t, h.GdOffset, h.RgdOffset)
+	assert.Zero(t, h.OverHead%defaultGrainSize)
+
+	info, err := f.Stat()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assert.Equal(t, int64(h.OverHead*SectorSize), info.Size())
+
+	d, err := ReadDescriptor(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assert.Equal(t, "ide", d.DDB["ddb.adapterType"])
+	assert.Equal(t, "16", d.DDB["ddb.geometry.heads"])
+	assert.Equal(t, "2080", d.DDB["ddb.geometry.cylinders"])
+	assert.False(t, d.HasParent())
+}
+
+func TestCreate_errors(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "disk.vmdk")
+
+	assert.Error(t, Create(path, 0, DiskTypeMonolithicSparse, "lsilogic"))
+	assert.Error(t, Create(path, 1024, 6, "lsilogic"))
+
+	if err := Create(path, 1024, DiskTypeMonolithicFlat, "lsilogic"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assert.Error(t, Create(path, 1024, DiskTypeMonolithicFlat, "lsilogic"))
+}
+
+func TestParseDiskType(t *testing.T) {
+	diskType, err := ParseDiskType("1")
+	assert.NoError(t, err)
+	assert.Equal(t, DiskTypeSplitSparse, diskType)
+
+	for _, s := range []string{"", "-1", "6", "sparse"} {
+		_, err := ParseDiskType(s)
+		assert.Error(t, err, s)
+	}
+}
@@ -0,0 +1,216 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package vmdk reads and writes VMware virtual disk (VMDK) files.
+package vmdk
+
+import (
+	"bufio"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// SectorSize is the size of a virtual disk sector in bytes.
+	SectorSize = 512
+
+	// NoParentCID is the parent content ID of a virtual disk without a parent.
+	NoParentCID uint32 = 0xffffffff
+)
+
+// Virtual disk create types.
+const (
+	CreateTypeMonolithicSparse     = "monolithicSparse"
+	CreateTypeMonolithicFlat       = "monolithicFlat"
+	CreateTypeTwoGbMaxExtentSparse = "twoGbMaxExtentSparse"
+	CreateTypeTwoGbMaxExtentFlat   = "twoGbMaxExtentFlat"
+	CreateTypeStreamOptimized      = "streamOptimized"
+	CreateTypeVmfs                 = "vmfs"
+)
+
+// Extent types.
+const (
+	ExtentTypeFlat   = "FLAT"
+	ExtentTypeSparse = "SPARSE"
+	ExtentTypeZero   = "ZERO"
+	ExtentTypeVmfs   = "VMFS"
+)
+
+// Extent describes a region of a virtual disk backed by a file.
+type Extent struct {
+	// Access is the access mode of the extent: RW, RDONLY, or NOACCESS.
+	Access string
+	// Sectors is the size of the extent in sectors.
+	Sectors int64
+	// Type is the extent type: FLAT, SPARSE, ZERO, or VMFS.
+	Type string
+	// Filename is the path of the extent file, relative to the descriptor.
+	Filename string
+	// Offset is the offset of the extent data in the file, in sectors. Only
+	// used for flat extents.
+	Offset int64
+}
+
+// Descriptor is the text descriptor of a virtual disk.
+type Descriptor struct {
+	Version            int
+	Encoding           string
+	CID                uint32
+	ParentCID          uint32
+	CreateType         string
+	ParentFileNameHint string
+	Extents            []Extent
+	// DDB is the disk database, keyed by the full key name, such as
+	// "ddb.adapterType".
+	DDB map[string]string
+}
+
+var extentLineRe = regexp.MustCompile(`^(RW|RDONLY|NOACCESS)\s+(\d+)\s+(\w+)(?:\s+"(.*)"(?:\s+(\d+))?)?$`)
+
+// ParseDescriptor parses the text descriptor of a virtual disk.
+func ParseDescriptor(contents string) (*Descriptor, error) {
+	d := &Descriptor{
+		ParentCID: NoParentCID,
+		DDB:       make(map[string]string),
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(contents))
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.TrimRight(scanner.Text(), "\x00"))
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if matches := extentLineRe.FindStringSubmatch(line); matches != nil {
+			sectors, _ := strconv.ParseInt(matches[2], 10, 64)
+			extent := Extent{
+				Access:   matches[1],
+				Sectors:  sectors,
+				Type:     matches[3],
+				Filename: matches[4],
+			}
+			if matches[5] != "" {
+				extent.Offset, _ = strconv.ParseInt(matches[5], 10, 64)
+			}
+			d.Extents = append(d.Extents, extent)
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid descriptor line: %q", line)
+		}
+		key = strings.TrimSpace(key)
+		value = strings.Trim(strings.TrimSpace(value), `"`)
+
+		switch {
+		case key == "version":
+			v, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid descriptor version: %q", value)
+			}
+			d.Version = v
+		case key == "encoding":
+			d.Encoding = value
+		case key == "CID":
+			cid, err := strconv.ParseUint(value, 16, 32)
+			if err != nil {
+				return nil, fmt.Errorf("invalid descriptor CID: %q", value)
+			}
+			d.CID = uint32(cid)
+		case key == "parentCID":
+			cid, err := strconv.ParseUint(value, 16, 32)
+			if err != nil {
+				return nil, fmt.Errorf("invalid descriptor parentCID: %q", value)
+			}
+			d.ParentCID = uint32(cid)
+		case key == "createType":
+			d.CreateType = value
+		case key == "parentFileNameHint":
+			d.ParentFileNameHint = value
+		case strings.HasPrefix(key, "ddb."):
+			d.DDB[key] = value
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(d.Extents) == 0 {
+		return nil, fmt.Errorf("descriptor does not define any extents")
+	}
+
+	return d, nil
+}
+
+// HasParent reports whether the virtual disk is a delta disk of a parent.
+func (d *Descriptor) HasParent() bool {
+	return d.ParentCID != NoParentCID && d.ParentFileNameHint != ""
+}
+
+// Sectors returns the capacity of the virtual disk in sectors.
+func (d *Descriptor) Sectors() int64 {
+	var sectors int64
+	for _, e := range d.Extents {
+		sectors += e.Sectors
+	}
+	return sectors
+}
+
+// Capacity returns the capacity of the virtual disk in bytes.
+func (d *Descriptor) Capacity() int64 {
+	return d.Sectors() * SectorSize
+}
+
+// String returns the text representation of the descriptor.
+func (d *Descriptor) String() string {
+	var b strings.Builder
+
+	encoding := d.Encoding
+	if encoding == "" {
+		encoding = "UTF-8"
+	}
+	version := d.Version
+	if version == 0 {
+		version = 1
+	}
+
+	b.WriteString("# Disk DescriptorFile\n")
+	fmt.Fprintf(&b, "version=%d\n", version)
+	fmt.Fprintf(&b, "encoding=%q\n", encoding)
+	fmt.Fprintf(&b, "CID=%08x\n", d.CID)
+	fmt.Fprintf(&b, "parentCID=%08x\n", d.ParentCID)
+	fmt.Fprintf(&b, "createType=%q\n", d.CreateType)
+	if d.ParentFileNameHint != "" {
+		fmt.Fprintf(&b, "parentFileNameHint=%q\n", d.ParentFileNameHint)
+	}
+
+	b.WriteString("\n# Extent description\n")
+	for _, e := range d.Extents {
+		fmt.Fprintf(&b, "%s %d %s", e.Access, e.Sectors, e.Type)
+		if e.Type != ExtentTypeZero {
+			fmt.Fprintf(&b, " %q", e.Filename)
+			if e.Offset != 0 {
+				fmt.Fprintf(&b, " %d", e.Offset)
+			}
+		}
+		b.WriteString("\n")
+	}
+
+	b.WriteString("\n# The Disk Data Base\n#DDB\n\n")
+	keys := make([]string, 0, len(d.DDB))
+	for k := range d.DDB {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&b, "%s = %q\n", k, d.DDB[k])
+	}
+
+	return b.String()
+}
@@ -0,0 +1,78 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+package vmdk
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testDescriptor = `# Disk DescriptorFile
+version=1
+encoding="UTF-8"
+CID=3e4b3e09
+parentCID=ffffffff
+createType="twoGbMaxExtentSparse"
+
+# Extent description
+RW 4192256 SPARSE "disk-s001.vmdk"
+RW 4192256 SPARSE "disk-s002.vmdk"
+RW 2048 ZERO
+
+# The Disk Data Base
+#DDB
+
+ddb.adapterType = "lsilogic"
+ddb.geometry.cylinders = "1305"
+ddb.virtualHWVersion = "21"
+`
+
+func TestParseDescriptor(t *testing.T) {
+	d, err := ParseDescriptor(testDescriptor)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	assert.Equal(t, 1, d.Version)
+	assert.Equal(t, uint32(0x3e4b3e09), d.CID)
+	assert.Equal(t, NoParentCID, d.ParentCID)
+	assert.False(t, d.HasParent())
+	assert.Equal(t, CreateTypeTwoGbMaxExtentSparse, d.CreateType)
+	assert.Equal(t, []Extent{
+		{Access: "RW", Sectors: 4192256, Type: ExtentTypeSparse, Filename: "disk-s001.vmdk"},
+		{Access: "RW", Sectors: 4192256, Type: ExtentTypeSparse, Filename: "disk-s002.vmdk"},
+		{Access: "RW", Sectors: 2048, Type: ExtentTypeZero},
+	}, d.Extents)
+	assert.Equal(t, int64(4192256*2+2048)*SectorSize, d.Capacity())
+	assert.Equal(t, "lsilogic", d.DDB["ddb.adapterType"])
+
+	assert.Equal(t, testDescriptor, d.String())
+}
+
+func TestParseDescriptor_parent(t *testing.T) {
+	d, err := ParseDescriptor(`version=1
+CID=00000002
+parentCID=00000001
+createType="monolithicSparse"
+parentFileNameHint="/vms/source/disk.vmdk"
+RW 2048 FLAT "disk-flat.vmdk" 0
+`)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	assert.True(t, d.HasParent())
+	assert.Equal(t, "/vms/source/disk.vmdk", d.ParentFileNameHint)
+}
+
+func TestParseDescriptor_invalid(t *testing.T) {
+	if _, err := ParseDescriptor("version=1\n"); err == nil {
+		t.Fatal("should have error for a descriptor without extents")
+	}
+	if _, err := ParseDescriptor("not a descriptor\nRW 1 FLAT \"a\"\n"); err == nil {
+		t.Fatal("should have error for an invalid line")
+	}
+}
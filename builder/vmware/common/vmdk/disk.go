@@ -0,0 +1,275 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+package vmdk
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// maxDescriptorSize is the maximum size of a text descriptor file.
+const maxDescriptorSize = 1 << 20
+
+// Disk is a virtual disk opened for reading. The contents of the virtual disk
+// are read as a flat image, regardless of the extent types. Unallocated grains
+// of a delta disk are read from the parent.
+type Disk struct {
+	// Descriptor is the descriptor of the virtual disk.
+	Descriptor *Descriptor
+
+	path    string
+	extents []*diskExtent
+	files   []*os.File
+	parent  *Disk
+}
+
+// diskExtent is an opened extent of a virtual disk.
+type diskExtent struct {
+	start   int64
+	sectors int64
+	file    *os.File
+	offset  int64
+	sparse  *sparseExtent
+
+	// The most recently read grain of a sparse extent.
+	grain     int64
+	grainBuf  []byte
+	allocated bool
+}
+
+// Open opens the virtual disk at the path, which is either a text descriptor
+// or a sparse extent with an embedded descriptor.
+func Open(path string) (*Disk, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &Disk{path: path}
+	if err := d.open(f); err != nil {
+		d.Close()
+		return nil, fmt.Errorf("error opening virtual disk %s: %s", path, err)
+	}
+
+	return d, nil
+}
+
+func (d *Disk) open(f *os.File) error {
+	d.files = append(d.files, f)
+
+	descriptor, embedded, err := readDescriptor(f)
+	if err != nil {
+		return err
+	}
+	d.Descriptor = descriptor
+
+	dir := filepath.Dir(d.path)
+	var start int64
+	for _, e := range d.Descriptor.Extents {
+		extent := &diskExtent{
+			start:   start,
+			sectors: e.Sectors,
+			offset:  e.Offset * SectorSize,
+			grain:   -1,
+		}
+		start += e.Sectors
+
+		switch e.Type {
+		case ExtentTypeZero:
+		case ExtentTypeFlat, ExtentTypeVmfs:
+			ef, err := os.Open(filepath.Join(dir, e.Filename))
+			if err != nil {
+				return err
+			}
+			d.files = append(d.files, ef)
+			extent.file = ef
+		case ExtentTypeSparse:
+			if embedded != nil {
+				extent.sparse = embedded
+				break
+			}
+			ef, err := os.Open(filepath.Join(dir, e.Filename))
+			if err != nil {
+				return err
+			}
+			d.files = append(d.files, ef)
+			if extent.sparse, err = openSparseExtent(ef); err != nil {
+				return fmt.Errorf("error opening extent %s: %s", e.Filename, err)
+			}
+		default:
+			return fmt.Errorf("unsupported extent type: %s", e.Type)
+		}
+
+		d.extents = append(d.extents, extent)
+	}
+
+	if d.Descriptor.HasParent() {
+		parentPath := d.Descriptor.ParentFileNameHint
+		if !filepath.IsAbs(parentPath) {
+			parentPath = filepath.Join(dir, parentPath)
+		}
+		parent, err := Open(parentPath)
+		if err != nil {
+			return fmt.Errorf("error opening parent: %s", err)
+		}
+		d.parent = parent
+	}
+
+	return nil
+}
+
+// readDescriptor reads the descriptor of the virtual disk in the file, which
+// is either a text descriptor or a sparse extent with an embedded descriptor.
+// The sparse extent is returned if the descriptor is embedded.
+func readDescriptor(f *os.File) (*Descriptor, *sparseExtent, error) {
+	if isSparseExtent(f) {
+		sparse, err := openSparseExtent(f)
+		if err != nil {
+			return nil, nil, err
+		}
+		if sparse.header.DescriptorOffset == 0 {
+			return nil, nil, errors.New("sparse extent does not have an embedded descriptor")
+		}
+
+		buf := make([]byte, sparse.header.DescriptorSize*SectorSize)
+		if _, err := f.ReadAt(buf, int64(sparse.header.DescriptorOffset)*SectorSize); err != nil {
+			return nil, nil, fmt.Errorf("error reading embedded descriptor: %s", err)
+		}
+		descriptor, err := ParseDescriptor(string(buf))
+		if err != nil {
+			return nil, nil, err
+		}
+		return descriptor, sparse, nil
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	if info.Size() > maxDescriptorSize {
+		return nil, nil, errors.New("file is not a virtual disk descriptor")
+	}
+	contents, err := io.ReadAll(f)
+	if err != nil {
+		return nil, nil, err
+	}
+	descriptor, err := ParseDescriptor(string(contents))
+	if err != nil {
+		return nil, nil, err
+	}
+	return descriptor, nil, nil
+}
+
+// Capacity returns the capacity of the virtual disk in bytes.
+func (d *Disk) Capacity() int64 {
+	return d.Descriptor.Capacity()
+}
+
+// ReadAt reads the contents of the virtual disk at the offset.
+func (d *Disk) ReadAt(p []byte, off int64) (int, error) {
+	capacity := d.Capacity()
+	if off >= capacity {
+		return 0, io.EOF
+	}
+
+	n := 0
+	for n < len(p) && off < capacity {
+		extent := d.extentAt(off)
+		if extent == nil {
+			return n, fmt.Errorf("no extent at offset %d", off)
+		}
+
+		end := (extent.start + extent.sectors) * SectorSize
+		chunk := p[n:min(len(p), n+int(end-off))]
+
+		read, err := d.readExtent(extent, chunk, off)
+		n += read
+		off += int64(read)
+		if err != nil {
+			return n, err
+		}
+	}
+
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// extentAt returns the extent that contains the offset.
+func (d *Disk) extentAt(off int64) *diskExtent {
+	sector := off / SectorSize
+	for _, e := range d.extents {
+		if sector >= e.start && sector < e.start+e.sectors {
+			return e
+		}
+	}
+	return nil
+}
+
+// readExtent reads from the extent at the offset of the virtual disk. The
+// read does not cross the end of the extent.
+func (d *Disk) readExtent(e *diskExtent, p []byte, off int64) (int, error) {
+	rel := off - e.start*SectorSize
+
+	switch {
+	case e.file != nil:
+		return e.file.ReadAt(p, e.offset+rel)
+	case e.sparse == nil:
+		clear(p)
+		return len(p), nil
+	}
+
+	grainBytes := e.sparse.grainBytes()
+	grain := rel / grainBytes
+	if grain != e.grain {
+		if e.grainBuf == nil {
+			e.grainBuf = make([]byte, grainBytes)
+		}
+		allocated, err := e.sparse.readGrain(grain, e.grainBuf)
+		if err != nil {
+			return 0, err
+		}
+		e.grain = grain
+		e.allocated = allocated
+	}
+
+	start := rel - grain*grainBytes
+	n := min(len(p), int(grainBytes-start))
+
+	switch {
+	case e.allocated:
+		copy(p[:n], e.grainBuf[start:])
+	case d.parent != nil:
+		return d.parent.ReadAt(p[:n], off)
+	default:
+		clear(p[:n])
+	}
+
+	return n, nil
+}
+
+// Close closes the files of the virtual disk and its parents.
+func (d *Disk) Close() error {
+	var err error
+	for _, f := range d.files {
+		if cerr := f.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	d.files = nil
+
+	if d.parent != nil {
+		if perr := d.parent.Close(); perr != nil && err == nil {
+			err = perr
+		}
+		d.parent = nil
+	}
+
+	return err
+}
@@ -0,0 +1,228 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+package vmdk
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+const (
+	// sparseMagic is the magic number of a hosted sparse extent ("KDMV").
+	sparseMagic uint32 = 0x564d444b
+
+	flagValidNewLineDetection uint32 = 1 << 0
+	flagCompressedGrains      uint32 = 1 << 16
+	flagMarkers               uint32 = 1 << 17
+
+	compressionDeflate uint16 = 1
+
+	// gdAtEnd indicates that the grain directory follows the grains and is
+	// located using the footer at the end of the extent.
+	gdAtEnd uint64 = 0xffffffffffffffff
+
+	// defaultGrainSize is the default size of a grain in sectors.
+	defaultGrainSize = 128
+	// defaultNumGTEsPerGT is the default number of entries in a grain table.
+	defaultNumGTEsPerGT = 512
+
+	// Stream-optimized marker types.
+	markerEOS    uint32 = 0
+	markerGT     uint32 = 1
+	markerGD     uint32 = 2
+	markerFooter uint32 = 3
+)
+
+// SparseExtentHeader is the header of a hosted sparse extent.
+type SparseExtentHeader struct {
+	MagicNumber        uint32
+	Version            uint32
+	Flags              uint32
+	Capacity           uint64
+	GrainSize          uint64
+	DescriptorOffset   uint64
+	DescriptorSize     uint64
+	NumGTEsPerGT       uint32
+	RgdOffset          uint64
+	GdOffset           uint64
+	OverHead           uint64
+	UncleanShutdown    uint8
+	SingleEndLineChar  byte
+	NonEndLineChar     byte
+	DoubleEndLineChar1 byte
+	DoubleEndLineChar2 byte
+	CompressAlgorithm  uint16
+	Pad                [433]uint8
+}
+
+// readSparseExtentHeader reads a sparse extent header at the offset.
+func readSparseExtentHeader(r io.ReaderAt, offset int64) (*SparseExtentHeader, error) {
+	buf := make([]byte, SectorSize)
+	if _, err := r.ReadAt(buf, offset); err != nil {
+		return nil, err
+	}
+
+	var h SparseExtentHeader
+	if err := binary.Read(bytes.NewReader(buf), binary.LittleEndian, &h); err != nil {
+		return nil, err
+	}
+	if h.MagicNumber != sparseMagic {
+		return nil, errors.New("not a sparse extent")
+	}
+	if h.GrainSize == 0 || h.NumGTEsPerGT == 0 {
+		return nil, errors.New("invalid sparse extent header")
+	}
+
+	return &h, nil
+}
+
+// isSparseExtent reports whether the file begins with a sparse extent header.
+func isSparseExtent(r io.ReaderAt) bool {
+	buf := make([]byte, 4)
+	if _, err := r.ReadAt(buf, 0); err != nil {
+		return false
+	}
+	return binary.LittleEndian.Uint32(buf) == sparseMagic
+}
+
+// sparseExtent reads the grains of a hosted sparse extent.
+type sparseExtent struct {
+	f      *os.File
+	header *SparseExtentHeader
+	gd     []uint32
+	gts    map[uint32][]uint32
+}
+
+// openSparseExtent reads the header and grain directory of a sparse extent.
+func openSparseExtent(f *os.File) (*sparseExtent, error) {
+	h, err := readSparseExtentHeader(f, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// The grain directory of a stream-optimized extent is located using the
+	// footer, which precedes the end-of-stream marker.
+	if h.GdOffset == gdAtEnd {
+		info, err := f.Stat()
+		if err != nil {
+			return nil, err
+		}
+		h, err = readSparseExtentHeader(f, info.Size()-2*SectorSize)
+		if err != nil {
+			return nil, fmt.Errorf("error reading footer: %s", err)
+		}
+	}
+
+	sectorsPerGT := h.GrainSize * uint64(h.NumGTEsPerGT)
+	numGTs := (h.Capacity + sectorsPerGT - 1) / sectorsPerGT
+
+	gd := make([]uint32, numGTs)
+	buf := make([]byte, numGTs*4)
+	if _, err := f.ReadAt(buf, int64(h.GdOffset)*SectorSize); err != nil {
+		return nil, fmt.Errorf("error reading grain directory: %s", err)
+	}
+	for i := range gd {
+		gd[i] = binary.LittleEndian.Uint32(buf[i*4:])
+	}
+
+	return &sparseExtent{
+		f:      f,
+		header: h,
+		gd:     gd,
+		gts:    make(map[uint32][]uint32),
+	}, nil
+}
+
+// grainBytes returns the size of a grain in bytes.
+func (e *sparseExtent) grainBytes() int64 {
+	return int64(e.header.GrainSize) * SectorSize
+}
+
+// grainTable returns the grain table at the grain directory index.
+func (e *sparseExtent) grainTable(index uint32) ([]uint32, error) {
+	if gt, ok := e.gts[index]; ok {
+		return gt, nil
+	}
+
+	buf := make([]byte, e.header.NumGTEsPerGT*4)
+	if _, err := e.f.ReadAt(buf, int64(e.gd[index])*SectorSize); err != nil {
+		return nil, fmt.Errorf("error reading grain table: %s", err)
+	}
+	gt := make([]uint32, e.header.NumGTEsPerGT)
+	for i := range gt {
+		gt[i] = binary.LittleEndian.Uint32(buf[i*4:])
+	}
+
+	e.gts[index] = gt
+	return gt, nil
+}
+
+// readGrain reads the grain into buf, which must be the size of a grain, and
+// reports whether the grain is allocated.
+func (e *sparseExtent) readGrain(grain int64, buf []byte) (bool, error) {
+	gdIndex := uint32(grain / int64(e.header.NumGTEsPerGT))
+	if int(gdIndex) >= len(e.gd) || e.gd[gdIndex] == 0 {
+		return false, nil
+	}
+
+	gt, err := e.grainTable(gdIndex)
+	if err != nil {
+		return false, err
+	}
+
+	sector := gt[grain%int64(e.header.NumGTEsPerGT)]
+	switch sector {
+	case 0:
+		return false, nil
+	case 1:
+		// The grain is allocated, but zeroed.
+		clear(buf)
+		return true, nil
+	}
+
+	offset := int64(sector) * SectorSize
+	if e.header.Flags&flagCompressedGrains == 0 {
+		if _, err := e.f.ReadAt(buf, offset); err != nil && err != io.EOF {
+			return false, err
+		}
+		return true, nil
+	}
+
+	// A compressed grain is preceded by its logical block address and the
+	// size of the compressed data.
+	marker := make([]byte, 12)
+	if _, err := e.f.ReadAt(marker, offset); err != nil {
+		return false, err
+	}
+	size := int64(binary.LittleEndian.Uint32(marker[8:]))
+
+	zr, err := zlib.NewReader(io.NewSectionReader(e.f, offset+12, size))
+	if err != nil {
+		return false, fmt.Errorf("error decompressing grain: %s", err)
+	}
+	defer zr.Close()
+
+	n, err := io.ReadFull(zr, buf)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return false, fmt.Errorf("error decompressing grain: %s", err)
+	}
+	clear(buf[n:])
+
+	return true, nil
+}
+
+// writeMarker writes a stream-optimized metadata marker.
+func writeMarker(w io.Writer, value uint64, markerType uint32) error {
+	buf := make([]byte, SectorSize)
+	binary.LittleEndian.PutUint64(buf, value)
+	binary.LittleEndian.PutUint32(buf[12:], markerType)
+	_, err := w.Write(buf)
+	return err
+}
@@ -0,0 +1,162 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+package vmdk
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Info describes a virtual disk and the files of its extents.
+type Info struct {
+	// Path is the path to the descriptor of the virtual disk.
+	Path string
+	// Descriptor is the descriptor of the virtual disk.
+	Descriptor *Descriptor
+	// Extents are the extents of the virtual disk, in order.
+	Extents []ExtentInfo
+	// Parent describes the parent of a delta disk.
+	Parent *Info
+}
+
+// ExtentInfo describes an extent of a virtual disk.
+type ExtentInfo struct {
+	Extent
+	// Path is the path to the extent file. Empty for ZERO extents.
+	Path string
+	// Size is the size of the extent file in bytes.
+	Size int64
+}
+
+// CreateType returns the create type of the virtual disk.
+func (i *Info) CreateType() string {
+	return i.Descriptor.CreateType
+}
+
+// Capacity returns the capacity of the virtual disk in bytes.
+func (i *Info) Capacity() int64 {
+	return i.Descriptor.Capacity()
+}
+
+// Size returns the size of the files of the virtual disk in bytes, excluding
+// the parent. An extent file shared by several extents is counted once.
+func (i *Info) Size() int64 {
+	seen := make(map[string]bool)
+	var size int64
+	for _, e := range i.Extents {
+		if e.Path == "" || seen[e.Path] {
+			continue
+		}
+		seen[e.Path] = true
+		size += e.Size
+	}
+	return size
+}
+
+// ReadDescriptor reads the descriptor of the virtual disk at the path, which
+// is either a text descriptor or a sparse extent with an embedded descriptor.
+func ReadDescriptor(path string) (*Descriptor, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	d, _, err := readDescriptor(f)
+	if err != nil {
+		return nil, fmt.Errorf("error reading virtual disk %s: %s", path, err)
+	}
+	return d, nil
+}
+
+// Stat reads the descriptor of the virtual disk at the path and validates the
+// extents of the virtual disk and its parents. An error is returned if an
+// extent file is missing, or if an extent file is smaller than the extent.
+func Stat(path string) (*Info, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	d, embedded, err := readDescriptor(f)
+	if err != nil {
+		return nil, fmt.Errorf("error reading virtual disk %s: %s", path, err)
+	}
+
+	info := &Info{Path: path, Descriptor: d}
+	dir := filepath.Dir(path)
+
+	for _, e := range d.Extents {
+		extent := ExtentInfo{Extent: e}
+
+		switch e.Type {
+		case ExtentTypeZero:
+		case ExtentTypeSparse:
+			if embedded != nil {
+				extent.Path = path
+				if embedded.header.Capacity < uint64(e.Sectors) {
+					return nil, fmt.Errorf("error validating virtual disk %s: extent capacity is %d sectors; expected %d", path, embedded.header.Capacity, e.Sectors)
+				}
+			} else {
+				extent.Path = filepath.Join(dir, e.Filename)
+				if err := validateSparseExtent(extent.Path, e.Sectors); err != nil {
+					return nil, fmt.Errorf("error validating virtual disk %s: %s", path, err)
+				}
+			}
+		case ExtentTypeFlat, ExtentTypeVmfs:
+			extent.Path = filepath.Join(dir, e.Filename)
+		default:
+			return nil, fmt.Errorf("error validating virtual disk %s: unsupported extent type: %s", path, e.Type)
+		}
+
+		if extent.Path != "" {
+			fi, err := os.Stat(extent.Path)
+			if err != nil {
+				return nil, fmt.Errorf("error validating virtual disk %s: %s", path, err)
+			}
+			extent.Size = fi.Size()
+		}
+
+		if e.Type == ExtentTypeFlat || e.Type == ExtentTypeVmfs {
+			if required := (e.Offset + e.Sectors) * SectorSize; extent.Size < required {
+				return nil, fmt.Errorf("error validating virtual disk %s: extent %s is %d bytes; expected at least %d", path, e.Filename, extent.Size, required)
+			}
+		}
+
+		info.Extents = append(info.Extents, extent)
+	}
+
+	if d.HasParent() {
+		parentPath := d.ParentFileNameHint
+		if !filepath.IsAbs(parentPath) {
+			parentPath = filepath.Join(dir, parentPath)
+		}
+		if info.Parent, err = Stat(parentPath); err != nil {
+			return nil, fmt.Errorf("error validating parent of virtual disk %s: %s", path, err)
+		}
+	}
+
+	return info, nil
+}
+
+// validateSparseExtent validates the header of the sparse extent at the path.
+func validateSparseExtent(path string, sectors int64) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	h, err := readSparseExtentHeader(f, 0)
+	if err != nil {
+		return fmt.Errorf("error reading extent %s: %s", filepath.Base(path), err)
+	}
+	if h.Capacity < uint64(sectors) {
+		return fmt.Errorf("extent %s capacity is %d sectors; expected %d", filepath.Base(path), h.Capacity, sectors)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+package vmdk
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStat_parent(t *testing.T) {
+	dir := t.TempDir()
+	parent := filepath.Join(dir, "parent.vmdk")
+	if err := Create(parent, 1024*1024, DiskTypeMonolithicFlat, "lsilogic"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	pd, err := ReadDescriptor(parent)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	child := filepath.Join(dir, "child.vmdk")
+	if err := Create(filepath.Join(dir, "child-base.vmdk"), 1024*1024, DiskTypeSplitSparse, "lsilogic"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	cd, err := ReadDescriptor(filepath.Join(dir, "child-base.vmdk"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	cd.ParentCID = pd.CID
+	cd.ParentFileNameHint = "parent.vmdk"
+	if err := os.WriteFile(child, []byte(cd.String()), 0o644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	info, err := Stat(child)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if assert.NotNil(t, info.Parent) {
+		assert.Equal(t, parent, info.Parent.Path)
+		assert.Equal(t, int64(1024*1024), info.Parent.Size())
+	}
+
+	if err := os.Remove(filepath.Join(dir, "parent-flat.vmdk")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	_, err = Stat(child)
+	assert.ErrorContains(t, err, "parent")
+}
+
+func TestStat_invalid(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "disk.vmdk")
+	if err := Create(path, 4*1024*1024, DiskTypeMonolithicFlat, "lsilogic"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// A truncated flat extent is smaller than the extent.
+	if err := os.Truncate(filepath.Join(dir, "disk-flat.vmdk"), 1024*1024); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	_, err := Stat(path)
+	assert.ErrorContains(t, err, "expected at least")
+
+	// A missing extent file.
+	if err := os.Remove(filepath.Join(dir, "disk-flat.vmdk")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	_, err = Stat(path)
+	assert.Error(t, err)
+
+	// A file that is not a virtual disk.
+	other := filepath.Join(dir, "other.vmdk")
+	if err := os.WriteFile(other, []byte("not a descriptor"), 0o644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	_, err = Stat(other)
+	assert.Error(t, err)
+
+	_, err = Stat(filepath.Join(dir, "missing.vmdk"))
+	assert.Error(t, err)
+}
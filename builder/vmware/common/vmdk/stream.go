@@ -0,0 +1,210 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+package vmdk
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/rand/v2"
+)
+
+// StreamOptimizedFormat is the OVF disk format identifier of a
+// stream-optimized virtual disk.
+const StreamOptimizedFormat = "http://www.vmware.com/interfaces/specifications/vmdk.html#streamOptimized"
+
+// StreamStats reports the result of writing a stream-optimized virtual disk.
+type StreamStats struct {
+	// Size is the number of bytes written.
+	Size int64
+	// PopulatedSize is the number of bytes of the virtual disk that contain
+	// data.
+	PopulatedSize int64
+}
+
+// sectorWriter tracks the number of sectors written to the underlying writer.
+type sectorWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *sectorWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+// sector returns the current sector.
+func (w *sectorWriter) sector() uint64 {
+	return uint64(w.n / SectorSize)
+}
+
+// pad writes zeros up to the next sector boundary.
+func (w *sectorWriter) pad() error {
+	if rem := w.n % SectorSize; rem != 0 {
+		_, err := w.Write(make([]byte, SectorSize-rem))
+		return err
+	}
+	return nil
+}
+
+// WriteStreamOptimized writes the contents of src as a stream-optimized
+// virtual disk to w. The virtual disk is written sequentially, one grain at a
+// time, so the disk is never held in memory. Grains that contain only zeros
+// are omitted. The filename is recorded in the embedded descriptor and the
+// ddb entries are copied to the disk database.
+func WriteStreamOptimized(w io.Writer, filename string, src io.ReaderAt, capacity int64, ddb map[string]string) (*StreamStats, error) {
+	sw := &sectorWriter{w: w}
+	stats := &StreamStats{}
+
+	sectors := (capacity + SectorSize - 1) / SectorSize
+	grainBytes := int64(defaultGrainSize * SectorSize)
+	numGrains := (sectors + defaultGrainSize - 1) / defaultGrainSize
+	numGTs := (numGrains + defaultNumGTEsPerGT - 1) / defaultNumGTEsPerGT
+
+	descriptor := &Descriptor{
+		Version:    1,
+		Encoding:   "UTF-8",
+		CID:        rand.Uint32(),
+		ParentCID:  NoParentCID,
+		CreateType: CreateTypeStreamOptimized,
+		Extents: []Extent{
+			{Access: "RW", Sectors: sectors, Type: ExtentTypeSparse, Filename: filename},
+		},
+		DDB: make(map[string]string),
+	}
+	for k, v := range ddb {
+		descriptor.DDB[k] = v
+	}
+	descriptorBytes := []byte(descriptor.String())
+	descriptorSectors := (int64(len(descriptorBytes)) + SectorSize - 1) / SectorSize
+
+	// The grains begin at the first grain boundary after the descriptor.
+	overhead := (1 + descriptorSectors + defaultGrainSize - 1) / defaultGrainSize * defaultGrainSize
+
+	header := SparseExtentHeader{
+		MagicNumber:        sparseMagic,
+		Version:            3,
+		Flags:              flagValidNewLineDetection | flagCompressedGrains | flagMarkers,
+		Capacity:           uint64(sectors),
+		GrainSize:          defaultGrainSize,
+		DescriptorOffset:   1,
+		DescriptorSize:     uint64(descriptorSectors),
+		NumGTEsPerGT:       defaultNumGTEsPerGT,
+		GdOffset:           gdAtEnd,
+		OverHead:           uint64(overhead),
+		SingleEndLineChar:  '\n',
+		NonEndLineChar:     ' ',
+		DoubleEndLineChar1: '\r',
+		DoubleEndLineChar2: '\n',
+		CompressAlgorithm:  compressionDeflate,
+	}
+
+	if err := binary.Write(sw, binary.LittleEndian, &header); err != nil {
+		return nil, err
+	}
+	if _, err := sw.Write(descriptorBytes); err != nil {
+		return nil, err
+	}
+	if _, err := sw.Write(make([]byte, overhead*SectorSize-sw.n)); err != nil {
+		return nil, err
+	}
+
+	// Write the grains.
+	gts := make([]uint32, numGTs*defaultNumGTEsPerGT)
+	buf := make([]byte, grainBytes)
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+
+	for grain := int64(0); grain < numGrains; grain++ {
+		offset := grain * grainBytes
+		length := min(grainBytes, capacity-offset)
+		data := buf[:length]
+
+		if n, err := src.ReadAt(data, offset); err != nil && !(err == io.EOF && int64(n) == length) {
+			return nil, fmt.Errorf("error reading grain %d: %s", grain, err)
+		}
+		if isZero(data) {
+			continue
+		}
+
+		compressed.Reset()
+		zw.Reset(&compressed)
+		if _, err := zw.Write(data); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+
+		gts[grain] = uint32(sw.sector())
+
+		marker := make([]byte, 12)
+		binary.LittleEndian.PutUint64(marker, uint64(grain*defaultGrainSize))
+		binary.LittleEndian.PutUint32(marker[8:], uint32(compressed.Len()))
+		if _, err := sw.Write(marker); err != nil {
+			return nil, err
+		}
+		if _, err := sw.Write(compressed.Bytes()); err != nil {
+			return nil, err
+		}
+		if err := sw.pad(); err != nil {
+			return nil, err
+		}
+
+		stats.PopulatedSize += length
+	}
+
+	// Write the grain tables, followed by the grain directory.
+	gtSectors := uint64(defaultNumGTEsPerGT * 4 / SectorSize)
+	gd := make([]uint32, numGTs)
+	for i := int64(0); i < numGTs; i++ {
+		if err := writeMarker(sw, gtSectors, markerGT); err != nil {
+			return nil, err
+		}
+		gd[i] = uint32(sw.sector())
+		if err := binary.Write(sw, binary.LittleEndian, gts[i*defaultNumGTEsPerGT:(i+1)*defaultNumGTEsPerGT]); err != nil {
+			return nil, err
+		}
+	}
+
+	gdSectors := (uint64(numGTs)*4 + SectorSize - 1) / SectorSize
+	if err := writeMarker(sw, gdSectors, markerGD); err != nil {
+		return nil, err
+	}
+	header.GdOffset = sw.sector()
+	if err := binary.Write(sw, binary.LittleEndian, gd); err != nil {
+		return nil, err
+	}
+	if err := sw.pad(); err != nil {
+		return nil, err
+	}
+
+	// Write the footer, followed by the end-of-stream marker.
+	if err := writeMarker(sw, 1, markerFooter); err != nil {
+		return nil, err
+	}
+	if err := binary.Write(sw, binary.LittleEndian, &header); err != nil {
+		return nil, err
+	}
+	if err := writeMarker(sw, 0, markerEOS); err != nil {
+		return nil, err
+	}
+
+	stats.Size = sw.n
+	return stats, nil
+}
+
+// isZero reports whether the buffer contains only zeros.
+func isZero(buf []byte) bool {
+	for _, b := range buf {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
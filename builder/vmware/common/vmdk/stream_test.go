@@ -0,0 +1,98 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+package vmdk
+
+import (
+	"bytes"
+	"io"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testImage returns an image with random data in some grains and zeros in
+// others. The size is not a multiple of the grain size.
+func testImage() []byte {
+	image := make([]byte, 3*1024*1024+SectorSize*3)
+	r := rand.New(rand.NewPCG(1, 2))
+	for i := 0; i < len(image); i += 256 * 1024 {
+		end := min(len(image), i+64*1024)
+		for j := i; j < end; j++ {
+			image[j] = byte(r.UintN(256))
+		}
+	}
+	copy(image[len(image)-4:], "tail")
+	return image
+}
+
+func TestWriteStreamOptimized(t *testing.T) {
+	image := testImage()
+	path := filepath.Join(t.TempDir(), "disk.vmdk")
+
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	stats, err := WriteStreamOptimized(f, "disk.vmdk", bytes.NewReader(image), int64(len(image)), map[string]string{
+		"ddb.adapterType": "lsilogic",
+	})
+	f.Close()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assert.Equal(t, info.Size(), stats.Size)
+	assert.Zero(t, stats.Size%SectorSize)
+	assert.Less(t, stats.PopulatedSize, int64(len(image)))
+
+	d, err := Open(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer d.Close()
+
+	assert.Equal(t, CreateTypeStreamOptimized, d.Descriptor.CreateType)
+	assert.Equal(t, "lsilogic", d.Descriptor.DDB["ddb.adapterType"])
+	assert.Equal(t, int64(len(image)), d.Capacity())
+
+	contents, err := io.ReadAll(io.NewSectionReader(d, 0, d.Capacity()))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assert.True(t, bytes.Equal(image, contents), "contents should match the source image")
+}
+
+func TestOpen_flat(t *testing.T) {
+	dir := t.TempDir()
+	image := testImage()[:4*SectorSize]
+
+	if err := os.WriteFile(filepath.Join(dir, "disk-flat.vmdk"), image, 0644); err != nil { //nolint:gosec
+		t.Fatalf("err: %s", err)
+	}
+	descriptor := "version=1\nCID=fffffffe\nparentCID=ffffffff\ncreateType=\"monolithicFlat\"\n" +
+		"RW 4 FLAT \"disk-flat.vmdk\" 0\nRW 2 ZERO\n"
+	if err := os.WriteFile(filepath.Join(dir, "disk.vmdk"), []byte(descriptor), 0644); err != nil { //nolint:gosec
+		t.Fatalf("err: %s", err)
+	}
+
+	d, err := Open(filepath.Join(dir, "disk.vmdk"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer d.Close()
+
+	contents, err := io.ReadAll(io.NewSectionReader(d, 0, d.Capacity()))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assert.Equal(t, append(image, make([]byte, 2*SectorSize)...), contents)
+}
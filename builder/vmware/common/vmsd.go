@@ -0,0 +1,439 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// vmsdSnapshotKeyRe matches the keys of a snapshot in the snapshot database,
+// such as "snapshot0.displayName".
+var vmsdSnapshotKeyRe = regexp.MustCompile(`(?i)^snapshot(\d+)\.(.+)$`)
+
+// vmsdDiskKeyRe matches the keys of a snapshot disk, such as "disk0.fileName".
+var vmsdDiskKeyRe = regexp.MustCompile(`(?i)^disk(\d+)\.(.+)$`)
+
+// vmsdLineRe matches a key and value in the snapshot database.
+var vmsdLineRe = regexp.MustCompile(`^(.+?)\s*=\s*"?(.*?)"?\s*$`)
+
+// Snapshot is a snapshot of a virtual machine recorded in the snapshot
+// database.
+type Snapshot struct {
+	// The unique identifier of the snapshot.
+	UID int
+	// The name of the snapshot.
+	DisplayName string
+	// The description of the snapshot.
+	Description string
+	// The name of the snapshot state file.
+	Filename string
+	// The time the snapshot was created.
+	CreateTime time.Time
+	// The virtual disks captured by the snapshot.
+	Disks []SnapshotDisk
+	// The parent snapshot, or nil for a root snapshot.
+	Parent *Snapshot
+	// The child snapshots.
+	Children []*Snapshot
+
+	// The remaining keys of the snapshot, retained for writing.
+	extra map[string]string
+}
+
+// SnapshotDisk is a virtual disk captured by a snapshot.
+type SnapshotDisk struct {
+	// The filename of the virtual disk.
+	Filename string
+	// The device node of the virtual disk, such as "scsi0:0".
+	Node string
+}
+
+// SnapshotTree is the snapshot database of a virtual machine, read from the
+// .vmsd file.
+type SnapshotTree struct {
+	// The snapshots, in the order they are recorded in the database.
+	Snapshots []*Snapshot
+	// The snapshots without a parent.
+	Roots []*Snapshot
+	// The unique identifier of the current snapshot, or 0 if there is none.
+	CurrentUID int
+	// The last unique identifier assigned to a snapshot.
+	LastUID int
+
+	// The remaining keys of the database, retained for writing.
+	extra map[string]string
+}
+
+// VMSDPath returns the path to the snapshot database of the virtual machine
+// described by the .vmx file.
+func VMSDPath(vmxPath string) string {
+	return strings.TrimSuffix(vmxPath, filepath.Ext(vmxPath)) + ".vmsd"
+}
+
+// ParseVMSD parses the contents of a snapshot database into a snapshot tree.
+// Values are decoded like the values of a .vmx file.
+func ParseVMSD(contents string) (*SnapshotTree, error) {
+	tree := &SnapshotTree{extra: make(map[string]string)}
+	snapshots := make(map[int]*Snapshot)
+	parents := make(map[int]int)
+
+	for _, line := range strings.Split(contents, "\n") {
+		matches := vmsdLineRe.FindStringSubmatch(strings.TrimSpace(line))
+		if matches == nil {
+			continue
+		}
+		key, value := matches[1], decodeVMXValue(matches[2])
+
+		switch strings.ToLower(key) {
+		case "snapshot.lastuid":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value for %s: %s", key, value)
+			}
+			tree.LastUID = n
+			continue
+		case "snapshot.current":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value for %s: %s", key, value)
+			}
+			tree.CurrentUID = n
+			continue
+		case "snapshot.numsnapshots":
+			// The number of snapshots is derived from the snapshots.
+			continue
+		}
+
+		m := vmsdSnapshotKeyRe.FindStringSubmatch(key)
+		if m == nil {
+			tree.extra[key] = value
+			continue
+		}
+
+		index, _ := strconv.Atoi(m[1])
+		snapshot, ok := snapshots[index]
+		if !ok {
+			snapshot = &Snapshot{extra: make(map[string]string)}
+			snapshots[index] = snapshot
+		}
+
+		if err := snapshot.parseKey(m[2], value, parents, index); err != nil {
+			return nil, fmt.Errorf("invalid value for %s: %s", key, value)
+		}
+	}
+
+	indexes := make([]int, 0, len(snapshots))
+	for index := range snapshots {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+
+	byUID := make(map[int]*Snapshot)
+	for _, index := range indexes {
+		snapshot := snapshots[index]
+		if snapshot.UID == 0 {
+			return nil, fmt.Errorf("snapshot%d has no uid", index)
+		}
+		if _, ok := byUID[snapshot.UID]; ok {
+			return nil, fmt.Errorf("duplicate snapshot uid: %d", snapshot.UID)
+		}
+		byUID[snapshot.UID] = snapshot
+		tree.Snapshots = append(tree.Snapshots, snapshot)
+	}
+
+	for _, index := range indexes {
+		snapshot := snapshots[index]
+		parentUID, ok := parents[index]
+		if !ok {
+			tree.Roots = append(tree.Roots, snapshot)
+			continue
+		}
+		parent, ok := byUID[parentUID]
+		if !ok {
+			return nil, fmt.Errorf("parent snapshot %d of snapshot %q not found", parentUID, snapshot.DisplayName)
+		}
+		snapshot.Parent = parent
+		parent.Children = append(parent.Children, snapshot)
+	}
+
+	if tree.CurrentUID != 0 {
+		if _, ok := byUID[tree.CurrentUID]; !ok {
+			return nil, fmt.Errorf("current snapshot %d not found", tree.CurrentUID)
+		}
+	}
+
+	return tree, nil
+}
+
+// parseKey sets the snapshot field for the key, relative to the snapshot.
+func (s *Snapshot) parseKey(key string, value string, parents map[int]int, index int) error {
+	switch strings.ToLower(key) {
+	case "uid":
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		s.UID = n
+	case "parent":
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		parents[index] = n
+	case "displayname":
+		s.DisplayName = value
+	case "description":
+		s.Description = value
+	case "filename":
+		s.Filename = value
+	case "createtimehigh":
+		n, err := strconv.ParseInt(value, 10, 32)
+		if err != nil {
+			return err
+		}
+		s.setCreateTime(uint64(uint32(n))<<32 | s.createTimeMicros()&0xffffffff)
+	case "createtimelow":
+		n, err := strconv.ParseInt(value, 10, 32)
+		if err != nil {
+			return err
+		}
+		s.setCreateTime(s.createTimeMicros()&^0xffffffff | uint64(uint32(n)))
+	case "numdisks":
+		// The number of disks is derived from the disks.
+	default:
+		m := vmsdDiskKeyRe.FindStringSubmatch(key)
+		if m == nil {
+			s.extra[key] = value
+			return nil
+		}
+		diskIndex, _ := strconv.Atoi(m[1])
+		for len(s.Disks) <= diskIndex {
+			s.Disks = append(s.Disks, SnapshotDisk{})
+		}
+		switch strings.ToLower(m[2]) {
+		case "filename":
+			s.Disks[diskIndex].Filename = value
+		case "node":
+			s.Disks[diskIndex].Node = value
+		default:
+			s.extra[key] = value
+		}
+	}
+
+	return nil
+}
+
+// createTimeMicros returns the creation time in microseconds since the epoch.
+// The snapshot database stores the high and low 32 bits of the value as
+// separate signed integers.
+func (s *Snapshot) createTimeMicros() uint64 {
+	if s.CreateTime.IsZero() {
+		return 0
+	}
+	return uint64(s.CreateTime.UnixMicro())
+}
+
+// setCreateTime sets the creation time from the microseconds since the epoch.
+func (s *Snapshot) setCreateTime(micros uint64) {
+	s.CreateTime = time.UnixMicro(int64(micros)).UTC()
+}
+
+// Path returns the path of the snapshot in the tree, with the names of the
+// snapshots separated by "/", such as "base/patched".
+func (s *Snapshot) Path() string {
+	return strings.Join(s.ChainNames(), "/")
+}
+
+// ChainNames returns the names of the snapshots from the root of the tree to
+// the snapshot.
+func (s *Snapshot) ChainNames() []string {
+	var names []string
+	for _, snapshot := range s.Chain() {
+		names = append(names, snapshot.DisplayName)
+	}
+	return names
+}
+
+// Chain returns the snapshots from the root of the tree to the snapshot.
+func (s *Snapshot) Chain() []*Snapshot {
+	var chain []*Snapshot
+	for snapshot := s; snapshot != nil; snapshot = snapshot.Parent {
+		chain = append([]*Snapshot{snapshot}, chain...)
+	}
+	return chain
+}
+
+// Current returns the current snapshot, or nil if there is none.
+func (t *SnapshotTree) Current() *Snapshot {
+	for _, snapshot := range t.Snapshots {
+		if snapshot.UID == t.CurrentUID {
+			return snapshot
+		}
+	}
+	return nil
+}
+
+// LeavesFirst returns the snapshots of the tree, ordered so that each snapshot
+// is before its parent. Deleting the snapshots in this order does not change
+// the paths of the snapshots that remain to be deleted.
+func (t *SnapshotTree) LeavesFirst() []*Snapshot {
+	var snapshots []*Snapshot
+	var visit func(*Snapshot)
+	visit = func(snapshot *Snapshot) {
+		for _, child := range snapshot.Children {
+			visit(child)
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	for _, root := range t.Roots {
+		visit(root)
+	}
+	return snapshots
+}
+
+// Lookup returns the snapshot with the name. The name is either the name of
+// a snapshot or the path of a snapshot in the tree, such as "base/patched".
+// An error is returned if no snapshot or more than one snapshot matches.
+func (t *SnapshotTree) Lookup(name string) (*Snapshot, error) {
+	var matches []*Snapshot
+	for _, snapshot := range t.Snapshots {
+		if snapshot.DisplayName == name || (strings.Contains(name, "/") && snapshot.Path() == name) {
+			matches = append(matches, snapshot)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("snapshot %q not found", name)
+	case 1:
+		return matches[0], nil
+	default:
+		return nil, fmt.Errorf("snapshot name %q is ambiguous; use the snapshot path instead", name)
+	}
+}
+
+// Encode returns the contents of the snapshot database. The snapshots are
+// renumbered in the order they appear in the tree, and the values are encoded
+// like the values of a .vmx file.
+func (t *SnapshotTree) Encode() string {
+	data := make(map[string]string)
+	for k, v := range t.extra {
+		data[k] = v
+	}
+
+	data["snapshot.lastUID"] = strconv.Itoa(t.LastUID)
+	if t.CurrentUID != 0 {
+		data["snapshot.current"] = strconv.Itoa(t.CurrentUID)
+	}
+	data["snapshot.numSnapshots"] = strconv.Itoa(len(t.Snapshots))
+
+	for i, snapshot := range t.Snapshots {
+		prefix := fmt.Sprintf("snapshot%d.", i)
+		for k, v := range snapshot.extra {
+			data[prefix+k] = v
+		}
+		data[prefix+"uid"] = strconv.Itoa(snapshot.UID)
+		if snapshot.Parent != nil {
+			data[prefix+"parent"] = strconv.Itoa(snapshot.Parent.UID)
+		}
+		if snapshot.Filename != "" {
+			data[prefix+"filename"] = snapshot.Filename
+		}
+		data[prefix+"displayName"] = snapshot.DisplayName
+		if snapshot.Description != "" {
+			data[prefix+"description"] = snapshot.Description
+		}
+		if !snapshot.CreateTime.IsZero() {
+			micros := snapshot.createTimeMicros()
+			data[prefix+"createTimeHigh"] = strconv.FormatInt(int64(int32(micros>>32)), 10)
+			data[prefix+"createTimeLow"] = strconv.FormatInt(int64(int32(micros)), 10)
+		}
+		data[prefix+"numDisks"] = strconv.Itoa(len(snapshot.Disks))
+		for j, disk := range snapshot.Disks {
+			data[fmt.Sprintf("%sdisk%d.fileName", prefix, j)] = disk.Filename
+			data[fmt.Sprintf("%sdisk%d.node", prefix, j)] = disk.Node
+		}
+	}
+
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var buf strings.Builder
+	if encoding, ok := data[".encoding"]; ok {
+		fmt.Fprintf(&buf, "%s = \"%s\"\n", ".encoding", encoding)
+	}
+	for _, k := range keys {
+		if k == ".encoding" {
+			continue
+		}
+		fmt.Fprintf(&buf, "%s = \"%s\"\n", k, encodeVMXValue(data[k]))
+	}
+
+	return buf.String()
+}
+
+// LookupSnapshot reads the snapshot database of the virtual machine described
+// by the .vmx file and returns the snapshot with the name.
+func LookupSnapshot(vmxPath string, name string) (*Snapshot, error) {
+	tree, err := ReadVMSD(VMSDPath(vmxPath))
+	if err != nil {
+		return nil, fmt.Errorf("error reading snapshot database: %s", err)
+	}
+
+	return tree.Lookup(name)
+}
+
+// ReadSnapshotTree reads the snapshot database of the virtual machine
+// described by the .vmx file. An empty tree is returned if the virtual machine
+// has no snapshot database.
+func ReadSnapshotTree(vmxPath string) (*SnapshotTree, error) {
+	tree, err := ReadVMSD(VMSDPath(vmxPath))
+	if os.IsNotExist(err) {
+		return &SnapshotTree{extra: make(map[string]string)}, nil
+	}
+	return tree, err
+}
+
+// DeleteSnapshots deletes the snapshots of the virtual machine, in order. Each
+// snapshot is deleted by its path in the tree, since the names of snapshots
+// are not unique; the snapshots are ordered with SnapshotTree.LeavesFirst so
+// that the paths remain valid.
+func DeleteSnapshots(driver Driver, vmxPath string, snapshots []*Snapshot) error {
+	for _, snapshot := range snapshots {
+		path := snapshot.Path()
+		log.Printf("[INFO] Deleting snapshot: %s", path)
+		if err := driver.DeleteSnapshot(vmxPath, path); err != nil {
+			return fmt.Errorf("error deleting snapshot %s: %s", path, err)
+		}
+	}
+	return nil
+}
+
+// ReadVMSD reads the snapshot database at the path.
+func ReadVMSD(path string) (*SnapshotTree, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseVMSD(string(data))
+}
+
+// WriteVMSD writes the snapshot tree to the snapshot database at the path.
+func WriteVMSD(path string, tree *SnapshotTree) error {
+	log.Printf("[INFO] Writing VMSD to: %s", path)
+	return os.WriteFile(path, []byte(tree.Encode()), 0644) //nolint:gosec
+}
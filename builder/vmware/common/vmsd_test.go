@@ -0,0 +1,202 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testVMSD = `.encoding = "UTF-8"
+snapshot.lastUID = "3"
+snapshot.current = "3"
+snapshot0.uid = "1"
+snapshot0.filename = "vm-Snapshot1.vmsn"
+snapshot0.displayName = "base"
+snapshot0.description = "Base installation"
+snapshot0.createTimeHigh = "408231"
+snapshot0.createTimeLow = "-1545600736"
+snapshot0.numDisks = "1"
+snapshot0.disk0.fileName = "disk.vmdk"
+snapshot0.disk0.node = "scsi0:0"
+snapshot0.type = "1"
+snapshot1.uid = "2"
+snapshot1.parent = "1"
+snapshot1.filename = "vm-Snapshot2.vmsn"
+snapshot1.displayName = "patched"
+snapshot1.numDisks = "1"
+snapshot1.disk0.fileName = "disk-000001.vmdk"
+snapshot1.disk0.node = "scsi0:0"
+snapshot2.uid = "3"
+snapshot2.parent = "2"
+snapshot2.filename = "vm-Snapshot3.vmsn"
+snapshot2.displayName = "base"
+snapshot2.numDisks = "1"
+snapshot2.disk0.fileName = "disk-000002.vmdk"
+snapshot2.disk0.node = "scsi0:0"
+snapshot.numSnapshots = "3"
+snapshot.mru0.uid = "3"
+`
+
+func TestParseVMSD(t *testing.T) {
+	tree, err := ParseVMSD(testVMSD)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	assert.Len(t, tree.Snapshots, 3)
+	assert.Equal(t, 3, tree.LastUID)
+	assert.Equal(t, 3, tree.CurrentUID)
+	assert.Len(t, tree.Roots, 1)
+
+	base := tree.Roots[0]
+	assert.Equal(t, 1, base.UID)
+	assert.Equal(t, "base", base.DisplayName)
+	assert.Equal(t, "Base installation", base.Description)
+	assert.Equal(t, "vm-Snapshot1.vmsn", base.Filename)
+	assert.Equal(t, []SnapshotDisk{{Filename: "disk.vmdk", Node: "scsi0:0"}}, base.Disks)
+	assert.Nil(t, base.Parent)
+	assert.Len(t, base.Children, 1)
+
+	// The high and low 32 bits of the microseconds since the epoch.
+	expected := time.UnixMicro(int64(408231)<<32 | int64(uint32(0xa3e00120))).UTC()
+	assert.Equal(t, expected, base.CreateTime)
+
+	current := tree.Current()
+	assert.NotNil(t, current)
+	assert.Equal(t, []string{"base", "patched", "base"}, current.ChainNames())
+	assert.Equal(t, "base/patched/base", current.Path())
+}
+
+func TestParseVMSD_empty(t *testing.T) {
+	tree, err := ParseVMSD(".encoding = \"UTF-8\"\n")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	assert.Empty(t, tree.Snapshots)
+	assert.Nil(t, tree.Current())
+}
+
+func TestParseVMSD_invalid(t *testing.T) {
+	testCases := map[string]string{
+		"missing parent": "snapshot0.uid = \"1\"\nsnapshot0.parent = \"7\"\n",
+		"missing uid":    "snapshot0.displayName = \"base\"\n",
+		"duplicate uid":  "snapshot0.uid = \"1\"\nsnapshot1.uid = \"1\"\n",
+		"invalid uid":    "snapshot0.uid = \"one\"\n",
+		"current":        "snapshot.current = \"2\"\nsnapshot0.uid = \"1\"\n",
+	}
+
+	for name, contents := range testCases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ParseVMSD(contents); err == nil {
+				t.Fatal("should error")
+			}
+		})
+	}
+}
+
+func TestSnapshotTree_Lookup(t *testing.T) {
+	tree, err := ParseVMSD(testVMSD)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	snapshot, err := tree.Lookup("patched")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, snapshot.UID)
+
+	snapshot, err = tree.Lookup("base/patched/base")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, snapshot.UID)
+
+	_, err = tree.Lookup("base")
+	assert.ErrorContains(t, err, "ambiguous")
+
+	_, err = tree.Lookup("missing")
+	assert.ErrorContains(t, err, "not found")
+}
+
+func TestSnapshotTree_Encode(t *testing.T) {
+	tree, err := ParseVMSD(testVMSD)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "vm.vmsd")
+	if err := WriteVMSD(path, tree); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assert.Contains(t, string(contents), "snapshot0.createTimeLow = \"-1545600736\"\n")
+	assert.Contains(t, string(contents), "snapshot0.type = \"1\"\n")
+	assert.Contains(t, string(contents), "snapshot.mru0.uid = \"3\"\n")
+	assert.Contains(t, string(contents), "snapshot.numSnapshots = \"3\"\n")
+
+	result, err := ReadVMSD(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assert.Equal(t, tree.Encode(), result.Encode())
+	assert.Equal(t, tree.LastUID, result.LastUID)
+	assert.Equal(t, tree.CurrentUID, result.CurrentUID)
+	for i, snapshot := range tree.Snapshots {
+		assert.Equal(t, snapshot.UID, result.Snapshots[i].UID)
+		assert.Equal(t, snapshot.DisplayName, result.Snapshots[i].DisplayName)
+		assert.Equal(t, snapshot.CreateTime, result.Snapshots[i].CreateTime)
+		assert.Equal(t, snapshot.Disks, result.Snapshots[i].Disks)
+		assert.Equal(t, snapshot.Path(), result.Snapshots[i].Path())
+	}
+}
+
+func TestSnapshotTree_encodedValues(t *testing.T) {
+	contents := `snapshot.lastUID = "1"
+snapshot0.uid = "1"
+snapshot0.displayName = "|22base|22 |7C v1"
+snapshot0.description = "First line|0ASecond line"
+`
+	tree, err := ParseVMSD(contents)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	snapshot, err := tree.Lookup(`"base" | v1`)
+	assert.NoError(t, err)
+	assert.Equal(t, "First line\nSecond line", snapshot.Description)
+
+	encoded := tree.Encode()
+	assert.Contains(t, encoded, "snapshot0.displayName = \"|22base|22 |7C v1\"\n")
+	assert.Contains(t, encoded, "snapshot0.description = \"First line|0ASecond line\"\n")
+
+	result, err := ParseVMSD(encoded)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assert.Equal(t, snapshot.DisplayName, result.Snapshots[0].DisplayName)
+	assert.Equal(t, snapshot.Description, result.Snapshots[0].Description)
+}
+
+func TestLookupSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	vmxPath := filepath.Join(dir, "vm.vmx")
+	if err := os.WriteFile(VMSDPath(vmxPath), []byte(testVMSD), 0644); err != nil { //nolint:gosec
+		t.Fatalf("err: %s", err)
+	}
+
+	snapshot, err := LookupSnapshot(vmxPath, "patched")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"base", "patched"}, snapshot.ChainNames())
+
+	_, err = LookupSnapshot(filepath.Join(dir, "missing.vmx"), "patched")
+	assert.ErrorContains(t, err, "error reading snapshot database")
+}
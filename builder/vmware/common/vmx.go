@@ -5,104 +5,582 @@
 package common
 
 import (
-	"bytes"
+	"errors"
 	"fmt"
-	"io"
 	"log"
 	"os"
+	"path/filepath"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 )
 
-// ParseVMX parses the keys and values from a VMX file and returns them as a Go map.
-func ParseVMX(contents string) map[string]string {
-	results := make(map[string]string)
+// vmxEntryRe matches a key and value entry of a .vmx file.
+var vmxEntryRe = regexp.MustCompile(`^\s*(.+?)\s*=\s*(.*?)\s*$`)
 
-	lineRe := regexp.MustCompile(`^(.+?)\s*=\s*"?(.*?)"?\s*$`)
+// vmxDeviceRe matches the key of an entry that marks a device as present.
+var vmxDeviceRe = regexp.MustCompile(`^(.+)\.present$`)
 
-	for _, line := range strings.Split(contents, "\n") {
-		matches := lineRe.FindStringSubmatch(line)
-		if matches == nil {
+// vmxNoQuotes is a list of .vmx key fragments whose values are not quoted.
+// Fragments are used to cover multiples (i.e. multiple disks).
+var vmxNoQuotes = []string{
+	".virtualssd",
+}
+
+// vmxCaseSensitive is a list of .vmx key fragments that are case-sensitive.
+// Fragments are used to cover multiples (i.e. multiple disks).
+var vmxCaseSensitive = []string{
+	".virtualSSD",
+}
+
+// vmxKeyAttributes is a list of the attributes of the devices, in the case that
+// the desktop hypervisor writes them. The attribute of a new key that is in
+// lowercase is written in this case; for example, `ide1:0.deviceType`.
+var vmxKeyAttributes = []string{
+	"addressType",
+	"connectionType",
+	"deviceType",
+	"fileName",
+	"fileType",
+	"generatedAddress",
+	"pciSlotNumber",
+	"sharedBus",
+	"startConnected",
+	"virtualDev",
+	"wakeOnPcktRcv",
+}
+
+// vmxUnlockTimeout is the maximum amount of time to wait for the virtual
+// machine to be stopped and unlocked before the .vmx file is edited.
+var vmxUnlockTimeout = 30 * time.Second
+
+// vmxUnlockPollInterval is the interval at which the lock is checked.
+var vmxUnlockPollInterval = 150 * time.Millisecond
+
+// vmxLine is a line of a .vmx file. A line that is not an entry, such as a
+// comment or an empty line, has no key.
+type vmxLine struct {
+	raw    string
+	key    string
+	value  string
+	quoted bool
+}
+
+// VMXDocument is the contents of a .vmx file. Unlike the map returned by
+// ParseVMX, the document preserves the order of the entries, comments, the
+// original case of the keys, and the quoting and encoding of the values of
+// entries that are not modified.
+//
+// Keys are matched case-insensitively. Values are decoded from, and encoded
+// to, the `|XX` hexadecimal encoding that the desktop hypervisor uses for
+// special characters; for example, `|22` for a double quote.
+type VMXDocument struct {
+	lines []*vmxLine
+}
+
+// NewVMXDocument returns an empty .vmx document.
+func NewVMXDocument() *VMXDocument {
+	return &VMXDocument{}
+}
+
+// ParseVMXDocument parses the contents of a .vmx file into a document.
+func ParseVMXDocument(contents string) *VMXDocument {
+	d := NewVMXDocument()
+	if contents == "" {
+		return d
+	}
+
+	for _, raw := range strings.Split(strings.TrimSuffix(contents, "\n"), "\n") {
+		line := &vmxLine{raw: raw}
+
+		trimmed := strings.TrimSpace(raw)
+		if trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+			if matches := vmxEntryRe.FindStringSubmatch(raw); matches != nil {
+				value := matches[2]
+				if len(value) >= 2 && strings.HasPrefix(value, `"`) && strings.HasSuffix(value, `"`) {
+					value, line.quoted = value[1:len(value)-1], true
+				} else {
+					value = strings.Trim(value, `"`)
+				}
+				line.key, line.value = matches[1], decodeVMXValue(value)
+			}
+		}
+
+		d.lines = append(d.lines, line)
+	}
+
+	return d
+}
+
+// ReadVMXDocument reads the .vmx file at the path into a document.
+func ReadVMXDocument(path string) (*VMXDocument, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseVMXDocument(string(data)), nil
+}
+
+// index returns the index of the last line with the key, or -1 if the key is
+// not found.
+func (d *VMXDocument) index(key string) int {
+	for i := len(d.lines) - 1; i >= 0; i-- {
+		if d.lines[i].key != "" && strings.EqualFold(d.lines[i].key, key) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Get returns the value of the key and whether the key is found. If the key
+// occurs more than once, the value of the last occurrence is returned.
+func (d *VMXDocument) Get(key string) (string, bool) {
+	if i := d.index(key); i >= 0 {
+		return d.lines[i].value, true
+	}
+	return "", false
+}
+
+// Set sets the value of the key. An existing entry is updated in place,
+// preserving the case of its key and the quoting of its value; otherwise, the
+// entry is appended.
+func (d *VMXDocument) Set(key string, value string) {
+	i := d.index(key)
+	if i < 0 {
+		d.lines = append(d.lines, &vmxLine{
+			key:    vmxKeyCase(key),
+			value:  value,
+			quoted: vmxQuoted(key),
+		})
+		return
+	}
+
+	// Remove any earlier occurrences of the key, so that the key has a single
+	// value.
+	line := d.lines[i]
+	lines := d.lines[:0]
+	for j, l := range d.lines {
+		if j < i && l.key != "" && strings.EqualFold(l.key, key) {
 			continue
 		}
+		lines = append(lines, l)
+	}
+	d.lines = lines
 
-		key := strings.ToLower(matches[1])
-		results[key] = matches[2]
+	if line.value != value {
+		line.value, line.raw = value, ""
 	}
+}
 
-	return results
+// Delete removes all the entries with the key and reports whether the key was
+// found.
+func (d *VMXDocument) Delete(key string) bool {
+	var found bool
+	lines := d.lines[:0]
+	for _, line := range d.lines {
+		if line.key != "" && strings.EqualFold(line.key, key) {
+			found = true
+			continue
+		}
+		lines = append(lines, line)
+	}
+	d.lines = lines
+	return found
 }
 
-// EncodeVMX converts a map of key-value pairs into valid VMX file contents.
-func EncodeVMX(contents map[string]string) string {
-	var buf bytes.Buffer
+// SetAll sets the values of the keys of the map, in the sorted order of the
+// keys, so that the keys that are appended are in a stable order.
+func (d *VMXDocument) SetAll(data map[string]string) {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		d.Set(key, data[key])
+	}
+}
+
+// DeleteDevice removes all the entries of the device or controller with the
+// name; for example, `floppy0` or `ide1:0`.
+func (d *VMXDocument) DeleteDevice(name string) {
+	prefix := strings.ToLower(name) + "."
+	lines := d.lines[:0]
+	for _, line := range d.lines {
+		if line.key != "" && strings.HasPrefix(strings.ToLower(line.key), prefix) {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	d.lines = lines
+}
+
+// Keys returns the keys of the entries in order, in their original case.
+func (d *VMXDocument) Keys() []string {
+	var keys []string
+	seen := make(map[string]bool)
+	for _, line := range d.lines {
+		if line.key == "" || seen[strings.ToLower(line.key)] {
+			continue
+		}
+		seen[strings.ToLower(line.key)] = true
+		keys = append(keys, line.key)
+	}
+	return keys
+}
 
-	i := 0
-	keys := make([]string, len(contents))
-	for k := range contents {
-		keys[i] = k
-		i++
+// Devices returns the names of the devices that are present, in order; for
+// example, `scsi0`, `scsi0:0`, or `ethernet0`.
+func (d *VMXDocument) Devices() []string {
+	var devices []string
+	for _, key := range d.Keys() {
+		matches := vmxDeviceRe.FindStringSubmatch(key)
+		if matches == nil {
+			continue
+		}
+		if value, _ := d.Get(key); strings.EqualFold(value, "TRUE") {
+			devices = append(devices, strings.ToLower(matches[1]))
+		}
 	}
+	return devices
+}
 
-	// a list of VMX key fragments that the value must not be quoted
-	// fragments are used to cover multiples (i.e. multiple disks)
-	// keys are still lowercase at this point, use lower fragments
-	noQuotes := []string{
-		".virtualssd",
+// Map returns the entries as a map with lowercase keys, as returned by
+// ParseVMX.
+func (d *VMXDocument) Map() map[string]string {
+	results := make(map[string]string)
+	for _, line := range d.lines {
+		if line.key != "" {
+			results[strings.ToLower(line.key)] = line.value
+		}
 	}
+	return results
+}
+
+// Update updates the document to match the map with lowercase keys. Entries
+// with keys that are not in the map are removed, entries with different values
+// are updated in place, and keys that are not in the document are appended in
+// sorted order.
+func (d *VMXDocument) Update(data map[string]string) {
+	current := d.Map()
 
-	// a list of VMX key fragments that are case-sensitive
-	// fragments are used to cover multiples (i.e. multiple disks)
-	caseSensitive := []string{
-		".virtualSSD",
+	for key := range current {
+		if _, ok := data[key]; !ok {
+			d.Delete(key)
+		}
 	}
 
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
 	sort.Strings(keys)
-	for _, k := range keys {
-		pat := "%s = \"%s\"\n"
-		// items with no quotes
-		for _, q := range noQuotes {
-			if strings.Contains(k, q) {
-				pat = "%s = %s\n"
-				break
+
+	for _, key := range keys {
+		if value, ok := current[key]; !ok || value != data[key] {
+			d.Set(key, data[key])
+		}
+	}
+}
+
+// String returns the contents of the .vmx file. Lines that are not modified
+// are returned as they were parsed.
+func (d *VMXDocument) String() string {
+	var b strings.Builder
+	for _, line := range d.lines {
+		switch {
+		case line.key == "" || line.raw != "":
+			b.WriteString(line.raw)
+		case line.quoted:
+			fmt.Fprintf(&b, "%s = \"%s\"", line.key, encodeVMXValue(line.value))
+		default:
+			fmt.Fprintf(&b, "%s = %s", line.key, encodeVMXValue(line.value))
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// vmxKeyCase returns the key with the case-sensitive key fragments restored,
+// and the attribute of a device in the case that the hypervisor writes it.
+func vmxKeyCase(key string) string {
+	for _, c := range vmxCaseSensitive {
+		key = strings.Replace(key, strings.ToLower(c), c, 1)
+	}
+
+	if i := strings.LastIndex(key, "."); i >= 0 {
+		for _, attr := range vmxKeyAttributes {
+			if key[i+1:] == strings.ToLower(attr) {
+				return key[:i+1] + attr
+			}
+		}
+	}
+	return key
+}
+
+// vmxQuoted reports whether the value of a new entry with the key is quoted.
+func vmxQuoted(key string) bool {
+	for _, q := range vmxNoQuotes {
+		if strings.Contains(strings.ToLower(key), q) {
+			return false
+		}
+	}
+	return true
+}
+
+// decodeVMXValue decodes the `|XX` hexadecimal encoded characters of a value.
+func decodeVMXValue(value string) string {
+	if !strings.Contains(value, "|") {
+		return value
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] == '|' && i+2 < len(value) {
+			if n, err := strconv.ParseUint(value[i+1:i+3], 16, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 2
+				continue
 			}
 		}
-		key := k
-		// Case-sensitive key fragments.
-		for _, c := range caseSensitive {
-			key = strings.Replace(key, strings.ToLower(c), c, 1)
+		b.WriteByte(value[i])
+	}
+	return b.String()
+}
+
+// encodeVMXValue encodes the characters of a value that cannot be written
+// literally as `|XX` hexadecimal encoded characters.
+func encodeVMXValue(value string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if c == '"' || c == '|' || c < 0x20 || c == 0x7f {
+			fmt.Fprintf(&b, "|%02X", c)
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// VMXDiff returns the changes from the .vmx data before to the data after as
+// a diff, with a line for each key that is removed, prefixed with `-`, and for
+// each key that is added, prefixed with `+`. A changed key has both lines. An
+// empty string is returned if there are no changes.
+func VMXDiff(before map[string]string, after map[string]string) string {
+	keys := make(map[string]bool)
+	for k := range before {
+		keys[k] = true
+	}
+	for k := range after {
+		keys[k] = true
+	}
+
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	var b strings.Builder
+	for _, k := range sorted {
+		oldValue, inBefore := before[k]
+		newValue, inAfter := after[k]
+		if inBefore && inAfter && oldValue == newValue {
+			continue
+		}
+		if inBefore {
+			fmt.Fprintf(&b, "- %s = \"%s\"\n", k, oldValue)
+		}
+		if inAfter {
+			fmt.Fprintf(&b, "+ %s = \"%s\"\n", k, newValue)
 		}
-		buf.WriteString(fmt.Sprintf(pat, key, contents[k]))
 	}
+	return b.String()
+}
+
+// ParseVMX parses the keys and values from a VMX file and returns them as a Go map.
+func ParseVMX(contents string) map[string]string {
+	return ParseVMXDocument(contents).Map()
+}
 
-	return buf.String()
+// EncodeVMX converts a map of key-value pairs into valid VMX file contents.
+func EncodeVMX(contents map[string]string) string {
+	d := NewVMXDocument()
+	d.Update(contents)
+	return d.String()
 }
 
-// WriteVMX writes VMX configuration data to a file at the specified path.
-func WriteVMX(path string, data map[string]string) (err error) {
+// WriteVMXDocument writes the .vmx document to a file at the specified path.
+// The file is replaced atomically, so that an interrupted write does not leave
+// a truncated file. The first write that replaces the file keeps the original
+// version as a backup at the path returned by VMXBackupPath; later writes
+// leave the backup untouched until it is removed with RemoveVMXBackup.
+func WriteVMXDocument(path string, d *VMXDocument) error {
 	log.Printf("[INFO] Writing VMX to: %s", path)
-	f, err := os.Create(path)
+
+	if err := waitForVMXLock(path, vmxUnlockTimeout); err != nil {
+		return err
+	}
+
+	if err := backupVMX(path); err != nil {
+		return fmt.Errorf("error writing VMX backup: %s", err)
+	}
+
+	return writeFileAtomic(path, []byte(d.String()))
+}
+
+// backupVMX copies the .vmx file to the backup path unless the file does not
+// exist yet or a backup was already made.
+func backupVMX(path string) error {
+	backupPath := VMXBackupPath(path)
+	if _, err := os.Stat(backupPath); err == nil {
+		return nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	original, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
 	if err != nil {
-		return
+		return err
 	}
-	defer f.Close()
+	return writeFileAtomic(backupPath, original)
+}
 
-	var buf bytes.Buffer
-	buf.WriteString(EncodeVMX(data))
-	if _, err = io.Copy(f, &buf); err != nil {
-		return
+// VMXBackupPath returns the path to the backup of the original version of the
+// .vmx file, which is kept for the duration of the build.
+func VMXBackupPath(path string) string {
+	return path + ".bak"
+}
+
+// RemoveVMXBackup removes the backup of the original version of the .vmx file,
+// if it exists.
+func RemoveVMXBackup(path string) error {
+	if err := os.Remove(VMXBackupPath(path)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// writeFileAtomic writes the data to a temporary file in the directory of the
+// path, flushes it to disk, and renames it to the path.
+func writeFileAtomic(path string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	tmpPath := f.Name()
+
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmpPath, 0644) //nolint:gosec
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+	}
+	return err
+}
+
+// vmxLockPath returns the path to the lock of the .vmx file, which the
+// hypervisor creates while the virtual machine is in use.
+func vmxLockPath(path string) string {
+	return path + ".lck"
+}
+
+// vmxLocked reports whether the .vmx file is locked by the hypervisor. The lock
+// is either a file or a directory that contains a lock file for each process
+// that holds the lock.
+func vmxLocked(path string) bool {
+	info, err := os.Stat(vmxLockPath(path))
+	if err != nil {
+		return false
+	}
+	if !info.IsDir() {
+		return true
+	}
+
+	entries, err := os.ReadDir(vmxLockPath(path))
+	return err != nil || len(entries) > 0
+}
+
+// WaitForVMXUnlocked waits until the virtual machine is not running and the
+// .vmx file is not locked by the hypervisor, so that the file can be edited
+// safely. An error is returned if the virtual machine is still running or the
+// file is still locked when the timeout is reached.
+func WaitForVMXUnlocked(driver Driver, path string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		running, err := driver.IsRunning(path)
+		if err != nil {
+			log.Printf("[WARN] Unable to determine if the virtual machine is running: %s", err)
+		}
+		if !running {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("virtual machine is running: %s", path)
+		}
+
+		log.Printf("[INFO] Waiting for the virtual machine to stop: %s", path)
+		time.Sleep(vmxUnlockPollInterval)
 	}
 
-	return
+	return waitForVMXLock(path, time.Until(deadline))
+}
+
+// waitForVMXLock waits until the .vmx file is not locked by the hypervisor. An
+// error is returned if the file is still locked when the timeout is reached.
+func waitForVMXLock(path string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for vmxLocked(path) {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("virtual machine is locked by the hypervisor: %s", vmxLockPath(path))
+		}
+
+		log.Printf("[INFO] Waiting for the lock to be released: %s", vmxLockPath(path))
+		time.Sleep(vmxUnlockPollInterval)
+	}
+	return nil
+}
+
+// WriteVMX writes VMX configuration data to a file at the specified path. If
+// the file exists, it is updated to match the data, preserving the order of
+// the entries, comments, and the original case of the keys.
+func WriteVMX(path string, data map[string]string) error {
+	d, err := ReadVMXDocument(path)
+	if errors.Is(err, os.ErrNotExist) {
+		d = NewVMXDocument()
+	} else if err != nil {
+		return err
+	}
+
+	d.Update(data)
+	return WriteVMXDocument(path, d)
 }
 
 // ReadVMX reads a VMX file from the specified path and returns its contents as a key-value map.
 func ReadVMX(path string) (map[string]string, error) {
-	data, err := os.ReadFile(path)
+	d, err := ReadVMXDocument(path)
 	if err != nil {
 		return nil, err
 	}
 
-	return ParseVMX(string(data)), nil
+	return d.Map(), nil
 }
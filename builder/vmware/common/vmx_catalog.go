@@ -0,0 +1,497 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The modes of the linting of the .vmx data.
+const (
+	VMXDataLintWarn  = "warn"
+	VMXDataLintError = "error"
+	VMXDataLintOff   = "off"
+)
+
+// allowedVMXDataLintModes is the list of allowed modes of the linting of the
+// .vmx data.
+var allowedVMXDataLintModes = []string{
+	VMXDataLintWarn,
+	VMXDataLintError,
+	VMXDataLintOff,
+}
+
+// vmxValueKind is the type of the value of a .vmx key.
+type vmxValueKind int
+
+const (
+	vmxString vmxValueKind = iota
+	vmxBool
+	vmxInt
+	vmxFloat
+	vmxEnum
+)
+
+// vmxKey describes a known .vmx key.
+type vmxKey struct {
+	kind vmxValueKind
+	// The allowed values of an enumeration, in lowercase.
+	values []string
+	// Whether the list of values of an enumeration is incomplete. A value that
+	// is not listed is reported as a warning only.
+	open bool
+}
+
+var (
+	vmxStringKey = vmxKey{kind: vmxString}
+	vmxBoolKey   = vmxKey{kind: vmxBool}
+	vmxIntKey    = vmxKey{kind: vmxInt}
+	vmxFloatKey  = vmxKey{kind: vmxFloat}
+)
+
+// vmxEnumKey returns a known key with an enumeration of values.
+func vmxEnumKey(values ...string) vmxKey {
+	return vmxKey{kind: vmxEnum, values: values}
+}
+
+// vmxOpenEnumKey returns a known key with an incomplete enumeration of values.
+func vmxOpenEnumKey(values ...string) vmxKey {
+	return vmxKey{kind: vmxEnum, values: values, open: true}
+}
+
+// vmxDiskModes is the list of disk modes.
+var vmxDiskModes = []string{
+	"persistent",
+	"nonpersistent",
+	"independent-persistent",
+	"independent-nonpersistent",
+	"undoable",
+	"append",
+}
+
+// vmxDeviceTypes is the list of device types of a device attached to a storage
+// controller.
+var vmxDeviceTypes = []string{
+	"disk",
+	"plaindisk",
+	"rawdisk",
+	"ata-harddisk",
+	"scsi-harddisk",
+	"scsi-nonpassthru-rdm",
+	"scsi-passthru",
+	"atapi-cdrom",
+	"cdrom-image",
+	"cdrom-raw",
+}
+
+// vmxPowerTypes is the list of the types of the power operations.
+var vmxPowerTypes = []string{"soft", "hard", "trysoft", "default"}
+
+// vmxGuestOSTypes is a list of common guest operating system types. The list
+// is incomplete, since the types depend on the version of the hypervisor.
+var vmxGuestOSTypes = []string{
+	FallbackGuestOsType,
+	DefaultGuestOsTypeAmd64,
+	DefaultGuestOsTypeArm64,
+	"arm-other5xlinux-64",
+	"arm-other6xlinux-64",
+	"arm-debian12-64",
+	"arm-ubuntu-64",
+	"arm-rhel9-64",
+	"arm-windows11-64",
+	"centos-64",
+	"centos7-64",
+	"centos8-64",
+	"centos9-64",
+	"debian10-64",
+	"debian11-64",
+	"debian12-64",
+	"darwin21-64",
+	"darwin22-64",
+	"darwin23-64",
+	"freebsd-64",
+	"freebsd13-64",
+	"freebsd14-64",
+	"other3xlinux-64",
+	"other4xlinux-64",
+	"other5xlinux-64",
+	"other6xlinux-64",
+	"otherlinux-64",
+	"oraclelinux-64",
+	"oraclelinux8-64",
+	"oraclelinux9-64",
+	"photon-64",
+	"rhel7-64",
+	"rhel8-64",
+	"rhel9-64",
+	"sles15-64",
+	"ubuntu-64",
+	"windows9-64",
+	"windows11-64",
+	"windows2019srv-64",
+	"windows2019srvnext-64",
+	"windows2022srvnext-64",
+}
+
+// vmxCatalog is the catalog of known .vmx keys. In the keys, which are in
+// lowercase, `#` matches a number.
+var vmxCatalog = map[string]vmxKey{
+	".encoding":                      vmxStringKey,
+	"annotation":                     vmxStringKey,
+	"bios.bootdelay":                 vmxIntKey,
+	"bios.bootorder":                 vmxStringKey,
+	"bios.forcesetuponce":            vmxBoolKey,
+	"bios.hddorder":                  vmxStringKey,
+	"checkpoint.vmstate":             vmxStringKey,
+	"cleanshutdown":                  vmxBoolKey,
+	"config.version":                 vmxIntKey,
+	"cpuid.corespersocket":           vmxIntKey,
+	"disk.enableuuid":                vmxBoolKey,
+	"displayname":                    vmxStringKey,
+	"ehci.present":                   vmxBoolKey,
+	"extendedconfigfile":             vmxStringKey,
+	"firmware":                       vmxEnumKey(FirmwareTypeBios, FirmwareTypeUEFI),
+	"guestos":                        vmxOpenEnumKey(vmxGuestOSTypes...),
+	"gui.fullscreenatpoweron":        vmxBoolKey,
+	"gui.viewmodeatpoweron":          vmxStringKey,
+	"hpet#.present":                  vmxBoolKey,
+	"keyboardandmouseprofile":        vmxStringKey,
+	"mem.hotadd":                     vmxBoolKey,
+	"memsize":                        vmxIntKey,
+	"mks.enable3d":                   vmxBoolKey,
+	"numvcpus":                       vmxIntKey,
+	"nvram":                          vmxStringKey,
+	"pcibridge#.functions":           vmxIntKey,
+	"pcibridge#.pcislotnumber":       vmxIntKey,
+	"pcibridge#.present":             vmxBoolKey,
+	"pcibridge#.virtualdev":          vmxEnumKey("pcierootport"),
+	"powertype.poweroff":             vmxEnumKey(vmxPowerTypes...),
+	"powertype.poweron":              vmxEnumKey(vmxPowerTypes...),
+	"powertype.reset":                vmxEnumKey(vmxPowerTypes...),
+	"powertype.suspend":              vmxEnumKey(vmxPowerTypes...),
+	"proxyapps.publishtohost":        vmxBoolKey,
+	"replay.filename":                vmxStringKey,
+	"replay.supported":               vmxBoolKey,
+	"softpoweroff":                   vmxBoolKey,
+	"sound.autodetect":               vmxBoolKey,
+	"sound.filename":                 vmxStringKey,
+	"sound.present":                  vmxBoolKey,
+	"sound.startconnected":           vmxBoolKey,
+	"sound.virtualdev":               vmxEnumKey("sb16", "es1371", "hdaudio"),
+	"usb.present":                    vmxBoolKey,
+	"usb_xhci.present":               vmxBoolKey,
+	"uuid.action":                    vmxEnumKey("create", "keep"),
+	"uuid.bios":                      vmxStringKey,
+	"uuid.location":                  vmxStringKey,
+	"vc.uuid":                        vmxStringKey,
+	"vcpu.hotadd":                    vmxBoolKey,
+	"vhv.enable":                     vmxBoolKey,
+	"virtualhw.productcompatibility": vmxStringKey,
+	"virtualhw.version":              vmxIntKey,
+	"vmci#.id":                       vmxIntKey,
+	"vmci#.pcislotnumber":            vmxIntKey,
+	"vmci#.present":                  vmxBoolKey,
+	"vmotion.checkpointfbsize":       vmxIntKey,
+	"vpmc.enable":                    vmxBoolKey,
+	"vtpm.present":                   vmxBoolKey,
+	"vvtd.enable":                    vmxBoolKey,
+
+	// Network adapters.
+	"ethernet#.address":                     vmxStringKey,
+	"ethernet#.addresstype":                 vmxEnumKey("generated", "static", "vpx"),
+	"ethernet#.bsdname":                     vmxStringKey,
+	"ethernet#.connectiontype":              vmxEnumKey("bridged", "custom", "hostonly", "nat", "pvn"),
+	"ethernet#.displayname":                 vmxStringKey,
+	"ethernet#.generatedaddress":            vmxStringKey,
+	"ethernet#.generatedaddressoffset":      vmxIntKey,
+	"ethernet#.linkstatepropagation.enable": vmxBoolKey,
+	"ethernet#.pcislotnumber":               vmxIntKey,
+	"ethernet#.present":                     vmxBoolKey,
+	"ethernet#.pvnid":                       vmxStringKey,
+	"ethernet#.rxbw.limit":                  vmxIntKey,
+	"ethernet#.rxfi.pktloss":                vmxFloatKey,
+	"ethernet#.startconnected":              vmxBoolKey,
+	"ethernet#.txbw.limit":                  vmxIntKey,
+	"ethernet#.txfi.pktloss":                vmxFloatKey,
+	"ethernet#.uptcompatibility":            vmxBoolKey,
+	"ethernet#.virtualdev":                  vmxEnumKey(append([]string{"vlance", "vmxnet"}, allowedNetworkAdapterTypes...)...),
+	"ethernet#.vnet":                        vmxStringKey,
+	"ethernet#.wakeonpcktrcv":               vmxBoolKey,
+
+	// Floppy devices, serial ports, and parallel ports.
+	"floppy#.autodetect":       vmxBoolKey,
+	"floppy#.clientdevice":     vmxBoolKey,
+	"floppy#.filename":         vmxStringKey,
+	"floppy#.filetype":         vmxEnumKey("file", "device"),
+	"floppy#.present":          vmxBoolKey,
+	"floppy#.readonly":         vmxBoolKey,
+	"floppy#.startconnected":   vmxBoolKey,
+	"parallel#.autodetect":     vmxBoolKey,
+	"parallel#.bidirectional":  vmxBoolKey,
+	"parallel#.filename":       vmxStringKey,
+	"parallel#.filetype":       vmxEnumKey("file", "device"),
+	"parallel#.present":        vmxBoolKey,
+	"parallel#.startconnected": vmxBoolKey,
+	"serial#.autodetect":       vmxBoolKey,
+	"serial#.filename":         vmxStringKey,
+	"serial#.filetype":         vmxEnumKey("file", "device", "pipe", "network", "thinprint"),
+	"serial#.network.endpoint": vmxEnumKey("client", "server"),
+	"serial#.pipe.endpoint":    vmxEnumKey("client", "server"),
+	"serial#.present":          vmxBoolKey,
+	"serial#.startconnected":   vmxBoolKey,
+	"serial#.trynorxloss":      vmxBoolKey,
+	"serial#.vspc":             vmxStringKey,
+	"serial#.yieldonmsrread":   vmxBoolKey,
+
+	// Storage controllers.
+	"ide#.present":        vmxBoolKey,
+	"nvme#.pcislotnumber": vmxIntKey,
+	"nvme#.present":       vmxBoolKey,
+	"sata#.pcislotnumber": vmxIntKey,
+	"sata#.present":       vmxBoolKey,
+	"scsi#.pcislotnumber": vmxIntKey,
+	"scsi#.present":       vmxBoolKey,
+	"scsi#.sharedbus":     vmxEnumKey("none", "virtual", "physical"),
+	"scsi#.virtualdev":    vmxEnumKey("buslogic", "lsilogic", "lsisas1068", "pvscsi"),
+}
+
+// vmxSlotKeys are the known keys of the devices attached to the storage
+// controllers, without the `<bus>#:#.` prefix.
+var vmxSlotKeys = map[string]vmxKey{
+	"autodetect":     vmxBoolKey,
+	"clientdevice":   vmxBoolKey,
+	"devicetype":     vmxOpenEnumKey(vmxDeviceTypes...),
+	"filename":       vmxStringKey,
+	"mode":           vmxEnumKey(vmxDiskModes...),
+	"present":        vmxBoolKey,
+	"redo":           vmxStringKey,
+	"startconnected": vmxBoolKey,
+	"virtualssd":     vmxIntKey,
+	"writethrough":   vmxBoolKey,
+}
+
+// vmxCatalogPrefixes are the prefixes of families of keys that are not
+// checked, since the keys are too many or depend on the guest or the
+// hypervisor.
+var vmxCatalogPrefixes = []string{
+	"answer.",
+	"cpuid.",
+	"featmask.",
+	"guestinfo.",
+	"hgfs.",
+	"isolation.",
+	"log.",
+	"migrate.",
+	"mks.",
+	"monitor.",
+	"monitor_control.",
+	"msg.",
+	"numa.",
+	"pcipassthru#.",
+	"remotedisplay.",
+	"sched.",
+	"sharedfolder#.",
+	"sharedfolder.",
+	"svga.",
+	"tools.",
+	"uefi.",
+	"usb.",
+	"usb_xhci.",
+	"vmx.",
+}
+
+func init() {
+	for _, bus := range []string{"ide", "sata", "scsi", "nvme"} {
+		for key, value := range vmxSlotKeys {
+			vmxCatalog[bus+"#:#."+key] = value
+		}
+	}
+}
+
+// normalizeVMXKey returns the key in lowercase, with each number replaced with
+// `#`.
+func normalizeVMXKey(key string) string {
+	var b strings.Builder
+	digits := false
+	for _, r := range strings.ToLower(key) {
+		if r >= '0' && r <= '9' {
+			if !digits {
+				b.WriteByte('#')
+			}
+			digits = true
+			continue
+		}
+		digits = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// vmxLintResult is the result of the linting of a .vmx key and value.
+type vmxLintResult struct {
+	// The problem found, if any.
+	problem string
+	// Whether the problem is reported as a warning only.
+	warning bool
+}
+
+// lintVMXKey checks the key and the value against the catalog of known keys.
+// Since the catalog is incomplete, an unknown key is reported only if it is
+// close to a known key, such as a misspelled key; keys in families that are
+// not checked are never reported.
+func lintVMXKey(key, value string) vmxLintResult {
+	normalized := normalizeVMXKey(key)
+
+	if known, ok := vmxCatalog[normalized]; ok {
+		return lintVMXValue(key, value, known)
+	}
+
+	for _, prefix := range vmxCatalogPrefixes {
+		if strings.HasPrefix(normalized, prefix) {
+			return vmxLintResult{}
+		}
+	}
+
+	if suggestion, ok := closestVMXKey(normalized); ok {
+		return vmxLintResult{problem: fmt.Sprintf("unknown key %s; did you mean %s?", key, denormalizeVMXKey(suggestion, key))}
+	}
+
+	return vmxLintResult{}
+}
+
+// lintVMXValue checks the value of a known key.
+func lintVMXValue(key, value string, known vmxKey) vmxLintResult {
+	switch known.kind {
+	case vmxBool:
+		if !strings.EqualFold(value, "TRUE") && !strings.EqualFold(value, "FALSE") {
+			return vmxLintResult{problem: fmt.Sprintf("invalid value for %s: %q; must be TRUE or FALSE", key, value)}
+		}
+	case vmxInt:
+		if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+			return vmxLintResult{problem: fmt.Sprintf("invalid value for %s: %q; must be an integer", key, value)}
+		}
+	case vmxFloat:
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return vmxLintResult{problem: fmt.Sprintf("invalid value for %s: %q; must be a number", key, value)}
+		}
+	case vmxEnum:
+		if !slices.Contains(known.values, strings.ToLower(value)) {
+			return vmxLintResult{
+				problem: fmt.Sprintf("unknown value for %s: %q; expected one of %s", key, value, strings.Join(known.values, ", ")),
+				warning: known.open,
+			}
+		}
+	}
+	return vmxLintResult{}
+}
+
+// closestVMXKey returns the known key that is closest to the normalized key,
+// if the key is within an edit distance of 2 of a known key. Keys shorter than
+// 4 characters are not matched, since most short keys are close to each other.
+func closestVMXKey(normalized string) (string, bool) {
+	if len(normalized) < 4 {
+		return "", false
+	}
+
+	var suggestion string
+	best := 3
+	for candidate := range vmxCatalog {
+		if d := editDistance(candidate, normalized); d < best || (d == best && candidate < suggestion) {
+			suggestion, best = candidate, d
+		}
+	}
+	return suggestion, suggestion != ""
+}
+
+// denormalizeVMXKey replaces each `#` in the normalized key with the numbers
+// of the original key, in order.
+func denormalizeVMXKey(normalized, key string) string {
+	var numbers []string
+	var current strings.Builder
+	for _, r := range key + "." {
+		if r >= '0' && r <= '9' {
+			current.WriteRune(r)
+			continue
+		}
+		if current.Len() > 0 {
+			numbers = append(numbers, current.String())
+			current.Reset()
+		}
+	}
+
+	var b strings.Builder
+	for _, r := range normalized {
+		if r == '#' {
+			if len(numbers) > 0 {
+				b.WriteString(numbers[0])
+				numbers = numbers[1:]
+			} else {
+				b.WriteByte('0')
+			}
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// editDistance returns the Levenshtein distance between the strings.
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+// LintVMXData checks the keys and values of the .vmx data against the catalog
+// of known keys. Problems are returned as warnings in the `warn` mode, and as
+// errors in the `error` mode, except for values that are not listed in an
+// incomplete enumeration, which are always returned as warnings. The name is
+// the name of the option that sets the data.
+func LintVMXData(name string, vmxData map[string]string, mode string) ([]string, []error) {
+	var warnings []string
+	var errs []error
+
+	if mode == VMXDataLintOff {
+		return nil, nil
+	}
+
+	keys := make([]string, 0, len(vmxData))
+	for key := range vmxData {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		result := lintVMXKey(key, vmxData[key])
+		if result.problem == "" {
+			continue
+		}
+		if result.warning || mode != VMXDataLintError {
+			warnings = append(warnings, fmt.Sprintf("%s: %s", name, result.problem))
+		} else {
+			errs = append(errs, fmt.Errorf("%s: %s", name, result.problem))
+		}
+	}
+
+	return warnings, errs
+}
@@ -0,0 +1,148 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/vmware/packer-plugin-vmware/builder/vmware/common/devices"
+)
+
+func TestNormalizeVMXKey(t *testing.T) {
+	tests := map[string]string{
+		"ethernet0.virtualDev":   "ethernet#.virtualdev",
+		"SCSI0:12.fileName":      "scsi#:#.filename",
+		"pciBridge4.functions":   "pcibridge#.functions",
+		"guestOS":                "guestos",
+		"usb_xhci.present":       "usb_xhci.present",
+		"ethernet10.addressType": "ethernet#.addresstype",
+	}
+
+	for key, expected := range tests {
+		if actual := normalizeVMXKey(key); actual != expected {
+			t.Errorf("%s: expected %s, got %s", key, expected, actual)
+		}
+	}
+}
+
+func TestLintVMXKey(t *testing.T) {
+	tests := []struct {
+		key     string
+		value   string
+		problem string
+		warning bool
+	}{
+		{key: "ethernet0.virtualDev", value: "vmxnet3"},
+		{key: "ethernet0.virtualDev", value: "E1000E"},
+		{key: "scsi0:1.present", value: "true"},
+		{key: "memsize", value: "4096"},
+		{key: "firmware", value: "efi"},
+		{key: "guestinfo.metadata", value: "anything"},
+		{key: "isolation.tools.copy.disable", value: "TRUE"},
+		{key: "no.collision", value: "awesomesauce"},
+		{key: "gui.exitAtPowerOff", value: "TRUE"},
+		{key: "vmci0.unrestricted", value: "FALSE"},
+		{key: "ethernet0.networkName", value: "VM Network"},
+		{key: "disk.locking", value: "FALSE"},
+		{key: "ethernet0.virtualdve", value: "vmxnet3", problem: "unknown key ethernet0.virtualdve; did you mean ethernet0.virtualdev?"},
+		{key: "etherent1.present", value: "TRUE", problem: "unknown key etherent1.present; did you mean ethernet1.present?"},
+		{key: "memsise", value: "4096", problem: "unknown key memsise; did you mean memsize?"},
+		{key: "ethernet0.virtualDev", value: "vmxnet4", problem: `unknown value for ethernet0.virtualDev: "vmxnet4"`},
+		{key: "scsi0.virtualDev", value: "lsilogic-sas", problem: `unknown value for scsi0.virtualDev: "lsilogic-sas"`},
+		{key: "firmware", value: "uefi", problem: `unknown value for firmware: "uefi"`},
+		{key: "vhv.enable", value: "yes", problem: `invalid value for vhv.enable: "yes"; must be TRUE or FALSE`},
+		{key: "numvcpus", value: "two", problem: `invalid value for numvcpus: "two"; must be an integer`},
+		{key: "ethernet0.rxfi.pktloss", value: "0.5"},
+		{key: "ethernet0.txfi.pktloss", value: "half", problem: `invalid value for ethernet0.txfi.pktloss: "half"; must be a number`},
+		{key: "guestOS", value: "windows2025srvnext-64", problem: `unknown value for guestOS: "windows2025srvnext-64"`, warning: true},
+	}
+
+	for _, tt := range tests {
+		result := lintVMXKey(tt.key, tt.value)
+		if tt.problem == "" {
+			if result.problem != "" {
+				t.Errorf("%s = %q: unexpected problem: %s", tt.key, tt.value, result.problem)
+			}
+			continue
+		}
+		if !strings.HasPrefix(result.problem, tt.problem) {
+			t.Errorf("%s = %q: expected problem %q, got %q", tt.key, tt.value, tt.problem, result.problem)
+		}
+		if result.warning != tt.warning {
+			t.Errorf("%s = %q: expected warning %t, got %t", tt.key, tt.value, tt.warning, result.warning)
+		}
+	}
+}
+
+func TestLintVMXData(t *testing.T) {
+	vmxData := map[string]string{
+		"ethernet0.virtualDev": "vmxnet3",
+		"ethernet0.virtualdve": "vmxnet3",
+		"vhv.enable":           "yes",
+		"guestOS":              "windows2025srvnext-64",
+	}
+
+	warnings, errs := LintVMXData("vmx_data", vmxData, VMXDataLintWarn)
+	if len(warnings) != 3 || len(errs) != 0 {
+		t.Fatalf("expected 3 warnings and no errors, got: %v, %v", warnings, errs)
+	}
+	if !strings.HasPrefix(warnings[0], "vmx_data: unknown key ethernet0.virtualdve") {
+		t.Errorf("unexpected warning: %s", warnings[0])
+	}
+
+	warnings, errs = LintVMXData("vmx_data", vmxData, VMXDataLintError)
+	if len(warnings) != 1 || len(errs) != 2 {
+		t.Fatalf("expected 1 warning and 2 errors, got: %v, %v", warnings, errs)
+	}
+
+	warnings, errs = LintVMXData("vmx_data", vmxData, VMXDataLintOff)
+	if len(warnings) != 0 || len(errs) != 0 {
+		t.Fatalf("expected no warnings or errors, got: %v, %v", warnings, errs)
+	}
+}
+
+func TestLintVMXData_pluginKeys(t *testing.T) {
+	// The keys written by the plugin must be in the catalog, so that the
+	// .vmx data of a valid template is not reported.
+	adapter := &NetworkAdapterConfig{
+		AdapterType:        "vmxnet3",
+		MACAddress:         "00:50:56:00:00:02",
+		IncomingBandwidth:  10240,
+		OutgoingBandwidth:  1024,
+		IncomingPacketLoss: 0.5,
+		OutgoingPacketLoss: 2,
+	}
+	disk := &AdditionalDiskConfig{Name: "data", SSD: true, Mode: "independent-persistent"}
+
+	for name, vmxData := range map[string]map[string]string{
+		"network adapter": adapter.VMXData(0, &NetworkConnection{Type: "custom", Device: "vmnet2", Network: "vmnet2"}),
+		"disk":            disk.VMXData(devices.Slot{Bus: devices.BusSCSI, Controller: 0, Unit: 1}),
+	} {
+		warnings, errs := LintVMXData("vmx_data", vmxData, VMXDataLintError)
+		if len(warnings) != 0 || len(errs) != 0 {
+			t.Errorf("%s: expected no warnings or errors, got: %v, %v", name, warnings, errs)
+		}
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{"", "", 0},
+		{"memsize", "memsize", 0},
+		{"memsize", "memsise", 1},
+		{"ethernet", "etherent", 2},
+		{"", "abc", 3},
+	}
+
+	for _, tt := range tests {
+		if actual := editDistance(tt.a, tt.b); actual != tt.expected {
+			t.Errorf("%s, %s: expected %d, got %d", tt.a, tt.b, tt.expected, actual)
+		}
+	}
+}
@@ -7,6 +7,10 @@
 package common
 
 import (
+	"fmt"
+	"slices"
+	"strings"
+
 	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
 )
 
@@ -27,6 +31,38 @@ type VMXConfig struct {
 	// the ramifications of making changes to the `.vmx` file. This option is
 	// not necessary for most users.
 	VMXDataPost map[string]string `mapstructure:"vmx_data_post" required:"false"`
+	// Keys that will be removed from the virtual machine `.vmx` file before
+	// the virtual machine is started; for example, keys inherited from the
+	// source virtual machine or the default template. Keys are matched
+	// case-insensitively and are removed before `vmx_data` is inserted.
+	VMXDataRemove []string `mapstructure:"vmx_data_remove" required:"false"`
+	// Patch operations that will be applied to the virtual machine `.vmx`
+	// file before the virtual machine is started, after `vmx_data` is
+	// inserted. Refer to the [VMX patch configuration](#vmx-patch-configuration)
+	// for more information.
+	VMXPatch []VMXPatchConfig `mapstructure:"vmx_patch" required:"false"`
+	// Patch operations that will be applied to the virtual machine `.vmx`
+	// file after the virtual machine build is complete, after `vmx_data_post`
+	// is inserted. Refer to the [VMX patch configuration](#vmx-patch-configuration)
+	// for more information.
+	VMXPatchPost []VMXPatchConfig `mapstructure:"vmx_patch_post" required:"false"`
+	// How the keys and values of `vmx_data`, `vmx_data_post`, and the `set`
+	// and `set_if_absent` patch operations are checked against a catalog of
+	// known `.vmx` keys. Allowed values are `warn`, `error`, and `off`.
+	// Defaults to `warn`.
+	//
+	// - `warn` - Unknown keys, such as misspelled keys, and invalid values,
+	//   such as a boolean that is not `TRUE` or `FALSE`, are reported as
+	//   warnings.
+	// - `error` - Unknown keys and invalid values are reported as errors.
+	//   Values of the `guestOS` key that are not in the catalog are always
+	//   reported as warnings, since the catalog is incomplete.
+	// - `off` - Keys and values are not checked.
+	//
+	// Since the catalog is incomplete, unknown keys are reported only if they
+	// are close to a known key. Families of keys such as `guestinfo.*` and
+	// `isolation.*` are not checked.
+	VMXDataLint string `mapstructure:"vmx_data_lint" required:"false"`
 	// Remove all network adapters from virtual machine `.vmx` file after the
 	// virtual machine build is complete. Defaults to `false`.
 	//
@@ -40,7 +76,56 @@ type VMXConfig struct {
 	VMXDisplayName string `mapstructure:"display_name" required:"false"`
 }
 
-// Prepare validates the VMX configuration (currently no validation needed).
-func (c *VMXConfig) Prepare(ctx *interpolate.Context) []error {
-	return nil
+// Prepare validates the VMX configuration and lints the .vmx data against the
+// catalog of known keys.
+func (c *VMXConfig) Prepare(ctx *interpolate.Context) ([]string, []error) {
+	var warnings []string
+	var errs []error
+
+	for i, key := range c.VMXDataRemove {
+		if key == "" {
+			errs = append(errs, fmt.Errorf("vmx_data_remove[%d]: key cannot be empty", i))
+		}
+	}
+
+	for i := range c.VMXPatch {
+		for _, err := range c.VMXPatch[i].Prepare() {
+			errs = append(errs, fmt.Errorf("vmx_patch[%d]: %s", i, err))
+		}
+	}
+
+	for i := range c.VMXPatchPost {
+		for _, err := range c.VMXPatchPost[i].Prepare() {
+			errs = append(errs, fmt.Errorf("vmx_patch_post[%d]: %s", i, err))
+		}
+	}
+
+	if c.VMXDataLint == "" {
+		c.VMXDataLint = VMXDataLintWarn
+	}
+	c.VMXDataLint = strings.ToLower(c.VMXDataLint)
+	if !slices.Contains(allowedVMXDataLintModes, c.VMXDataLint) {
+		errs = append(errs, fmt.Errorf("invalid 'vmx_data_lint' specified: %s; must be one of %s", c.VMXDataLint, strings.Join(allowedVMXDataLintModes, ", ")))
+		return warnings, errs
+	}
+
+	lint := func(name string, vmxData map[string]string) {
+		lintWarnings, lintErrs := LintVMXData(name, vmxData, c.VMXDataLint)
+		warnings = append(warnings, lintWarnings...)
+		errs = append(errs, lintErrs...)
+	}
+	lint("vmx_data", c.VMXData)
+	lint("vmx_data_post", c.VMXDataPost)
+	for i, patch := range c.VMXPatch {
+		if patch.Op == vmxPatchSet || patch.Op == vmxPatchSetIfAbsent {
+			lint(fmt.Sprintf("vmx_patch[%d]", i), map[string]string{patch.Key: patch.Value})
+		}
+	}
+	for i, patch := range c.VMXPatchPost {
+		if patch.Op == vmxPatchSet || patch.Op == vmxPatchSetIfAbsent {
+			lint(fmt.Sprintf("vmx_patch_post[%d]", i), map[string]string{patch.Key: patch.Value})
+		}
+	}
+
+	return warnings, errs
 }
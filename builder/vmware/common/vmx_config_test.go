@@ -17,7 +17,7 @@ func TestVMXConfigPrepare(t *testing.T) {
 		"two": "bar",
 	}
 
-	errs := c.Prepare(interpolate.NewContext())
+	_, errs := c.Prepare(interpolate.NewContext())
 	if len(errs) > 0 {
 		t.Fatalf("bad: %#v", errs)
 	}
@@ -26,3 +26,48 @@ func TestVMXConfigPrepare(t *testing.T) {
 		t.Fatal("should have two items in VMXData")
 	}
 }
+
+func TestVMXConfigPrepare_patches(t *testing.T) {
+	c := &VMXConfig{
+		VMXDataRemove: []string{"sound.present", ""},
+		VMXPatch:      []VMXPatchConfig{{Op: "delete", Key: "foo"}, {Op: "invalid", Key: "foo"}},
+		VMXPatchPost:  []VMXPatchConfig{{Op: "set"}},
+	}
+
+	_, errs := c.Prepare(interpolate.NewContext())
+	if len(errs) != 3 {
+		t.Fatalf("expected 3 errors, got: %v", errs)
+	}
+}
+
+func TestVMXConfigPrepare_lint(t *testing.T) {
+	c := &VMXConfig{
+		VMXData:     map[string]string{"ethernet0.virtualdve": "vmxnet3"},
+		VMXDataPost: map[string]string{"vhv.enable": "yes"},
+		VMXPatch:    []VMXPatchConfig{{Op: "set", Key: "memsise", Value: "4096"}},
+	}
+	warnings, errs := c.Prepare(interpolate.NewContext())
+	if len(warnings) != 3 || len(errs) != 0 {
+		t.Fatalf("expected 3 warnings and no errors, got: %v, %v", warnings, errs)
+	}
+	if c.VMXDataLint != VMXDataLintWarn {
+		t.Fatalf("expected default lint mode %s, got: %s", VMXDataLintWarn, c.VMXDataLint)
+	}
+
+	c.VMXDataLint = "ERROR"
+	warnings, errs = c.Prepare(interpolate.NewContext())
+	if len(warnings) != 0 || len(errs) != 3 {
+		t.Fatalf("expected no warnings and 3 errors, got: %v, %v", warnings, errs)
+	}
+
+	c.VMXDataLint = "off"
+	warnings, errs = c.Prepare(interpolate.NewContext())
+	if len(warnings) != 0 || len(errs) != 0 {
+		t.Fatalf("expected no warnings or errors, got: %v, %v", warnings, errs)
+	}
+
+	c.VMXDataLint = "invalid"
+	if _, errs = c.Prepare(interpolate.NewContext()); len(errs) != 1 {
+		t.Fatalf("expected an error for an invalid lint mode, got: %v", errs)
+	}
+}
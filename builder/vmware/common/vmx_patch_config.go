@@ -0,0 +1,158 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type VMXPatchConfig
+
+package common
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// The operations of a patch to the .vmx file.
+const (
+	vmxPatchSet          = "set"
+	vmxPatchSetIfAbsent  = "set_if_absent"
+	vmxPatchDelete       = "delete"
+	vmxPatchDeletePrefix = "delete_prefix"
+	vmxPatchDeleteRegex  = "delete_regex"
+)
+
+// allowedVMXPatchOps is the list of allowed operations of a patch.
+var allowedVMXPatchOps = []string{
+	vmxPatchSet,
+	vmxPatchSetIfAbsent,
+	vmxPatchDelete,
+	vmxPatchDeletePrefix,
+	vmxPatchDeleteRegex,
+}
+
+// VMXPatchConfig defines an operation that modifies the virtual machine `.vmx`
+// file. Keys are matched case-insensitively. Patches are applied in order,
+// after `vmx_data` or `vmx_data_post`.
+//
+// HCL Example:
+//
+// ```hcl
+//
+//	vmx_patch {
+//	  op  = "delete_prefix"
+//	  key = "sound."
+//	}
+//
+//	vmx_patch {
+//	  op    = "set_if_absent"
+//	  key   = "tools.synctime"
+//	  value = "TRUE"
+//	}
+//
+// ```
+//
+// JSON Example:
+//
+// ```json
+//
+//	"vmx_patch": [
+//	  {
+//	    "op": "delete_prefix",
+//	    "key": "sound."
+//	  },
+//	  {
+//	    "op": "set_if_absent",
+//	    "key": "tools.synctime",
+//	    "value": "TRUE"
+//	  }
+//	]
+//
+// ```
+type VMXPatchConfig struct {
+	// The operation. Allowed values are `set`, `set_if_absent`, `delete`,
+	// `delete_prefix`, and `delete_regex`.
+	//
+	// - `set` - Sets the key to the value.
+	// - `set_if_absent` - Sets the key to the value, if the key is not set.
+	// - `delete` - Removes the key.
+	// - `delete_prefix` - Removes the keys that start with the prefix in
+	//   `key`; for example, `sound.`.
+	// - `delete_regex` - Removes the keys that match the regular expression
+	//   in `key`; for example, `^serial\d+\.`.
+	Op string `mapstructure:"op" required:"true"`
+	// The key, the key prefix, or the regular expression, depending on the
+	// operation.
+	Key string `mapstructure:"key" required:"true"`
+	// The value of the key for the `set` and `set_if_absent` operations.
+	Value string `mapstructure:"value" required:"false"`
+}
+
+// Prepare validates the patch configuration.
+func (c *VMXPatchConfig) Prepare() []error {
+	var errs []error
+
+	c.Op = strings.ToLower(c.Op)
+	if c.Op == "" {
+		errs = append(errs, fmt.Errorf("'op' is required; must be one of %s", strings.Join(allowedVMXPatchOps, ", ")))
+	} else if !slices.Contains(allowedVMXPatchOps, c.Op) {
+		errs = append(errs, fmt.Errorf("invalid 'op' specified: %s; must be one of %s", c.Op, strings.Join(allowedVMXPatchOps, ", ")))
+	}
+
+	if c.Key == "" {
+		errs = append(errs, fmt.Errorf("'key' is required"))
+	} else if c.Op == vmxPatchDeleteRegex {
+		if _, err := regexp.Compile(c.Key); err != nil {
+			errs = append(errs, fmt.Errorf("invalid 'key' specified: %s", err))
+		}
+	}
+
+	if c.Value != "" && c.Op != vmxPatchSet && c.Op != vmxPatchSetIfAbsent {
+		errs = append(errs, fmt.Errorf("'value' is not supported by the %s operation", c.Op))
+	}
+
+	return errs
+}
+
+// Apply applies the patch to the .vmx document. Keys that are set are written
+// in the case of the key of the patch, unless the key is already present.
+func (c *VMXPatchConfig) Apply(vmx *VMXDocument) error {
+	switch c.Op {
+	case vmxPatchSet:
+		log.Printf("[INFO] Setting VMX: '%s' = '%s'", c.Key, c.Value)
+		vmx.Set(c.Key, c.Value)
+	case vmxPatchSetIfAbsent:
+		if _, ok := vmx.Get(c.Key); !ok {
+			log.Printf("[INFO] Setting VMX: '%s' = '%s'", c.Key, c.Value)
+			vmx.Set(c.Key, c.Value)
+		}
+	case vmxPatchDelete:
+		log.Printf("[INFO] Deleting VMX: '%s'", c.Key)
+		vmx.Delete(c.Key)
+	case vmxPatchDeletePrefix:
+		prefix := strings.ToLower(c.Key)
+		for _, k := range vmx.Keys() {
+			if strings.HasPrefix(strings.ToLower(k), prefix) {
+				log.Printf("[INFO] Deleting VMX: '%s'", k)
+				vmx.Delete(k)
+			}
+		}
+	case vmxPatchDeleteRegex:
+		re, err := regexp.Compile("(?i)" + c.Key)
+		if err != nil {
+			return err
+		}
+		for _, k := range vmx.Keys() {
+			if re.MatchString(k) {
+				log.Printf("[INFO] Deleting VMX: '%s'", k)
+				vmx.Delete(k)
+			}
+		}
+	default:
+		return fmt.Errorf("invalid patch operation: %s", c.Op)
+	}
+
+	return nil
+}
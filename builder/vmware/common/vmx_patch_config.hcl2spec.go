@@ -0,0 +1,35 @@
+// Code generated by "packer-sdc mapstructure-to-hcl2"; DO NOT EDIT.
+
+package common
+
+import (
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// FlatVMXPatchConfig is an auto-generated flat version of VMXPatchConfig.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatVMXPatchConfig struct {
+	Op    *string `mapstructure:"op" required:"true" cty:"op" hcl:"op"`
+	Key   *string `mapstructure:"key" required:"true" cty:"key" hcl:"key"`
+	Value *string `mapstructure:"value" required:"false" cty:"value" hcl:"value"`
+}
+
+// FlatMapstructure returns a new FlatVMXPatchConfig.
+// FlatVMXPatchConfig is an auto-generated flat version of VMXPatchConfig.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*VMXPatchConfig) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatVMXPatchConfig)
+}
+
+// HCL2Spec returns the hcl spec of a VMXPatchConfig.
+// This spec is used by HCL to read the fields of VMXPatchConfig.
+// The decoded values from this spec will then be applied to a FlatVMXPatchConfig.
+func (*FlatVMXPatchConfig) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"op":    &hcldec.AttrSpec{Name: "op", Type: cty.String, Required: false},
+		"key":   &hcldec.AttrSpec{Name: "key", Type: cty.String, Required: false},
+		"value": &hcldec.AttrSpec{Name: "value", Type: cty.String, Required: false},
+	}
+	return s
+}
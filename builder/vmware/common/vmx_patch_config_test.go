@@ -0,0 +1,85 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestVMXPatchConfigPrepare(t *testing.T) {
+	cases := []struct {
+		name    string
+		config  VMXPatchConfig
+		wantErr bool
+	}{
+		{"set", VMXPatchConfig{Op: "set", Key: "foo", Value: "bar"}, false},
+		{"set empty value", VMXPatchConfig{Op: "set", Key: "foo"}, false},
+		{"set_if_absent", VMXPatchConfig{Op: "SET_IF_ABSENT", Key: "foo", Value: "bar"}, false},
+		{"delete", VMXPatchConfig{Op: "delete", Key: "foo"}, false},
+		{"delete_prefix", VMXPatchConfig{Op: "delete_prefix", Key: "sound."}, false},
+		{"delete_regex", VMXPatchConfig{Op: "delete_regex", Key: `^serial\d+\.`}, false},
+		{"missing op", VMXPatchConfig{Key: "foo"}, true},
+		{"invalid op", VMXPatchConfig{Op: "rename", Key: "foo"}, true},
+		{"missing key", VMXPatchConfig{Op: "delete"}, true},
+		{"invalid regex", VMXPatchConfig{Op: "delete_regex", Key: "("}, true},
+		{"value with delete", VMXPatchConfig{Op: "delete", Key: "foo", Value: "bar"}, true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			errs := tc.config.Prepare()
+			if (len(errs) > 0) != tc.wantErr {
+				t.Fatalf("unexpected errors: %v", errs)
+			}
+		})
+	}
+}
+
+func TestVMXPatchConfigApply(t *testing.T) {
+	vmx := newTestVMXDocument(map[string]string{
+		"sound.present":           "TRUE",
+		"sound.filename":          "-1",
+		"serial0.present":         "TRUE",
+		"serial1.present":         "TRUE",
+		"floppy0.present":         "FALSE",
+		"tools.synctime":          "FALSE",
+		"ethernet0.vnet":          "vmnet8",
+		"ethernet0.pcislotnumber": "33",
+	})
+
+	patches := []VMXPatchConfig{
+		{Op: "delete_prefix", Key: "Sound."},
+		{Op: "delete_regex", Key: `^SERIAL\d+\.`},
+		{Op: "delete", Key: "floppy0.present"},
+		{Op: "set_if_absent", Key: "tools.synctime", Value: "TRUE"},
+		{Op: "set_if_absent", Key: "tools.upgrade.policy", Value: "manual"},
+		{Op: "set", Key: "ethernet0.pciSlotNumber", Value: "160"},
+	}
+	for i := range patches {
+		if errs := patches[i].Prepare(); len(errs) > 0 {
+			t.Fatalf("errs: %v", errs)
+		}
+		if err := patches[i].Apply(vmx); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	expected := map[string]string{
+		"tools.synctime":          "FALSE",
+		"tools.upgrade.policy":    "manual",
+		"ethernet0.vnet":          "vmnet8",
+		"ethernet0.pcislotnumber": "160",
+	}
+	if vmxData := vmx.Map(); !reflect.DeepEqual(vmxData, expected) {
+		t.Errorf("invalid data: %#v", vmxData)
+	}
+
+	// The order of the entries is preserved, and new keys are appended.
+	keys := []string{"ethernet0.pciSlotNumber", "ethernet0.vnet", "tools.synctime", "tools.upgrade.policy"}
+	if !reflect.DeepEqual(vmx.Keys(), keys) {
+		t.Errorf("invalid keys: %v", vmx.Keys())
+	}
+}
@@ -4,7 +4,24 @@
 
 package common
 
-import "testing"
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// newTestVMXDocument returns a .vmx document with the data, or nil if the data is
+// nil.
+func newTestVMXDocument(data map[string]string) *VMXDocument {
+	if data == nil {
+		return nil
+	}
+	d := NewVMXDocument()
+	d.SetAll(data)
+	return d
+}
 
 func TestParseVMX(t *testing.T) {
 	contents := `
@@ -48,3 +65,337 @@ scsi0:0.virtualSSD = 1
 		t.Errorf("invalid results: %s", result)
 	}
 }
+
+const testVMXDocument = `.encoding = "UTF-8"
+# Comment preserved.
+displayName = "Packer|22Test|22"
+scsi0.present = "TRUE"
+scsi0:0.present = "TRUE"
+scsi0:0.virtualSSD = 1
+
+ethernet0.present = "FALSE"
+guestOS = "other"
+`
+
+func TestVMXDocument_roundTrip(t *testing.T) {
+	d := ParseVMXDocument(testVMXDocument)
+	if result := d.String(); result != testVMXDocument {
+		t.Errorf("invalid results:\n%s", result)
+	}
+}
+
+func TestVMXDocument_get(t *testing.T) {
+	d := ParseVMXDocument(testVMXDocument)
+
+	if value, ok := d.Get("displayname"); !ok || value != `Packer"Test"` {
+		t.Errorf("invalid displayName: %q", value)
+	}
+	if value, ok := d.Get("SCSI0:0.VIRTUALSSD"); !ok || value != "1" {
+		t.Errorf("invalid scsi0:0.virtualSSD: %q", value)
+	}
+	if _, ok := d.Get("floppy0.present"); ok {
+		t.Error("floppy0.present should not be found")
+	}
+}
+
+func TestVMXDocument_set(t *testing.T) {
+	d := ParseVMXDocument(testVMXDocument)
+	d.Set("guestos", "ubuntu-64")
+	d.Set("displayname", `Packer "Build"`)
+	d.Set("scsi0:0.virtualssd", "0")
+	d.Set("scsi0:1.virtualssd", "1")
+	d.Set("memsize", "1024")
+
+	expected := `.encoding = "UTF-8"
+# Comment preserved.
+displayName = "Packer |22Build|22"
+scsi0.present = "TRUE"
+scsi0:0.present = "TRUE"
+scsi0:0.virtualSSD = 0
+
+ethernet0.present = "FALSE"
+guestOS = "ubuntu-64"
+scsi0:1.virtualSSD = 1
+memsize = "1024"
+`
+	if result := d.String(); result != expected {
+		t.Errorf("invalid results:\n%s", result)
+	}
+}
+
+func TestVMXDocument_setAttributeCase(t *testing.T) {
+	d := NewVMXDocument()
+	d.SetAll(map[string]string{
+		"ide1:0.devicetype":    "cdrom-image",
+		"ide1:0.filename":      "packer.iso",
+		"ide1:0.present":       "TRUE",
+		"remotedisplay.vnc.ip": "127.0.0.1",
+	})
+	d.Set("floppy0.fileType", "file")
+
+	expected := `ide1:0.deviceType = "cdrom-image"
+ide1:0.fileName = "packer.iso"
+ide1:0.present = "TRUE"
+remotedisplay.vnc.ip = "127.0.0.1"
+floppy0.fileType = "file"
+`
+	if result := d.String(); result != expected {
+		t.Errorf("invalid results:\n%s", result)
+	}
+}
+
+func TestVMXDocument_setDuplicate(t *testing.T) {
+	d := ParseVMXDocument("a = \"1\"\nb = \"2\"\nA = \"3\"\n")
+	d.Set("a", "4")
+
+	expected := "b = \"2\"\nA = \"4\"\n"
+	if result := d.String(); result != expected {
+		t.Errorf("invalid results:\n%s", result)
+	}
+}
+
+func TestVMXDocument_delete(t *testing.T) {
+	d := ParseVMXDocument(testVMXDocument)
+	if !d.Delete("ETHERNET0.present") {
+		t.Error("ethernet0.present should be deleted")
+	}
+	if d.Delete("ethernet0.present") {
+		t.Error("ethernet0.present should not be found")
+	}
+
+	if _, ok := d.Get("ethernet0.present"); ok {
+		t.Error("ethernet0.present should not be found")
+	}
+	if len(d.Keys()) != 6 {
+		t.Errorf("not correct number of keys: %v", d.Keys())
+	}
+}
+
+func TestVMXDocument_deleteDevice(t *testing.T) {
+	d := ParseVMXDocument("scsi0.present = \"TRUE\"\nSCSI0:0.fileName = \"disk.vmdk\"\nscsi0:0.present = \"TRUE\"\nscsi0:10.present = \"TRUE\"\n")
+	d.DeleteDevice("scsi0:0")
+
+	expected := "scsi0.present = \"TRUE\"\nscsi0:10.present = \"TRUE\"\n"
+	if result := d.String(); result != expected {
+		t.Errorf("invalid results:\n%s", result)
+	}
+}
+
+func TestVMXDocument_devices(t *testing.T) {
+	d := ParseVMXDocument(testVMXDocument)
+
+	expected := []string{"scsi0", "scsi0:0"}
+	if result := d.Devices(); !reflect.DeepEqual(result, expected) {
+		t.Errorf("invalid devices: %v", result)
+	}
+}
+
+func TestVMXDocument_update(t *testing.T) {
+	d := ParseVMXDocument(testVMXDocument)
+	data := d.Map()
+	data["guestos"] = "ubuntu-64"
+	data["memsize"] = "1024"
+	data["cpuid.corespersocket"] = "2"
+	delete(data, "ethernet0.present")
+	d.Update(data)
+
+	expected := `.encoding = "UTF-8"
+# Comment preserved.
+displayName = "Packer|22Test|22"
+scsi0.present = "TRUE"
+scsi0:0.present = "TRUE"
+scsi0:0.virtualSSD = 1
+
+guestOS = "ubuntu-64"
+cpuid.corespersocket = "2"
+memsize = "1024"
+`
+	if result := d.String(); result != expected {
+		t.Errorf("invalid results:\n%s", result)
+	}
+}
+
+func TestVMXValueEncoding(t *testing.T) {
+	cases := map[string]string{
+		"plain":            "plain",
+		`a "quoted" value`: "a |22quoted|22 value",
+		"pipe|value":       "pipe|7Cvalue",
+		"line\nbreak":      "line|0Abreak",
+	}
+
+	for value, encoded := range cases {
+		if result := encodeVMXValue(value); result != encoded {
+			t.Errorf("invalid encoding of %q: %q", value, result)
+		}
+		if result := decodeVMXValue(encoded); result != value {
+			t.Errorf("invalid decoding of %q: %q", encoded, result)
+		}
+	}
+
+	if result := decodeVMXValue("trailing|2"); result != "trailing|2" {
+		t.Errorf("invalid decoding of truncated value: %q", result)
+	}
+}
+
+func TestWriteVMX_preservesDocument(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.vmx")
+	if err := os.WriteFile(path, []byte(testVMXDocument), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	data, err := ReadVMX(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	data["guestos"] = "ubuntu-64"
+	if err := WriteVMX(path, data); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	result, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := ParseVMXDocument(testVMXDocument)
+	expected.Set("guestos", "ubuntu-64")
+	if string(result) != expected.String() {
+		t.Errorf("invalid results:\n%s", result)
+	}
+}
+
+func TestWriteVMXDocument_backup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.vmx")
+
+	if err := WriteVMXDocument(path, ParseVMXDocument("a = \"1\"\n")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := os.Stat(VMXBackupPath(path)); !os.IsNotExist(err) {
+		t.Fatal("backup should not exist for a new file")
+	}
+
+	if err := WriteVMXDocument(path, ParseVMXDocument("a = \"2\"\n")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	backup, err := os.ReadFile(VMXBackupPath(path))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if string(backup) != "a = \"1\"\n" {
+		t.Errorf("invalid backup: %s", backup)
+	}
+
+	if err := WriteVMXDocument(path, ParseVMXDocument("a = \"3\"\n")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	backup, err = os.ReadFile(VMXBackupPath(path))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if string(backup) != "a = \"1\"\n" {
+		t.Errorf("backup should keep the original version: %s", backup)
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("temporary files should be removed: %v", entries)
+	}
+
+	if err := RemoveVMXBackup(path); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := RemoveVMXBackup(path); err != nil {
+		t.Fatalf("removing a missing backup should not fail: %s", err)
+	}
+}
+
+func TestWaitForVMXUnlocked(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.vmx")
+	driver := new(DriverMock)
+
+	if err := WaitForVMXUnlocked(driver, path, 0); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !driver.IsRunningCalled || driver.IsRunningPath != path {
+		t.Error("IsRunning should be called with the path")
+	}
+
+	// An empty lock directory does not lock the file.
+	if err := os.Mkdir(vmxLockPath(path), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := WaitForVMXUnlocked(driver, path, 0); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// A lock that is still held after the timeout is an error.
+	if err := os.WriteFile(filepath.Join(vmxLockPath(path), "M00001.lck"), nil, 0644); err != nil { //nolint:gosec
+		t.Fatalf("err: %s", err)
+	}
+	if !vmxLocked(path) {
+		t.Error("file should be locked")
+	}
+	if err := WaitForVMXUnlocked(driver, path, 0); err == nil {
+		t.Fatal("should error when the file is locked")
+	}
+
+	driver.IsRunningResult = true
+	if err := WaitForVMXUnlocked(driver, path, 0); err == nil {
+		t.Fatal("should error when the virtual machine is running")
+	}
+}
+
+func TestWriteVMX_locked(t *testing.T) {
+	defer func(timeout time.Duration) { vmxUnlockTimeout = timeout }(vmxUnlockTimeout)
+	vmxUnlockTimeout = 0
+
+	path := filepath.Join(t.TempDir(), "test.vmx")
+	if err := os.WriteFile(vmxLockPath(path), nil, 0644); err != nil { //nolint:gosec
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := WriteVMX(path, map[string]string{"a": "1"}); err == nil {
+		t.Fatal("should error when the file is locked")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("file should not be written while it is locked")
+	}
+
+	if err := os.Remove(vmxLockPath(path)); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := WriteVMX(path, map[string]string{"a": "1"}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestVMXDiff(t *testing.T) {
+	before := map[string]string{
+		"a": "1",
+		"b": "2",
+		"c": "3",
+	}
+	after := map[string]string{
+		"a": "1",
+		"b": "4",
+		"d": "5",
+	}
+
+	expected := `- b = "2"
++ b = "4"
+- c = "3"
++ d = "5"
+`
+	if result := VMXDiff(before, after); result != expected {
+		t.Errorf("invalid diff:\n%s", result)
+	}
+
+	if result := VMXDiff(before, before); result != "" {
+		t.Errorf("expected no diff:\n%s", result)
+	}
+}
@@ -37,7 +37,7 @@ func TestBuilder_ImplementsBuilder(t *testing.T) {
 func TestBuilderPrepare_Defaults(t *testing.T) {
 	var b Builder
 	config := testConfig()
-	_, warns, err := b.Prepare(config)
+	generatedData, warns, err := b.Prepare(config)
 	if len(warns) > 0 {
 		t.Fatalf("bad: %#v", warns)
 	}
@@ -45,6 +45,10 @@ func TestBuilderPrepare_Defaults(t *testing.T) {
 		t.Fatalf("should not have error: %s", err)
 	}
 
+	if !reflect.DeepEqual(generatedData, vmwcommon.GeneratedDataKeys()) {
+		t.Errorf("bad generated data: %#v", generatedData)
+	}
+
 	if b.config.DiskName != "disk" {
 		t.Errorf("bad disk name: %s", b.config.DiskName)
 	}
@@ -63,9 +63,12 @@ type Config struct {
 	VMName string `mapstructure:"vm_name" required:"false"`
 	// The path to a [configuration template](/packer/docs/templates/legacy_json_templates/engine)
 	// for defining the contents of a virtual machine `.vmx` configuration file
-	// for a virtual disk. Template variables `{{ .DiskType }}`, `{{ .DiskUnit }}`,
+	// for a virtual disk. Template variables `{{ .DiskType }}`,
+	// `{{ .DiskController }}`, `{{ .DiskUnit }}`, `{{ .DiskFileName }}`,
 	// `{{ .DiskName }}`, and `{{ .DiskNumber }}` are available for use within
-	// the template.
+	// the template. `{{ .DiskNumber }}` starts at `1` and skips `7`. The
+	// settings of the disk that are not set by the template, such as the mode,
+	// are added to the `.vmx` file.
 	//
 	// ~> **Note:** This option is intended for advanced users, as incorrect
 	// configurations can lead to non-functional virtual machines.
@@ -123,7 +126,9 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 	errs = packersdk.MultiErrorAppend(errs, c.ToolsConfig.Prepare(&c.ctx)...)
 	errs = packersdk.MultiErrorAppend(errs, c.CDConfig.Prepare(&c.ctx)...)
 	errs = packersdk.MultiErrorAppend(errs, c.VNCConfig.Prepare(&c.ctx)...)
-	errs = packersdk.MultiErrorAppend(errs, c.VMXConfig.Prepare(&c.ctx)...)
+	vmxConfigWarnings, vmxConfigErrs := c.VMXConfig.Prepare(&c.ctx)
+	warnings = append(warnings, vmxConfigWarnings...)
+	errs = packersdk.MultiErrorAppend(errs, vmxConfigErrs...)
 	errs = packersdk.MultiErrorAppend(errs, c.FloppyConfig.Prepare(&c.ctx)...)
 	errs = packersdk.MultiErrorAppend(errs, c.ExportConfig.Prepare(&c.ctx)...)
 	errs = packersdk.MultiErrorAppend(errs, c.DiskConfig.Prepare(&c.ctx)...)
@@ -172,15 +177,15 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 		}
 	}
 
-	if c.Network == "" {
+	if c.Network == "" && len(c.NetworkAdapters) == 0 {
 		c.Network = vmwcommon.DefaultNetworkType
 	}
 
-	if c.Format == "" {
+	if c.Format == "" && len(c.Formats) == 0 {
 		c.Format = vmwcommon.ExportFormatVmx
 	}
 
-	if c.Format == vmwcommon.ExportFormatVmx {
+	if slices.Equal(c.ExportFormats(), []string{vmwcommon.ExportFormatVmx}) {
 		// Set skip an export flag to avoid an unneeded export.
 		c.SkipExport = true
 	}
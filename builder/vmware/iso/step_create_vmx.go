@@ -8,8 +8,10 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -17,6 +19,7 @@ import (
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
 	"github.com/vmware/packer-plugin-vmware/builder/vmware/common"
+	"github.com/vmware/packer-plugin-vmware/builder/vmware/common/devices"
 )
 
 // vmxTemplateData contains the data used to populate the VMX template.
@@ -61,10 +64,12 @@ type vmxTemplateData struct {
 
 // additionalDiskTemplateData contains data for configuring additional disks in the VMX template.
 type additionalDiskTemplateData struct {
-	DiskUnit   int
-	DiskNumber int
-	DiskName   string
-	DiskType   string
+	DiskUnit       int
+	DiskController int
+	DiskNumber     int
+	DiskName       string
+	DiskFileName   string
+	DiskType       string
 }
 
 // stepCreateVMX creates the VMX configuration file for the virtual machine.
@@ -117,54 +122,62 @@ func (s *stepCreateVMX) Run(ctx context.Context, state multistep.StateBag) multi
 	diskAndCDConfigData := common.DefaultDiskAndCDROMTypes(config.DiskAdapterType, config.CdromAdapterType)
 	ictx := config.ctx
 
-	// Mount extra VMDKs we created earlier.
-	if len(config.AdditionalDiskSize) > 0 {
-
-		incrementer := 1
-
-		// Extra VMDKs after primary disk and CDROM.
-		unitSkip := 2
+	// Mount extra VMDKs we created earlier. The disks are attached to the
+	// first free units of their controllers, after the primary disk and the
+	// CD-ROM device.
+	primaryDisk := devices.Slot{Bus: devices.Bus(diskAndCDConfigData.DiskType)}
+	cdromUnit, _ := strconv.Atoi(diskAndCDConfigData.CdromTypePrimarySecondary)
+	cdrom := devices.Slot{Bus: devices.Bus(diskAndCDConfigData.CdromType), Unit: cdromUnit}
+	allocator := devices.NewAllocator(&devices.Devices{})
+	for _, slot := range []devices.Slot{primaryDisk, cdrom} {
+		_ = allocator.Reserve(slot)
+	}
 
-		// If the CD-ROM is on a different bus we only have to skip the primary disk's unit.
-		if diskAndCDConfigData.CdromType != diskAndCDConfigData.DiskType {
-			unitSkip = 1
+	additionalDiskSlots := make([]devices.Slot, len(config.AdditionalDisks))
+	for i, disk := range config.AdditionalDisks {
+		adapterType := disk.AdapterType
+		if adapterType == "" {
+			adapterType = config.DiskAdapterType
+		}
+		slot, err := allocator.AllocateFrom(common.DiskBus(adapterType), disk.Controller, 0)
+		if err != nil {
+			err := fmt.Errorf("error attaching additional disk %s: %s", disk.FileName(), err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		additionalDiskSlots[i] = slot
+
+		ictx.Data = &additionalDiskTemplateData{
+			DiskUnit:       slot.Unit,
+			DiskController: slot.Controller,
+			DiskNumber:     common.AdditionalDiskNumber(i),
+			DiskName:       config.DiskName,
+			DiskFileName:   disk.VMXFileName(),
+			DiskType:       string(slot.Bus),
 		}
 
-		for i := range config.AdditionalDiskSize {
-			// Slot 7 is special and reserved, so we need to skip that index.
-			if i+1 == 7 {
-				incrementer = 2
-				unitSkip++
-			}
-			ictx.Data = &additionalDiskTemplateData{
-				DiskUnit:   i + unitSkip,
-				DiskNumber: i + incrementer,
-				DiskName:   config.DiskName,
-				DiskType:   diskAndCDConfigData.DiskType,
-			}
-
-			diskTemplate := DefaultAdditionalDiskTemplate
-			if config.VMXDiskTemplatePath != "" {
-				rawBytes, err := os.ReadFile(config.VMXDiskTemplatePath)
-				if err != nil {
-					err := fmt.Errorf("error reading VMX disk template: %s", err)
-					state.Put("error", err)
-					ui.Error(err.Error())
-					return multistep.ActionHalt
-				}
-				diskTemplate = string(rawBytes)
-			}
-
-			diskContents, err := interpolate.Render(diskTemplate, &ictx)
+		diskTemplate := DefaultAdditionalDiskTemplate
+		if config.VMXDiskTemplatePath != "" {
+			rawBytes, err := os.ReadFile(config.VMXDiskTemplatePath)
 			if err != nil {
-				err := fmt.Errorf("error preparing VMX template for additional disk: %s", err)
+				err := fmt.Errorf("error reading VMX disk template: %s", err)
 				state.Put("error", err)
 				ui.Error(err.Error())
 				return multistep.ActionHalt
 			}
+			diskTemplate = string(rawBytes)
+		}
 
-			vmxTemplate += diskContents
+		diskContents, err := interpolate.Render(diskTemplate, &ictx)
+		if err != nil {
+			err := fmt.Errorf("error preparing VMX template for additional disk: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
 		}
+
+		vmxTemplate += diskContents
 	}
 
 	templateData := vmxTemplateData{
@@ -190,6 +203,14 @@ func (s *stepCreateVMX) Run(ctx context.Context, state multistep.StateBag) multi
 	tmpBuildDevices := state.Get("temporaryDevices").([]string)
 	tmpCdromDevice := fmt.Sprintf("%s0:%s", templateData.CdromType, templateData.CdromTypePrimarySecondary)
 	tmpBuildDevices = append(tmpBuildDevices, tmpCdromDevice)
+
+	// The additional disks that are detached at the end of the build are
+	// temporary build devices.
+	for i, disk := range config.AdditionalDisks {
+		if disk.Detach {
+			tmpBuildDevices = append(tmpBuildDevices, additionalDiskSlots[i].String())
+		}
+	}
 	state.Put("temporaryDevices", tmpBuildDevices)
 
 	// Assign the network adapter type into the template if one was specified.
@@ -198,48 +219,28 @@ func (s *stepCreateVMX) Run(ctx context.Context, state multistep.StateBag) multi
 		templateData.NetworkAdapter = networkAdapter
 	}
 
-	// Check the network type that the user specified
-	network := config.Network
-
 	driver := state.Get("driver").(common.Driver).GetVmwareDriver()
 
-	// check to see if the driver implements a network mapper for mapping
-	// the network-type to its device-name.
-	if driver.NetworkMapper != nil {
-
-		// read network map configuration into a NetworkNameMapper.
-		netmap, err := driver.NetworkMapper()
-		if err != nil {
-			err := fmt.Errorf("error reading network map configuration: %s", err)
-			state.Put("error", err)
-			ui.Error(err.Error())
-			return multistep.ActionHalt
-		}
+	// Connect the network adapter in the template to the network that the
+	// user specified, or to the network of the first network adapter.
+	network := config.Network
+	if len(config.NetworkAdapters) > 0 {
+		network = config.NetworkAdapters[0].Network
+		templateData.NetworkAdapter = config.NetworkAdapters[0].AdapterType
+	}
 
-		// try and convert the specified network to a device.
-		devices, err := netmap.NameIntoDevices(network)
-
-		if err == nil && len(devices) > 0 {
-			// If multiple devices exist, for example for network "nat", VMware chooses
-			// the actual device. Only type "custom" allows the exact choice of a
-			// specific virtual network (see below). We allow VMware to choose the device
-			// and for device-specific operations like GuestIP, try to go over all
-			// devices that match a name (e.g. "nat").
-			templateData.NetworkType = network
-			templateData.NetworkDevice = ""
-		} else {
-			// otherwise, we were unable to find the type, so assume it's a custom device
-			templateData.NetworkType = "custom"
-			templateData.NetworkDevice = network
-		}
-	} else {
-		templateData.NetworkType = common.DefaultNetworkType
-		templateData.NetworkDevice = network
-		network = common.DefaultNetworkType
+	conn, err := common.ResolveNetwork(driver, network)
+	if err != nil {
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
 	}
+	templateData.NetworkType = conn.Type
+	templateData.NetworkDevice = conn.Device
 
 	// store the network so that we can later figure out what ip address to bind to
-	state.Put("vmnetwork", network)
+	state.Put("vmnetwork", conn.Network)
+	state.Put("vmnetwork_adapter", config.CommunicatorNetworkAdapter)
 
 	// check if serial port has been configured
 	if !config.HasSerial() {
@@ -360,21 +361,51 @@ func (s *stepCreateVMX) Run(ctx context.Context, state multistep.StateBag) multi
 	vmxDir := config.OutputDir
 
 	// Now to handle options that will modify the template without using "vmxTemplateData"
-	vmxData := common.ParseVMX(vmxContents)
+	vmxDoc := common.ParseVMXDocument(vmxContents)
 
 	// If no cpus were specified, then remove the entry to use the default
-	if vmxData["numvcpus"] == "" {
-		delete(vmxData, "numvcpus")
+	if numvcpus, _ := vmxDoc.Get("numvcpus"); numvcpus == "" {
+		vmxDoc.Delete("numvcpus")
 	}
 
 	// If some number of cores were specified, then update "cpuid.coresPerSocket" with the requested value
 	if config.CoreCount > 0 {
-		vmxData["cpuid.corespersocket"] = strconv.Itoa(config.CoreCount)
+		vmxDoc.Set("cpuid.coresPerSocket", strconv.Itoa(config.CoreCount))
+	}
+
+	// Add the settings of the additional disks that are not set by the disk
+	// template, and the controllers of the disks that are not present.
+	for i, disk := range config.AdditionalDisks {
+		slot := additionalDiskSlots[i]
+		diskData := disk.VMXData(slot)
+		for _, key := range slices.Sorted(maps.Keys(diskData)) {
+			if _, ok := vmxDoc.Get(key); !ok {
+				vmxDoc.Set(key, diskData[key])
+			}
+		}
+		adapterType := disk.AdapterType
+		if adapterType == "" {
+			adapterType = config.DiskAdapterType
+		}
+		common.EnableDiskController(vmxDoc, slot, adapterType)
+	}
+
+	// Replace the network adapters in the template with the network adapters
+	// that the user specified.
+	if len(config.NetworkAdapters) > 0 {
+		conns, err := common.ApplyNetworkAdapters(driver, config.NetworkAdapters, vmxDoc)
+		if err != nil {
+			err := fmt.Errorf("error configuring network adapters: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		state.Put("vmnetwork", conns[config.CommunicatorNetworkAdapter].Network)
 	}
 
-	// Write the vmxData to the vmxPath
+	// Write the document to the vmxPath, preserving the order of the template.
 	vmxPath := filepath.Join(vmxDir, config.VMName+".vmx")
-	if err := common.WriteVMX(vmxPath, vmxData); err != nil {
+	if err := common.WriteVMXDocument(vmxPath, vmxDoc); err != nil {
 		err = fmt.Errorf("error creating VMX file: %s", err)
 		state.Put("error", err)
 		ui.Error(err.Error())
@@ -530,7 +561,7 @@ parallel0.bidirectional = "{{ .ParallelBidirectional }}"
 
 // DefaultAdditionalDiskTemplate is the template for additional disk configuration in VMX files.
 const DefaultAdditionalDiskTemplate = `
-{{ .DiskType }}0:{{ .DiskUnit }}.fileName = "{{ .DiskName}}-{{ .DiskNumber }}.vmdk
-{{ .DiskType }}0:{{ .DiskUnit }}.present = "TRUE
-{{ .DiskType }}0:{{ .DiskUnit }}.redo = "
+{{ .DiskType }}{{ .DiskController }}:{{ .DiskUnit }}.fileName = "{{ .DiskFileName }}"
+{{ .DiskType }}{{ .DiskController }}:{{ .DiskUnit }}.present = "TRUE"
+{{ .DiskType }}{{ .DiskController }}:{{ .DiskUnit }}.redo = ""
 `
@@ -634,3 +634,25 @@ func TestVMXTemplateData_PopulationFromConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestDefaultAdditionalDiskTemplate(t *testing.T) {
+	ctx := interpolate.Context{}
+	ctx.Data = &additionalDiskTemplateData{
+		DiskUnit:       2,
+		DiskController: 1,
+		DiskNumber:     1,
+		DiskName:       "disk",
+		DiskFileName:   "data.vmdk",
+		DiskType:       "sata",
+	}
+
+	result, err := interpolate.Render(DefaultAdditionalDiskTemplate, &ctx)
+	if err != nil {
+		t.Fatalf("Failed to render disk template: %s", err)
+	}
+
+	vmxData := common.ParseVMX(result)
+	if vmxData["sata1:2.filename"] != "data.vmdk" || vmxData["sata1:2.present"] != "TRUE" {
+		t.Errorf("unexpected disk data: %v", vmxData)
+	}
+}
@@ -37,7 +37,7 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, []string, error) {
 		return nil, warnings, errs
 	}
 
-	return nil, warnings, nil
+	return vmwcommon.GeneratedDataKeys(), warnings, nil
 }
 
 // Run executes the builder's steps to create a virtual machine from an existing VMX file.
@@ -48,8 +48,9 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 		return nil, fmt.Errorf("failed creating driver : %s", err)
 	}
 
-	// Verify that ovftool is installed if exporting the virtual machine.
-	if err := driver.VerifyOvfTool(b.config.SkipExport, false); err != nil {
+	// Verify that ovftool is installed if exporting the virtual machine with
+	// VMware OVF Tool.
+	if err := driver.VerifyOvfTool(!b.config.UsesOvfTool(), false); err != nil {
 		return nil, err
 	}
 
@@ -92,30 +93,46 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 			Label:   b.config.CDLabel,
 		},
 		&vmwcommon.StepCreateDisks{
-			OutputDir:          &b.config.OutputDir,
-			CreateMainDisk:     false,
-			DiskName:           b.config.DiskName,
-			MainDiskSize:       0,
-			AdditionalDiskSize: b.config.AdditionalDiskSize,
-			DiskAdapterType:    b.config.DiskAdapterType,
-			DiskTypeId:         b.config.DiskTypeId,
+			OutputDir:       &b.config.OutputDir,
+			CreateMainDisk:  false,
+			DiskName:        b.config.DiskName,
+			MainDiskSize:    0,
+			AdditionalDisks: b.config.AdditionalDisks,
+			DiskAdapterType: b.config.DiskAdapterType,
+			DiskTypeId:      b.config.DiskTypeId,
+		},
+		&StepRevertSourceSnapshot{
+			Path:     b.config.SourcePath,
+			Snapshot: b.config.RevertSourceSnapshot,
 		},
 		&StepCloneVMX{
-			Path:        b.config.SourcePath,
-			OutputDir:   &b.config.OutputDir,
-			VMName:      b.config.VMName,
-			Linked:      b.config.Linked,
-			Snapshot:    b.config.AttachSnapshot,
-			Version:     b.config.Version,
-			GuestOSType: b.config.GuestOSType,
+			Path:           b.config.SourcePath,
+			OutputDir:      &b.config.OutputDir,
+			VMName:         b.config.VMName,
+			Linked:         b.config.Linked,
+			Snapshot:       b.config.AttachSnapshot,
+			Version:        b.config.Version,
+			GuestOSType:    b.config.GuestOSType,
+			NetworkAdapter: b.config.CommunicatorNetworkAdapter,
 		},
+		&StepValidateDisks{},
+		&StepResizeDisk{
+			DiskSize: b.config.DiskSize,
+		},
+		&StepConfigureHardware{},
 		&vmwcommon.StepConfigureVMX{
 			CustomData:       b.config.VMXData,
+			RemoveData:       b.config.VMXDataRemove,
+			Patches:          b.config.VMXPatch,
 			VMName:           b.config.VMName,
 			DisplayName:      b.config.VMXDisplayName,
 			DiskAdapterType:  b.config.DiskAdapterType,
 			CDROMAdapterType: b.config.CdromAdapterType,
 		},
+		&vmwcommon.StepDhcpReservation{
+			NetworkAdapters:            b.config.NetworkAdapters,
+			CommunicatorNetworkAdapter: b.config.CommunicatorNetworkAdapter,
+		},
 		&StepAttachAdditionalDisks{},
 		&vmwcommon.StepAttachToolsCDROM{
 			ToolsMode:         b.config.ToolsMode,
@@ -147,10 +164,20 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 			Ctx:    b.config.ctx,
 			Comm:   &b.config.Comm,
 		},
+		&vmwcommon.StepNatPortForward{
+			Config: &b.config.SSHConfig,
+		},
 		&communicator.StepConnect{
 			Config:    &b.config.Comm,
 			Host:      driver.CommHost,
 			SSHConfig: b.config.Comm.SSHConfigFunc(),
+			SSHPort:   vmwcommon.CommPort(&b.config.SSHConfig),
+			WinRMPort: vmwcommon.CommPort(&b.config.SSHConfig),
+			CustomConnect: map[string]multistep.Step{
+				vmwcommon.CommunicatorVMTools: &vmwcommon.StepConnectVMTools{
+					Config: &b.config.SSHConfig,
+				},
+			},
 		},
 		&vmwcommon.StepUploadTools{
 			ToolsUploadFlavor: b.config.ToolsUploadFlavor,
@@ -158,6 +185,9 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 			ToolsMode:         b.config.ToolsMode,
 			Ctx:               b.config.ctx,
 		},
+		&vmwcommon.StepGeneratedData{
+			VMName: b.config.VMName,
+		},
 		&commonsteps.StepProvision{},
 		&commonsteps.StepCleanupTempKeys{
 			Comm: &b.config.Comm,
@@ -172,6 +202,7 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 		},
 		&vmwcommon.StepConfigureVMX{
 			CustomData:  b.config.VMXDataPost,
+			Patches:     b.config.VMXPatchPost,
 			SkipDevices: true,
 			VMName:      b.config.VMName,
 			DisplayName: b.config.VMXDisplayName,
@@ -180,15 +211,26 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 			RemoveEthernetInterfaces: b.config.VMXRemoveEthernet,
 			VNCEnabled:               !b.config.DisableVNC,
 		},
+		&StepPruneSnapshots{
+			Enabled: b.config.PruneSnapshots,
+		},
 		&vmwcommon.StepCreateSnapshot{
 			SnapshotName: &b.config.SnapshotName,
 		},
 		&vmwcommon.StepExport{
-			Format:         b.config.Format,
-			SkipExport:     b.config.SkipExport,
-			VMName:         b.config.VMName,
-			OVFToolOptions: b.config.OVFToolOptions,
-			OutputDir:      &b.config.OutputDir,
+			Format:               b.config.Format,
+			Formats:              b.config.Formats,
+			ExportEngine:         b.config.ExportEngine,
+			SkipExport:           b.config.SkipExport,
+			VMName:               b.config.VMName,
+			OVFToolOptions:       b.config.OVFToolOptions,
+			OutputDir:            &b.config.OutputDir,
+			VagrantfileTemplate:  b.config.VagrantfileTemplate,
+			DiskConversionEngine: b.config.DiskConversionEngine,
+		},
+		&vmwcommon.StepChecksum{
+			ChecksumTypes: b.config.ChecksumTypes,
+			SkipChecksum:  b.config.SkipChecksum,
 		},
 	}
 
@@ -212,5 +254,5 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 	}
 
 	// Generate the artifact.
-	return vmwcommon.NewArtifact(b.config.Format, b.config.VMName, b.config.SkipExport, state)
+	return vmwcommon.NewArtifact(b.config.ExportFormats(), b.config.VMName, b.config.SkipExport, state)
 }
@@ -31,6 +31,7 @@ type Config struct {
 	bootcommand.VNCConfig          `mapstructure:",squash"`
 	commonsteps.CDConfig           `mapstructure:",squash"`
 	vmwcommon.DriverConfig         `mapstructure:",squash"`
+	vmwcommon.HWConfig             `mapstructure:",squash"`
 	vmwcommon.OutputConfig         `mapstructure:",squash"`
 	vmwcommon.RunConfig            `mapstructure:",squash"`
 	shutdowncommand.ShutdownConfig `mapstructure:",squash"`
@@ -57,11 +58,31 @@ type Config struct {
 	// scenarios. Most users will wish to create a full clone instead.
 	// Defaults to `false`.
 	Linked bool `mapstructure:"linked" required:"false"`
+	// The size of the boot disk of the virtual machine in megabytes. If
+	// specified, the disk is expanded to this size before the virtual machine
+	// is started. The boot disk is the first present disk in `bios.hddOrder`,
+	// if set; otherwise, it is the disk at unit 0 of controller 0, such as
+	// `scsi0:0`. The disk can not be shrunk, and the disks of a linked clone
+	// can not be expanded. The partitions and file systems of the guest
+	// operating system are not resized. Defaults to the size of the disk of
+	// the source virtual machine.
+	DiskSize uint `mapstructure:"disk_size" required:"false"`
 	// The name of an existing snapshot to which the builder shall attach the
 	// virtual machine before powering on. If no snapshot is specified the
-	// virtual machine is started from its current state. Defaults to
-	// `null/empty`.
+	// virtual machine is started from its current state. The snapshot is
+	// either the name of a snapshot or, if the name is not unique, the path
+	// of the snapshot in the snapshot tree, such as `base/patched`. The
+	// snapshot must exist in the `.vmsd` file of the source virtual machine.
+	// Defaults to `null/empty`.
 	AttachSnapshot string `mapstructure:"attach_snapshot" required:"false"`
+	// The name of an existing snapshot to which the builder shall revert the
+	// source virtual machine, in place, before cloning. The current state of
+	// the source virtual machine is discarded. Only supported when
+	// `source_path` is a `.vmx` file. Defaults to `null/empty`.
+	RevertSourceSnapshot string `mapstructure:"revert_source_snapshot" required:"false"`
+	// Delete all snapshots of the virtual machine after provisioning and
+	// cleanup, before `snapshot_name` is created. Defaults to `false`.
+	PruneSnapshots bool `mapstructure:"prune_snapshots" required:"false"`
 	// Path to the source `.vmx`, `.ovf`, or `.ova` file to clone.
 	SourcePath string `mapstructure:"source_path" required:"true"`
 	// This is the name of the `.vmx` file for the virtual machine, without
@@ -123,6 +144,7 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 	errs = packersdk.MultiErrorAppend(errs, runConfigErrs...)
 	errs = packersdk.MultiErrorAppend(errs, c.DriverConfig.Prepare(&c.ctx)...)
 	errs = packersdk.MultiErrorAppend(errs, c.HTTPConfig.Prepare(&c.ctx)...)
+	errs = packersdk.MultiErrorAppend(errs, c.HWConfig.PrepareClone(&c.ctx)...)
 	errs = packersdk.MultiErrorAppend(errs, c.OutputConfig.Prepare(&c.ctx, &c.PackerConfig)...)
 	errs = packersdk.MultiErrorAppend(errs, c.ShutdownConfig.Prepare(&c.ctx)...)
 	errs = packersdk.MultiErrorAppend(errs, c.SSHConfig.Prepare(&c.ctx)...)
@@ -130,6 +152,9 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 	errs = packersdk.MultiErrorAppend(errs, c.FloppyConfig.Prepare(&c.ctx)...)
 	errs = packersdk.MultiErrorAppend(errs, c.CDConfig.Prepare(&c.ctx)...)
 	errs = packersdk.MultiErrorAppend(errs, c.VNCConfig.Prepare(&c.ctx)...)
+	vmxConfigWarnings, vmxConfigErrs := c.VMXConfig.Prepare(&c.ctx)
+	warnings = append(warnings, vmxConfigWarnings...)
+	errs = packersdk.MultiErrorAppend(errs, vmxConfigErrs...)
 	errs = packersdk.MultiErrorAppend(errs, c.ExportConfig.Prepare(&c.ctx)...)
 	errs = packersdk.MultiErrorAppend(errs, c.DiskConfig.Prepare(&c.ctx)...)
 
@@ -159,9 +184,34 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 				errs = packersdk.MultiErrorAppend(errs,
 					errors.New("'guest_os_type' is required when cloning from OVF/OVA files"))
 			}
+			if c.RevertSourceSnapshot != "" {
+				errs = packersdk.MultiErrorAppend(errs,
+					errors.New("'revert_source_snapshot' is not supported when cloning from OVF/OVA files"))
+			}
+		}
+
+		// Check that the snapshots exist in the snapshot database of the source.
+		if strings.HasSuffix(lowerPath, ".vmx") {
+			snapshots := []struct{ key, name string }{
+				{"attach_snapshot", c.AttachSnapshot},
+				{"revert_source_snapshot", c.RevertSourceSnapshot},
+			}
+			for _, snapshot := range snapshots {
+				if snapshot.name == "" {
+					continue
+				}
+				if _, err := vmwcommon.LookupSnapshot(c.SourcePath, snapshot.name); err != nil {
+					errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("%s is invalid: %s", snapshot.key, err))
+				}
+			}
 		}
 	}
 
+	if c.DiskSize > 0 && c.Linked {
+		errs = packersdk.MultiErrorAppend(errs,
+			errors.New("'disk_size' is not supported for linked clones"))
+	}
+
 	if c.Headless && c.DisableVNC {
 		warnings = append(warnings,
 			"Headless mode uses VNC to retrieve output. Since VNC has been disabled,\n"+
@@ -172,11 +222,11 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 		c.DiskTypeId = vmwcommon.DefaultDiskType
 	}
 
-	if c.Format == "" {
+	if c.Format == "" && len(c.Formats) == 0 {
 		c.Format = vmwcommon.ExportFormatVmx
 	}
 
-	if c.Format == vmwcommon.ExportFormatVmx {
+	if slices.Equal(c.ExportFormats(), []string{vmwcommon.ExportFormatVmx}) {
 		c.SkipExport = true
 	}
 
@@ -6,6 +6,8 @@ package vmx
 
 import (
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -62,6 +64,62 @@ func TestNewConfig_sourcePath(t *testing.T) {
 	testConfigOk(t, warns, errs)
 }
 
+func TestNewConfig_attachSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	vmxPath := filepath.Join(dir, "source.vmx")
+	if err := os.WriteFile(vmxPath, nil, 0644); err != nil { //nolint:gosec
+		t.Fatalf("err: %s", err)
+	}
+
+	// Bad: no snapshot database.
+	cfg := testConfig(t)
+	cfg["source_path"] = vmxPath
+	cfg["attach_snapshot"] = "base"
+	warns, errs := (&Config{}).Prepare(cfg)
+	testConfigErr(t, warns, errs)
+
+	vmsd := "snapshot0.uid = \"1\"\nsnapshot0.displayName = \"base\"\n"
+	if err := os.WriteFile(filepath.Join(dir, "source.vmsd"), []byte(vmsd), 0644); err != nil { //nolint:gosec
+		t.Fatalf("err: %s", err)
+	}
+
+	// Good
+	warns, errs = (&Config{}).Prepare(cfg)
+	testConfigOk(t, warns, errs)
+
+	// Bad: the snapshot does not exist.
+	cfg["attach_snapshot"] = "missing"
+	warns, errs = (&Config{}).Prepare(cfg)
+	testConfigErr(t, warns, errs)
+	if !strings.Contains(errs.Error(), "attach_snapshot is invalid") {
+		t.Fatalf("bad error: %s", errs)
+	}
+}
+
+func TestNewConfig_revertSourceSnapshot(t *testing.T) {
+	// Good
+	cfg := testConfig(t)
+	cfg["revert_source_snapshot"] = "base"
+	warns, errs := (&Config{}).Prepare(cfg)
+	testConfigOk(t, warns, errs)
+
+	// Bad
+	dir := t.TempDir()
+	ovfPath := filepath.Join(dir, "source.ovf")
+	if err := os.WriteFile(ovfPath, nil, 0644); err != nil { //nolint:gosec
+		t.Fatalf("err: %s", err)
+	}
+
+	cfg = testConfig(t)
+	cfg["source_path"] = ovfPath
+	cfg["guest_os_type"] = "other"
+	cfg["revert_source_snapshot"] = "base"
+	_, errs = (&Config{}).Prepare(cfg)
+	if errs == nil || !strings.Contains(errs.Error(), "revert_source_snapshot") {
+		t.Fatalf("should error on revert_source_snapshot: %v", errs)
+	}
+}
+
 func TestNewConfig_exportConfig(t *testing.T) {
 	type testCase struct {
 		InputConfigVals         map[string]string
@@ -134,3 +192,144 @@ func TestNewConfig_exportConfig(t *testing.T) {
 		}
 	}
 }
+
+func TestNewConfig_exportFormats(t *testing.T) {
+	testCases := []struct {
+		formats            []string
+		expectedSkipExport bool
+	}{
+		{formats: []string{"vmx"}, expectedSkipExport: true},
+		{formats: []string{"vmx", "ova"}, expectedSkipExport: false},
+		{formats: []string{"ovf", "vagrant"}, expectedSkipExport: false},
+	}
+
+	for _, tc := range testCases {
+		cfg := testConfig(t)
+		cfg["formats"] = tc.formats
+
+		var c Config
+		warns, errs := c.Prepare(cfg)
+		testConfigOk(t, warns, errs)
+
+		if c.Format != "" {
+			t.Fatalf("format should not default when formats is set: %s", c.Format)
+		}
+		if c.SkipExport != tc.expectedSkipExport {
+			t.Fatalf("For SkipExport expected %t but received %t with formats %v",
+				tc.expectedSkipExport, c.SkipExport, tc.formats)
+		}
+	}
+}
+
+func TestNewConfig_hardwareConfig(t *testing.T) {
+	testCases := []struct {
+		name        string
+		inputConfig map[string]interface{}
+		expectedErr bool
+	}{
+		{
+			name:        "no hardware options",
+			inputConfig: map[string]interface{}{},
+			expectedErr: false,
+		},
+		{
+			name: "valid hardware options",
+			inputConfig: map[string]interface{}{
+				"cpus":                 4,
+				"cores":                2,
+				"memory":               4096,
+				"network_adapter_type": "vmxnet3",
+				"firmware":             "efi",
+			},
+			expectedErr: false,
+		},
+		{
+			name: "invalid cpus",
+			inputConfig: map[string]interface{}{
+				"cpus": -1,
+			},
+			expectedErr: true,
+		},
+		{
+			name: "invalid network adapter type",
+			inputConfig: map[string]interface{}{
+				"network_adapter_type": "invalid",
+			},
+			expectedErr: true,
+		},
+		{
+			name: "invalid firmware",
+			inputConfig: map[string]interface{}{
+				"firmware": "invalid",
+			},
+			expectedErr: true,
+		},
+		{
+			name: "usb version without usb",
+			inputConfig: map[string]interface{}{
+				"usb_version": "3.1",
+			},
+			expectedErr: true,
+		},
+		{
+			name: "invalid serial",
+			inputConfig: map[string]interface{}{
+				"serial": "invalid:foo",
+			},
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := testConfig(t)
+			for k, v := range tc.inputConfig {
+				cfg[k] = v
+			}
+
+			var c Config
+			warns, errs := c.Prepare(cfg)
+			if tc.expectedErr {
+				testConfigErr(t, warns, errs)
+			} else {
+				testConfigOk(t, warns, errs)
+			}
+
+			if c.Serial != "" && !tc.expectedErr {
+				t.Fatalf("serial should not default when cloning: %s", c.Serial)
+			}
+		})
+	}
+}
+
+func TestNewConfig_vmxPatch(t *testing.T) {
+	cfg := testConfig(t)
+	cfg["vmx_patch"] = []map[string]interface{}{
+		{"op": "delete_prefix", "key": "sound."},
+	}
+	warns, errs := (&Config{}).Prepare(cfg)
+	testConfigOk(t, warns, errs)
+
+	cfg = testConfig(t)
+	cfg["vmx_patch_post"] = []map[string]interface{}{
+		{"op": "rename", "key": "sound.present"},
+	}
+	warns, errs = (&Config{}).Prepare(cfg)
+	testConfigErr(t, warns, errs)
+}
+
+func TestNewConfig_diskSize(t *testing.T) {
+	cfg := testConfig(t)
+	cfg["disk_size"] = 122880
+	warns, errs := (&Config{}).Prepare(cfg)
+	testConfigOk(t, warns, errs)
+
+	cfg = testConfig(t)
+	cfg["disk_size"] = 122880
+	cfg["linked"] = true
+	warns, errs = (&Config{}).Prepare(cfg)
+	testConfigErr(t, warns, errs)
+	if !strings.Contains(errs.Error(), "linked clones") {
+		t.Fatalf("bad error: %s", errs)
+	}
+}
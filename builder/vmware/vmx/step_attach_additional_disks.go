@@ -7,15 +7,29 @@ package vmx
 import (
 	"context"
 	"fmt"
-	"strconv"
+	"path/filepath"
 	"strings"
 
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 	vmwcommon "github.com/vmware/packer-plugin-vmware/builder/vmware/common"
+	"github.com/vmware/packer-plugin-vmware/builder/vmware/common/devices"
 )
 
 // StepAttachAdditionalDisks attaches additional disks to the virtual machine.
+//
+// Uses:
+// disk_full_paths []string
+// disk_skip_compaction []string
+// temporaryDevices []string
+//
+// Produces:
+// disk_full_paths []string - The paths to the disks, including the attached
+// disks that are not referenced in place.
+// disk_skip_compaction []string - The paths to the disks that are not
+// compacted, including the attached disks.
+// temporaryDevices []string - The devices that are removed at the end of the
+// build, including the attached disks that are detached.
 type StepAttachAdditionalDisks struct{}
 
 // Run attaches additional disks to the virtual machine.
@@ -24,15 +38,15 @@ func (s *StepAttachAdditionalDisks) Run(ctx context.Context, state multistep.Sta
 	ui := state.Get("ui").(packersdk.Ui)
 	vmxPath := state.Get("vmx_path").(string)
 
-	if len(config.AdditionalDiskSize) == 0 {
+	if len(config.AdditionalDisks) == 0 {
 		// No additional disks to attach.
 		return multistep.ActionContinue
 	}
 
-	ui.Sayf("Attaching %d additional disk(s)...", len(config.AdditionalDiskSize))
+	ui.Sayf("Attaching %d additional disk(s)...", len(config.AdditionalDisks))
 
 	// Read the existing .vmx configuration file.
-	vmxData, err := vmwcommon.ReadVMX(vmxPath)
+	vmx, err := vmwcommon.ReadVMXDocument(vmxPath)
 	if err != nil {
 		err = fmt.Errorf("error reading .vmx file for additional disk attachment: %s", err)
 		state.Put("error", err)
@@ -40,8 +54,9 @@ func (s *StepAttachAdditionalDisks) Run(ctx context.Context, state multistep.Sta
 		return multistep.ActionHalt
 	}
 
-	// Detect adapter type from existing VMX file
-	adapterType := s.detectAdapterType(vmxData)
+	// Detect adapter type from existing VMX file. Disks without an adapter
+	// type are attached to the controllers of this type.
+	adapterType := s.detectAdapterType(vmx.Map())
 	if adapterType == "" {
 		err = fmt.Errorf("error reading .vmx file for the disk adapter type")
 		state.Put("error", err)
@@ -51,43 +66,69 @@ func (s *StepAttachAdditionalDisks) Run(ctx context.Context, state multistep.Sta
 
 	ui.Sayf("Detected existing disk adapter type: %s", adapterType)
 
-	// Find the next available unit number for the adapter type.
-	nextUnit := s.getNextAvailableUnit(vmxData, adapterType)
+	// Allocate the units after the last unit used on the controller of each
+	// disk. Reserved units, such as SCSI unit 7, are skipped.
+	allocator := devices.NewAllocator(devices.Decode(vmx.Map()))
+
+	diskFullPaths, _ := state.Get("disk_full_paths").([]string)
+	skipCompaction, _ := state.Get("disk_skip_compaction").([]string)
+	temporaryDevices, _ := state.Get("temporaryDevices").([]string)
+	vmxDir := filepath.Dir(vmxPath)
 
 	// Attach additional disks to the virtual machine.
-	incrementer := 1
-	for i := range config.AdditionalDiskSize {
-		// SCSI slot 7 is reserved; increment the unit number for the next disk.
-		if i+incrementer == 7 {
-			incrementer = 2
-			nextUnit++
+	for _, disk := range config.AdditionalDisks {
+		diskAdapterType := disk.AdapterType
+		if diskAdapterType == "" {
+			diskAdapterType = adapterType
 		}
-
-		diskNumber := i + incrementer
-		diskUnit := nextUnit + i
-		if diskUnit >= 7 && adapterType == "scsi" {
-			diskUnit++ // Skip reserved slot 7.
+		bus := vmwcommon.DiskBus(diskAdapterType)
+
+		nextUnit := s.getNextAvailableUnit(vmx.Map(), string(bus), disk.Controller)
+		slot, err := allocator.AllocateFrom(bus, disk.Controller, nextUnit)
+		if err != nil {
+			err = fmt.Errorf("error attaching additional disk: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
 		}
 
-		diskPrefix := fmt.Sprintf("%s0:%d", adapterType, diskUnit)
-		diskFilename := fmt.Sprintf("%s-%d.vmdk", config.DiskName, diskNumber)
-
-		// Add disk entries to the .vmx configuration file.
-		vmxData[diskPrefix+".filename"] = diskFilename
-		vmxData[diskPrefix+".present"] = "TRUE"
-		vmxData[diskPrefix+".redo"] = ""
+		// Add disk entries to the .vmx configuration file. A SCSI controller
+		// that is added uses the disk adapter type of the configuration,
+		// unless the disk sets the adapter type.
+		if bus == devices.BusSCSI && disk.AdapterType == "" {
+			diskAdapterType = config.DiskAdapterType
+		}
+		vmwcommon.EnableDiskController(vmx, slot, diskAdapterType)
+		vmx.SetAll(disk.VMXData(slot))
+
+		// Referenced disks are not in the output directory, so they are not
+		// compacted or exported.
+		if !disk.Reference() {
+			diskFullPath := filepath.Join(vmxDir, disk.FileName())
+			diskFullPaths = append(diskFullPaths, diskFullPath)
+			if disk.SkipCompaction {
+				skipCompaction = append(skipCompaction, diskFullPath)
+			}
+		}
+		if disk.Detach {
+			temporaryDevices = append(temporaryDevices, slot.String())
+		}
 
-		ui.Sayf("Attached additional disk: %s at %s", diskFilename, diskPrefix)
+		ui.Sayf("Attached additional disk: %s at %s", disk.VMXFileName(), slot)
 	}
 
 	// Write updated .vmx configuration file.
-	if err := vmwcommon.WriteVMX(vmxPath, vmxData); err != nil {
+	if err := vmwcommon.WriteVMXDocument(vmxPath, vmx); err != nil {
 		err = fmt.Errorf("error updating VMX file with additional disks: %s", err)
 		state.Put("error", err)
 		ui.Error(err.Error())
 		return multistep.ActionHalt
 	}
 
+	state.Put("disk_full_paths", diskFullPaths)
+	state.Put("disk_skip_compaction", skipCompaction)
+	state.Put("temporaryDevices", temporaryDevices)
+
 	return multistep.ActionContinue
 }
 
@@ -109,28 +150,10 @@ func (s *StepAttachAdditionalDisks) detectAdapterType(vmxData map[string]string)
 	return "" // No disk adapter type detected.
 }
 
-// getNextAvailableUnit returns the next available unit number for the given adapter type.
-func (s *StepAttachAdditionalDisks) getNextAvailableUnit(vmxData map[string]string, adapterType string) int {
-	maxUnit := -1
-	prefix := adapterType + "0:"
-
-	for key := range vmxData {
-		if strings.HasPrefix(key, prefix) {
-			// Extract the unit number from the key.
-			remaining := key[len(prefix):]
-			dotIndex := strings.Index(remaining, ".")
-			if dotIndex > 0 {
-				unitStr := remaining[:dotIndex]
-				if unit, err := strconv.Atoi(unitStr); err == nil {
-					if unit > maxUnit {
-						maxUnit = unit
-					}
-				}
-			}
-		}
-	}
-
-	return maxUnit + 1
+// getNextAvailableUnit returns the next available unit number for the given
+// adapter type and controller.
+func (s *StepAttachAdditionalDisks) getNextAvailableUnit(vmxData map[string]string, adapterType string, controller int) int {
+	return devices.Decode(vmxData).LastUnit(devices.Bus(adapterType), controller) + 1
 }
 
 // Cleanup performs any necessary cleanup operations after attaching additional disks.
@@ -13,6 +13,7 @@ import (
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 	vmwcommon "github.com/vmware/packer-plugin-vmware/builder/vmware/common"
+	"github.com/vmware/packer-plugin-vmware/builder/vmware/common/vmdk"
 )
 
 func TestGetNextAvailableUnit(t *testing.T) {
@@ -28,12 +29,12 @@ func TestGetNextAvailableUnit(t *testing.T) {
 		"displayName": "vm",
 	}
 
-	next := step.getNextAvailableUnit(vmxData, "scsi")
+	next := step.getNextAvailableUnit(vmxData, "scsi", 0)
 	if next != 3 {
 		t.Fatalf("expected next scsi unit 3, got %d", next)
 	}
 
-	nextSata := step.getNextAvailableUnit(vmxData, "sata")
+	nextSata := step.getNextAvailableUnit(vmxData, "sata", 0)
 	if nextSata != 2 {
 		t.Fatalf("expected next sata unit 2, got %d", nextSata)
 	}
@@ -155,3 +156,139 @@ func TestRun_NoAdditionalDisks_NoOp(t *testing.T) {
 		t.Fatalf("expected VMX unchanged; diff found:\ninitial=%v\nafter=%v", initial, after)
 	}
 }
+
+func TestRun_AttachAdditionalDisks_perDisk(t *testing.T) {
+	tmpDir := t.TempDir()
+	vmxPath := filepath.Join(tmpDir, "test.vmx")
+
+	initial := map[string]string{
+		"config.version":   "8",
+		"scsi0.present":    "TRUE",
+		"scsi0.virtualdev": "lsilogic",
+		"scsi0:0.present":  "TRUE",
+		"scsi0:0.filename": "disk.vmdk",
+	}
+	if err := vmwcommon.WriteVMX(vmxPath, initial); err != nil {
+		t.Fatalf("failed to write initial vmx: %v", err)
+	}
+
+	state := new(multistep.BasicStateBag)
+	state.Put("ui", packersdk.TestUi(t))
+	state.Put("vmx_path", vmxPath)
+	state.Put("disk_full_paths", []string{filepath.Join(tmpDir, "disk.vmdk")})
+
+	cfg := &Config{}
+	cfg.AdditionalDisks = []vmwcommon.AdditionalDiskConfig{
+		{Size: 1024},
+		{Size: 2048, Name: "data", AdapterType: "sata", Controller: 1, SSD: true, Mode: "independent-persistent", SkipCompaction: true},
+	}
+	if errs := cfg.DiskConfig.Prepare(nil); len(errs) > 0 {
+		t.Fatalf("failed to prepare disk config: %v", errs)
+	}
+	state.Put("config", cfg)
+
+	step := &StepAttachAdditionalDisks{}
+	if res := step.Run(context.Background(), state); res != multistep.ActionContinue {
+		t.Fatalf("expected ActionContinue, got %v", res)
+	}
+
+	updated, err := vmwcommon.ReadVMX(vmxPath)
+	if err != nil {
+		t.Fatalf("failed to read updated vmx: %v", err)
+	}
+
+	expected := map[string]string{
+		"scsi0:1.present":    "TRUE",
+		"scsi0:1.filename":   "disk-1.vmdk",
+		"sata1.present":      "TRUE",
+		"sata1:0.present":    "TRUE",
+		"sata1:0.filename":   "data.vmdk",
+		"sata1:0.mode":       "independent-persistent",
+		"sata1:0.virtualssd": "1",
+	}
+	for key, value := range expected {
+		if got := updated[key]; got != value {
+			t.Errorf("expected %s = %q, got %q", key, value, got)
+		}
+	}
+
+	diskFullPaths := state.Get("disk_full_paths").([]string)
+	if !reflect.DeepEqual(diskFullPaths, []string{
+		filepath.Join(tmpDir, "disk.vmdk"),
+		filepath.Join(tmpDir, "disk-1.vmdk"),
+		filepath.Join(tmpDir, "data.vmdk"),
+	}) {
+		t.Errorf("unexpected disk_full_paths: %v", diskFullPaths)
+	}
+	if skip := state.Get("disk_skip_compaction").([]string); !reflect.DeepEqual(skip, []string{filepath.Join(tmpDir, "data.vmdk")}) {
+		t.Errorf("unexpected disk_skip_compaction: %v", skip)
+	}
+}
+
+func TestRun_AttachAdditionalDisks_source(t *testing.T) {
+	tmpDir := t.TempDir()
+	vmxPath := filepath.Join(tmpDir, "test.vmx")
+
+	initial := map[string]string{
+		"config.version":   "8",
+		"sata0.present":    "TRUE",
+		"sata0:0.present":  "TRUE",
+		"sata0:0.filename": "disk.vmdk",
+	}
+	if err := vmwcommon.WriteVMX(vmxPath, initial); err != nil {
+		t.Fatalf("failed to write initial vmx: %v", err)
+	}
+
+	source := filepath.Join(t.TempDir(), "source.vmdk")
+	if err := vmdk.Create(source, 1024*1024, vmdk.DiskTypeMonolithicSparse, "ide"); err != nil {
+		t.Fatalf("failed to create source disk: %v", err)
+	}
+
+	state := new(multistep.BasicStateBag)
+	state.Put("ui", packersdk.TestUi(t))
+	state.Put("vmx_path", vmxPath)
+	state.Put("disk_full_paths", []string{filepath.Join(tmpDir, "disk.vmdk")})
+	state.Put("temporaryDevices", []string{})
+
+	cfg := &Config{}
+	cfg.AdditionalDisks = []vmwcommon.AdditionalDiskConfig{
+		{SourcePath: source, Name: "copied", Detach: true},
+		{SourcePath: source, SourceMode: "reference", Detach: true},
+	}
+	if errs := cfg.DiskConfig.Prepare(nil); len(errs) > 0 {
+		t.Fatalf("failed to prepare disk config: %v", errs)
+	}
+	state.Put("config", cfg)
+
+	step := &StepAttachAdditionalDisks{}
+	if res := step.Run(context.Background(), state); res != multistep.ActionContinue {
+		t.Fatalf("expected ActionContinue, got %v", res)
+	}
+
+	updated, err := vmwcommon.ReadVMX(vmxPath)
+	if err != nil {
+		t.Fatalf("failed to read updated vmx: %v", err)
+	}
+
+	expected := map[string]string{
+		"sata0:1.filename": "copied.vmdk",
+		"sata0:2.filename": source,
+		"sata0:2.mode":     "independent-nonpersistent",
+	}
+	for key, value := range expected {
+		if got := updated[key]; got != value {
+			t.Errorf("expected %s = %q, got %q", key, value, got)
+		}
+	}
+
+	diskFullPaths := state.Get("disk_full_paths").([]string)
+	if !reflect.DeepEqual(diskFullPaths, []string{
+		filepath.Join(tmpDir, "disk.vmdk"),
+		filepath.Join(tmpDir, "copied.vmdk"),
+	}) {
+		t.Errorf("unexpected disk_full_paths: %v", diskFullPaths)
+	}
+	if devices := state.Get("temporaryDevices").([]string); !reflect.DeepEqual(devices, []string{"sata0:1", "sata0:2"}) {
+		t.Errorf("unexpected temporaryDevices: %v", devices)
+	}
+}
@@ -11,24 +11,25 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
-	"regexp"
 	"strings"
 
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 	vmwcommon "github.com/vmware/packer-plugin-vmware/builder/vmware/common"
+	"github.com/vmware/packer-plugin-vmware/builder/vmware/common/devices"
 )
 
 // StepCloneVMX clones the source virtual machine from a supplied path.
 type StepCloneVMX struct {
-	OutputDir   *string
-	Path        string
-	VMName      string
-	Linked      bool
-	Snapshot    string
-	Version     int
-	GuestOSType string
-	tempDir     string
+	OutputDir      *string
+	Path           string
+	VMName         string
+	Linked         bool
+	Snapshot       string
+	Version        int
+	GuestOSType    string
+	NetworkAdapter int
+	tempDir        string
 }
 
 // Run executes the VMX cloning step, creating a copy of the source virtual machine.
@@ -153,14 +154,14 @@ func (s *StepCloneVMX) Run(ctx context.Context, state multistep.StateBag) multis
 		// Override guest operating system identifier, if specified.
 		if s.GuestOSType != "" {
 			log.Printf("[INFO] Overriding guest operating system identifier set by ovftool: %s", s.GuestOSType)
-			vmxData, err := vmwcommon.ReadVMX(vmxPath)
+			vmx, err := vmwcommon.ReadVMXDocument(vmxPath)
 			if err != nil {
 				return halt(fmt.Errorf("failed to read vmx: %w", err))
 			}
 
-			vmxData["guestos"] = s.GuestOSType
+			vmx.Set("guestOS", s.GuestOSType)
 
-			if err := vmwcommon.WriteVMX(vmxPath, vmxData); err != nil {
+			if err := vmwcommon.WriteVMXDocument(vmxPath, vmx); err != nil {
 				return halt(fmt.Errorf("failed to write vmx: %w", err))
 			}
 		}
@@ -174,6 +175,17 @@ func (s *StepCloneVMX) Run(ctx context.Context, state multistep.StateBag) multis
 		if err := driver.Clone(vmxPath, s.Path, s.Linked, s.Snapshot); err != nil {
 			return halt(fmt.Errorf("failed to clone from .vmx: %s", err))
 		}
+
+		// Record the chain of the snapshot the virtual machine is cloned from.
+		if s.Snapshot != "" {
+			snapshot, err := vmwcommon.LookupSnapshot(s.Path, s.Snapshot)
+			if err != nil {
+				log.Printf("[WARN] Unable to determine the snapshot chain: %s", err)
+			} else {
+				log.Printf("[INFO] Cloned from snapshot: %s", snapshot.Path())
+				state.Put("snapshot_chain", snapshot.ChainNames())
+			}
+		}
 	}
 
 	ui.Say("Successfully cloned the source virtual machine.")
@@ -184,12 +196,19 @@ func (s *StepCloneVMX) Run(ctx context.Context, state multistep.StateBag) multis
 		return halt(err)
 	}
 
+	// The boot disk is enumerated first, since it is the disk that is
+	// resized; the other disks follow in the order of their slots.
+	disks := devices.Decode(vmxData)
+	bootDisk, hasBootDisk := disks.BootDisk(vmxData["bios.hddorder"])
 	var diskFilenames []string
-	diskPathKeyRe := regexp.MustCompile(`(?i)^(scsi|sata|ide|nvme)[[:digit:]]:[[:digit:]]{1,2}\.fileName`)
-	for k, v := range vmxData {
-		match := diskPathKeyRe.FindString(k)
-		if match != "" && filepath.Ext(v) == ".vmdk" {
-			diskFilenames = append(diskFilenames, v)
+	for _, disk := range disks.Disks {
+		if filepath.Ext(disk.FileName) != ".vmdk" {
+			continue
+		}
+		if hasBootDisk && disk.Slot == bootDisk.Slot {
+			diskFilenames = append([]string{disk.FileName}, diskFilenames...)
+		} else {
+			diskFilenames = append(diskFilenames, disk.FileName)
 		}
 	}
 
@@ -198,7 +217,10 @@ func (s *StepCloneVMX) Run(ctx context.Context, state multistep.StateBag) multis
 	for _, diskFilename := range diskFilenames {
 		log.Printf("[INFO] Found attached disk with filename: %s", diskFilename)
 		// Disk paths are relative to the .vmx file location, not OutputDir.
-		diskFullPaths = append(diskFullPaths, filepath.Join(vmxDir, diskFilename))
+		if !filepath.IsAbs(diskFilename) {
+			diskFilename = filepath.Join(vmxDir, diskFilename)
+		}
+		diskFullPaths = append(diskFullPaths, diskFilename)
 	}
 
 	if len(diskFullPaths) == 0 {
@@ -206,8 +228,9 @@ func (s *StepCloneVMX) Run(ctx context.Context, state multistep.StateBag) multis
 	}
 
 	var networkType string
-	if _, ok := vmxData["ethernet0.connectiontype"]; ok {
-		networkType = vmxData["ethernet0.connectiontype"]
+	connectionTypeKey := fmt.Sprintf("ethernet%d.connectiontype", s.NetworkAdapter)
+	if _, ok := vmxData[connectionTypeKey]; ok {
+		networkType = vmxData[connectionTypeKey]
 		log.Printf("[INFO] Discovered the network type: %s", networkType)
 	}
 	if networkType == "" {
@@ -218,6 +241,7 @@ func (s *StepCloneVMX) Run(ctx context.Context, state multistep.StateBag) multis
 	state.Put("vmx_path", vmxPath)
 	state.Put("disk_full_paths", diskFullPaths)
 	state.Put("vmnetwork", networkType)
+	state.Put("vmnetwork_adapter", s.NetworkAdapter)
 
 	return multistep.ActionContinue
 }
@@ -36,16 +36,20 @@ func TestStepCloneVMX(t *testing.T) {
 	defer os.RemoveAll(td)
 
 	// Set up mock vmx file contents
-	var testCloneVMX = fmt.Sprintf("scsi0:0.filename = \"%s\"\n"+
+	var testCloneVMX = fmt.Sprintf("scsi0:0.present = \"TRUE\"\n"+
+		"scsi0:0.filename = \"%s\"\n"+
+		"sata0:0.present = \"TRUE\"\n"+
 		"sata0:0.filename = \"%s\"\n"+
+		"nvme0:0.present = \"TRUE\"\n"+
 		"nvme0:0.filename = \"%s\"\n"+
+		"ide1:0.present = \"TRUE\"\n"+
 		"ide1:0.filename = \"%s\"\n"+
 		"ide0:0.filename = \"auto detect\"\n"+
 		"ethernet0.connectiontype = \"nat\"\n", scsiFilename,
 		sataFilename, nvmeFilename, ideFilename)
 
-	// Set up expected mock disk file paths
-	diskFilenames := []string{scsiFilename, sataFilename, ideFilename, nvmeFilename}
+	// Set up expected mock disk file paths; the boot disk is first.
+	diskFilenames := []string{sataFilename, ideFilename, scsiFilename, nvmeFilename}
 	var diskFullPaths []string
 	for _, diskFilename := range diskFilenames {
 		diskFullPaths = append(diskFullPaths, filepath.Join(td, diskFilename))
@@ -94,8 +98,8 @@ func TestStepCloneVMX(t *testing.T) {
 	if stateDiskPaths, ok := state.GetOk("disk_full_paths"); !ok {
 		t.Fatal("should set disk_full_paths")
 	} else {
-		assert.ElementsMatchf(t, stateDiskPaths.([]string), diskFullPaths,
-			"%s\nshould contain the same elements as:\n%s", stateDiskPaths.([]string), diskFullPaths)
+		assert.Equal(t, diskFullPaths, stateDiskPaths.([]string),
+			"the boot disk should be enumerated first, then the disks in the order of their slots")
 	}
 
 	// Test we got the network type
@@ -105,3 +109,82 @@ func TestStepCloneVMX(t *testing.T) {
 		t.Fatalf("bad network type: %#v", networkType)
 	}
 }
+
+func TestStepCloneVMX_bootDisk(t *testing.T) {
+	td := t.TempDir()
+
+	testCloneVMX := fmt.Sprintf("bios.hddOrder = \"nvme0:0\"\n"+
+		"sata0:0.present = \"TRUE\"\n"+
+		"sata0:0.filename = \"%s\"\n"+
+		"scsi0:0.present = \"FALSE\"\n"+
+		"scsi0:0.filename = \"%s\"\n"+
+		"nvme0:0.present = \"TRUE\"\n"+
+		"nvme0:0.filename = \"%s\"\n", sataFilename, scsiFilename, nvmeFilename)
+
+	sourcePath := filepath.Join(td, "source.vmx")
+	if err := os.WriteFile(sourcePath, []byte(testCloneVMX), 0644); err != nil { //nolint:gosec
+		t.Fatalf("err: %s", err)
+	}
+	if err := os.WriteFile(filepath.Join(td, "foo.vmx"), []byte(testCloneVMX), 0644); err != nil { //nolint:gosec
+		t.Fatalf("err: %s", err)
+	}
+
+	state := testState(t)
+	step := &StepCloneVMX{
+		OutputDir: &td,
+		Path:      sourcePath,
+		VMName:    "foo",
+	}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	expected := []string{
+		filepath.Join(td, nvmeFilename),
+		filepath.Join(td, sataFilename),
+		filepath.Join(td, scsiFilename),
+	}
+	assert.Equal(t, expected, state.Get("disk_full_paths"),
+		"the first disk in bios.hddOrder should be enumerated first")
+}
+
+func TestStepCloneVMX_snapshotChain(t *testing.T) {
+	td := t.TempDir()
+
+	testCloneVMX := fmt.Sprintf("scsi0:0.filename = \"%s\"\n", scsiFilename)
+	testCloneVMSD := "snapshot0.uid = \"1\"\n" +
+		"snapshot0.displayName = \"base\"\n" +
+		"snapshot1.uid = \"2\"\n" +
+		"snapshot1.parent = \"1\"\n" +
+		"snapshot1.displayName = \"patched\"\n"
+
+	sourcePath := filepath.Join(td, "source.vmx")
+	if err := os.WriteFile(sourcePath, []byte(testCloneVMX), 0644); err != nil { //nolint:gosec
+		t.Fatalf("err: %s", err)
+	}
+	if err := os.WriteFile(filepath.Join(td, "source.vmsd"), []byte(testCloneVMSD), 0644); err != nil { //nolint:gosec
+		t.Fatalf("err: %s", err)
+	}
+
+	// Create the dest because the mock driver won't
+	if err := os.WriteFile(filepath.Join(td, "foo.vmx"), []byte(testCloneVMX), 0644); err != nil { //nolint:gosec
+		t.Fatalf("err: %s", err)
+	}
+
+	state := testState(t)
+	step := &StepCloneVMX{
+		OutputDir: &td,
+		Path:      sourcePath,
+		VMName:    "foo",
+		Snapshot:  "patched",
+	}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	driver := state.Get("driver").(*vmwcommon.DriverMock)
+	assert.Equal(t, "patched", driver.Snapshot)
+	assert.Equal(t, []string{"base", "patched"}, state.Get("snapshot_chain"))
+}
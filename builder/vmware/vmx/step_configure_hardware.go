@@ -0,0 +1,264 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+package vmx
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	vmwcommon "github.com/vmware/packer-plugin-vmware/builder/vmware/common"
+)
+
+// StepConfigureHardware applies the hardware configuration to the cloned
+// virtual machine. Only the options that are set are applied; all others
+// retain the values from the source virtual machine.
+type StepConfigureHardware struct{}
+
+// Run updates the .vmx file of the cloned virtual machine with the hardware configuration.
+func (s *StepConfigureHardware) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	config := state.Get("config").(*Config)
+	ui := state.Get("ui").(packersdk.Ui)
+	vmxPath := state.Get("vmx_path").(string)
+
+	halt := func(err error) multistep.StepAction {
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	vmx, err := vmwcommon.ReadVMXDocument(vmxPath)
+	if err != nil {
+		return halt(fmt.Errorf("error reading .vmx file for hardware configuration: %s", err))
+	}
+
+	changed, err := s.applyHardware(state, &config.HWConfig, vmx)
+	if err != nil {
+		return halt(fmt.Errorf("error applying hardware configuration: %s", err))
+	}
+
+	if !changed {
+		log.Printf("[INFO] No hardware configuration specified; retaining the source configuration.")
+		return multistep.ActionContinue
+	}
+
+	ui.Say("Configuring virtual machine hardware...")
+
+	if err := vmwcommon.WriteVMXDocument(vmxPath, vmx); err != nil {
+		return halt(fmt.Errorf("error writing .vmx file with hardware configuration: %s", err))
+	}
+
+	return multistep.ActionContinue
+}
+
+// applyHardware updates the .vmx document with the options set in the hardware
+// configuration and reports whether any option was applied.
+func (s *StepConfigureHardware) applyHardware(state multistep.StateBag, hw *vmwcommon.HWConfig, vmx *vmwcommon.VMXDocument) (bool, error) {
+	set := func(key, value string) {
+		log.Printf("[INFO] Setting VMX: '%s' = '%s'", key, value)
+		vmx.Set(key, value)
+	}
+	changed := false
+
+	switch hw.Firmware {
+	case vmwcommon.FirmwareTypeBios:
+		set("firmware", vmwcommon.FirmwareTypeBios)
+		vmx.Delete("uefi.secureBoot.enabled")
+		changed = true
+	case vmwcommon.FirmwareTypeUEFI:
+		set("firmware", vmwcommon.FirmwareTypeUEFI)
+		set("uefi.secureBoot.enabled", "FALSE")
+		changed = true
+	case vmwcommon.FirmwareTypeUEFISecure:
+		set("firmware", vmwcommon.FirmwareTypeUEFI)
+		set("uefi.secureBoot.enabled", "TRUE")
+		changed = true
+	}
+
+	if hw.CpuCount > 0 {
+		set("numvcpus", strconv.Itoa(hw.CpuCount))
+		changed = true
+	}
+
+	if hw.CoreCount > 0 {
+		set("cpuid.coresPerSocket", strconv.Itoa(hw.CoreCount))
+		changed = true
+	}
+
+	if hw.MemorySize > 0 {
+		set("memsize", strconv.Itoa(hw.MemorySize))
+		changed = true
+	}
+
+	if len(hw.NetworkAdapters) > 0 {
+		driver := state.Get("driver").(vmwcommon.Driver).GetVmwareDriver()
+		conns, err := vmwcommon.ApplyNetworkAdapters(driver, hw.NetworkAdapters, vmx)
+		if err != nil {
+			return false, err
+		}
+		state.Put("vmnetwork", conns[hw.CommunicatorNetworkAdapter].Network)
+		changed = true
+	} else {
+		if hw.Network != "" {
+			if err := s.applyNetwork(state, hw, vmx); err != nil {
+				return false, err
+			}
+			changed = true
+		}
+
+		if hw.NetworkAdapterType != "" {
+			set("ethernet0.virtualDev", strings.ToLower(hw.NetworkAdapterType))
+			changed = true
+		}
+	}
+
+	if hw.Sound {
+		set("sound.present", "TRUE")
+		set("sound.startConnected", "TRUE")
+		set("sound.fileName", "-1")
+		set("sound.autodetect", "TRUE")
+		changed = true
+	}
+
+	if hw.USB {
+		set("usb.present", "TRUE")
+		set("ehci.present", "TRUE")
+		if hw.USBVersion == vmwcommon.UsbVersion20 {
+			set("usb_xhci.present", "FALSE")
+		} else {
+			set("usb_xhci.present", "TRUE")
+		}
+		changed = true
+	}
+
+	if hw.HasSerial() {
+		if err := s.applySerial(hw, vmx); err != nil {
+			return false, err
+		}
+		changed = true
+	}
+
+	if hw.HasParallel() {
+		if err := s.applyParallel(hw, vmx); err != nil {
+			return false, err
+		}
+		changed = true
+	}
+
+	return changed, nil
+}
+
+// applyNetwork updates the .vmx document with the network for the first network
+// adapter. If the first network adapter is used for address discovery, the
+// network is stored so that the host and guest addresses are discovered on the
+// updated network.
+func (s *StepConfigureHardware) applyNetwork(state multistep.StateBag, hw *vmwcommon.HWConfig, vmx *vmwcommon.VMXDocument) error {
+	driver := state.Get("driver").(vmwcommon.Driver).GetVmwareDriver()
+
+	conn, err := vmwcommon.ResolveNetwork(driver, hw.Network)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[INFO] Setting VMX: 'ethernet0.connectionType' = '%s'", conn.Type)
+	vmx.Set("ethernet0.connectionType", conn.Type)
+	if conn.Device != "" {
+		log.Printf("[INFO] Setting VMX: 'ethernet0.vnet' = '%s'", conn.Device)
+		vmx.Set("ethernet0.vnet", conn.Device)
+	} else {
+		vmx.Delete("ethernet0.vnet")
+	}
+
+	if hw.CommunicatorNetworkAdapter == 0 {
+		state.Put("vmnetwork", conn.Network)
+	}
+	return nil
+}
+
+// applySerial updates the .vmx document with the serial port configuration.
+func (s *StepConfigureHardware) applySerial(hw *vmwcommon.HWConfig, vmx *vmwcommon.VMXDocument) error {
+	serial, err := hw.ReadSerial()
+	if err != nil {
+		return err
+	}
+
+	// Remove any existing serial port configuration from the source.
+	vmx.DeleteDevice("serial0")
+
+	switch serial.Union.(type) {
+	case *vmwcommon.SerialConfigPipe:
+		vmx.Set("serial0.fileType", "pipe")
+		vmx.Set("serial0.fileName", filepath.FromSlash(serial.Pipe.Filename))
+		vmx.Set("serial0.pipe.endPoint", serial.Pipe.Endpoint)
+		vmx.Set("serial0.tryNoRxLoss", serial.Pipe.Host)
+		vmx.Set("serial0.yieldOnMsrRead", serial.Pipe.Yield)
+		vmx.Set("serial0.autodetect", "FALSE")
+	case *vmwcommon.SerialConfigFile:
+		vmx.Set("serial0.fileType", "file")
+		vmx.Set("serial0.fileName", filepath.FromSlash(serial.File.Filename))
+		vmx.Set("serial0.yieldOnMsrRead", serial.File.Yield)
+		vmx.Set("serial0.autodetect", "FALSE")
+	case *vmwcommon.SerialConfigDevice:
+		vmx.Set("serial0.fileType", "device")
+		vmx.Set("serial0.fileName", filepath.FromSlash(serial.Device.Devicename))
+		vmx.Set("serial0.yieldOnMsrRead", serial.Device.Yield)
+		vmx.Set("serial0.autodetect", "FALSE")
+	case *vmwcommon.SerialConfigAuto:
+		vmx.Set("serial0.fileType", "device")
+		vmx.Set("serial0.fileName", filepath.FromSlash(serial.Auto.Devicename))
+		vmx.Set("serial0.yieldOnMsrRead", serial.Auto.Yield)
+		vmx.Set("serial0.autodetect", "TRUE")
+	case nil:
+		vmx.Set("serial0.present", "FALSE")
+		return nil
+	default:
+		return fmt.Errorf("unexpected serial port configuration: %v", serial)
+	}
+
+	vmx.Set("serial0.present", "TRUE")
+	vmx.Set("serial0.startConnected", "TRUE")
+	return nil
+}
+
+// applyParallel updates the .vmx document with the parallel port configuration.
+func (s *StepConfigureHardware) applyParallel(hw *vmwcommon.HWConfig, vmx *vmwcommon.VMXDocument) error {
+	parallel, err := hw.ReadParallel()
+	if err != nil {
+		return err
+	}
+
+	// Remove any existing parallel port configuration from the source.
+	vmx.DeleteDevice("parallel0")
+
+	switch parallel.Union.(type) {
+	case *vmwcommon.ParallelPortFile:
+		vmx.Set("parallel0.fileName", filepath.FromSlash(parallel.File.Filename))
+		vmx.Set("parallel0.autodetect", "FALSE")
+	case *vmwcommon.ParallelPortDevice:
+		vmx.Set("parallel0.fileName", filepath.FromSlash(parallel.Device.Devicename))
+		vmx.Set("parallel0.bidirectional", parallel.Device.Bidirectional)
+		vmx.Set("parallel0.autodetect", "FALSE")
+	case *vmwcommon.ParallelPortAuto:
+		vmx.Set("parallel0.bidirectional", parallel.Auto.Bidirectional)
+		vmx.Set("parallel0.autodetect", "TRUE")
+	case nil:
+		vmx.Set("parallel0.present", "FALSE")
+		return nil
+	default:
+		return fmt.Errorf("unexpected parallel port configuration: %v", parallel)
+	}
+
+	vmx.Set("parallel0.present", "TRUE")
+	vmx.Set("parallel0.startConnected", "TRUE")
+	return nil
+}
+
+// Cleanup performs any necessary cleanup after configuring the hardware.
+func (s *StepConfigureHardware) Cleanup(state multistep.StateBag) {}
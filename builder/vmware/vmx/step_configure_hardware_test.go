@@ -0,0 +1,160 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+package vmx
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/stretchr/testify/assert"
+	vmwcommon "github.com/vmware/packer-plugin-vmware/builder/vmware/common"
+)
+
+const testHardwareVMX = `numvcpus = "1"
+memsize = "1024"
+firmware = "bios"
+ethernet0.virtualdev = "e1000"
+ethernet0.connectiontype = "nat"
+serial0.present = "TRUE"
+serial0.filetype = "file"
+serial0.filename = "serial.txt"
+`
+
+func testHardwareState(t *testing.T, hw vmwcommon.HWConfig) (multistep.StateBag, string) {
+	vmxPath := filepath.Join(t.TempDir(), "clone.vmx")
+	if err := os.WriteFile(vmxPath, []byte(testHardwareVMX), 0644); err != nil { //nolint:gosec
+		t.Fatalf("err: %s", err)
+	}
+
+	state := testState(t)
+	state.Put("config", &Config{HWConfig: hw})
+	state.Put("vmx_path", vmxPath)
+	return state, vmxPath
+}
+
+func TestStepConfigureHardware_impl(t *testing.T) {
+	var _ multistep.Step = new(StepConfigureHardware)
+}
+
+func TestStepConfigureHardware(t *testing.T) {
+	state, vmxPath := testHardwareState(t, vmwcommon.HWConfig{
+		CpuCount:           4,
+		CoreCount:          2,
+		MemorySize:         4096,
+		NetworkAdapterType: "VMXNET3",
+		Firmware:           vmwcommon.FirmwareTypeUEFISecure,
+		USB:                true,
+		USBVersion:         vmwcommon.UsbVersion20,
+		Serial:             "NONE",
+	})
+
+	step := new(StepConfigureHardware)
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); ok {
+		t.Fatal("should NOT have error")
+	}
+
+	vmxData, err := vmwcommon.ReadVMX(vmxPath)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := map[string]string{
+		"numvcpus":                 "4",
+		"cpuid.corespersocket":     "2",
+		"memsize":                  "4096",
+		"firmware":                 "efi",
+		"uefi.secureboot.enabled":  "TRUE",
+		"ethernet0.virtualdev":     "vmxnet3",
+		"ethernet0.connectiontype": "nat",
+		"usb.present":              "TRUE",
+		"ehci.present":             "TRUE",
+		"usb_xhci.present":         "FALSE",
+		"serial0.present":          "FALSE",
+	}
+	for k, v := range expected {
+		assert.Equal(t, v, vmxData[k], "unexpected value for %s", k)
+	}
+
+	_, ok := vmxData["serial0.filename"]
+	assert.False(t, ok, "existing serial port configuration should be removed")
+}
+
+func TestStepConfigureHardware_network(t *testing.T) {
+	state, vmxPath := testHardwareState(t, vmwcommon.HWConfig{
+		Network: "vmnet2",
+	})
+
+	step := new(StepConfigureHardware)
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	vmxData, err := vmwcommon.ReadVMX(vmxPath)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The mock network mapper maps no devices, so a custom device is assumed.
+	assert.Equal(t, "custom", vmxData["ethernet0.connectiontype"])
+	assert.Equal(t, "vmnet2", vmxData["ethernet0.vnet"])
+	assert.Equal(t, "vmnet2", state.Get("vmnetwork"))
+}
+
+func TestStepConfigureHardware_unset(t *testing.T) {
+	state, vmxPath := testHardwareState(t, vmwcommon.HWConfig{})
+
+	step := new(StepConfigureHardware)
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	contents, err := os.ReadFile(vmxPath)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assert.Equal(t, testHardwareVMX, string(contents), "source configuration should be retained")
+}
+
+func TestStepConfigureHardware_networkAdapters(t *testing.T) {
+	state, vmxPath := testHardwareState(t, vmwcommon.HWConfig{
+		NetworkAdapters: []vmwcommon.NetworkAdapterConfig{
+			{Network: "nat", AdapterType: "vmxnet3"},
+			{Network: "vmnet2", AdapterType: "e1000e", MACAddress: "00:50:56:00:00:02"},
+		},
+		CommunicatorNetworkAdapter: 1,
+	})
+
+	step := new(StepConfigureHardware)
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	vmxData, err := vmwcommon.ReadVMX(vmxPath)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The mock network mapper maps no devices, so custom devices are assumed.
+	expected := map[string]string{
+		"ethernet0.connectiontype": "custom",
+		"ethernet0.vnet":           "nat",
+		"ethernet0.virtualdev":     "vmxnet3",
+		"ethernet1.connectiontype": "custom",
+		"ethernet1.vnet":           "vmnet2",
+		"ethernet1.virtualdev":     "e1000e",
+		"ethernet1.addresstype":    "static",
+		"ethernet1.address":        "00:50:56:00:00:02",
+	}
+	for k, v := range expected {
+		assert.Equal(t, v, vmxData[k], "unexpected value for %s", k)
+	}
+	assert.Equal(t, "vmnet2", state.Get("vmnetwork"))
+}
@@ -0,0 +1,60 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+package vmx
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	vmwcommon "github.com/vmware/packer-plugin-vmware/builder/vmware/common"
+)
+
+// StepPruneSnapshots deletes the snapshots of the output virtual machine so
+// that the artifact does not carry the snapshots of the source.
+type StepPruneSnapshots struct {
+	Enabled bool
+}
+
+// Run deletes all snapshots of the output virtual machine, if enabled.
+func (s *StepPruneSnapshots) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	if !s.Enabled {
+		return multistep.ActionContinue
+	}
+
+	driver := state.Get("driver").(vmwcommon.Driver)
+	ui := state.Get("ui").(packersdk.Ui)
+	vmxPath := state.Get("vmx_path").(string)
+
+	halt := func(err error) multistep.StepAction {
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	tree, err := vmwcommon.ReadSnapshotTree(vmxPath)
+	if err != nil {
+		return halt(fmt.Errorf("error reading snapshot database: %s", err))
+	}
+
+	if len(tree.Snapshots) == 0 {
+		log.Printf("[INFO] No snapshots to prune.")
+		return multistep.ActionContinue
+	}
+
+	// The snapshots are deleted by their paths, children first, since the
+	// names of snapshots are not unique.
+	ui.Say("Pruning snapshots of virtual machine...")
+	if err := vmwcommon.DeleteSnapshots(driver, vmxPath, tree.LeavesFirst()); err != nil {
+		return halt(err)
+	}
+
+	return multistep.ActionContinue
+}
+
+// Cleanup performs any necessary cleanup after the step completes.
+func (s *StepPruneSnapshots) Cleanup(multistep.StateBag) {}
@@ -0,0 +1,93 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+package vmx
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	vmwcommon "github.com/vmware/packer-plugin-vmware/builder/vmware/common"
+)
+
+func TestStepPruneSnapshots_impl(t *testing.T) {
+	var _ multistep.Step = new(StepPruneSnapshots)
+}
+
+func TestStepPruneSnapshots(t *testing.T) {
+	state := testState(t)
+	vmxPath := filepath.Join(t.TempDir(), "foo.vmx")
+	vmsd := `snapshot.lastUID = "4"
+snapshot0.uid = "1"
+snapshot0.displayName = "base"
+snapshot1.uid = "2"
+snapshot1.parent = "1"
+snapshot1.displayName = "patched"
+snapshot2.uid = "3"
+snapshot2.parent = "2"
+snapshot2.displayName = "base"
+snapshot3.uid = "4"
+snapshot3.parent = "1"
+snapshot3.displayName = "tested"
+`
+	if err := os.WriteFile(vmwcommon.VMSDPath(vmxPath), []byte(vmsd), 0644); err != nil { //nolint:gosec
+		t.Fatalf("err: %s", err)
+	}
+	state.Put("vmx_path", vmxPath)
+	step := &StepPruneSnapshots{Enabled: true}
+
+	driver := state.Get("driver").(*vmwcommon.DriverMock)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); ok {
+		t.Fatal("should not error")
+	}
+
+	if driver.DeleteSnapshotVMXPath != vmxPath {
+		t.Fatalf("bad path: %s", driver.DeleteSnapshotVMXPath)
+	}
+
+	// The snapshots are deleted by their paths, children first, since the
+	// name "base" is ambiguous.
+	expected := []string{"base/patched/base", "base/patched", "base/tested", "base"}
+	if !reflect.DeepEqual(driver.DeleteSnapshotNames, expected) {
+		t.Fatalf("bad snapshots deleted: %v", driver.DeleteSnapshotNames)
+	}
+}
+
+func TestStepPruneSnapshots_noSnapshots(t *testing.T) {
+	state := testState(t)
+	state.Put("vmx_path", filepath.Join(t.TempDir(), "foo.vmx"))
+	step := &StepPruneSnapshots{Enabled: true}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	driver := state.Get("driver").(*vmwcommon.DriverMock)
+	if driver.DeleteSnapshotCalled {
+		t.Fatal("should not delete snapshots")
+	}
+}
+
+func TestStepPruneSnapshots_disabled(t *testing.T) {
+	state := testState(t)
+	state.Put("vmx_path", "foo.vmx")
+	step := &StepPruneSnapshots{}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	driver := state.Get("driver").(*vmwcommon.DriverMock)
+	if driver.DeleteSnapshotCalled {
+		t.Fatal("should not delete snapshots")
+	}
+}
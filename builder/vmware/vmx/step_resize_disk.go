@@ -0,0 +1,72 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+package vmx
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	vmwcommon "github.com/vmware/packer-plugin-vmware/builder/vmware/common"
+	"github.com/vmware/packer-plugin-vmware/builder/vmware/common/vmdk"
+)
+
+// StepResizeDisk expands the boot disk of the cloned virtual machine to the
+// size in megabytes, if specified. The boot disk is the first disk of
+// disk_full_paths.
+type StepResizeDisk struct {
+	DiskSize uint
+}
+
+// Run expands the boot disk. The disk is not shrunk, and delta disks, such as
+// the disks of a linked clone, are not expanded.
+func (s *StepResizeDisk) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	if s.DiskSize == 0 {
+		return multistep.ActionContinue
+	}
+
+	driver := state.Get("driver").(vmwcommon.Driver)
+	ui := state.Get("ui").(packersdk.Ui)
+	diskFullPaths := state.Get("disk_full_paths").([]string)
+
+	halt := func(err error) multistep.StepAction {
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	path := diskFullPaths[0]
+	descriptor, err := vmdk.ReadDescriptor(path)
+	if err != nil {
+		return halt(fmt.Errorf("error resizing disk: %s", err))
+	}
+	if descriptor.HasParent() {
+		return halt(fmt.Errorf("error resizing disk: %s is a delta disk of %s; the disks of linked clones and snapshots can not be expanded",
+			path, descriptor.ParentFileNameHint))
+	}
+
+	size := int64(s.DiskSize) * 1024 * 1024
+	capacity := descriptor.Capacity()
+	switch {
+	case size < capacity:
+		return halt(fmt.Errorf("error resizing disk: refusing to shrink %s from %d MB to %d MB",
+			path, capacity/1024/1024, s.DiskSize))
+	case size == capacity:
+		log.Printf("[INFO] Disk %s is already %d MB.", path, s.DiskSize)
+		return multistep.ActionContinue
+	}
+
+	ui.Sayf("Expanding disk from %d MB to %d MB...", capacity/1024/1024, s.DiskSize)
+	if err := driver.ExpandDisk(path, fmt.Sprintf("%dM", s.DiskSize)); err != nil {
+		return halt(fmt.Errorf("error expanding disk: %s", err))
+	}
+
+	return multistep.ActionContinue
+}
+
+// Cleanup performs any necessary cleanup after the step completes.
+func (s *StepResizeDisk) Cleanup(multistep.StateBag) {}
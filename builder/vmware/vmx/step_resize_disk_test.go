@@ -0,0 +1,119 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+package vmx
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	vmwcommon "github.com/vmware/packer-plugin-vmware/builder/vmware/common"
+	"github.com/vmware/packer-plugin-vmware/builder/vmware/common/vmdk"
+)
+
+// testResizeDisk creates a 100 MB disk and returns the state of the step.
+func testResizeDisk(t *testing.T) (multistep.StateBag, string) {
+	path := filepath.Join(t.TempDir(), "disk.vmdk")
+	if err := vmdk.Create(path, 100*1024*1024, vmdk.DiskTypeMonolithicSparse, "lsilogic"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	state := testState(t)
+	state.Put("disk_full_paths", []string{path, "disk-1.vmdk"})
+	return state, path
+}
+
+func TestStepResizeDisk_impl(t *testing.T) {
+	var _ multistep.Step = new(StepResizeDisk)
+}
+
+func TestStepResizeDisk(t *testing.T) {
+	state, path := testResizeDisk(t)
+	step := &StepResizeDisk{DiskSize: 200}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); ok {
+		t.Fatal("should not error")
+	}
+
+	driver := state.Get("driver").(*vmwcommon.DriverMock)
+	if !driver.ExpandDiskCalled {
+		t.Fatal("should expand disk")
+	}
+	if driver.ExpandDiskPath != path {
+		t.Fatalf("bad path: %s", driver.ExpandDiskPath)
+	}
+	if driver.ExpandDiskSize != "200M" {
+		t.Fatalf("bad size: %s", driver.ExpandDiskSize)
+	}
+}
+
+func TestStepResizeDisk_sameSize(t *testing.T) {
+	state, _ := testResizeDisk(t)
+	step := &StepResizeDisk{DiskSize: 100}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if state.Get("driver").(*vmwcommon.DriverMock).ExpandDiskCalled {
+		t.Fatal("should not expand disk")
+	}
+}
+
+func TestStepResizeDisk_shrink(t *testing.T) {
+	state, _ := testResizeDisk(t)
+	step := &StepResizeDisk{DiskSize: 50}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("should error")
+	}
+	if state.Get("driver").(*vmwcommon.DriverMock).ExpandDiskCalled {
+		t.Fatal("should not expand disk")
+	}
+}
+
+func TestStepResizeDisk_deltaDisk(t *testing.T) {
+	state, path := testResizeDisk(t)
+	step := &StepResizeDisk{DiskSize: 200}
+
+	// A delta disk of the disk, as created for a linked clone.
+	d, err := vmdk.ReadDescriptor(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	child := filepath.Join(filepath.Dir(path), "child.vmdk")
+	d.ParentCID = d.CID
+	d.ParentFileNameHint = path
+	if err := os.WriteFile(child, []byte(d.String()), 0o644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	state.Put("disk_full_paths", []string{child})
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("should error")
+	}
+}
+
+func TestStepResizeDisk_disabled(t *testing.T) {
+	state := testState(t)
+	step := new(StepResizeDisk)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if state.Get("driver").(*vmwcommon.DriverMock).ExpandDiskCalled {
+		t.Fatal("should not expand disk")
+	}
+}
@@ -0,0 +1,44 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+package vmx
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	vmwcommon "github.com/vmware/packer-plugin-vmware/builder/vmware/common"
+)
+
+// StepRevertSourceSnapshot reverts the source virtual machine in place to a
+// named snapshot before it is cloned.
+type StepRevertSourceSnapshot struct {
+	Path     string
+	Snapshot string
+}
+
+// Run reverts the source virtual machine to the snapshot, if configured.
+func (s *StepRevertSourceSnapshot) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	if s.Snapshot == "" {
+		return multistep.ActionContinue
+	}
+
+	driver := state.Get("driver").(vmwcommon.Driver)
+	ui := state.Get("ui").(packersdk.Ui)
+
+	ui.Sayf("Reverting source virtual machine to snapshot: %s", s.Snapshot)
+	if err := driver.RevertToSnapshot(s.Path, s.Snapshot); err != nil {
+		err := fmt.Errorf("error reverting source virtual machine to snapshot: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	return multistep.ActionContinue
+}
+
+// Cleanup performs any necessary cleanup after the step completes.
+func (s *StepRevertSourceSnapshot) Cleanup(multistep.StateBag) {}
@@ -0,0 +1,73 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+package vmx
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	vmwcommon "github.com/vmware/packer-plugin-vmware/builder/vmware/common"
+)
+
+func TestStepRevertSourceSnapshot_impl(t *testing.T) {
+	var _ multistep.Step = new(StepRevertSourceSnapshot)
+}
+
+func TestStepRevertSourceSnapshot(t *testing.T) {
+	state := testState(t)
+	step := &StepRevertSourceSnapshot{
+		Path:     "source.vmx",
+		Snapshot: "base",
+	}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); ok {
+		t.Fatal("should not error")
+	}
+
+	driver := state.Get("driver").(*vmwcommon.DriverMock)
+	if !driver.RevertToSnapshotCalled {
+		t.Fatal("should call revert to snapshot")
+	}
+	if driver.RevertToSnapshotVMXPath != "source.vmx" || driver.RevertToSnapshotName != "base" {
+		t.Fatalf("bad revert: %s, %s", driver.RevertToSnapshotVMXPath, driver.RevertToSnapshotName)
+	}
+}
+
+func TestStepRevertSourceSnapshot_skip(t *testing.T) {
+	state := testState(t)
+	step := &StepRevertSourceSnapshot{Path: "source.vmx"}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	driver := state.Get("driver").(*vmwcommon.DriverMock)
+	if driver.RevertToSnapshotCalled {
+		t.Fatal("should not call revert to snapshot")
+	}
+}
+
+func TestStepRevertSourceSnapshot_error(t *testing.T) {
+	state := testState(t)
+	step := &StepRevertSourceSnapshot{
+		Path:     "source.vmx",
+		Snapshot: "base",
+	}
+
+	driver := state.Get("driver").(*vmwcommon.DriverMock)
+	driver.RevertToSnapshotErr = errors.New("error")
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("should error")
+	}
+}
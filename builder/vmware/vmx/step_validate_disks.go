@@ -0,0 +1,47 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+package vmx
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/vmware/packer-plugin-vmware/builder/vmware/common/vmdk"
+)
+
+// StepValidateDisks validates the disks referenced by the cloned virtual
+// machine, so that a missing or truncated disk fails the build before the
+// virtual machine is started.
+type StepValidateDisks struct{}
+
+// Run validates the descriptor and the extents of each disk and its parents.
+func (s *StepValidateDisks) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+	diskFullPaths := state.Get("disk_full_paths").([]string)
+
+	for _, path := range diskFullPaths {
+		info, err := vmdk.Stat(path)
+		if err != nil {
+			err = fmt.Errorf("error validating disk: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+
+		log.Printf("[INFO] Validated disk: %s (type: %s, capacity: %d bytes, extents: %d)",
+			path, info.CreateType(), info.Capacity(), len(info.Extents))
+		for parent := info.Parent; parent != nil; parent = parent.Parent {
+			log.Printf("[INFO] - parent: %s", parent.Path)
+		}
+	}
+
+	return multistep.ActionContinue
+}
+
+// Cleanup performs any necessary cleanup after the step completes.
+func (s *StepValidateDisks) Cleanup(multistep.StateBag) {}
@@ -0,0 +1,53 @@
+// © Broadcom. All Rights Reserved.
+// The term "Broadcom" refers to Broadcom Inc. and/or its subsidiaries.
+// SPDX-License-Identifier: MPL-2.0
+
+package vmx
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/vmware/packer-plugin-vmware/builder/vmware/common/vmdk"
+)
+
+func TestStepValidateDisks_impl(t *testing.T) {
+	var _ multistep.Step = new(StepValidateDisks)
+}
+
+func TestStepValidateDisks(t *testing.T) {
+	dir := t.TempDir()
+	var paths []string
+	for _, name := range []string{"disk.vmdk", "disk-1.vmdk"} {
+		path := filepath.Join(dir, name)
+		if err := vmdk.Create(path, 1024*1024, vmdk.DiskTypeSplitSparse, "lsilogic"); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		paths = append(paths, path)
+	}
+
+	state := testState(t)
+	state.Put("disk_full_paths", paths)
+	step := new(StepValidateDisks)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); ok {
+		t.Fatal("should not error")
+	}
+
+	// A missing extent fails the validation.
+	if err := os.Remove(filepath.Join(dir, "disk-1-s001.vmdk")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("should error")
+	}
+}